@@ -5,45 +5,128 @@ import {models} from '../models';
 import {services} from '../models';
 import {app} from '../models';
 import {proto} from '../models';
+import {logging} from '../models';
 
 export function CallGRPCMethod(arg1:string,arg2:string,arg3:string,arg4:string,arg5:string):Promise<string>;
 
+export function CaptureGRPCMethod(arg1:string,arg2:string,arg3:string,arg4:string,arg5:string):Promise<services.InvocationResult>;
+
+export function CheckHealth(arg1:string,arg2:string):Promise<services.HealthStatus>;
+
 export function ConnectServer(arg1:context.Context,arg2:string):Promise<void>;
 
 export function ConnectToServer(arg1:string):Promise<void>;
 
+export function ConvertMessage(arg1:string,arg2:string,arg3:string,arg4:string,arg5:string,arg6:boolean):Promise<string>;
+
+export function CreateCollection(arg1:string,arg2:string):Promise<models.Collection>;
+
+export function CreateEnvironment(arg1:string):Promise<models.Environment>;
+
 export function CreateProtoPath(arg1:string,arg2:string,arg3:string):Promise<void>;
 
+export function CreateSavedRequest(arg1:models.SavedRequest):Promise<models.SavedRequest>;
+
 export function CreateServerProfile(arg1:string,arg2:string,arg3:number,arg4:boolean,arg5:any,arg6:boolean,arg7:Array<models.Header>):Promise<models.ServerProfile>;
 
+export function CreateWorkspace(arg1:string,arg2:string):Promise<models.Workspace>;
+
+export function DecodeProtobuf(arg1:string,arg2:string,arg3:string,arg4:string):Promise<string>;
+
+export function DecodeProtobufRaw(arg1:string,arg2:string):Promise<Array<services.WireField>>;
+
+export function DeleteCollection(arg1:string):Promise<void>;
+
+export function DeleteEnvironment(arg1:string):Promise<void>;
+
 export function DeleteProtoDefinition(arg1:string):Promise<void>;
 
 export function DeleteProtoPath(arg1:string):Promise<void>;
 
+export function DeleteSavedRequest(arg1:string):Promise<void>;
+
 export function DeleteServerProfile(arg1:string):Promise<void>;
 
 export function DisconnectFromServer(arg1:string):Promise<void>;
 
+export function EncodeProtobuf(arg1:string,arg2:string,arg3:string,arg4:string):Promise<string>;
+
+export function EncodeProtobufToFile(arg1:string,arg2:string,arg3:string,arg4:string):Promise<void>;
+
+export function ExportRunReportJUnit(arg1:services.RunReport):Promise<string>;
+
+export function ExportWorkspace(arg1:Array<string>,arg2:boolean):Promise<string>;
+
+export function GetActiveEnvironment():Promise<models.Environment>;
+
+export function GetConnectionDetails(arg1:string):Promise<services.ConnectionDetails>;
+
+export function GetConnectionState(arg1:string):Promise<string>;
+
+export function GetCurrentWorkspace():Promise<models.Workspace>;
+
+export function GetHealthDashboard():Promise<Array<services.HealthStatus>>;
+
+export function GetHealthPollInterval():Promise<number>;
+
+export function GetLogDirectory():Promise<string>;
+
+export function GetLogLevel():Promise<string>;
+
+export function GetLogs():Promise<Array<logging.Entry>>;
+
 export function GetMethodInputDescriptor(arg1:string,arg2:string,arg3:string):Promise<Array<services.FieldDescriptor>>;
 
 export function GetPerRequestHeaders(arg1:string,arg2:string,arg3:string):Promise<string>;
 
+export function GetPerRequestSecretHeaders(arg1:string,arg2:string,arg3:string):Promise<Array<string>>;
+
+export function GetReconnectPolicy():Promise<services.ReconnectPolicy>;
+
+export function GetRemoteChannelz(arg1:string):Promise<services.RemoteChannelz>;
+
+export function GetSecretsStatus():Promise<services.SecretsStatus>;
+
 export function GetServerProfile(arg1:string):Promise<models.ServerProfile>;
 
 export function Greet(arg1:string):Promise<string>;
 
 export function ImportProtoFilesFromFolder():Promise<Array<app.ProtoFileImport>>;
 
+export function ImportWorkspace(arg1:string,arg2:boolean,arg3:boolean):Promise<services.ImportReport>;
+
+export function InvokeGRPCMethod(arg1:string,arg2:string,arg3:string,arg4:string,arg5:string):Promise<services.InvocationResult>;
+
+export function InvokeGRPCMethodFormatted(arg1:string,arg2:string,arg3:string,arg4:string,arg5:string,arg6:string,arg7:string):Promise<services.InvocationResult>;
+
+export function InvokeGRPCMethodWithOptions(arg1:string,arg2:string,arg3:string,arg4:string,arg5:string,arg6:models.JSONOptions):Promise<services.InvocationResult>;
+
 export function IsServerConnected(arg1:string):Promise<boolean>;
 
+export function ListCollections():Promise<Array<models.Collection>>;
+
+export function ListEnvironments():Promise<Array<models.Environment>>;
+
 export function ListProtoDefinitionsByProfile(arg1:string):Promise<Array<proto.ProtoDefinition>>;
 
 export function ListProtoPathsByServer(arg1:string):Promise<Array<proto.ProtoPath>>;
 
+export function ListSavedRequests(arg1:string):Promise<Array<models.SavedRequest>>;
+
 export function ListServerProfiles():Promise<Array<models.ServerProfile>>;
 
 export function ListServerServices(arg1:string):Promise<Record<string, Array<string>>>;
 
+export function ListWorkspaces():Promise<Array<models.Workspace>>;
+
+export function LockSecrets():Promise<void>;
+
+export function RemoveWorkspace(arg1:string):Promise<void>;
+
+export function RunCollection(arg1:string,arg2:boolean):Promise<services.RunReport>;
+
+export function RunSavedRequest(arg1:string):Promise<services.RequestRunResult>;
+
 export function SavePerRequestHeaders(arg1:string,arg2:string,arg3:string,arg4:string):Promise<void>;
 
 export function SaveProtoDefinition(arg1:proto.ProtoDefinition):Promise<void>;
@@ -52,8 +135,38 @@ export function ScanAndParseProtoPath(arg1:string,arg2:string,arg3:string):Promi
 
 export function SelectProtoFolder():Promise<string>;
 
+export function SelectWorkspaceDatabase(arg1:boolean):Promise<string>;
+
+export function SelectWorkspaceFile():Promise<string>;
+
+export function SetActiveEnvironment(arg1:string):Promise<void>;
+
+export function SetHealthPollInterval(arg1:number):Promise<void>;
+
+export function SetLogLevel(arg1:string):Promise<void>;
+
+export function SetPerRequestSecretHeaders(arg1:string,arg2:string,arg3:string,arg4:Array<string>):Promise<void>;
+
+export function SetReconnectPolicy(arg1:services.ReconnectPolicy):Promise<void>;
+
 export function Shutdown(arg1:context.Context):Promise<void>;
 
 export function Startup(arg1:context.Context):Promise<void>;
 
+export function StopHealthWatch(arg1:string,arg2:string):Promise<void>;
+
+export function SwitchWorkspace(arg1:string):Promise<void>;
+
+export function UnlockSecrets(arg1:string):Promise<void>;
+
+export function UnlockSecretsWithKeyFile(arg1:string):Promise<void>;
+
+export function UpdateCollection(arg1:models.Collection):Promise<void>;
+
+export function UpdateEnvironment(arg1:models.Environment):Promise<void>;
+
+export function UpdateSavedRequest(arg1:models.SavedRequest):Promise<void>;
+
 export function UpdateServerProfile(arg1:models.ServerProfile):Promise<void>;
+
+export function WatchHealth(arg1:string,arg2:string):Promise<void>;
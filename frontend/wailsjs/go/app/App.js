@@ -6,6 +6,14 @@ export function CallGRPCMethod(arg1, arg2, arg3, arg4, arg5) {
   return window['go']['app']['App']['CallGRPCMethod'](arg1, arg2, arg3, arg4, arg5);
 }
 
+export function CaptureGRPCMethod(arg1, arg2, arg3, arg4, arg5) {
+  return window['go']['app']['App']['CaptureGRPCMethod'](arg1, arg2, arg3, arg4, arg5);
+}
+
+export function CheckHealth(arg1, arg2) {
+  return window['go']['app']['App']['CheckHealth'](arg1, arg2);
+}
+
 export function ConnectServer(arg1, arg2) {
   return window['go']['app']['App']['ConnectServer'](arg1, arg2);
 }
@@ -14,14 +22,50 @@ export function ConnectToServer(arg1) {
   return window['go']['app']['App']['ConnectToServer'](arg1);
 }
 
+export function ConvertMessage(arg1, arg2, arg3, arg4, arg5, arg6) {
+  return window['go']['app']['App']['ConvertMessage'](arg1, arg2, arg3, arg4, arg5, arg6);
+}
+
+export function CreateCollection(arg1, arg2) {
+  return window['go']['app']['App']['CreateCollection'](arg1, arg2);
+}
+
+export function CreateEnvironment(arg1) {
+  return window['go']['app']['App']['CreateEnvironment'](arg1);
+}
+
 export function CreateProtoPath(arg1, arg2, arg3) {
   return window['go']['app']['App']['CreateProtoPath'](arg1, arg2, arg3);
 }
 
+export function CreateSavedRequest(arg1) {
+  return window['go']['app']['App']['CreateSavedRequest'](arg1);
+}
+
 export function CreateServerProfile(arg1, arg2, arg3, arg4, arg5, arg6, arg7) {
   return window['go']['app']['App']['CreateServerProfile'](arg1, arg2, arg3, arg4, arg5, arg6, arg7);
 }
 
+export function CreateWorkspace(arg1, arg2) {
+  return window['go']['app']['App']['CreateWorkspace'](arg1, arg2);
+}
+
+export function DecodeProtobuf(arg1, arg2, arg3, arg4) {
+  return window['go']['app']['App']['DecodeProtobuf'](arg1, arg2, arg3, arg4);
+}
+
+export function DecodeProtobufRaw(arg1, arg2) {
+  return window['go']['app']['App']['DecodeProtobufRaw'](arg1, arg2);
+}
+
+export function DeleteCollection(arg1) {
+  return window['go']['app']['App']['DeleteCollection'](arg1);
+}
+
+export function DeleteEnvironment(arg1) {
+  return window['go']['app']['App']['DeleteEnvironment'](arg1);
+}
+
 export function DeleteProtoDefinition(arg1) {
   return window['go']['app']['App']['DeleteProtoDefinition'](arg1);
 }
@@ -30,6 +74,10 @@ export function DeleteProtoPath(arg1) {
   return window['go']['app']['App']['DeleteProtoPath'](arg1);
 }
 
+export function DeleteSavedRequest(arg1) {
+  return window['go']['app']['App']['DeleteSavedRequest'](arg1);
+}
+
 export function DeleteServerProfile(arg1) {
   return window['go']['app']['App']['DeleteServerProfile'](arg1);
 }
@@ -38,6 +86,58 @@ export function DisconnectFromServer(arg1) {
   return window['go']['app']['App']['DisconnectFromServer'](arg1);
 }
 
+export function EncodeProtobuf(arg1, arg2, arg3, arg4) {
+  return window['go']['app']['App']['EncodeProtobuf'](arg1, arg2, arg3, arg4);
+}
+
+export function EncodeProtobufToFile(arg1, arg2, arg3, arg4) {
+  return window['go']['app']['App']['EncodeProtobufToFile'](arg1, arg2, arg3, arg4);
+}
+
+export function ExportRunReportJUnit(arg1) {
+  return window['go']['app']['App']['ExportRunReportJUnit'](arg1);
+}
+
+export function ExportWorkspace(arg1, arg2) {
+  return window['go']['app']['App']['ExportWorkspace'](arg1, arg2);
+}
+
+export function GetActiveEnvironment() {
+  return window['go']['app']['App']['GetActiveEnvironment']();
+}
+
+export function GetConnectionDetails(arg1) {
+  return window['go']['app']['App']['GetConnectionDetails'](arg1);
+}
+
+export function GetConnectionState(arg1) {
+  return window['go']['app']['App']['GetConnectionState'](arg1);
+}
+
+export function GetCurrentWorkspace() {
+  return window['go']['app']['App']['GetCurrentWorkspace']();
+}
+
+export function GetHealthDashboard() {
+  return window['go']['app']['App']['GetHealthDashboard']();
+}
+
+export function GetHealthPollInterval() {
+  return window['go']['app']['App']['GetHealthPollInterval']();
+}
+
+export function GetLogDirectory() {
+  return window['go']['app']['App']['GetLogDirectory']();
+}
+
+export function GetLogLevel() {
+  return window['go']['app']['App']['GetLogLevel']();
+}
+
+export function GetLogs() {
+  return window['go']['app']['App']['GetLogs']();
+}
+
 export function GetMethodInputDescriptor(arg1, arg2, arg3) {
   return window['go']['app']['App']['GetMethodInputDescriptor'](arg1, arg2, arg3);
 }
@@ -46,6 +146,22 @@ export function GetPerRequestHeaders(arg1, arg2, arg3) {
   return window['go']['app']['App']['GetPerRequestHeaders'](arg1, arg2, arg3);
 }
 
+export function GetPerRequestSecretHeaders(arg1, arg2, arg3) {
+  return window['go']['app']['App']['GetPerRequestSecretHeaders'](arg1, arg2, arg3);
+}
+
+export function GetReconnectPolicy() {
+  return window['go']['app']['App']['GetReconnectPolicy']();
+}
+
+export function GetRemoteChannelz(arg1) {
+  return window['go']['app']['App']['GetRemoteChannelz'](arg1);
+}
+
+export function GetSecretsStatus() {
+  return window['go']['app']['App']['GetSecretsStatus']();
+}
+
 export function GetServerProfile(arg1) {
   return window['go']['app']['App']['GetServerProfile'](arg1);
 }
@@ -58,10 +174,34 @@ export function ImportProtoFilesFromFolder() {
   return window['go']['app']['App']['ImportProtoFilesFromFolder']();
 }
 
+export function ImportWorkspace(arg1, arg2, arg3) {
+  return window['go']['app']['App']['ImportWorkspace'](arg1, arg2, arg3);
+}
+
+export function InvokeGRPCMethod(arg1, arg2, arg3, arg4, arg5) {
+  return window['go']['app']['App']['InvokeGRPCMethod'](arg1, arg2, arg3, arg4, arg5);
+}
+
+export function InvokeGRPCMethodFormatted(arg1, arg2, arg3, arg4, arg5, arg6, arg7) {
+  return window['go']['app']['App']['InvokeGRPCMethodFormatted'](arg1, arg2, arg3, arg4, arg5, arg6, arg7);
+}
+
+export function InvokeGRPCMethodWithOptions(arg1, arg2, arg3, arg4, arg5, arg6) {
+  return window['go']['app']['App']['InvokeGRPCMethodWithOptions'](arg1, arg2, arg3, arg4, arg5, arg6);
+}
+
 export function IsServerConnected(arg1) {
   return window['go']['app']['App']['IsServerConnected'](arg1);
 }
 
+export function ListCollections() {
+  return window['go']['app']['App']['ListCollections']();
+}
+
+export function ListEnvironments() {
+  return window['go']['app']['App']['ListEnvironments']();
+}
+
 export function ListProtoDefinitionsByProfile(arg1) {
   return window['go']['app']['App']['ListProtoDefinitionsByProfile'](arg1);
 }
@@ -70,6 +210,10 @@ export function ListProtoPathsByServer(arg1) {
   return window['go']['app']['App']['ListProtoPathsByServer'](arg1);
 }
 
+export function ListSavedRequests(arg1) {
+  return window['go']['app']['App']['ListSavedRequests'](arg1);
+}
+
 export function ListServerProfiles() {
   return window['go']['app']['App']['ListServerProfiles']();
 }
@@ -78,6 +222,26 @@ export function ListServerServices(arg1) {
   return window['go']['app']['App']['ListServerServices'](arg1);
 }
 
+export function ListWorkspaces() {
+  return window['go']['app']['App']['ListWorkspaces']();
+}
+
+export function LockSecrets() {
+  return window['go']['app']['App']['LockSecrets']();
+}
+
+export function RemoveWorkspace(arg1) {
+  return window['go']['app']['App']['RemoveWorkspace'](arg1);
+}
+
+export function RunCollection(arg1, arg2) {
+  return window['go']['app']['App']['RunCollection'](arg1, arg2);
+}
+
+export function RunSavedRequest(arg1) {
+  return window['go']['app']['App']['RunSavedRequest'](arg1);
+}
+
 export function SavePerRequestHeaders(arg1, arg2, arg3, arg4) {
   return window['go']['app']['App']['SavePerRequestHeaders'](arg1, arg2, arg3, arg4);
 }
@@ -94,6 +258,34 @@ export function SelectProtoFolder() {
   return window['go']['app']['App']['SelectProtoFolder']();
 }
 
+export function SelectWorkspaceDatabase(arg1) {
+  return window['go']['app']['App']['SelectWorkspaceDatabase'](arg1);
+}
+
+export function SelectWorkspaceFile() {
+  return window['go']['app']['App']['SelectWorkspaceFile']();
+}
+
+export function SetActiveEnvironment(arg1) {
+  return window['go']['app']['App']['SetActiveEnvironment'](arg1);
+}
+
+export function SetHealthPollInterval(arg1) {
+  return window['go']['app']['App']['SetHealthPollInterval'](arg1);
+}
+
+export function SetLogLevel(arg1) {
+  return window['go']['app']['App']['SetLogLevel'](arg1);
+}
+
+export function SetPerRequestSecretHeaders(arg1, arg2, arg3, arg4) {
+  return window['go']['app']['App']['SetPerRequestSecretHeaders'](arg1, arg2, arg3, arg4);
+}
+
+export function SetReconnectPolicy(arg1) {
+  return window['go']['app']['App']['SetReconnectPolicy'](arg1);
+}
+
 export function Shutdown(arg1) {
   return window['go']['app']['App']['Shutdown'](arg1);
 }
@@ -102,6 +294,38 @@ export function Startup(arg1) {
   return window['go']['app']['App']['Startup'](arg1);
 }
 
+export function StopHealthWatch(arg1, arg2) {
+  return window['go']['app']['App']['StopHealthWatch'](arg1, arg2);
+}
+
+export function SwitchWorkspace(arg1) {
+  return window['go']['app']['App']['SwitchWorkspace'](arg1);
+}
+
+export function UnlockSecrets(arg1) {
+  return window['go']['app']['App']['UnlockSecrets'](arg1);
+}
+
+export function UnlockSecretsWithKeyFile(arg1) {
+  return window['go']['app']['App']['UnlockSecretsWithKeyFile'](arg1);
+}
+
+export function UpdateCollection(arg1) {
+  return window['go']['app']['App']['UpdateCollection'](arg1);
+}
+
+export function UpdateEnvironment(arg1) {
+  return window['go']['app']['App']['UpdateEnvironment'](arg1);
+}
+
+export function UpdateSavedRequest(arg1) {
+  return window['go']['app']['App']['UpdateSavedRequest'](arg1);
+}
+
 export function UpdateServerProfile(arg1) {
   return window['go']['app']['App']['UpdateServerProfile'](arg1);
 }
+
+export function WatchHealth(arg1, arg2) {
+  return window['go']['app']['App']['WatchHealth'](arg1, arg2);
+}
@@ -4,77 +4,214 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
-	"encoding/json"
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
+	"protodesk/pkg/logging"
 	"protodesk/pkg/models"
 	"protodesk/pkg/models/proto"
 	"protodesk/pkg/services"
 
-	"github.com/jhump/protoreflect/dynamic"
-	"github.com/jhump/protoreflect/grpcreflect"
 	"github.com/wailsapp/wails/v2/pkg/runtime"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/metadata"
-	reflectpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/connectivity"
 )
 
+// connectionStateEvent is emitted with a services.ConnectionStateEvent on
+// every connectivity transition of a server profile
+const connectionStateEvent = "connection:state"
+
+// healthStatusEvent is emitted with a services.HealthStatus for every health
+// check, watch update and poll result
+const healthStatusEvent = "health:status"
+
 // App struct represents the main application
 type App struct {
-	ctx            context.Context
+	ctx context.Context
+	// logger is shared with the services; logLevel adjusts it at runtime
+	// and logs keeps recent entries for the log viewer
+	logger    *slog.Logger
+	logLevel  *slog.LevelVar
+	logs      *logging.Buffer
+	logCloser io.Closer
+	// logDir holds the rotating log files once Startup opened them
+	logDir string
+
+	// mu guards the open workspace below. SwitchWorkspace replaces it while
+	// bindings, collection runs and listeners use it, so they read it
+	// through session.
+	mu             sync.RWMutex
+	workspace      models.Workspace
 	profileManager *services.ServerProfileManager
 	protoParser    *services.ProtoParser
+	// activeEnvironmentID selects the environment whose variables are
+	// expanded into requests; empty means no environment
+	activeEnvironmentID string
+	// calls counts the calls using the open workspace; its database is
+	// closed once they are done
+	calls *sync.WaitGroup
+
+	// workspaces lists the named workspaces
+	workspaces *services.WorkspaceRegistry
+	// workspaceRef is the workspace requested on the command line
+	workspaceRef string
+}
+
+// workspaceSession is the open workspace as seen by one call
+type workspaceSession struct {
+	workspace           models.Workspace
+	profileManager      *services.ServerProfileManager
+	protoParser         *services.ProtoParser
+	activeEnvironmentID string
+}
+
+// session returns the open workspace for a call. Its database stays open
+// until the returned function is called, which the call must do once it no
+// longer uses the workspace.
+func (a *App) session() (workspaceSession, func()) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	s := workspaceSession{
+		workspace:           a.workspace,
+		profileManager:      a.profileManager,
+		protoParser:         a.protoParser,
+		activeEnvironmentID: a.activeEnvironmentID,
+	}
+	if a.calls == nil {
+		return s, func() {}
+	}
+	a.calls.Add(1)
+	return s, a.calls.Done
 }
 
 // NewApp creates a new App application struct
 func NewApp() *App {
-	return &App{}
+	return NewAppForWorkspace("")
+}
+
+// NewAppForWorkspace creates an App that opens the given workspace name or
+// database path at startup instead of the active one
+func NewAppForWorkspace(workspace string) *App {
+	a := &App{workspaceRef: workspace}
+	a.initLogging()
+	return a
 }
 
 // Startup is called when the app starts. The context is saved
 // so we can call the runtime methods
 func (a *App) Startup(ctx context.Context) error {
-	fmt.Println("[Startup] Startup called")
 	a.ctx = ctx
 
-	homeDir, err := os.UserHomeDir()
+	dataDir, err := defaultDataDir()
 	if err != nil {
-		fmt.Println("[Startup] Failed to get user home directory:", err)
-		return fmt.Errorf("failed to get user home directory: %w", err)
+		a.logger.Error("Failed to prepare data directory", "error", err)
+		return err
 	}
-	fmt.Println("[Startup] Home directory:", homeDir)
+	a.startLogging(dataDir)
+	a.logger.Info("Starting", "dataDir", dataDir, "logDir", a.logDir)
 
-	dataDir := filepath.Join(homeDir, ".protodesk")
-	if err := os.MkdirAll(dataDir, 0755); err != nil {
-		fmt.Println("[Startup] Failed to create data directory:", err)
-		return fmt.Errorf("failed to create data directory: %w", err)
+	a.workspaces, err = services.LoadWorkspaceRegistry(dataDir)
+	if err != nil {
+		a.logger.Error("Failed to load workspaces", "error", err)
+		return err
 	}
-	fmt.Println("[Startup] Data directory:", dataDir)
-
-	store, err := services.NewSQLiteStore(dataDir)
+	ref := a.workspaceRef
+	if ref == "" {
+		ref = os.Getenv(workspaceEnv)
+	}
+	workspace, err := a.workspaces.Resolve(ref)
 	if err != nil {
-		fmt.Println("[Startup] Failed to initialize server profile store:", err)
+		a.logger.Error("Failed to resolve workspace", "error", err)
+		return err
+	}
+
+	if err := a.openWorkspace(workspace); err != nil {
+		a.logger.Error("Failed to initialize server profile store", "error", err)
 		return fmt.Errorf("failed to initialize server profile store: %w", err)
 	}
-	fmt.Println("[Startup] Server profile store initialized")
+	a.logger.Info("Workspace opened", "workspace", workspace.Name, "path", workspace.Path)
+	return nil
+}
 
-	a.profileManager = services.NewServerProfileManager(store)
-	fmt.Println("[Startup] profileManager initialized successfully")
+// openWorkspace opens the database of a workspace and makes it the one the
+// app works on. Connections of the previous workspace are closed and its
+// database released once the calls still using it are done; the active
+// environment is cleared as it belonged there.
+func (a *App) openWorkspace(workspace models.Workspace) error {
+	store, err := services.OpenSQLiteStore(workspace.Path)
+	if err != nil {
+		return err
+	}
+
+	manager := services.NewServerProfileManager(store, a.logger)
+	manager.SetStateListener(func(event services.ConnectionStateEvent) {
+		if a.ctx != nil {
+			runtime.EventsEmit(a.ctx, connectionStateEvent, event)
+		}
+	})
+	manager.SetHealthListener(func(result services.HealthStatus) {
+		if a.ctx != nil {
+			runtime.EventsEmit(a.ctx, healthStatusEvent, result)
+		}
+	})
+	if interval, err := manager.GetHealthPollInterval(a.ctx); err != nil {
+		a.logger.Warn("Failed to read the health poll interval", "error", err)
+	} else {
+		manager.StartHealthPolling(interval)
+	}
 
-	a.protoParser = services.NewProtoParser(store)
-	fmt.Println("[Startup] protoParser initialized successfully")
+	// A key file unlocks secret storage without prompting for a passphrase
+	if keyFile := os.Getenv(keyFileEnv); keyFile != "" {
+		if err := store.UnlockSecretsWithKeyFile(a.ctx, keyFile); err != nil {
+			a.logger.Warn("Failed to unlock secrets with key file", "error", err)
+		}
+	}
+
+	a.mu.Lock()
+	previous, previousCalls, previousName := a.profileManager, a.calls, a.workspace.Name
+	a.workspace = workspace
+	a.profileManager = manager
+	a.protoParser = services.NewProtoParser(store, a.logger)
+	a.activeEnvironmentID = ""
+	a.calls = &sync.WaitGroup{}
+	a.mu.Unlock()
 
+	if previous != nil {
+		// Calls still running on the previous workspace fail fast once its
+		// connections are gone
+		previous.DisconnectAll()
+		previousCalls.Wait()
+		if err := previous.GetStore().Close(); err != nil {
+			a.logger.Warn("Failed to close workspace", "workspace", previousName, "error", err)
+		}
+	}
 	return nil
 }
 
+// defaultDataDir returns ~/.protodesk, creating it if needed
+func defaultDataDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user home directory: %w", err)
+	}
+	dataDir := filepath.Join(homeDir, ".protodesk")
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create data directory: %w", err)
+	}
+	return dataDir, nil
+}
+
 // CreateServerProfile creates a new server profile
 func (a *App) CreateServerProfile(name string, host string, port int, enableTLS bool, certPath *string, useReflection bool, headers []models.Header) (*models.ServerProfile, error) {
-	if a.profileManager == nil {
+	s, done := a.session()
+	defer done()
+	if s.profileManager == nil {
 		return nil, fmt.Errorf("profileManager is not initialized (did Startup run successfully?)")
 	}
 	profile := models.NewServerProfile(name, host, port)
@@ -87,7 +224,7 @@ func (a *App) CreateServerProfile(name string, host string, port int, enableTLS
 		return nil, err
 	}
 
-	if err := a.profileManager.GetStore().Create(a.ctx, profile); err != nil {
+	if err := s.profileManager.GetStore().Create(a.ctx, profile); err != nil {
 		return nil, fmt.Errorf("failed to create server profile: %w", err)
 	}
 
@@ -96,51 +233,159 @@ func (a *App) CreateServerProfile(name string, host string, port int, enableTLS
 
 // GetServerProfile retrieves a server profile by ID
 func (a *App) GetServerProfile(id string) (*models.ServerProfile, error) {
-	return a.profileManager.GetStore().Get(a.ctx, id)
+	s, done := a.session()
+	defer done()
+	return s.profileManager.GetStore().Get(a.ctx, id)
 }
 
 // ListServerProfiles returns all server profiles
 func (a *App) ListServerProfiles() ([]*models.ServerProfile, error) {
-	return a.profileManager.GetStore().List(a.ctx)
+	s, done := a.session()
+	defer done()
+	return s.profileManager.GetStore().List(a.ctx)
 }
 
 // UpdateServerProfile updates an existing server profile
 func (a *App) UpdateServerProfile(profile *models.ServerProfile) error {
+	s, done := a.session()
+	defer done()
 	if err := profile.Validate(); err != nil {
 		return err
 	}
-	return a.profileManager.GetStore().Update(a.ctx, profile)
+	return s.profileManager.GetStore().Update(a.ctx, profile)
 }
 
 // DeleteServerProfile deletes a server profile by ID
 func (a *App) DeleteServerProfile(id string) error {
-	// Disconnect if connected
-	if a.profileManager.IsConnected(id) {
-		if err := a.profileManager.Disconnect(a.ctx, id); err != nil {
+	s, done := a.session()
+	defer done()
+	// Disconnect if connected, or still connecting
+	if s.profileManager.ConnectionState(id) != connectivity.Shutdown {
+		if err := s.profileManager.Disconnect(a.ctx, id); err != nil {
 			return fmt.Errorf("failed to disconnect before deletion: %w", err)
 		}
 	}
-	return a.profileManager.GetStore().Delete(a.ctx, id)
+	return s.profileManager.GetStore().Delete(a.ctx, id)
 }
 
-// ConnectToServer establishes a connection to a server profile
+// ConnectToServer starts connecting to a server profile without waiting for
+// the server; progress is reported through connection:state events
 func (a *App) ConnectToServer(id string) error {
-	return a.profileManager.Connect(a.ctx, id)
+	s, done := a.session()
+	defer done()
+	return s.profileManager.Connect(a.ctx, id)
 }
 
 // DisconnectFromServer closes the connection to a server profile
 func (a *App) DisconnectFromServer(id string) error {
-	return a.profileManager.Disconnect(a.ctx, id)
+	s, done := a.session()
+	defer done()
+	return s.profileManager.Disconnect(a.ctx, id)
 }
 
-// IsServerConnected checks if a server profile is currently connected
+// IsServerConnected checks if a server profile is currently connected. A
+// dropped connection that is still reconnecting is not connected.
 func (a *App) IsServerConnected(id string) bool {
-	return a.profileManager.IsConnected(id)
+	s, done := a.session()
+	defer done()
+	return s.profileManager.IsConnected(id)
+}
+
+// GetConnectionState returns the connectivity state of a server profile:
+// IDLE, CONNECTING, READY, TRANSIENT_FAILURE or SHUTDOWN
+func (a *App) GetConnectionState(id string) string {
+	s, done := a.session()
+	defer done()
+	return s.profileManager.ConnectionState(id).String()
+}
+
+// GetReconnectPolicy returns the backoff used to reconnect dropped connections
+func (a *App) GetReconnectPolicy() (services.ReconnectPolicy, error) {
+	s, done := a.session()
+	defer done()
+	return s.profileManager.GetReconnectPolicy(a.ctx)
+}
+
+// SetReconnectPolicy saves the backoff used by connections made from now on
+func (a *App) SetReconnectPolicy(policy services.ReconnectPolicy) error {
+	s, done := a.session()
+	defer done()
+	return s.profileManager.SetReconnectPolicy(a.ctx, policy)
+}
+
+// CheckHealth checks the health of a service of a connected server profile
+// with grpc.health.v1; an empty service checks the server as a whole
+func (a *App) CheckHealth(profileID, service string) (services.HealthStatus, error) {
+	s, done := a.session()
+	defer done()
+	return s.profileManager.CheckHealth(a.ctx, profileID, service)
+}
+
+// WatchHealth subscribes to the health of a service; updates are emitted as
+// health:status events
+func (a *App) WatchHealth(profileID, service string) error {
+	s, done := a.session()
+	defer done()
+	return s.profileManager.WatchHealth(profileID, service)
+}
+
+// StopHealthWatch ends a subscription started with WatchHealth
+func (a *App) StopHealthWatch(profileID, service string) {
+	s, done := a.session()
+	defer done()
+	s.profileManager.StopHealthWatch(profileID, service)
+}
+
+// GetHealthDashboard returns the latest health result of every checked
+// profile and service
+func (a *App) GetHealthDashboard() []services.HealthStatus {
+	s, done := a.session()
+	defer done()
+	return s.profileManager.HealthDashboard()
+}
+
+// GetHealthPollInterval returns how often connected profiles are polled for
+// their health, in seconds; zero means polling is off
+func (a *App) GetHealthPollInterval() (int, error) {
+	s, done := a.session()
+	defer done()
+	interval, err := s.profileManager.GetHealthPollInterval(a.ctx)
+	return int(interval / time.Second), err
+}
+
+// SetHealthPollInterval sets how often connected profiles are polled for
+// their health, in seconds; zero turns polling off
+func (a *App) SetHealthPollInterval(seconds int) error {
+	s, done := a.session()
+	defer done()
+	return s.profileManager.SetHealthPollInterval(a.ctx, time.Duration(seconds)*time.Second)
+}
+
+// GetConnectionDetails returns the client-side channelz data of a connected
+// server profile: subchannels, resolved addresses, call counts and the last
+// failure
+func (a *App) GetConnectionDetails(profileID string) (*services.ConnectionDetails, error) {
+	s, done := a.session()
+	defer done()
+	return s.profileManager.ConnectionDetails(a.ctx, profileID)
+}
+
+// GetRemoteChannelz queries the channelz service of a connected server
+// profile, for servers that expose grpc.channelz.v1
+func (a *App) GetRemoteChannelz(profileID string) (*services.RemoteChannelz, error) {
+	s, done := a.session()
+	defer done()
+	return s.profileManager.RemoteChannelz(a.ctx, profileID)
 }
 
 // Shutdown handles cleanup when the application exits
 func (a *App) Shutdown(ctx context.Context) {
-	a.profileManager.DisconnectAll()
+	s, done := a.session()
+	done()
+	if s.profileManager != nil {
+		s.profileManager.DisconnectAll()
+	}
+	a.closeLogging()
 }
 
 // Greet returns a greeting for the given name
@@ -150,20 +395,26 @@ func (a *App) Greet(name string) string {
 
 // SaveProtoDefinition saves a parsed proto definition to storage
 func (a *App) SaveProtoDefinition(def *proto.ProtoDefinition) error {
-	return a.profileManager.GetStore().CreateProtoDefinition(a.ctx, def)
+	s, done := a.session()
+	defer done()
+	return s.profileManager.GetStore().CreateProtoDefinition(a.ctx, def)
 }
 
 // ListProtoDefinitionsByProfile lists proto definitions for a server profile
 func (a *App) ListProtoDefinitionsByProfile(profileID string) ([]*proto.ProtoDefinition, error) {
+	s, done := a.session()
+	defer done()
 	if a.ctx == nil {
 		return nil, fmt.Errorf("context not initialized")
 	}
-	return a.profileManager.ListProtoDefinitionsByProfile(a.ctx, profileID)
+	return s.profileManager.ListProtoDefinitionsByProfile(a.ctx, profileID)
 }
 
 // DeleteProtoDefinition deletes a proto definition by ID
 func (a *App) DeleteProtoDefinition(id string) error {
-	return a.profileManager.GetStore().DeleteProtoDefinition(a.ctx, id)
+	s, done := a.session()
+	defer done()
+	return s.profileManager.GetStore().DeleteProtoDefinition(a.ctx, id)
 }
 
 // ProtoFileImport represents a proto file to be imported
@@ -194,7 +445,7 @@ func (a *App) ImportProtoFilesFromFolder() ([]ProtoFileImport, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to get absolute path: %w", err)
 	}
-	fmt.Printf("[DEBUG] Selected folder: %s\n", absPath)
+	a.logger.Debug("Importing proto files", "folder", absPath)
 
 	var results []ProtoFileImport
 	err = filepath.Walk(absPath, func(path string, info os.FileInfo, err error) error {
@@ -203,11 +454,9 @@ func (a *App) ImportProtoFilesFromFolder() ([]ProtoFileImport, error) {
 		}
 		// Skip node_modules directory
 		if info.IsDir() && info.Name() == "node_modules" {
-			fmt.Printf("[DEBUG] Skipping node_modules directory: %s\n", path)
 			return filepath.SkipDir
 		}
 		if !info.IsDir() && filepath.Ext(path) == ".proto" {
-			fmt.Printf("[DEBUG] Found proto file: %s\n", path)
 			content, readErr := os.ReadFile(path)
 			if readErr != nil {
 				return readErr
@@ -224,7 +473,7 @@ func (a *App) ImportProtoFilesFromFolder() ([]ProtoFileImport, error) {
 	if err != nil {
 		return nil, err
 	}
-	fmt.Printf("[DEBUG] Total proto files found: %d\n", len(results))
+	a.logger.Debug("Found proto files", "folder", absPath, "files", len(results))
 	return results, nil
 }
 
@@ -244,17 +493,18 @@ func (a *App) SelectProtoFolder() (string, error) {
 
 // ScanAndParseProtoPath scans a proto path, parses all .proto files, and stores results in the DB
 func (a *App) ScanAndParseProtoPath(serverProfileId string, protoPathId string, path string) error {
-	fmt.Printf("[DEBUG] Scanning proto path: %s\n", path)
-	return a.protoParser.ScanAndParseProtoPath(a.ctx, serverProfileId, protoPathId, path)
+	s, done := a.session()
+	defer done()
+	return s.protoParser.ScanAndParseProtoPath(a.ctx, serverProfileId, protoPathId, path)
 }
 
 // CreateProtoPath creates a proto path record in the database and links it to a server profile
 func (a *App) CreateProtoPath(id, serverProfileId, path string) error {
-	if a.profileManager == nil {
+	s, done := a.session()
+	defer done()
+	if s.profileManager == nil {
 		return fmt.Errorf("profile manager not initialized; startup may not have run successfully")
 	}
-	fmt.Printf("[DEBUG] Creating proto path with ID: %s, ServerProfileID: %s, Path: %s\n", id, serverProfileId, path)
-
 	// Calculate hash of all proto files in the directory
 	hash, err := calculateProtoPathHash(path)
 	if err != nil {
@@ -269,17 +519,16 @@ func (a *App) CreateProtoPath(id, serverProfileId, path string) error {
 		LastScanned:     time.Now(),
 	}
 
-	err = a.profileManager.GetStore().CreateProtoPath(context.Background(), protoPath)
+	err = s.profileManager.GetStore().CreateProtoPath(context.Background(), protoPath)
 	if err != nil {
-		fmt.Printf("[ERROR] Failed to create proto path: %v\n", err)
+		a.logger.Error("Failed to create proto path", "path", path, "error", err)
 		return err
 	}
-	fmt.Printf("[DEBUG] Successfully created proto path\n")
 
 	// Parse proto files
-	err = a.protoParser.ScanAndParseProtoPath(context.Background(), serverProfileId, id, path)
+	err = s.protoParser.ScanAndParseProtoPath(context.Background(), serverProfileId, id, path)
 	if err != nil {
-		fmt.Printf("[ERROR] Failed to parse proto files: %v\n", err)
+		a.logger.Error("Failed to parse proto files", "path", path, "error", err)
 		return err
 	}
 
@@ -320,63 +569,108 @@ func calculateProtoPathHash(path string) (string, error) {
 
 // ListProtoPathsByServer lists proto paths for a given server profile
 func (a *App) ListProtoPathsByServer(serverID string) ([]*proto.ProtoPath, error) {
-	if a.profileManager == nil {
+	s, done := a.session()
+	defer done()
+	if s.profileManager == nil {
 		return nil, fmt.Errorf("profile manager not initialized; startup may not have run successfully")
 	}
-	return a.profileManager.GetStore().ListProtoPathsByServer(context.Background(), serverID)
+	return s.profileManager.GetStore().ListProtoPathsByServer(context.Background(), serverID)
 }
 
 // DeleteProtoPath deletes a proto path by its ID
 func (a *App) DeleteProtoPath(id string) error {
-	if a.profileManager == nil {
+	s, done := a.session()
+	defer done()
+	if s.profileManager == nil {
 		return fmt.Errorf("profile manager not initialized; startup may not have run successfully")
 	}
-	return a.profileManager.GetStore().DeleteProtoPath(context.Background(), id)
+	return s.profileManager.GetStore().DeleteProtoPath(context.Background(), id)
 }
 
 // ConnectServer establishes a connection to the specified server profile
 func (a *App) ConnectServer(ctx context.Context, profileID string) error {
-	return a.profileManager.Connect(ctx, profileID)
+	s, done := a.session()
+	defer done()
+	return s.profileManager.Connect(ctx, profileID)
 }
 
 // ListServerServices returns all services and their methods for a connected server using reflection
 func (a *App) ListServerServices(profileID string) (map[string][]string, error) {
-	if a.profileManager == nil {
+	s, done := a.session()
+	defer done()
+	if s.profileManager == nil {
 		return nil, fmt.Errorf("profileManager is not initialized")
 	}
-	conn, err := a.profileManager.GetConnection(profileID)
+	conn, err := s.profileManager.GetConnection(profileID)
 	if err != nil {
 		return nil, fmt.Errorf("no active connection for profile %s: %w", profileID, err)
 	}
-	return a.profileManager.GetGRPCClient().ListServicesAndMethods(conn)
+	return s.profileManager.GetGRPCClient().ListServicesAndMethods(conn)
 }
 
 // GetMethodInputDescriptor returns the input fields for a given service/method using reflection
 func (a *App) GetMethodInputDescriptor(profileID, serviceName, methodName string) ([]services.FieldDescriptor, error) {
-	if a.profileManager == nil {
+	s, done := a.session()
+	defer done()
+	if s.profileManager == nil {
 		return nil, fmt.Errorf("profileManager is not initialized")
 	}
-	conn, err := a.profileManager.GetConnection(profileID)
+	conn, err := s.profileManager.GetConnection(profileID)
 	if err != nil {
 		return nil, fmt.Errorf("no active connection for profile %s: %w", profileID, err)
 	}
-	return a.profileManager.GetGRPCClient().GetMethodInputDescriptor(conn, serviceName, methodName)
+	return s.profileManager.GetGRPCClient().GetMethodInputDescriptor(conn, serviceName, methodName)
 }
 
 // SavePerRequestHeaders saves or updates per-request headers for a method
 func (a *App) SavePerRequestHeaders(serverProfileID, serviceName, methodName, headersJSON string) error {
+	s, done := a.session()
+	defer done()
+	// Keep the secret flags set with SetPerRequestSecretHeaders
+	var secretKeys []string
+	if existing, err := s.profileManager.GetStore().GetPerRequestHeaders(a.ctx, serverProfileID, serviceName, methodName); err == nil {
+		secretKeys = existing.SecretKeys
+	}
 	h := &models.PerRequestHeaders{
 		ServerProfileID: serverProfileID,
 		ServiceName:     serviceName,
 		MethodName:      methodName,
 		HeadersJSON:     headersJSON,
+		SecretKeys:      secretKeys,
+	}
+	return s.profileManager.GetStore().UpsertPerRequestHeaders(a.ctx, h)
+}
+
+// SetPerRequestSecretHeaders marks which per-request headers of a method are
+// secret; their values are encrypted at rest
+func (a *App) SetPerRequestSecretHeaders(serverProfileID, serviceName, methodName string, secretKeys []string) error {
+	s, done := a.session()
+	defer done()
+	h, err := s.profileManager.GetStore().GetPerRequestHeaders(a.ctx, serverProfileID, serviceName, methodName)
+	if err != nil {
+		return fmt.Errorf("failed to get per-request headers: %w", err)
+	}
+	h.SecretKeys = secretKeys
+	return s.profileManager.GetStore().UpsertPerRequestHeaders(a.ctx, h)
+}
+
+// GetPerRequestSecretHeaders returns the per-request headers of a method that
+// are marked as secret
+func (a *App) GetPerRequestSecretHeaders(serverProfileID, serviceName, methodName string) ([]string, error) {
+	s, done := a.session()
+	defer done()
+	h, err := s.profileManager.GetStore().GetPerRequestHeaders(a.ctx, serverProfileID, serviceName, methodName)
+	if err != nil {
+		return nil, err
 	}
-	return a.profileManager.GetStore().UpsertPerRequestHeaders(a.ctx, h)
+	return h.SecretKeys, nil
 }
 
 // GetPerRequestHeaders retrieves per-request headers for a method
 func (a *App) GetPerRequestHeaders(serverProfileID, serviceName, methodName string) (string, error) {
-	h, err := a.profileManager.GetStore().GetPerRequestHeaders(a.ctx, serverProfileID, serviceName, methodName)
+	s, done := a.session()
+	defer done()
+	h, err := s.profileManager.GetStore().GetPerRequestHeaders(a.ctx, serverProfileID, serviceName, methodName)
 	if err != nil {
 		return "", err
 	}
@@ -391,196 +685,125 @@ func (a *App) CallGRPCMethod(
 	requestJSON string,
 	headersJSON string,
 ) (string, error) {
-	// 1. Get connection
-	conn, err := a.profileManager.GetConnection(profileID)
+	result, err := a.InvokeGRPCMethod(profileID, serviceName, methodName, requestJSON, headersJSON)
 	if err != nil {
-		return "", fmt.Errorf("no active connection for profile %s: %w", profileID, err)
+		return "", err
+	}
+	if err := result.Err(); err != nil {
+		return "", fmt.Errorf("gRPC call failed: %w", err)
 	}
+	return result.ResponseJSON, nil
+}
+
+// InvokeGRPCMethod calls a gRPC method and returns the full result, including
+// the status, response headers and trailers, and latency
+func (a *App) InvokeGRPCMethod(
+	profileID string,
+	serviceName string,
+	methodName string,
+	requestJSON string,
+	headersJSON string,
+) (*services.InvocationResult, error) {
+	return a.invoke(profileID, headersJSON, services.InvocationRequest{
+		ServiceName: serviceName,
+		MethodName:  methodName,
+		RequestJSON: requestJSON,
+	})
+}
 
-	// 2. Set up reflection client
-	ctx := context.Background()
-	rc := grpcreflect.NewClient(ctx, reflectpb.NewServerReflectionClient(conn))
-	defer rc.Reset()
+// InvokeGRPCMethodFormatted calls a gRPC method like InvokeGRPCMethod with
+// the request written in requestFormat, json, prototext or yaml, and the
+// response also rendered in responseFormat
+func (a *App) InvokeGRPCMethodFormatted(
+	profileID string,
+	serviceName string,
+	methodName string,
+	request string,
+	headersJSON string,
+	requestFormat string,
+	responseFormat string,
+) (*services.InvocationResult, error) {
+	return a.invoke(profileID, headersJSON, services.InvocationRequest{
+		ServiceName:    serviceName,
+		MethodName:     methodName,
+		RequestJSON:    request,
+		RequestFormat:  models.MessageFormat(requestFormat),
+		ResponseFormat: models.MessageFormat(responseFormat),
+	})
+}
+
+// InvokeGRPCMethodWithOptions calls a gRPC method like InvokeGRPCMethod,
+// reading the request and writing the response with JSON options that
+// replace those of the profile for this call
+func (a *App) InvokeGRPCMethodWithOptions(
+	profileID string,
+	serviceName string,
+	methodName string,
+	requestJSON string,
+	headersJSON string,
+	options models.JSONOptions,
+) (*services.InvocationResult, error) {
+	return a.invoke(profileID, headersJSON, services.InvocationRequest{
+		ServiceName: serviceName,
+		MethodName:  methodName,
+		RequestJSON: requestJSON,
+		JSONOptions: &options,
+	})
+}
 
-	svcDesc, err := rc.ResolveService(serviceName)
+// ConvertMessage converts a message of a type resolved from the proto
+// definitions or reflection of a profile between json, prototext and yaml.
+// With multiple the text holds a list of messages, as for streaming calls.
+func (a *App) ConvertMessage(profileID, messageName, text, fromFormat, toFormat string, multiple bool) (string, error) {
+	s, done := a.session()
+	defer done()
+	return s.profileManager.ConvertMessage(context.Background(), profileID, messageName, text,
+		models.MessageFormat(fromFormat), models.MessageFormat(toFormat), multiple)
+}
+
+// CaptureGRPCMethod calls a gRPC method like InvokeGRPCMethod and also
+// records the headers and message frames it sent and received, with hex,
+// wire format and JSON views of each message
+func (a *App) CaptureGRPCMethod(
+	profileID string,
+	serviceName string,
+	methodName string,
+	requestJSON string,
+	headersJSON string,
+) (*services.InvocationResult, error) {
+	return a.invoke(profileID, headersJSON, services.InvocationRequest{
+		ServiceName: serviceName,
+		MethodName:  methodName,
+		RequestJSON: requestJSON,
+		Capture:     true,
+	})
+}
+
+// invoke expands the variables of the request body and headers of a call
+// and makes it
+func (a *App) invoke(profileID, headersJSON string, req services.InvocationRequest) (*services.InvocationResult, error) {
+	s, done := a.session()
+	defer done()
+	if s.profileManager == nil {
+		return nil, fmt.Errorf("profileManager is not initialized")
+	}
+	vars, err := a.activeVariables(s)
 	if err != nil {
-		return "", fmt.Errorf("service not found: %w", err)
+		return nil, err
 	}
-	mDesc := svcDesc.FindMethodByName(methodName)
-	if mDesc == nil {
-		return "", fmt.Errorf("method not found: %s", methodName)
+	req.RequestJSON, err = services.ExpandVariables(req.RequestJSON, vars)
+	if err != nil {
+		return nil, fmt.Errorf("request body: %w", err)
 	}
-
-	// 3. Set up headers
-	md := metadata.New(nil)
-	if headersJSON != "" {
-		var headers map[string]string
-		if err := json.Unmarshal([]byte(headersJSON), &headers); err == nil {
-			for k, v := range headers {
-				md.Append(k, v)
-			}
-		}
+	headersJSON, err = services.ExpandVariables(headersJSON, vars)
+	if err != nil {
+		return nil, fmt.Errorf("headers: %w", err)
 	}
-	ctx = metadata.NewOutgoingContext(ctx, md)
-
-	// 4. Handle method type
-	if mDesc.IsClientStreaming() && !mDesc.IsServerStreaming() {
-		// Client streaming (single response)
-		var arr []json.RawMessage
-		if err := json.Unmarshal([]byte(requestJSON), &arr); err != nil {
-			return "", fmt.Errorf("expected JSON array for client streaming: %w", err)
-		}
-		inputType := mDesc.GetInputType()
-		streamDesc := &grpc.StreamDesc{
-			ClientStreams: true,
-			ServerStreams: false,
-		}
-		methodFullName := fmt.Sprintf("/%s/%s", serviceName, methodName)
-		stream, err := conn.NewStream(ctx, streamDesc, methodFullName)
-		if err != nil {
-			return "", fmt.Errorf("failed to open client stream: %w", err)
-		}
-		s := grpc.ClientStream(stream)
-		for _, msgBytes := range arr {
-			msg := dynamic.NewMessage(inputType)
-			if err := msg.UnmarshalJSON(msgBytes); err != nil {
-				return "", fmt.Errorf("failed to unmarshal stream message: %w", err)
-			}
-			if err := s.SendMsg(msg); err != nil {
-				return "", fmt.Errorf("failed to send stream message: %w", err)
-			}
-		}
-		if err := s.CloseSend(); err != nil {
-			return "", fmt.Errorf("failed to close stream: %w", err)
-		}
-		outType := mDesc.GetOutputType()
-		respMsg := dynamic.NewMessage(outType)
-		if err := s.RecvMsg(respMsg); err != nil {
-			return "", fmt.Errorf("failed to receive response: %w", err)
-		}
-		respJSON, err := respMsg.MarshalJSON()
-		if err != nil {
-			return "", fmt.Errorf("failed to marshal response: %w", err)
-		}
-		return string(respJSON), nil
-	} else if !mDesc.IsClientStreaming() && mDesc.IsServerStreaming() {
-		// Server streaming (single request, multiple responses)
-		inputType := mDesc.GetInputType()
-		reqMsg := dynamic.NewMessage(inputType)
-		if err := reqMsg.UnmarshalJSON([]byte(requestJSON)); err != nil {
-			return "", fmt.Errorf("failed to unmarshal request: %w", err)
-		}
-		streamDesc := &grpc.StreamDesc{
-			ClientStreams: false,
-			ServerStreams: true,
-		}
-		methodFullName := fmt.Sprintf("/%s/%s", serviceName, methodName)
-		stream, err := conn.NewStream(ctx, streamDesc, methodFullName)
-		if err != nil {
-			return "", fmt.Errorf("failed to open server stream: %w", err)
-		}
-		s := grpc.ClientStream(stream)
-		if err := s.SendMsg(reqMsg); err != nil {
-			return "", fmt.Errorf("failed to send request: %w", err)
-		}
-		if err := s.CloseSend(); err != nil {
-			return "", fmt.Errorf("failed to close send: %w", err)
-		}
-		outType := mDesc.GetOutputType()
-		var responses []json.RawMessage
-		for {
-			respMsg := dynamic.NewMessage(outType)
-			err := s.RecvMsg(respMsg)
-			if err != nil {
-				if err.Error() == "EOF" {
-					break
-				}
-				return "", fmt.Errorf("failed to receive response: %w", err)
-			}
-			respJSON, err := respMsg.MarshalJSON()
-			if err != nil {
-				return "", fmt.Errorf("failed to marshal response: %w", err)
-			}
-			responses = append(responses, respJSON)
-		}
-		finalJSON, err := json.Marshal(responses)
-		if err != nil {
-			return "", fmt.Errorf("failed to marshal responses array: %w", err)
-		}
-		return string(finalJSON), nil
-	} else if mDesc.IsClientStreaming() && mDesc.IsServerStreaming() {
-		// Bidirectional streaming (multiple requests, multiple responses)
-		var arr []json.RawMessage
-		if err := json.Unmarshal([]byte(requestJSON), &arr); err != nil {
-			return "", fmt.Errorf("expected JSON array for bidi streaming: %w", err)
-		}
-		inputType := mDesc.GetInputType()
-		streamDesc := &grpc.StreamDesc{
-			ClientStreams: true,
-			ServerStreams: true,
-		}
-		methodFullName := fmt.Sprintf("/%s/%s", serviceName, methodName)
-		stream, err := conn.NewStream(ctx, streamDesc, methodFullName)
-		if err != nil {
-			return "", fmt.Errorf("failed to open bidi stream: %w", err)
-		}
-		s := grpc.ClientStream(stream)
-		// Send all requests
-		for _, msgBytes := range arr {
-			msg := dynamic.NewMessage(inputType)
-			if err := msg.UnmarshalJSON(msgBytes); err != nil {
-				return "", fmt.Errorf("failed to unmarshal stream message: %w", err)
-			}
-			if err := s.SendMsg(msg); err != nil {
-				return "", fmt.Errorf("failed to send stream message: %w", err)
-			}
-		}
-		if err := s.CloseSend(); err != nil {
-			return "", fmt.Errorf("failed to close send: %w", err)
-		}
-		outType := mDesc.GetOutputType()
-		var responses []json.RawMessage
-		for {
-			respMsg := dynamic.NewMessage(outType)
-			err := s.RecvMsg(respMsg)
-			if err != nil {
-				if err.Error() == "EOF" {
-					break
-				}
-				return "", fmt.Errorf("failed to receive response: %w", err)
-			}
-			respJSON, err := respMsg.MarshalJSON()
-			if err != nil {
-				return "", fmt.Errorf("failed to marshal response: %w", err)
-			}
-			responses = append(responses, respJSON)
-		}
-		finalJSON, err := json.Marshal(responses)
-		if err != nil {
-			return "", fmt.Errorf("failed to marshal responses array: %w", err)
-		}
-		return string(finalJSON), nil
-	} else if !mDesc.IsClientStreaming() && !mDesc.IsServerStreaming() {
-		// Unary
-		inputType := mDesc.GetInputType()
-		reqMsg := dynamic.NewMessage(inputType)
-		if err := reqMsg.UnmarshalJSON([]byte(requestJSON)); err != nil {
-			return "", fmt.Errorf("failed to unmarshal request: %w", err)
-		}
-		outType := mDesc.GetOutputType()
-		respMsg := dynamic.NewMessage(outType)
-		// Use the full service name from the service descriptor
-		methodFullName := fmt.Sprintf("/%s/%s", svcDesc.GetFullyQualifiedName(), methodName)
-		err = conn.Invoke(ctx, methodFullName, reqMsg, respMsg)
-		if err != nil {
-			return "", fmt.Errorf("gRPC call failed: %w", err)
-		}
-		respJSON, err := respMsg.MarshalJSON()
-		if err != nil {
-			return "", fmt.Errorf("failed to marshal response: %w", err)
-		}
-		return string(respJSON), nil
-	} else {
-		return "", fmt.Errorf("unknown gRPC method type")
+	// Malformed headers are ignored rather than failing the call
+	req.Metadata, _ = services.MetadataFromJSON(headersJSON)
+	result, err := s.profileManager.Invoke(context.Background(), profileID, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to invoke %s/%s: %w", req.ServiceName, req.MethodName, err)
 	}
+	return result, nil
 }
@@ -12,6 +12,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
 )
 
 func TestNewApp(t *testing.T) {
@@ -52,7 +53,7 @@ func TestApp_ServerProfileOperations(t *testing.T) {
 	require.NoError(t, app.Startup(ctx))
 
 	// Test CreateServerProfile
-	profile, err := app.CreateServerProfile("test-server", "localhost", 50051, false, nil)
+	profile, err := app.CreateServerProfile("test-server", "localhost", 50051, false, nil, true, nil)
 	require.NoError(t, err)
 	assert.NotNil(t, profile)
 	assert.NotEmpty(t, profile.ID)
@@ -104,7 +105,7 @@ func TestApp_ServerConnections(t *testing.T) {
 	require.NoError(t, app.Startup(ctx))
 
 	// Create a test profile
-	profile, err := app.CreateServerProfile("test-server", "localhost", 50051, false, nil)
+	profile, err := app.CreateServerProfile("test-server", "localhost", 50051, false, nil, true, nil)
 	require.NoError(t, err)
 
 	// Test connection operations
@@ -131,7 +132,7 @@ func TestApp_Shutdown(t *testing.T) {
 	require.NoError(t, app.Startup(ctx))
 
 	// Create and connect to a test profile
-	profile, err := app.CreateServerProfile("test-server", "localhost", 50051, false, nil)
+	profile, err := app.CreateServerProfile("test-server", "localhost", 50051, false, nil, true, nil)
 	require.NoError(t, err)
 	require.NoError(t, app.ConnectToServer(profile.ID))
 
@@ -157,7 +158,7 @@ func TestApp_UpdateServerProfileErrors(t *testing.T) {
 	assert.Error(t, err)
 
 	// Test updating with invalid profile
-	profile, err := app.CreateServerProfile("test-server", "localhost", 50051, false, nil)
+	profile, err := app.CreateServerProfile("test-server", "localhost", 50051, false, nil, true, nil)
 	require.NoError(t, err)
 
 	invalidProfile := *profile
@@ -182,19 +183,21 @@ func TestApp_DeleteServerProfileErrors(t *testing.T) {
 	assert.Error(t, err)
 
 	// Test deleting connected profile with disconnect error
-	profile, err := app.CreateServerProfile("test-server", "localhost", 50051, false, nil)
+	profile, err := app.CreateServerProfile("test-server", "localhost", 50051, false, nil, true, nil)
 	require.NoError(t, err)
 
 	// Create a mock gRPC client that will fail to disconnect
+	conn := &grpc.ClientConn{}
 	mockClient := &services.MockGRPCClientManager{
-		ConnectFunc: func(ctx context.Context, target string, useTLS bool, certPath string) error {
+		ConnectFunc: func(ctx context.Context, id string, target string, opts services.ConnectOptions) error {
+			opts.OnStateChange(conn, connectivity.Ready)
 			return nil
 		},
-		DisconnectFunc: func(target string) error {
+		DisconnectFunc: func(id string) error {
 			return fmt.Errorf("mock disconnect error")
 		},
-		GetConnectionFunc: func(target string) (*grpc.ClientConn, error) {
-			return &grpc.ClientConn{}, nil
+		GetConnectionFunc: func(id string) (*grpc.ClientConn, error) {
+			return conn, nil
 		},
 	}
 	app.profileManager.SetGRPCClient(mockClient)
@@ -209,6 +212,48 @@ func TestApp_DeleteServerProfileErrors(t *testing.T) {
 	assert.Contains(t, err.Error(), "failed to disconnect")
 }
 
+func TestApp_CreateSavedRequestKeepsFormats(t *testing.T) {
+	app := NewApp()
+	app.ctx = context.Background()
+	require.NoError(t, app.openWorkspace(models.Workspace{Name: "test", Path: filepath.Join(t.TempDir(), "test.db")}))
+
+	profile, err := app.CreateServerProfile("test-server", "localhost", 50051, false, nil, true, nil)
+	require.NoError(t, err)
+	collection, err := app.CreateCollection("smoke", "")
+	require.NoError(t, err)
+
+	req := models.NewSavedRequest(collection.ID, profile.ID, "get", "test.Users", "Get")
+	req.RequestJSON = "id: 1\n"
+	req.RequestFormat = models.FormatPrototext
+	req.ResponseFormat = models.FormatYAML
+	_, err = app.CreateSavedRequest(req)
+	require.NoError(t, err)
+
+	saved, err := app.ListSavedRequests(collection.ID)
+	require.NoError(t, err)
+	require.Len(t, saved, 1)
+	assert.Equal(t, "id: 1\n", saved[0].RequestJSON)
+	assert.Equal(t, models.FormatPrototext, saved[0].RequestFormat)
+	assert.Equal(t, models.FormatYAML, saved[0].ResponseFormat)
+
+	// Unknown formats are rejected by the store
+	req.ResponseFormat = "xml"
+	_, err = app.CreateSavedRequest(req)
+	assert.ErrorIs(t, err, models.ErrInvalidMessageFormat)
+}
+
+func TestApp_CollectionsRequireWorkspace(t *testing.T) {
+	app := NewApp()
+	for name, err := range map[string]error{
+		"UpdateCollection":   app.UpdateCollection(&models.Collection{}),
+		"DeleteCollection":   app.DeleteCollection("c1"),
+		"UpdateSavedRequest": app.UpdateSavedRequest(&models.SavedRequest{}),
+		"DeleteSavedRequest": app.DeleteSavedRequest("r1"),
+	} {
+		assert.ErrorContains(t, err, "profileManager is not initialized", name)
+	}
+}
+
 func TestApp_Greet(t *testing.T) {
 	app := NewApp()
 	result := app.Greet("Test")
@@ -0,0 +1,346 @@
+package app
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"protodesk/pkg/logging"
+	"protodesk/pkg/models"
+	"protodesk/pkg/services"
+)
+
+// cliUsage lists the subcommands of the headless CLI
+const cliUsage = `usage:
+  protodesk run -collection <name|id> [-junit file] [-env name|id] [-parallel] [-concurrency n] [store flags]
+  protodesk export -o <file> [-profiles name,...] [-strip-secrets] [store flags]
+  protodesk import [-overwrite] [-dry-run] [store flags] <file>
+
+store flags: [-workspace name|path] [-key-file file] [-data-dir dir]`
+
+// RunCLI runs protodesk without a window, for use in CI. args excludes the
+// program name, e.g. ["run", "-collection", "smoke", "-junit", "report.xml"].
+// It returns the process exit code: 0 on success, 1 when a request failed or
+// an import left conflicts, and 2 on usage or setup errors.
+func RunCLI(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprintln(stderr, cliUsage)
+		return 2
+	}
+	switch args[0] {
+	case "run":
+		return runCLIRun(args[1:], stdout, stderr)
+	case "export":
+		return runCLIExport(args[1:], stdout, stderr)
+	case "import":
+		return runCLIImport(args[1:], stdout, stderr)
+	default:
+		fmt.Fprintln(stderr, cliUsage)
+		return 2
+	}
+}
+
+// cliStoreFlags are the flags every subcommand uses to open the store
+type cliStoreFlags struct {
+	dataDir   string
+	workspace string
+	keyFile   string
+}
+
+// register adds the store flags to a flag set
+func (f *cliStoreFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.workspace, "workspace", os.Getenv(workspaceEnv), "workspace name or database path (defaults to the active workspace)")
+	fs.StringVar(&f.keyFile, "key-file", os.Getenv(keyFileEnv), "key file that unlocks secret values (or set "+passphraseEnv+")")
+	fs.StringVar(&f.dataDir, "data-dir", "", "data directory holding the workspace list (defaults to ~/.protodesk)")
+}
+
+// cliLogger logs warnings and errors to stderr, next to the command's output
+func cliLogger(stderr io.Writer) *slog.Logger {
+	logger, _, _ := logging.New(logging.Options{Level: slog.LevelWarn, Console: stderr})
+	return logger
+}
+
+// openCLIStore opens the store of the selected workspace and unlocks secrets
+// with the key file or the passphrase environment variable, printing any error
+func openCLIStore(ctx context.Context, flags cliStoreFlags, stderr io.Writer) (*services.SQLiteStore, bool) {
+	dataDir := flags.dataDir
+	if dataDir == "" {
+		dir, err := defaultDataDir()
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return nil, false
+		}
+		dataDir = dir
+	}
+	registry, err := services.LoadWorkspaceRegistry(dataDir)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return nil, false
+	}
+	workspace, err := registry.Resolve(flags.workspace)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return nil, false
+	}
+	store, err := services.OpenSQLiteStore(workspace.Path)
+	if err != nil {
+		fmt.Fprintf(stderr, "failed to open store: %v\n", err)
+		return nil, false
+	}
+
+	if flags.keyFile != "" {
+		if err := store.UnlockSecretsWithKeyFile(ctx, flags.keyFile); err != nil {
+			fmt.Fprintf(stderr, "failed to unlock secrets: %v\n", err)
+			return nil, false
+		}
+	} else if passphrase := os.Getenv(passphraseEnv); passphrase != "" {
+		if err := store.UnlockSecrets(ctx, passphrase); err != nil {
+			fmt.Fprintf(stderr, "failed to unlock secrets: %v\n", err)
+			return nil, false
+		}
+	}
+	return store, true
+}
+
+// runCLIRun runs a collection and prints a pass/fail line per request
+func runCLIRun(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	collectionRef := fs.String("collection", "", "name or ID of the collection to run")
+	junitPath := fs.String("junit", "", "write a JUnit XML report to this file")
+	parallel := fs.Bool("parallel", false, "run requests in parallel")
+	concurrency := fs.Int("concurrency", 0, "maximum requests in flight with -parallel (0 = unlimited)")
+	envRef := fs.String("env", "", "name or ID of the environment providing {{variables}}")
+	var storeFlags cliStoreFlags
+	storeFlags.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *collectionRef == "" {
+		fmt.Fprintln(stderr, "-collection is required")
+		return 2
+	}
+
+	ctx := context.Background()
+	store, ok := openCLIStore(ctx, storeFlags, stderr)
+	if !ok {
+		return 2
+	}
+	manager := services.NewServerProfileManager(store, cliLogger(stderr))
+	defer manager.DisconnectAll()
+
+	collections, err := store.ListCollections(ctx)
+	if err != nil {
+		fmt.Fprintf(stderr, "failed to list collections: %v\n", err)
+		return 2
+	}
+	collectionID := ""
+	for _, c := range collections {
+		if c.ID == *collectionRef || c.Name == *collectionRef {
+			collectionID = c.ID
+			break
+		}
+	}
+	if collectionID == "" {
+		fmt.Fprintf(stderr, "collection %q not found\n", *collectionRef)
+		return 2
+	}
+
+	environmentID := ""
+	if *envRef != "" {
+		envs, err := store.ListEnvironments(ctx)
+		if err != nil {
+			fmt.Fprintf(stderr, "failed to list environments: %v\n", err)
+			return 2
+		}
+		for _, env := range envs {
+			if env.ID == *envRef || env.Name == *envRef {
+				environmentID = env.ID
+				break
+			}
+		}
+		if environmentID == "" {
+			fmt.Fprintf(stderr, "environment %q not found\n", *envRef)
+			return 2
+		}
+	}
+
+	report, err := runCollection(ctx, manager, collectionID, services.RunOptions{
+		Parallel:      *parallel,
+		Concurrency:   *concurrency,
+		EnvironmentID: environmentID,
+	})
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	for _, res := range report.Results {
+		outcome := "PASS"
+		if res.Error != "" {
+			outcome = "ERROR"
+		} else if !res.Passed {
+			outcome = "FAIL"
+		}
+		fmt.Fprintf(stdout, "%-5s %s (%dms)\n", outcome, res.Name, res.DurationMs)
+		if res.Error != "" {
+			fmt.Fprintf(stdout, "      %s\n", res.Error)
+		}
+		for _, a := range res.Assertions {
+			if !a.Passed {
+				fmt.Fprintf(stdout, "      [%s] %s\n", a.Assertion.Type, a.Message)
+			}
+		}
+	}
+	fmt.Fprintf(stdout, "%d passed, %d failed, %d errored in %dms\n", report.Passed, report.Failed, report.Errored, report.DurationMs)
+
+	if *junitPath != "" {
+		data, err := report.JUnitXML()
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+		if err := os.WriteFile(*junitPath, data, 0644); err != nil {
+			fmt.Fprintf(stderr, "failed to write JUnit report: %v\n", err)
+			return 2
+		}
+	}
+
+	if !report.Success() {
+		return 1
+	}
+	return 0
+}
+
+// runCLIExport writes the selected profiles and the saved requests that use
+// them to a workspace file
+func runCLIExport(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	out := fs.String("o", "", "workspace file to write; .yaml/.yml writes YAML, anything else JSON")
+	profileRefs := fs.String("profiles", "", "comma-separated names or IDs of the profiles to export (default all)")
+	stripSecrets := fs.Bool("strip-secrets", false, "leave secret header values and auth credentials out")
+	var storeFlags cliStoreFlags
+	storeFlags.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *out == "" {
+		fmt.Fprintln(stderr, "-o is required")
+		return 2
+	}
+
+	ctx := context.Background()
+	store, ok := openCLIStore(ctx, storeFlags, stderr)
+	if !ok {
+		return 2
+	}
+	var profileIDs []string
+	if *profileRefs != "" {
+		profiles, err := store.List(ctx)
+		if err != nil {
+			fmt.Fprintf(stderr, "failed to list profiles: %v\n", err)
+			return 2
+		}
+		for _, ref := range strings.Split(*profileRefs, ",") {
+			ref = strings.TrimSpace(ref)
+			i := slices.IndexFunc(profiles, func(p *models.ServerProfile) bool { return p.ID == ref || p.Name == ref })
+			if i < 0 {
+				fmt.Fprintf(stderr, "profile %q not found\n", ref)
+				return 2
+			}
+			profileIDs = append(profileIDs, profiles[i].ID)
+		}
+	}
+
+	path, err := filepath.Abs(*out)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	file, err := services.ExportWorkspace(ctx, store, services.ExportOptions{
+		ProfileIDs:   profileIDs,
+		StripSecrets: *stripSecrets,
+		BaseDir:      filepath.Dir(path),
+	})
+	if err != nil {
+		fmt.Fprintf(stderr, "failed to export workspace: %v\n", err)
+		return 2
+	}
+	if err := services.WriteWorkspaceFile(path, file); err != nil {
+		fmt.Fprintf(stderr, "failed to write workspace file: %v\n", err)
+		return 2
+	}
+	fmt.Fprintf(stdout, "exported %d profiles and %d collections to %s\n", len(file.Profiles), len(file.Collections), *out)
+	return 0
+}
+
+// runCLIImport merges a workspace file into the store and prints what
+// happened to each record
+func runCLIImport(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	overwrite := fs.Bool("overwrite", false, "replace local records that conflict with the file")
+	dryRun := fs.Bool("dry-run", false, "report what would change without changing anything")
+	var storeFlags cliStoreFlags
+	storeFlags.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(stderr, "exactly one workspace file is required")
+		return 2
+	}
+
+	ctx := context.Background()
+	store, ok := openCLIStore(ctx, storeFlags, stderr)
+	if !ok {
+		return 2
+	}
+	path, err := filepath.Abs(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	file, err := services.ReadWorkspaceFile(path)
+	if err != nil {
+		fmt.Fprintf(stderr, "failed to read workspace file: %v\n", err)
+		return 2
+	}
+	report, err := services.ImportWorkspace(ctx, store, file, services.ImportOptions{
+		Overwrite: *overwrite,
+		DryRun:    *dryRun,
+		BaseDir:   filepath.Dir(path),
+	})
+	if err != nil {
+		fmt.Fprintf(stderr, "failed to import workspace: %v\n", err)
+		return 2
+	}
+
+	for _, item := range report.Items {
+		if item.Action == services.ImportUnchanged {
+			continue
+		}
+		fmt.Fprintf(stdout, "%-9s %s %s\n", item.Action, item.Kind, item.Name)
+		if item.Detail != "" {
+			fmt.Fprintf(stdout, "          %s\n", item.Detail)
+		}
+	}
+	for _, err := range scanProtoPaths(ctx, store, services.NewProtoParser(store, cliLogger(stderr)), report.NewProtoPaths) {
+		fmt.Fprintf(stderr, "warning: %v\n", err)
+	}
+	fmt.Fprintf(stdout, "%d created, %d updated, %d unchanged, %d conflicts, %d failed\n",
+		report.Count(services.ImportCreated), report.Count(services.ImportUpdated), report.Count(services.ImportUnchanged),
+		report.Count(services.ImportConflict), report.Count(services.ImportFailed))
+
+	if report.Count(services.ImportConflict) > 0 || report.Count(services.ImportFailed) > 0 {
+		return 1
+	}
+	return 0
+}
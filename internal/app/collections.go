@@ -0,0 +1,197 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"protodesk/pkg/models"
+	"protodesk/pkg/services"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// CreateCollection creates a new collection of saved requests
+func (a *App) CreateCollection(name string, description string) (*models.Collection, error) {
+	s, done := a.session()
+	defer done()
+	if s.profileManager == nil {
+		return nil, fmt.Errorf("profileManager is not initialized")
+	}
+	collection := models.NewCollection(name, description)
+	if err := s.profileManager.GetStore().CreateCollection(a.ctx, collection); err != nil {
+		return nil, fmt.Errorf("failed to create collection: %w", err)
+	}
+	return collection, nil
+}
+
+// ListCollections returns all collections
+func (a *App) ListCollections() ([]*models.Collection, error) {
+	s, done := a.session()
+	defer done()
+	if s.profileManager == nil {
+		return nil, fmt.Errorf("profileManager is not initialized")
+	}
+	return s.profileManager.GetStore().ListCollections(a.ctx)
+}
+
+// UpdateCollection updates the name and description of a collection
+func (a *App) UpdateCollection(collection *models.Collection) error {
+	s, done := a.session()
+	defer done()
+	if s.profileManager == nil {
+		return fmt.Errorf("profileManager is not initialized")
+	}
+	collection.UpdatedAt = time.Now()
+	return s.profileManager.GetStore().UpdateCollection(a.ctx, collection)
+}
+
+// DeleteCollection deletes a collection and its saved requests
+func (a *App) DeleteCollection(id string) error {
+	s, done := a.session()
+	defer done()
+	if s.profileManager == nil {
+		return fmt.Errorf("profileManager is not initialized")
+	}
+	return s.profileManager.GetStore().DeleteCollection(a.ctx, id)
+}
+
+// CreateSavedRequest saves a request, with its assertions, into a collection
+func (a *App) CreateSavedRequest(req *models.SavedRequest) (*models.SavedRequest, error) {
+	s, done := a.session()
+	defer done()
+	if s.profileManager == nil {
+		return nil, fmt.Errorf("profileManager is not initialized")
+	}
+	saved := models.NewSavedRequest(req.CollectionID, req.ServerProfileID, req.Name, req.ServiceName, req.MethodName)
+	if req.RequestJSON != "" {
+		saved.RequestJSON = req.RequestJSON
+	}
+	saved.RequestFormat = req.RequestFormat
+	saved.ResponseFormat = req.ResponseFormat
+	saved.HeadersJSON = req.HeadersJSON
+	saved.Position = req.Position
+	saved.Assertions = req.Assertions
+	saved.Extractions = req.Extractions
+	if err := s.profileManager.GetStore().CreateSavedRequest(a.ctx, saved); err != nil {
+		return nil, fmt.Errorf("failed to save request: %w", err)
+	}
+	return saved, nil
+}
+
+// ListSavedRequests returns the saved requests of a collection in run order
+func (a *App) ListSavedRequests(collectionID string) ([]*models.SavedRequest, error) {
+	s, done := a.session()
+	defer done()
+	if s.profileManager == nil {
+		return nil, fmt.Errorf("profileManager is not initialized")
+	}
+	return s.profileManager.GetStore().ListSavedRequests(a.ctx, collectionID)
+}
+
+// UpdateSavedRequest updates an existing saved request
+func (a *App) UpdateSavedRequest(req *models.SavedRequest) error {
+	s, done := a.session()
+	defer done()
+	if s.profileManager == nil {
+		return fmt.Errorf("profileManager is not initialized")
+	}
+	req.UpdatedAt = time.Now()
+	return s.profileManager.GetStore().UpdateSavedRequest(a.ctx, req)
+}
+
+// DeleteSavedRequest deletes a saved request by ID
+func (a *App) DeleteSavedRequest(id string) error {
+	s, done := a.session()
+	defer done()
+	if s.profileManager == nil {
+		return fmt.Errorf("profileManager is not initialized")
+	}
+	return s.profileManager.GetStore().DeleteSavedRequest(a.ctx, id)
+}
+
+// RunCollection runs every saved request of a collection, sequentially or in
+// parallel, and returns a pass/fail report
+func (a *App) RunCollection(collectionID string, parallel bool) (*services.RunReport, error) {
+	s, done := a.session()
+	defer done()
+	if s.profileManager == nil {
+		return nil, fmt.Errorf("profileManager is not initialized")
+	}
+	return runCollection(a.ctx, s.profileManager, collectionID, services.RunOptions{
+		Parallel:      parallel,
+		EnvironmentID: s.activeEnvironmentID,
+	})
+}
+
+// RunSavedRequest runs a single saved request with the active environment,
+// storing any extracted values in it
+func (a *App) RunSavedRequest(requestID string) (*services.RequestRunResult, error) {
+	s, done := a.session()
+	defer done()
+	if s.profileManager == nil {
+		return nil, fmt.Errorf("profileManager is not initialized")
+	}
+	req, err := s.profileManager.GetStore().GetSavedRequest(a.ctx, requestID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.profileManager.Connect(a.ctx, req.ServerProfileID); err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+	if err := s.profileManager.WaitForReady(a.ctx, req.ServerProfileID); err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+	runner := services.NewCollectionRunner(s.profileManager.GetStore(), s.profileManager)
+	return runner.RunSavedRequest(a.ctx, requestID, services.RunOptions{EnvironmentID: s.activeEnvironmentID})
+}
+
+// ExportRunReportJUnit asks for a file location and writes the report there as
+// JUnit XML. It returns the chosen path, or an empty string if cancelled.
+func (a *App) ExportRunReportJUnit(report *services.RunReport) (string, error) {
+	if a.ctx == nil {
+		return "", fmt.Errorf("context not initialized")
+	}
+	path, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
+		Title:           "Export JUnit report",
+		DefaultFilename: fmt.Sprintf("%s-junit.xml", report.CollectionName),
+		Filters: []runtime.FileFilter{
+			{DisplayName: "JUnit XML (*.xml)", Pattern: "*.xml"},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	if path == "" {
+		return "", nil // user cancelled
+	}
+	data, err := report.JUnitXML()
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write JUnit report: %w", err)
+	}
+	return path, nil
+}
+
+// runCollection connects every profile the collection uses and runs it
+func runCollection(ctx context.Context, manager *services.ServerProfileManager, collectionID string, opts services.RunOptions) (*services.RunReport, error) {
+	requests, err := manager.GetStore().ListSavedRequests(ctx, collectionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list saved requests: %w", err)
+	}
+	for _, req := range requests {
+		if err := manager.Connect(ctx, req.ServerProfileID); err != nil {
+			return nil, fmt.Errorf("failed to connect profile for %q: %w", req.Name, err)
+		}
+	}
+	for _, req := range requests {
+		if err := manager.WaitForReady(ctx, req.ServerProfileID); err != nil {
+			return nil, fmt.Errorf("failed to connect profile for %q: %w", req.Name, err)
+		}
+	}
+	runner := services.NewCollectionRunner(manager.GetStore(), manager)
+	return runner.RunCollection(ctx, collectionID, opts)
+}
@@ -0,0 +1,106 @@
+package app
+
+import (
+	"fmt"
+	"time"
+
+	"protodesk/pkg/models"
+)
+
+// CreateEnvironment creates a new, empty environment
+func (a *App) CreateEnvironment(name string) (*models.Environment, error) {
+	s, done := a.session()
+	defer done()
+	if s.profileManager == nil {
+		return nil, fmt.Errorf("profileManager is not initialized")
+	}
+	env := models.NewEnvironment(name)
+	if err := s.profileManager.GetStore().CreateEnvironment(a.ctx, env); err != nil {
+		return nil, fmt.Errorf("failed to create environment: %w", err)
+	}
+	return env, nil
+}
+
+// ListEnvironments returns all environments
+func (a *App) ListEnvironments() ([]*models.Environment, error) {
+	s, done := a.session()
+	defer done()
+	if s.profileManager == nil {
+		return nil, fmt.Errorf("profileManager is not initialized")
+	}
+	return s.profileManager.GetStore().ListEnvironments(a.ctx)
+}
+
+// UpdateEnvironment updates the name and variables of an environment
+func (a *App) UpdateEnvironment(env *models.Environment) error {
+	s, done := a.session()
+	defer done()
+	env.UpdatedAt = time.Now()
+	return s.profileManager.GetStore().UpdateEnvironment(a.ctx, env)
+}
+
+// DeleteEnvironment deletes an environment, deactivating it if it is active
+func (a *App) DeleteEnvironment(id string) error {
+	s, done := a.session()
+	defer done()
+	if err := s.profileManager.GetStore().DeleteEnvironment(a.ctx, id); err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.activeEnvironmentID == id {
+		a.activeEnvironmentID = ""
+	}
+	return nil
+}
+
+// SetActiveEnvironment selects the environment used to expand {{variables}}.
+// An empty ID deactivates the current environment.
+func (a *App) SetActiveEnvironment(id string) error {
+	s, done := a.session()
+	defer done()
+	if id != "" {
+		if s.profileManager == nil {
+			return fmt.Errorf("profileManager is not initialized")
+		}
+		if _, err := s.profileManager.GetStore().GetEnvironment(a.ctx, id); err != nil {
+			return err
+		}
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	// The environment belongs to the workspace it was looked up in
+	if a.workspace.Path != s.workspace.Path {
+		return fmt.Errorf("workspace %s was closed", s.workspace.Name)
+	}
+	a.activeEnvironmentID = id
+	return nil
+}
+
+// GetActiveEnvironment returns the active environment, or nil if none is set
+func (a *App) GetActiveEnvironment() (*models.Environment, error) {
+	s, done := a.session()
+	defer done()
+	return a.activeEnvironment(s)
+}
+
+// activeEnvironment returns the active environment of a session
+func (a *App) activeEnvironment(s workspaceSession) (*models.Environment, error) {
+	if s.activeEnvironmentID == "" || s.profileManager == nil {
+		return nil, nil
+	}
+	return s.profileManager.GetStore().GetEnvironment(a.ctx, s.activeEnvironmentID)
+}
+
+// activeVariables returns the variables of the active environment of a
+// session
+func (a *App) activeVariables(s workspaceSession) (map[string]string, error) {
+	env, err := a.activeEnvironment(s)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load active environment: %w", err)
+	}
+	if env == nil {
+		return map[string]string{}, nil
+	}
+	return env.Variables, nil
+}
@@ -0,0 +1,89 @@
+package app
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"protodesk/pkg/logging"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// logLevelEnv sets the log level at startup, e.g. debug; it defaults to info
+const logLevelEnv = "PROTODESK_LOG_LEVEL"
+
+// logEntryEvent is emitted with every logging.Entry for the log viewer
+const logEntryEvent = "log:entry"
+
+// logDirName is the directory of the data directory holding the log files
+const logDirName = "logs"
+
+// initLogging sets up the log level and viewer buffer of a new App. Until
+// Startup knows the data directory the log only goes to the buffer and
+// stderr.
+func (a *App) initLogging() {
+	a.logLevel = new(slog.LevelVar)
+	a.logs = logging.NewBuffer(logging.DefaultBufferSize)
+	a.logs.SetListener(func(entry logging.Entry) {
+		if a.ctx != nil {
+			runtime.EventsEmit(a.ctx, logEntryEvent, entry)
+		}
+	})
+	a.logger, _, _ = logging.New(logging.Options{Level: a.logLevel, Console: os.Stderr, Buffer: a.logs})
+
+	if name := os.Getenv(logLevelEnv); name != "" {
+		if level, err := logging.ParseLevel(name); err != nil {
+			a.logger.Warn("Ignoring "+logLevelEnv, "error", err)
+		} else {
+			a.logLevel.Set(level)
+		}
+	}
+}
+
+// startLogging adds rotating log files in the data directory to the log
+func (a *App) startLogging(dataDir string) {
+	dir := filepath.Join(dataDir, logDirName)
+	logger, closer, err := logging.New(logging.Options{Level: a.logLevel, Dir: dir, Console: os.Stderr, Buffer: a.logs})
+	if err != nil {
+		a.logger.Warn("Logging to stderr only", "error", err)
+		return
+	}
+	a.logger, a.logCloser, a.logDir = logger, closer, dir
+}
+
+// closeLogging closes the log file, if one is open
+func (a *App) closeLogging() {
+	if a.logCloser != nil {
+		_ = a.logCloser.Close()
+		a.logCloser = nil
+	}
+}
+
+// GetLogs returns the most recent log entries, oldest first; newer entries
+// are emitted as log:entry events
+func (a *App) GetLogs() []logging.Entry {
+	return a.logs.Entries()
+}
+
+// GetLogLevel returns the minimum level logged, e.g. INFO
+func (a *App) GetLogLevel() string {
+	return a.logLevel.Level().String()
+}
+
+// SetLogLevel changes the minimum level logged: debug, info, warn or error
+func (a *App) SetLogLevel(name string) error {
+	level, err := logging.ParseLevel(name)
+	if err != nil {
+		return err
+	}
+	a.logLevel.Set(level)
+	a.logger.Info("Log level changed", "level", level.String())
+	return nil
+}
+
+// GetLogDirectory returns the directory holding the log files, to attach
+// them to support requests; it is empty if no log file could be opened
+func (a *App) GetLogDirectory() string {
+	return a.logDir
+}
@@ -0,0 +1,60 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"protodesk/pkg/services"
+)
+
+// DecodeProtobuf decodes protobuf bytes as a message type resolved from the
+// proto definitions or reflection of a profile and returns it as JSON. input
+// is base64 or hex text or, with encoding "file", the path of a file holding
+// the raw bytes.
+func (a *App) DecodeProtobuf(profileID, messageName, input, encoding string) (string, error) {
+	s, done := a.session()
+	defer done()
+	data, err := services.DecodeBytes(input, encoding)
+	if err != nil {
+		return "", err
+	}
+	return s.profileManager.DecodeMessage(context.Background(), profileID, messageName, data)
+}
+
+// DecodeProtobufRaw breaks protobuf bytes down by field number and wire type
+// when their message type is not known. input is read as by DecodeProtobuf.
+func (a *App) DecodeProtobufRaw(input, encoding string) ([]services.WireField, error) {
+	data, err := services.DecodeBytes(input, encoding)
+	if err != nil {
+		return nil, err
+	}
+	return services.DecodeRaw(data)
+}
+
+// EncodeProtobuf serializes the JSON of a message type resolved as by
+// DecodeProtobuf and returns the bytes as base64 or hex
+func (a *App) EncodeProtobuf(profileID, messageName, messageJSON, encoding string) (string, error) {
+	s, done := a.session()
+	defer done()
+	data, err := s.profileManager.EncodeMessage(context.Background(), profileID, messageName, messageJSON)
+	if err != nil {
+		return "", err
+	}
+	return services.EncodeBytes(data, encoding)
+}
+
+// EncodeProtobufToFile serializes the JSON of a message like EncodeProtobuf
+// and writes the raw bytes to path
+func (a *App) EncodeProtobufToFile(profileID, messageName, messageJSON, path string) error {
+	s, done := a.session()
+	defer done()
+	data, err := s.profileManager.EncodeMessage(context.Background(), profileID, messageName, messageJSON)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
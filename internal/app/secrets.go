@@ -0,0 +1,55 @@
+package app
+
+import (
+	"fmt"
+
+	"protodesk/pkg/services"
+)
+
+const (
+	// keyFileEnv names a key file that unlocks secret storage at startup
+	keyFileEnv = "PROTODESK_KEY_FILE"
+	// passphraseEnv holds the passphrase for secret storage in the CLI
+	passphraseEnv = "PROTODESK_PASSPHRASE"
+)
+
+// UnlockSecrets unlocks encrypted secret storage with a passphrase. The first
+// call sets up secret storage with that passphrase.
+func (a *App) UnlockSecrets(passphrase string) error {
+	s, done := a.session()
+	defer done()
+	if s.profileManager == nil {
+		return fmt.Errorf("profileManager is not initialized")
+	}
+	return s.profileManager.GetStore().UnlockSecrets(a.ctx, passphrase)
+}
+
+// UnlockSecretsWithKeyFile unlocks encrypted secret storage with a key file
+// holding a 32 byte key (raw, hex or base64)
+func (a *App) UnlockSecretsWithKeyFile(path string) error {
+	s, done := a.session()
+	defer done()
+	if s.profileManager == nil {
+		return fmt.Errorf("profileManager is not initialized")
+	}
+	return s.profileManager.GetStore().UnlockSecretsWithKeyFile(a.ctx, path)
+}
+
+// LockSecrets forgets the secret key until storage is unlocked again
+func (a *App) LockSecrets() {
+	s, done := a.session()
+	defer done()
+	if s.profileManager != nil {
+		s.profileManager.GetStore().LockSecrets()
+	}
+}
+
+// GetSecretsStatus reports whether secret storage is set up and unlocked
+func (a *App) GetSecretsStatus() (services.SecretsStatus, error) {
+	s, done := a.session()
+	defer done()
+	if s.profileManager == nil {
+		return services.SecretsStatus{}, fmt.Errorf("profileManager is not initialized")
+	}
+	return s.profileManager.GetStore().GetSecretsStatus(a.ctx)
+}
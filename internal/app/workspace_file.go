@@ -0,0 +1,115 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"protodesk/pkg/models/proto"
+	"protodesk/pkg/services"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// ExportWorkspace asks for a file location and writes the selected profiles,
+// their proto paths, per-request headers and saved requests there as a
+// workspace file. The file is YAML unless a .json name is chosen. It returns
+// the chosen path, or an empty string if cancelled.
+func (a *App) ExportWorkspace(profileIDs []string, stripSecrets bool) (string, error) {
+	s, done := a.session()
+	defer done()
+	if s.profileManager == nil {
+		return "", fmt.Errorf("profileManager is not initialized")
+	}
+	path, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
+		Title:           "Export workspace",
+		DefaultFilename: "protodesk-workspace.yaml",
+		Filters: []runtime.FileFilter{
+			{DisplayName: "Workspace files (*.yaml, *.json)", Pattern: "*.yaml;*.yml;*.json"},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	if path == "" {
+		return "", nil // user cancelled
+	}
+	file, err := services.ExportWorkspace(a.ctx, s.profileManager.GetStore(), services.ExportOptions{
+		ProfileIDs:   profileIDs,
+		StripSecrets: stripSecrets,
+		BaseDir:      filepath.Dir(path),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to export workspace: %w", err)
+	}
+	if err := services.WriteWorkspaceFile(path, file); err != nil {
+		return "", fmt.Errorf("failed to write workspace file: %w", err)
+	}
+	return path, nil
+}
+
+// SelectWorkspaceFile opens a file picker for a workspace file to import. It
+// returns an empty string if cancelled.
+func (a *App) SelectWorkspaceFile() (string, error) {
+	if a.ctx == nil {
+		return "", fmt.Errorf("context not initialized")
+	}
+	return runtime.OpenFileDialog(a.ctx, runtime.OpenDialogOptions{
+		Title: "Import workspace",
+		Filters: []runtime.FileFilter{
+			{DisplayName: "Workspace files (*.yaml, *.json)", Pattern: "*.yaml;*.yml;*.json"},
+		},
+	})
+}
+
+// ImportWorkspace merges a workspace file into the local profiles and
+// collections. Conflicting records are kept unless overwrite is set; with
+// dryRun nothing is changed and the report shows what would happen.
+func (a *App) ImportWorkspace(path string, overwrite bool, dryRun bool) (*services.ImportReport, error) {
+	s, done := a.session()
+	defer done()
+	if s.profileManager == nil {
+		return nil, fmt.Errorf("profileManager is not initialized")
+	}
+	file, err := services.ReadWorkspaceFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read workspace file: %w", err)
+	}
+	report, err := services.ImportWorkspace(a.ctx, s.profileManager.GetStore(), file, services.ImportOptions{
+		Overwrite: overwrite,
+		DryRun:    dryRun,
+		BaseDir:   filepath.Dir(path),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to import workspace: %w", err)
+	}
+	for _, err := range scanProtoPaths(a.ctx, s.profileManager.GetStore(), s.protoParser, report.NewProtoPaths) {
+		a.logger.Warn("Failed to scan imported proto path", "error", err)
+	}
+	return report, nil
+}
+
+// scanProtoPaths hashes and parses newly added proto paths. A path that does
+// not exist on this machine is not an import failure, so errors are returned
+// for reporting rather than stopping the scan.
+func scanProtoPaths(ctx context.Context, store services.ServerProfileStore, parser *services.ProtoParser, paths []*proto.ProtoPath) []error {
+	var errs []error
+	for _, pp := range paths {
+		hash, err := calculateProtoPathHash(pp.Path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("proto path %s was added but not scanned: %w", pp.Path, err))
+			continue
+		}
+		pp.Hash = hash
+		pp.LastScanned = time.Now()
+		if err := store.UpdateProtoPath(ctx, pp); err != nil {
+			errs = append(errs, fmt.Errorf("failed to update proto path %s: %w", pp.Path, err))
+			continue
+		}
+		if err := parser.ScanAndParseProtoPath(ctx, pp.ServerProfileID, pp.ID, pp.Path); err != nil {
+			errs = append(errs, fmt.Errorf("failed to parse proto files in %s: %w", pp.Path, err))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,110 @@
+package app
+
+import (
+	"fmt"
+
+	"protodesk/pkg/models"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// workspaceEnv names the workspace, or database path, opened at startup
+const workspaceEnv = "PROTODESK_WORKSPACE"
+
+// workspaceSwitchedEvent is emitted with the new workspace after a switch so
+// that the frontend reloads profiles, collections and environments
+const workspaceSwitchedEvent = "workspace:switched"
+
+// ListWorkspaces returns the default workspace followed by the named ones
+func (a *App) ListWorkspaces() ([]models.Workspace, error) {
+	if a.workspaces == nil {
+		return nil, fmt.Errorf("workspaces are not initialized")
+	}
+	return a.workspaces.List(), nil
+}
+
+// GetCurrentWorkspace returns the workspace the app is working on
+func (a *App) GetCurrentWorkspace() models.Workspace {
+	s, done := a.session()
+	done()
+	return s.workspace
+}
+
+// CreateWorkspace registers a new workspace backed by the database at path,
+// which is created on first use. An empty path keeps the database in the
+// data directory.
+func (a *App) CreateWorkspace(name string, path string) (*models.Workspace, error) {
+	if a.workspaces == nil {
+		return nil, fmt.Errorf("workspaces are not initialized")
+	}
+	if path == "" {
+		path = a.workspaces.DefaultPath(name)
+	}
+	workspace := models.Workspace{Name: name, Path: path}
+	if err := a.workspaces.Add(workspace); err != nil {
+		return nil, fmt.Errorf("failed to create workspace: %w", err)
+	}
+	return &workspace, nil
+}
+
+// SelectWorkspaceDatabase asks for the database file of a workspace: an
+// existing one to open, or a new one to create. It returns an empty string if
+// cancelled.
+func (a *App) SelectWorkspaceDatabase(existing bool) (string, error) {
+	if a.ctx == nil {
+		return "", fmt.Errorf("context not initialized")
+	}
+	filters := []runtime.FileFilter{{DisplayName: "protodesk database (*.db)", Pattern: "*.db"}}
+	if existing {
+		return runtime.OpenFileDialog(a.ctx, runtime.OpenDialogOptions{
+			Title:   "Open workspace database",
+			Filters: filters,
+		})
+	}
+	return runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
+		Title:                "New workspace database",
+		DefaultFilename:      "protodesk.db",
+		CanCreateDirectories: true,
+		Filters:              filters,
+	})
+}
+
+// SwitchWorkspace closes the current workspace, with all its connections,
+// and opens the named one. It stays the active workspace on later starts.
+func (a *App) SwitchWorkspace(name string) error {
+	if a.workspaces == nil {
+		return fmt.Errorf("workspaces are not initialized")
+	}
+	workspace, err := a.workspaces.Get(name)
+	if err != nil {
+		return err
+	}
+	// The session is released before switching, which waits for the calls
+	// using the open workspace
+	current, done := a.session()
+	done()
+	if workspace.Path != current.workspace.Path {
+		if err := a.openWorkspace(workspace); err != nil {
+			return fmt.Errorf("failed to open workspace %s: %w", name, err)
+		}
+	}
+	if err := a.workspaces.SetActive(name); err != nil {
+		return err
+	}
+	runtime.EventsEmit(a.ctx, workspaceSwitchedEvent, workspace)
+	return nil
+}
+
+// RemoveWorkspace forgets a workspace without deleting its database file.
+// The open workspace cannot be removed.
+func (a *App) RemoveWorkspace(name string) error {
+	if a.workspaces == nil {
+		return fmt.Errorf("workspaces are not initialized")
+	}
+	s, done := a.session()
+	done()
+	if name == s.workspace.Name {
+		return fmt.Errorf("cannot remove the open workspace %s; switch to another one first", name)
+	}
+	return a.workspaces.Remove(name)
+}
@@ -3,6 +3,8 @@ package main
 import (
 	"context"
 	"embed"
+	"flag"
+	"os"
 
 	"protodesk/internal/app"
 
@@ -15,8 +17,21 @@ import (
 var assets embed.FS
 
 func main() {
+	// Headless mode for running collections in CI and sharing workspaces
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "run", "export", "import":
+			os.Exit(app.RunCLI(os.Args[1:], os.Stdout, os.Stderr))
+		}
+	}
+
+	// -workspace opens a workspace other than the active one
+	flags := flag.NewFlagSet("protodesk", flag.ExitOnError)
+	workspace := flags.String("workspace", "", "workspace name or database path to open (or set PROTODESK_WORKSPACE)")
+	_ = flags.Parse(os.Args[1:])
+
 	// Create an instance of the app structure
-	application := app.NewApp()
+	application := app.NewAppForWorkspace(*workspace)
 
 	// Create application with options
 	err := wails.Run(&options.App{
@@ -0,0 +1,150 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// DefaultBufferSize is how many entries a log viewer buffer usually keeps
+const DefaultBufferSize = 1000
+
+// Entry is a log record as shown in the log viewer
+type Entry struct {
+	Time    time.Time `json:"time"`
+	Level   string    `json:"level"`
+	Message string    `json:"message"`
+	// Attrs holds the attributes by their dotted group path, with sensitive
+	// values redacted
+	Attrs map[string]string `json:"attrs,omitempty"`
+}
+
+// Buffer keeps the most recent log entries in a ring and tells a listener
+// about each new one
+type Buffer struct {
+	mu       sync.Mutex
+	entries  []Entry
+	next     int
+	full     bool
+	listener func(Entry)
+}
+
+// NewBuffer creates a buffer keeping the last capacity entries
+func NewBuffer(capacity int) *Buffer {
+	if capacity <= 0 {
+		capacity = DefaultBufferSize
+	}
+	return &Buffer{entries: make([]Entry, capacity)}
+}
+
+// SetListener registers a function told about every new entry. It is called
+// from the goroutine that logs and must not block or log.
+func (b *Buffer) SetListener(listener func(Entry)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.listener = listener
+}
+
+// Entries returns the buffered entries, oldest first
+func (b *Buffer) Entries() []Entry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.full {
+		return append([]Entry(nil), b.entries[:b.next]...)
+	}
+	return append(append([]Entry(nil), b.entries[b.next:]...), b.entries[:b.next]...)
+}
+
+// add stores an entry, replacing the oldest once the buffer is full
+func (b *Buffer) add(entry Entry) {
+	b.mu.Lock()
+	b.entries[b.next] = entry
+	b.next = (b.next + 1) % len(b.entries)
+	if b.next == 0 {
+		b.full = true
+	}
+	listener := b.listener
+	b.mu.Unlock()
+	if listener != nil {
+		listener(entry)
+	}
+}
+
+// bufferHandler turns records into entries of a Buffer
+type bufferHandler struct {
+	buffer *Buffer
+	level  slog.Leveler
+	// attrs are the attributes added with WithAttrs, already flattened
+	attrs  map[string]string
+	prefix string
+}
+
+func (h *bufferHandler) Enabled(_ context.Context, level slog.Level) bool {
+	minLevel := slog.LevelInfo
+	if h.level != nil {
+		minLevel = h.level.Level()
+	}
+	return level >= minLevel
+}
+
+func (h *bufferHandler) Handle(_ context.Context, record slog.Record) error {
+	entry := Entry{
+		Time:    record.Time,
+		Level:   record.Level.String(),
+		Message: record.Message,
+	}
+	if len(h.attrs) > 0 || record.NumAttrs() > 0 {
+		entry.Attrs = make(map[string]string, len(h.attrs)+record.NumAttrs())
+		for key, value := range h.attrs {
+			entry.Attrs[key] = value
+		}
+		record.Attrs(func(a slog.Attr) bool {
+			flatten(entry.Attrs, h.prefix, a)
+			return true
+		})
+	}
+	h.buffer.add(entry)
+	return nil
+}
+
+func (h *bufferHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.attrs = make(map[string]string, len(h.attrs)+len(attrs))
+	for key, value := range h.attrs {
+		clone.attrs[key] = value
+	}
+	for _, a := range attrs {
+		flatten(clone.attrs, h.prefix, a)
+	}
+	return &clone
+}
+
+func (h *bufferHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	clone := *h
+	clone.prefix = h.prefix + name + "."
+	return &clone
+}
+
+// flatten adds an attribute to attrs under its dotted path, redacting
+// sensitive values
+func flatten(attrs map[string]string, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, member := range a.Value.Group() {
+			flatten(attrs, prefix, member)
+		}
+		return
+	}
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	a = redactAttr(nil, a)
+	attrs[prefix+a.Key] = a.Value.String()
+}
@@ -0,0 +1,169 @@
+// Package logging sets up the structured logger shared by the app and its
+// services: levelled, written to rotating files in the data directory, with
+// sensitive metadata redacted and recent entries kept for the log viewer.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strings"
+)
+
+// FileName is the name of the active log file in the log directory
+const FileName = "protodesk.log"
+
+const (
+	// DefaultMaxSize is the size at which a log file is rotated
+	DefaultMaxSize = 5 << 20
+	// DefaultMaxFiles is how many rotated log files are kept
+	DefaultMaxFiles = 3
+)
+
+// Redacted replaces the value of sensitive attributes
+const Redacted = "[REDACTED]"
+
+// sensitiveKeys are the parts of attribute and metadata keys whose values
+// are never logged
+var sensitiveKeys = []string{"authorization", "cookie", "password", "secret", "token", "api-key", "apikey", "passphrase"}
+
+// Options configure New
+type Options struct {
+	// Level is the minimum level logged; nil logs info and above
+	Level slog.Leveler
+	// Dir receives JSON log files rotated at MaxSize, keeping MaxFiles old
+	// files; empty writes no files
+	Dir      string
+	MaxSize  int64
+	MaxFiles int
+	// Console, if set, also receives the log as text, e.g. os.Stderr
+	Console io.Writer
+	// Buffer, if set, keeps recent entries for the log viewer
+	Buffer *Buffer
+}
+
+// New creates a logger writing to the outputs in opts. The returned closer
+// closes the log file.
+func New(opts Options) (*slog.Logger, io.Closer, error) {
+	handlerOpts := &slog.HandlerOptions{Level: opts.Level, ReplaceAttr: redactAttr}
+	var handlers []slog.Handler
+	var closer io.Closer = nopCloser{}
+	if opts.Dir != "" {
+		file, err := OpenRotatingFile(filepath.Join(opts.Dir, FileName), opts.MaxSize, opts.MaxFiles)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open log file: %w", err)
+		}
+		handlers = append(handlers, slog.NewJSONHandler(file, handlerOpts))
+		closer = file
+	}
+	if opts.Console != nil {
+		handlers = append(handlers, slog.NewTextHandler(opts.Console, handlerOpts))
+	}
+	if opts.Buffer != nil {
+		handlers = append(handlers, &bufferHandler{buffer: opts.Buffer, level: opts.Level})
+	}
+	return slog.New(fanoutHandler(handlers)), closer, nil
+}
+
+// Discard returns a logger that drops everything
+func Discard() *slog.Logger {
+	return slog.New(fanoutHandler(nil))
+}
+
+// OrDiscard returns logger, or a logger that drops everything if it is nil
+func OrDiscard(logger *slog.Logger) *slog.Logger {
+	if logger == nil {
+		return Discard()
+	}
+	return logger
+}
+
+// ParseLevel parses a level name such as "debug" or "WARN"
+func ParseLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return 0, fmt.Errorf("invalid log level %q", name)
+	}
+	return level, nil
+}
+
+// IsSensitive reports whether values under key, an attribute or metadata
+// key, must be redacted
+func IsSensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactAttr hides the values of sensitive attributes
+func redactAttr(_ []string, a slog.Attr) slog.Attr {
+	if a.Value.Kind() != slog.KindGroup && IsSensitive(a.Key) {
+		return slog.String(a.Key, Redacted)
+	}
+	return a
+}
+
+// Metadata returns an attribute holding request or response metadata, as
+// in grpc's metadata.MD, with sensitive values redacted
+func Metadata(key string, md map[string][]string) slog.Attr {
+	attrs := make([]any, 0, len(md))
+	for name, values := range md {
+		value := strings.Join(values, ", ")
+		if IsSensitive(name) {
+			value = Redacted
+		}
+		attrs = append(attrs, slog.String(name, value))
+	}
+	return slog.Group(key, attrs...)
+}
+
+// fanoutHandler passes records to every handler that is enabled for them
+type fanoutHandler []slog.Handler
+
+func (h fanoutHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, handler := range h {
+		if handler.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h fanoutHandler) Handle(ctx context.Context, record slog.Record) error {
+	var firstErr error
+	for _, handler := range h {
+		if !handler.Enabled(ctx, record.Level) {
+			continue
+		}
+		if err := handler.Handle(ctx, record.Clone()); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (h fanoutHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make(fanoutHandler, len(h))
+	for i, handler := range h {
+		handlers[i] = handler.WithAttrs(attrs)
+	}
+	return handlers
+}
+
+func (h fanoutHandler) WithGroup(name string) slog.Handler {
+	handlers := make(fanoutHandler, len(h))
+	for i, handler := range h {
+		handlers[i] = handler.WithGroup(name)
+	}
+	return handlers
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
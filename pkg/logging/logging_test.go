@@ -0,0 +1,87 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew_RedactsAndFiltersByLevel(t *testing.T) {
+	dir := t.TempDir()
+	var console bytes.Buffer
+	buffer := NewBuffer(10)
+	level := new(slog.LevelVar)
+	logger, closer, err := New(Options{Level: level, Dir: dir, Console: &console, Buffer: buffer})
+	require.NoError(t, err)
+
+	logger.Debug("dropped")
+	logger.With("profile", "p1").Info("connecting",
+		"password", "hunter2",
+		Metadata("headers", map[string][]string{"authorization": {"Bearer abc"}, "x-team": {"payments"}}))
+	level.Set(slog.LevelDebug)
+	logger.WithGroup("dial").Debug("resolved", "address", "10.0.0.1:443")
+	require.NoError(t, closer.Close())
+
+	data, err := os.ReadFile(filepath.Join(dir, FileName))
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 2)
+	var record map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &record))
+	assert.Equal(t, "connecting", record["msg"])
+	assert.Equal(t, Redacted, record["password"])
+	assert.Equal(t, map[string]any{"authorization": Redacted, "x-team": "payments"}, record["headers"])
+	assert.NotContains(t, string(data), "hunter2")
+	assert.NotContains(t, string(data), "Bearer abc")
+	assert.NotContains(t, console.String(), "hunter2")
+
+	entries := buffer.Entries()
+	require.Len(t, entries, 2)
+	assert.Equal(t, "INFO", entries[0].Level)
+	assert.Equal(t, map[string]string{
+		"profile":               "p1",
+		"password":              Redacted,
+		"headers.authorization": Redacted,
+		"headers.x-team":        "payments",
+	}, entries[0].Attrs)
+	assert.Equal(t, map[string]string{"dial.address": "10.0.0.1:443"}, entries[1].Attrs)
+}
+
+func TestBuffer_KeepsMostRecent(t *testing.T) {
+	buffer := NewBuffer(3)
+	var seen []string
+	buffer.SetListener(func(entry Entry) { seen = append(seen, entry.Message) })
+	logger, _, err := New(Options{Buffer: buffer})
+	require.NoError(t, err)
+	for _, message := range []string{"a", "b", "c", "d", "e"} {
+		logger.Info(message)
+	}
+
+	var messages []string
+	for _, entry := range buffer.Entries() {
+		messages = append(messages, entry.Message)
+	}
+	assert.Equal(t, []string{"c", "d", "e"}, messages)
+	assert.Equal(t, []string{"a", "b", "c", "d", "e"}, seen)
+}
+
+func TestParseLevel(t *testing.T) {
+	level, err := ParseLevel("warn")
+	require.NoError(t, err)
+	assert.Equal(t, slog.LevelWarn, level)
+	_, err = ParseLevel("loud")
+	assert.Error(t, err)
+}
+
+func TestDiscard(t *testing.T) {
+	assert.False(t, Discard().Enabled(context.Background(), slog.LevelError))
+	assert.NotNil(t, OrDiscard(nil))
+}
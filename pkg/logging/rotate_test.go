@@ -0,0 +1,44 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", FileName)
+	f, err := OpenRotatingFile(path, 10, 2)
+	require.NoError(t, err)
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		_, err := f.Write([]byte(line))
+		require.NoError(t, err)
+	}
+	require.NoError(t, f.Close())
+
+	read := func(path string) string {
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		return string(data)
+	}
+	assert.Equal(t, "fourth\n", read(path))
+	assert.Equal(t, "third\n", read(path+".1"))
+	assert.Equal(t, "second\n", read(path+".2"))
+	_, err = os.Stat(path + ".3")
+	assert.True(t, os.IsNotExist(err), "only two rotated files are kept")
+
+	// Reopening appends to the active file
+	f, err = OpenRotatingFile(path, 100, 2)
+	require.NoError(t, err)
+	_, err = f.Write([]byte("fifth\n"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	assert.Equal(t, []string{"fourth", "fifth"}, strings.Fields(read(path)))
+
+	_, err = f.Write([]byte("late\n"))
+	assert.ErrorIs(t, err, os.ErrClosed)
+}
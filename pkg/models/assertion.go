@@ -0,0 +1,68 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+)
+
+// AssertionType identifies what an assertion checks on a response
+type AssertionType string
+
+const (
+	// AssertionStatus checks the gRPC status code (e.g. "OK", "NOT_FOUND")
+	AssertionStatus AssertionType = "status"
+	// AssertionJSONPath checks a JSONPath expression against the response body
+	AssertionJSONPath AssertionType = "jsonpath"
+	// AssertionCEL evaluates a boolean CEL expression against the response
+	AssertionCEL AssertionType = "cel"
+	// AssertionHeader checks that a response header is present
+	AssertionHeader AssertionType = "header"
+	// AssertionTrailer checks that a response trailer is present
+	AssertionTrailer AssertionType = "trailer"
+	// AssertionLatency checks that the call completed under a threshold
+	AssertionLatency AssertionType = "latency"
+)
+
+// ErrInvalidAssertion is returned when an assertion is missing required values
+var ErrInvalidAssertion = errors.New("invalid assertion")
+
+// Assertion describes a single check run against a response
+type Assertion struct {
+	Type AssertionType `json:"type"`
+	// Expression is the JSONPath or CEL expression, or the header/trailer key
+	Expression string `json:"expression,omitempty"`
+	// Expected is the expected value. For status assertions it is the code name
+	// or number; for jsonpath, header and trailer assertions an empty value
+	// only checks for presence.
+	Expected string `json:"expected,omitempty"`
+	// MaxLatencyMs is the threshold for latency assertions
+	MaxLatencyMs int64 `json:"maxLatencyMs,omitempty"`
+}
+
+// Validate checks that the assertion has the values its type requires
+func (a Assertion) Validate() error {
+	switch a.Type {
+	case AssertionStatus:
+		if a.Expected == "" {
+			return fmt.Errorf("%w: status assertion requires an expected code", ErrInvalidAssertion)
+		}
+	case AssertionJSONPath, AssertionCEL, AssertionHeader, AssertionTrailer:
+		if a.Expression == "" {
+			return fmt.Errorf("%w: %s assertion requires an expression", ErrInvalidAssertion, a.Type)
+		}
+	case AssertionLatency:
+		if a.MaxLatencyMs <= 0 {
+			return fmt.Errorf("%w: latency assertion requires a positive threshold", ErrInvalidAssertion)
+		}
+	default:
+		return fmt.Errorf("%w: unknown type %q", ErrInvalidAssertion, a.Type)
+	}
+	return nil
+}
+
+// AssertionResult is the outcome of evaluating an assertion
+type AssertionResult struct {
+	Assertion Assertion `json:"assertion"`
+	Passed    bool      `json:"passed"`
+	Message   string    `json:"message,omitempty"`
+}
@@ -0,0 +1,87 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+
+	"protodesk/pkg/secrets"
+)
+
+// AuthType identifies how a profile obtains the token it sends with each call
+type AuthType string
+
+const (
+	// AuthNone sends no token beyond the static profile headers
+	AuthNone AuthType = ""
+	// AuthOAuth2ClientCredentials fetches tokens with the OAuth2 client credentials grant
+	AuthOAuth2ClientCredentials AuthType = "oauth2_client_credentials"
+	// AuthOAuth2RefreshToken exchanges a long-lived refresh token for access tokens
+	AuthOAuth2RefreshToken AuthType = "oauth2_refresh_token"
+	// AuthCommand runs a local command that prints a token, e.g. `gcloud auth print-access-token`
+	AuthCommand AuthType = "command"
+)
+
+// ErrInvalidAuthConfig is returned when an auth provider is missing required values
+var ErrInvalidAuthConfig = errors.New("invalid auth configuration")
+
+// AuthConfig configures the auth provider of a server profile
+type AuthConfig struct {
+	Type AuthType `json:"type"`
+
+	// TokenURL, ClientID, ClientSecret, Scopes and EndpointParams configure
+	// the OAuth2 providers
+	TokenURL       string              `json:"tokenUrl,omitempty"`
+	ClientID       string              `json:"clientId,omitempty"`
+	ClientSecret   string              `json:"clientSecret,omitempty"`
+	Scopes         []string            `json:"scopes,omitempty"`
+	EndpointParams map[string][]string `json:"endpointParams,omitempty"`
+	// RefreshToken is exchanged for access tokens by the refresh-token provider
+	RefreshToken string `json:"refreshToken,omitempty"`
+
+	// Command and Args configure the command provider. The command prints
+	// either a bare token or an OAuth2 token response as JSON.
+	Command string   `json:"command,omitempty"`
+	Args    []string `json:"args,omitempty"`
+	// CacheSeconds is how long a command token without an expiry is reused
+	CacheSeconds int `json:"cacheSeconds,omitempty"`
+
+	// HeaderName is the metadata key the token is sent in (default authorization)
+	HeaderName string `json:"headerName,omitempty"`
+}
+
+// Validate checks that the config has the values its provider requires
+func (c *AuthConfig) Validate() error {
+	switch c.Type {
+	case AuthNone:
+	case AuthOAuth2ClientCredentials:
+		if c.TokenURL == "" || c.ClientID == "" {
+			return fmt.Errorf("%w: client credentials require a token URL and client ID", ErrInvalidAuthConfig)
+		}
+	case AuthOAuth2RefreshToken:
+		if c.TokenURL == "" || c.RefreshToken == "" {
+			return fmt.Errorf("%w: refresh token auth requires a token URL and refresh token", ErrInvalidAuthConfig)
+		}
+	case AuthCommand:
+		if c.Command == "" {
+			return fmt.Errorf("%w: command auth requires a command", ErrInvalidAuthConfig)
+		}
+	default:
+		return fmt.Errorf("%w: unknown type %q", ErrInvalidAuthConfig, c.Type)
+	}
+	if c.CacheSeconds < 0 {
+		return fmt.Errorf("%w: cache duration cannot be negative", ErrInvalidAuthConfig)
+	}
+	return nil
+}
+
+// Masked returns a copy of the config with the client secret and refresh
+// token masked
+func (c *AuthConfig) Masked() *AuthConfig {
+	if c == nil {
+		return nil
+	}
+	masked := *c
+	masked.ClientSecret = secrets.Mask(c.ClientSecret)
+	masked.RefreshToken = secrets.Mask(c.RefreshToken)
+	return &masked
+}
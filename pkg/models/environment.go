@@ -0,0 +1,71 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"protodesk/pkg/secrets"
+
+	"github.com/google/uuid"
+)
+
+// Environment is a named set of variables that requests can reference as
+// {{name}}. Values extracted from responses are written back to it.
+// Variables listed in Secrets are encrypted at rest and masked in exports.
+type Environment struct {
+	ID            string            `json:"id" db:"id"`
+	Name          string            `json:"name" db:"name"`
+	Variables     map[string]string `json:"variables" db:"-"`
+	VariablesJSON string            `json:"-" db:"variables_json"`
+	Secrets       []string          `json:"secrets" db:"-"`
+	SecretsJSON   string            `json:"-" db:"secrets_json"`
+	CreatedAt     time.Time         `json:"createdAt" db:"created_at"`
+	UpdatedAt     time.Time         `json:"updatedAt" db:"updated_at"`
+}
+
+// NewEnvironment creates a new environment with no variables
+func NewEnvironment(name string) *Environment {
+	now := time.Now()
+	return &Environment{
+		ID:        uuid.New().String(),
+		Name:      name,
+		Variables: map[string]string{},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// Validate checks if the environment has valid values
+func (e *Environment) Validate() error {
+	if e.Name == "" {
+		return ErrEmptyEnvironmentName
+	}
+	for name := range e.Variables {
+		if !IsValidVariableName(name) {
+			return fmt.Errorf("%w: %q", ErrInvalidVariableName, name)
+		}
+	}
+	return nil
+}
+
+// IsSecret reports whether a variable is marked as secret
+func (e *Environment) IsSecret(name string) bool {
+	return slices.Contains(e.Secrets, name)
+}
+
+// MaskVariables returns a copy of vars with the environment's secret
+// variables masked
+func (e *Environment) MaskVariables(vars map[string]string) map[string]string {
+	if vars == nil {
+		return nil
+	}
+	masked := make(map[string]string, len(vars))
+	for name, value := range vars {
+		if e.IsSecret(name) {
+			value = secrets.Mask(value)
+		}
+		masked[name] = value
+	}
+	return masked
+}
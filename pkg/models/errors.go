@@ -12,6 +12,46 @@ var (
 	// ErrInvalidPort is returned when the server port is invalid
 	ErrInvalidPort = errors.New("server port must be between 1 and 65535")
 
+	// ErrInvalidTargetKind is returned when the target kind is not tcp, unix or raw
+	ErrInvalidTargetKind = errors.New("invalid target kind")
+
+	// ErrEmptyTarget is returned when a unix or raw profile has no target
+	ErrEmptyTarget = errors.New("server target cannot be empty")
+
+	// ErrRelativeSocketPath is returned when a unix socket path is not absolute
+	ErrRelativeSocketPath = errors.New("unix socket path must be absolute")
+
+	// ErrInvalidProtocol is returned when the protocol is unknown or cannot
+	// reach the profile's target
+	ErrInvalidProtocol = errors.New("invalid protocol")
+
+	// ErrInvalidConnectTimeout is returned when the connect timeout is negative
+	ErrInvalidConnectTimeout = errors.New("connect timeout cannot be negative")
+
 	// ErrProfileNotFound is returned when a profile cannot be found
 	ErrProfileNotFound = errors.New("server profile not found")
+
+	// ErrEmptyCollectionName is returned when the collection name is empty
+	ErrEmptyCollectionName = errors.New("collection name cannot be empty")
+
+	// ErrCollectionNotFound is returned when a collection cannot be found
+	ErrCollectionNotFound = errors.New("collection not found")
+
+	// ErrEmptyRequestName is returned when the saved request name is empty
+	ErrEmptyRequestName = errors.New("saved request name cannot be empty")
+
+	// ErrEmptyMethod is returned when a saved request has no service or method
+	ErrEmptyMethod = errors.New("saved request must specify a service and method")
+
+	// ErrSavedRequestNotFound is returned when a saved request cannot be found
+	ErrSavedRequestNotFound = errors.New("saved request not found")
+
+	// ErrEmptyEnvironmentName is returned when the environment name is empty
+	ErrEmptyEnvironmentName = errors.New("environment name cannot be empty")
+
+	// ErrEnvironmentNotFound is returned when an environment cannot be found
+	ErrEnvironmentNotFound = errors.New("environment not found")
+
+	// ErrInvalidVariableName is returned when a variable name cannot be referenced as {{name}}
+	ErrInvalidVariableName = errors.New("invalid variable name")
 )
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// ExtractionSource identifies where an extraction rule reads its value from
+type ExtractionSource string
+
+const (
+	// ExtractFromJSONPath reads a value from the response body with JSONPath
+	ExtractFromJSONPath ExtractionSource = "jsonpath"
+	// ExtractFromHeader reads the first value of a response header
+	ExtractFromHeader ExtractionSource = "header"
+	// ExtractFromTrailer reads the first value of a response trailer
+	ExtractFromTrailer ExtractionSource = "trailer"
+)
+
+// ErrInvalidExtraction is returned when an extraction rule is incomplete
+var ErrInvalidExtraction = errors.New("invalid extraction rule")
+
+// variableNamePattern matches the names that can be referenced as {{name}}
+var variableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// ExtractionRule copies a value out of a response into a variable so that
+// later requests can reference it as {{variable}}
+type ExtractionRule struct {
+	Source ExtractionSource `json:"source"`
+	// Expression is the JSONPath expression or the header/trailer key
+	Expression string `json:"expression"`
+	// Variable is the name the extracted value is stored under
+	Variable string `json:"variable"`
+}
+
+// Validate checks that the rule has a source, an expression and a usable
+// variable name
+func (r ExtractionRule) Validate() error {
+	switch r.Source {
+	case ExtractFromJSONPath, ExtractFromHeader, ExtractFromTrailer:
+	default:
+		return fmt.Errorf("%w: unknown source %q", ErrInvalidExtraction, r.Source)
+	}
+	if r.Expression == "" {
+		return fmt.Errorf("%w: %s extraction requires an expression", ErrInvalidExtraction, r.Source)
+	}
+	if !IsValidVariableName(r.Variable) {
+		return fmt.Errorf("%w: invalid variable name %q", ErrInvalidExtraction, r.Variable)
+	}
+	return nil
+}
+
+// IsValidVariableName reports whether name can be referenced as {{name}}
+func IsValidVariableName(name string) bool {
+	return variableNamePattern.MatchString(name)
+}
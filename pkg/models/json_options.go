@@ -0,0 +1,24 @@
+package models
+
+// JSONOptions control how protobuf messages are written to and read from
+// JSON. Zero values keep the protobuf JSON mapping defaults.
+type JSONOptions struct {
+	// EmitDefaults writes fields that hold their default value, such as
+	// zero numbers, empty strings and empty lists
+	EmitDefaults bool `json:"emitDefaults,omitempty"`
+	// UseProtoNames writes the field names of the proto file instead of
+	// their lowerCamelCase JSON names
+	UseProtoNames bool `json:"useProtoNames,omitempty"`
+	// EnumsAsNumbers writes enum values as numbers instead of names
+	EnumsAsNumbers bool `json:"enumsAsNumbers,omitempty"`
+	// Indent writes responses over several indented lines
+	Indent bool `json:"indent,omitempty"`
+	// AllowUnknownFields ignores request fields the message does not define
+	// instead of rejecting the request
+	AllowUnknownFields bool `json:"allowUnknownFields,omitempty"`
+}
+
+// IsZero reports whether the options keep every default
+func (o *JSONOptions) IsZero() bool {
+	return o == nil || *o == JSONOptions{}
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+)
+
+// MessageFormat is the text format of a request body or rendered response
+type MessageFormat string
+
+const (
+	// FormatJSON is the protobuf JSON mapping; requests without a format
+	// use it
+	FormatJSON MessageFormat = "json"
+	// FormatPrototext is the protobuf text format
+	FormatPrototext MessageFormat = "prototext"
+	// FormatYAML is the protobuf JSON mapping written as YAML
+	FormatYAML MessageFormat = "yaml"
+)
+
+// ErrInvalidMessageFormat is returned when a message format is unknown
+var ErrInvalidMessageFormat = errors.New("invalid message format")
+
+// IsJSON reports whether the format is JSON, which an empty format means
+func (f MessageFormat) IsJSON() bool {
+	return f == "" || f == FormatJSON
+}
+
+// Validate checks that the format is known
+func (f MessageFormat) Validate() error {
+	switch f {
+	case "", FormatJSON, FormatPrototext, FormatYAML:
+		return nil
+	}
+	return fmt.Errorf("%w: %q", ErrInvalidMessageFormat, f)
+}
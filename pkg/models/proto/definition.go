@@ -25,8 +25,8 @@ type ProtoDefinition struct {
 	Error           string        `json:"error"`           // Parsing/validation error, if any
 
 	// New fields for enums and file options
-	Enums       []EnumType `json:"enums"`       // List of enums defined in the proto
-	FileOptions string     `json:"fileOptions"` // File-level options as string
+	Enums       []EnumType     `json:"enums"`                 // List of enums defined in the proto
+	FileOptions map[string]any `json:"fileOptions,omitempty"` // File-level options, custom options included
 
 	// DependencyGraph is built in-memory during parsing and is not persisted to the DB.
 	DependencyGraph map[string][]string `json:"dependencyGraph,omitempty"`
@@ -37,6 +37,9 @@ type Service struct {
 	Name        string   `json:"name"`        // Service name
 	Methods     []Method `json:"methods"`     // List of methods in the service
 	Description string   `json:"description"` // Service description from comments
+
+	Options  map[string]any `json:"options,omitempty"`  // Service options, custom options included
+	Comments *Comments      `json:"comments,omitempty"` // Comments the description is taken from
 }
 
 // Method represents a gRPC method in a service
@@ -47,6 +50,9 @@ type Method struct {
 	OutputType      MessageType `json:"outputType"`      // Output message type
 	ClientStreaming bool        `json:"clientStreaming"` // Whether the method is client streaming
 	ServerStreaming bool        `json:"serverStreaming"` // Whether the method is server streaming
+
+	Options  map[string]any `json:"options,omitempty"`  // Method options such as (google.api.http)
+	Comments *Comments      `json:"comments,omitempty"` // Comments the description is taken from
 }
 
 // MessageType represents a Protocol Buffer message type
@@ -54,6 +60,13 @@ type MessageType struct {
 	Name        string         `json:"name"`        // Type name
 	Fields      []MessageField `json:"fields"`      // List of fields in the message
 	Description string         `json:"description"` // Message description from comments
+
+	// Types declared inside the message, without the entries of map fields
+	Messages []MessageType `json:"messages,omitempty"` // Nested message types
+	Enums    []EnumType    `json:"enums,omitempty"`    // Nested enum types
+
+	Options  map[string]any `json:"options,omitempty"`  // Message options, custom options included
+	Comments *Comments      `json:"comments,omitempty"` // Comments the description is taken from
 }
 
 // MessageField represents a field in a Protocol Buffer message
@@ -65,13 +78,23 @@ type MessageField struct {
 	IsRequired  bool        `json:"isRequired"`  // Whether the field is required (proto2)
 	Description string      `json:"description"` // Field description from comments
 	Options     FieldOption `json:"options"`     // Field options
+
+	// Oneof membership, presence, maps and defaults
+	Oneof        string `json:"oneof,omitempty"`        // Name of the oneof the field belongs to
+	IsOptional   bool   `json:"isOptional,omitempty"`   // Whether the field is declared optional, tracking presence
+	IsMap        bool   `json:"isMap,omitempty"`        // Whether the field is a map
+	MapKeyType   string `json:"mapKeyType,omitempty"`   // Key type of a map field
+	MapValueType string `json:"mapValueType,omitempty"` // Value type of a map field
+	DefaultValue string `json:"defaultValue,omitempty"` // Explicit default value (proto2), in proto syntax
+
+	Comments *Comments `json:"comments,omitempty"` // Comments the description is taken from
 }
 
 // FieldOption represents options that can be set on a field
 type FieldOption struct {
 	Deprecated    bool           `json:"deprecated"`    // Whether the field is deprecated
 	JSONName      string         `json:"jsonName"`      // Custom JSON name for the field
-	CustomOptions map[string]any `json:"customOptions"` // Custom options defined for the field
+	CustomOptions map[string]any `json:"customOptions"` // Custom options set on the field, such as (google.api.field_behavior)
 }
 
 // EnumType represents a Protocol Buffer enum type
@@ -79,6 +102,8 @@ type EnumType struct {
 	Name        string      `json:"name"`        // Enum name
 	Values      []EnumValue `json:"values"`      // Enum values
 	Description string      `json:"description"` // Enum description from comments
+
+	Comments *Comments `json:"comments,omitempty"` // Comments the description is taken from
 }
 
 // EnumValue represents a value in a Protocol Buffer enum
@@ -86,6 +111,16 @@ type EnumValue struct {
 	Name        string `json:"name"`        // Value name
 	Number      int32  `json:"number"`      // Value number
 	Description string `json:"description"` // Value description from comments
+
+	Comments *Comments `json:"comments,omitempty"` // Comments the description is taken from
+}
+
+// Comments are the comments attached to a declaration in its proto file.
+// Descriptions are the leading comment or, without one, the trailing one.
+type Comments struct {
+	Leading  string   `json:"leading,omitempty"`  // Comment directly above the declaration
+	Trailing string   `json:"trailing,omitempty"` // Comment after the declaration, on its line or the next
+	Detached []string `json:"detached,omitempty"` // Comments above it separated by blank lines, as for file headers
 }
 
 // NewProtoDefinition creates a new ProtoDefinition instance
@@ -0,0 +1,273 @@
+package proto
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Describe fills in the options of a compiled proto file and the services,
+// messages and enums defined at its top level
+func (pd *ProtoDefinition) Describe(fd protoreflect.FileDescriptor) {
+	pd.FileOptions = decodeOptions(fd, false)
+	pd.Services = make([]Service, 0, fd.Services().Len())
+	for i := 0; i < fd.Services().Len(); i++ {
+		pd.Services = append(pd.Services, NewService(fd.Services().Get(i)))
+	}
+	pd.Messages = make([]MessageType, 0, fd.Messages().Len())
+	for i := 0; i < fd.Messages().Len(); i++ {
+		pd.Messages = append(pd.Messages, NewMessageType(fd.Messages().Get(i)))
+	}
+	pd.Enums = make([]EnumType, 0, fd.Enums().Len())
+	for i := 0; i < fd.Enums().Len(); i++ {
+		pd.Enums = append(pd.Enums, NewEnumType(fd.Enums().Get(i)))
+	}
+}
+
+// NewService describes a service and the input and output types of its
+// methods
+func NewService(sd protoreflect.ServiceDescriptor) Service {
+	service := Service{
+		Name:    string(sd.FullName()),
+		Methods: make([]Method, 0, sd.Methods().Len()),
+		Options: decodeOptions(sd, false),
+	}
+	service.Comments, service.Description = describeComments(sd)
+	for i := 0; i < sd.Methods().Len(); i++ {
+		md := sd.Methods().Get(i)
+		method := Method{
+			Name:            string(md.Name()),
+			InputType:       NewMessageType(md.Input()),
+			OutputType:      NewMessageType(md.Output()),
+			ClientStreaming: md.IsStreamingClient(),
+			ServerStreaming: md.IsStreamingServer(),
+			Options:         decodeOptions(md, false),
+		}
+		method.Comments, method.Description = describeComments(md)
+		service.Methods = append(service.Methods, method)
+	}
+	return service
+}
+
+// NewMessageType describes a message, its fields and the messages and enums
+// nested in it. Fields of message types refer to them by name only, so
+// recursive messages are described once.
+func NewMessageType(md protoreflect.MessageDescriptor) MessageType {
+	mt := MessageType{
+		Name:    string(md.FullName()),
+		Fields:  make([]MessageField, 0, md.Fields().Len()),
+		Options: decodeOptions(md, false),
+	}
+	mt.Comments, mt.Description = describeComments(md)
+	for i := 0; i < md.Fields().Len(); i++ {
+		mt.Fields = append(mt.Fields, NewMessageField(md.Fields().Get(i)))
+	}
+	for i := 0; i < md.Messages().Len(); i++ {
+		// The entry types of map fields are described by the fields
+		if nested := md.Messages().Get(i); !nested.IsMapEntry() {
+			mt.Messages = append(mt.Messages, NewMessageType(nested))
+		}
+	}
+	for i := 0; i < md.Enums().Len(); i++ {
+		mt.Enums = append(mt.Enums, NewEnumType(md.Enums().Get(i)))
+	}
+	return mt
+}
+
+// NewMessageField describes a field of a message. Map fields are not
+// repeated; their key and value types are given instead.
+func NewMessageField(fd protoreflect.FieldDescriptor) MessageField {
+	field := MessageField{
+		Name:       string(fd.Name()),
+		Number:     int32(fd.Number()),
+		Type:       fieldTypeName(fd),
+		IsRepeated: fd.IsList(),
+		IsRequired: fd.Cardinality() == protoreflect.Required,
+		Options: FieldOption{
+			JSONName:      fd.JSONName(),
+			CustomOptions: decodeOptions(fd, true),
+		},
+		IsOptional: fd.HasOptionalKeyword(),
+		IsMap:      fd.IsMap(),
+	}
+	field.Comments, field.Description = describeComments(fd)
+	// The oneof of a proto3 optional field is synthetic and not declared
+	if oneof := fd.ContainingOneof(); oneof != nil && !oneof.IsSynthetic() {
+		field.Oneof = string(oneof.Name())
+	}
+	if fd.IsMap() {
+		field.MapKeyType = fieldTypeName(fd.MapKey())
+		field.MapValueType = fieldTypeName(fd.MapValue())
+	}
+	if opts, ok := fd.Options().(*descriptorpb.FieldOptions); ok {
+		field.Options.Deprecated = opts.GetDeprecated()
+	}
+	if fd.HasDefault() {
+		field.DefaultValue = protodesc.ToFieldDescriptorProto(fd).GetDefaultValue()
+	}
+	return field
+}
+
+// NewEnumType describes an enum and its values
+func NewEnumType(ed protoreflect.EnumDescriptor) EnumType {
+	enum := EnumType{
+		Name:   string(ed.Name()),
+		Values: make([]EnumValue, 0, ed.Values().Len()),
+	}
+	enum.Comments, enum.Description = describeComments(ed)
+	for i := 0; i < ed.Values().Len(); i++ {
+		vd := ed.Values().Get(i)
+		value := EnumValue{
+			Name:   string(vd.Name()),
+			Number: int32(vd.Number()),
+		}
+		value.Comments, value.Description = describeComments(vd)
+		enum.Values = append(enum.Values, value)
+	}
+	return enum
+}
+
+// fieldTypeName returns the scalar type of a field, the full name of its
+// message or enum type, or map<key, value> for map fields
+func fieldTypeName(fd protoreflect.FieldDescriptor) string {
+	if fd.IsMap() {
+		return fmt.Sprintf("map<%s, %s>", fieldTypeName(fd.MapKey()), fieldTypeName(fd.MapValue()))
+	}
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return string(fd.Message().FullName())
+	case protoreflect.EnumKind:
+		return string(fd.Enum().FullName())
+	default:
+		return fd.Kind().String()
+	}
+}
+
+// describeComments returns the comments attached to a declaration and the
+// description taken from them, if the file was compiled with source info.
+// The source location is found by the declaration's path in the file.
+func describeComments(d protoreflect.Descriptor) (*Comments, string) {
+	loc := d.ParentFile().SourceLocations().ByDescriptor(d)
+	comments := Comments{
+		Leading:  cleanComment(loc.LeadingComments),
+		Trailing: cleanComment(loc.TrailingComments),
+	}
+	for _, detached := range loc.LeadingDetachedComments {
+		if text := cleanComment(detached); text != "" {
+			comments.Detached = append(comments.Detached, text)
+		}
+	}
+	if comments.Leading == "" && comments.Trailing == "" && len(comments.Detached) == 0 {
+		return nil, ""
+	}
+	description := comments.Leading
+	if description == "" {
+		description = comments.Trailing
+	}
+	return &comments, description
+}
+
+// cleanComment removes the space protoc keeps after the comment markers of
+// each line, and the blank lines around the comment
+func cleanComment(comment string) string {
+	lines := strings.Split(comment, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimPrefix(strings.TrimRight(line, " \t"), " ")
+	}
+	return strings.Trim(strings.Join(lines, "\n"), "\n")
+}
+
+// decodeOptions returns the options set on a declaration as JSON values,
+// keyed by their names in proto syntax: java_package or deprecated for
+// standard options and (google.api.http) for custom ones. Custom options are
+// decoded with the extensions of the declaring file and its imports. With
+// customOnly, standard options are left out.
+func decodeOptions(d protoreflect.Descriptor, customOnly bool) map[string]any {
+	opts := d.Options()
+	if opts == nil || !opts.ProtoReflect().IsValid() {
+		return nil
+	}
+	msg := proto.Clone(opts)
+	var types *protoregistry.Types
+	// Extensions the app does not link in are left as unknown fields
+	if len(msg.ProtoReflect().GetUnknown()) > 0 {
+		types = extensionTypes(d.ParentFile())
+		data, err := proto.Marshal(opts)
+		if err != nil {
+			return nil
+		}
+		msg = opts.ProtoReflect().Type().New().Interface()
+		if err := (proto.UnmarshalOptions{Resolver: types}).Unmarshal(data, msg); err != nil {
+			return nil
+		}
+	}
+	if customOnly {
+		m := msg.ProtoReflect()
+		m.Range(func(fd protoreflect.FieldDescriptor, _ protoreflect.Value) bool {
+			if !fd.IsExtension() {
+				m.Clear(fd)
+			}
+			return true
+		})
+	}
+
+	marshal := protojson.MarshalOptions{UseProtoNames: true}
+	if types != nil {
+		marshal.Resolver = types
+	}
+	data, err := marshal.Marshal(msg)
+	if err != nil {
+		return nil
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal(data, &decoded); err != nil || len(decoded) == 0 {
+		return nil
+	}
+	// protojson writes extensions as [name]; option syntax is (name)
+	for key, value := range decoded {
+		if name, ok := strings.CutPrefix(key, "["); ok {
+			delete(decoded, key)
+			decoded["("+strings.TrimSuffix(name, "]")+")"] = value
+		}
+	}
+	return decoded
+}
+
+// extensionTypes collects the extensions declared in a file and the files it
+// imports, which are those its custom options can use
+func extensionTypes(fd protoreflect.FileDescriptor) *protoregistry.Types {
+	types := new(protoregistry.Types)
+	seen := make(map[string]bool)
+	var visit func(file protoreflect.FileDescriptor)
+	visit = func(file protoreflect.FileDescriptor) {
+		if seen[file.Path()] {
+			return
+		}
+		seen[file.Path()] = true
+		registerExtensions(types, file.Extensions(), file.Messages())
+		for i := 0; i < file.Imports().Len(); i++ {
+			visit(file.Imports().Get(i).FileDescriptor)
+		}
+	}
+	visit(fd)
+	return types
+}
+
+// registerExtensions adds extensions and those nested in messages to types
+func registerExtensions(types *protoregistry.Types, exts protoreflect.ExtensionDescriptors, msgs protoreflect.MessageDescriptors) {
+	for i := 0; i < exts.Len(); i++ {
+		// A conflicting declaration keeps the first one
+		_ = types.RegisterExtension(dynamicpb.NewExtensionType(exts.Get(i)))
+	}
+	for i := 0; i < msgs.Len(); i++ {
+		registerExtensions(types, msgs.Get(i).Extensions(), msgs.Get(i).Messages())
+	}
+}
@@ -0,0 +1,311 @@
+package proto
+
+import (
+	"testing"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/protoparse"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+const catalogProto = `syntax = "proto3";
+package shop.v1;
+
+service Catalog {
+  rpc Search(Search.Query) returns (Search.Result);
+}
+
+message Search {
+  // Query selects products
+  message Query {
+    // Free text to match
+    string text = 1;
+    optional int32 limit = 2;
+    oneof scope {
+      string category = 3;
+      string brand = 4;
+    }
+    map<string, Sort> sort = 5;
+    repeated string tags = 6;
+  }
+  message Result {
+    repeated Search.Query products = 1;
+  }
+  enum Sort {
+    SORT_UNSPECIFIED = 0;
+    SORT_PRICE = 1;
+  }
+}
+
+message Node {
+  Node parent = 1;
+  repeated Node children = 2;
+}
+`
+
+const legacyProto = `syntax = "proto2";
+package shop.v1;
+
+message Legacy {
+  required string id = 1;
+  optional int32 page_size = 2 [default = 20];
+  optional string label = 3 [default = "none"];
+}
+`
+
+// compileTestFile compiles a proto file and links it the way the parsers
+// link the descriptor sets protoc writes
+func compileTestFile(t *testing.T, name string, files map[string]string) protoreflect.FileDescriptor {
+	t.Helper()
+	parser := protoparse.Parser{
+		Accessor:              protoparse.FileContentsFromMap(files),
+		IncludeSourceCodeInfo: true,
+	}
+	fds, err := parser.ParseFiles(name)
+	require.NoError(t, err)
+
+	set := &descriptorpb.FileDescriptorSet{}
+	seen := map[string]bool{}
+	var add func(fd *desc.FileDescriptor)
+	add = func(fd *desc.FileDescriptor) {
+		if seen[fd.GetName()] {
+			return
+		}
+		seen[fd.GetName()] = true
+		for _, dep := range fd.GetDependencies() {
+			add(dep)
+		}
+		set.File = append(set.File, fd.AsFileDescriptorProto())
+	}
+	add(fds[0])
+	data, err := proto.Marshal(set)
+	require.NoError(t, err)
+	set = &descriptorpb.FileDescriptorSet{}
+	require.NoError(t, proto.Unmarshal(data, set))
+	registry, err := protodesc.NewFiles(set)
+	require.NoError(t, err)
+	fd, err := registry.FindFileByPath(name)
+	require.NoError(t, err)
+	return fd
+}
+
+func TestProtoDefinition_Describe(t *testing.T) {
+	fd := compileTestFile(t, "catalog.proto", map[string]string{
+		"catalog.proto": catalogProto,
+	})
+	pd := NewProtoDefinition("/protos/catalog.proto", catalogProto)
+	pd.Describe(fd)
+
+	// Nested messages are found as method types
+	require.Len(t, pd.Services, 1)
+	method := pd.Services[0].Methods[0]
+	assert.Equal(t, "shop.v1.Catalog", pd.Services[0].Name)
+	assert.Equal(t, "shop.v1.Search.Query", method.InputType.Name)
+	assert.Equal(t, "shop.v1.Search.Result", method.OutputType.Name)
+	assert.Equal(t, "Query selects products", method.InputType.Description)
+
+	fields := method.InputType.Fields
+	require.Len(t, fields, 6)
+	assert.Equal(t, "Free text to match", fields[0].Description)
+	assert.False(t, fields[0].IsOptional)
+	assert.True(t, fields[1].IsOptional)
+	assert.Empty(t, fields[1].Oneof, "proto3 optional fields are not in a declared oneof")
+	assert.Equal(t, "scope", fields[2].Oneof)
+	assert.Equal(t, "scope", fields[3].Oneof)
+	assert.Equal(t, MessageField{
+		Name:         "sort",
+		Number:       5,
+		Type:         "map<string, shop.v1.Search.Sort>",
+		Options:      FieldOption{JSONName: "sort"},
+		IsMap:        true,
+		MapKeyType:   "string",
+		MapValueType: "shop.v1.Search.Sort",
+	}, fields[4])
+	assert.True(t, fields[5].IsRepeated)
+
+	// Top-level messages carry their nested types, without map entries
+	require.Len(t, pd.Messages, 2)
+	search := pd.Messages[0]
+	require.Len(t, search.Messages, 2)
+	assert.Equal(t, "shop.v1.Search.Query", search.Messages[0].Name)
+	assert.Empty(t, search.Messages[0].Messages)
+	require.Len(t, search.Enums, 1)
+	assert.Equal(t, "Sort", search.Enums[0].Name)
+	assert.Len(t, search.Enums[0].Values, 2)
+
+	// Recursive messages refer to themselves by name
+	node := pd.Messages[1]
+	assert.Equal(t, "shop.v1.Node", node.Fields[0].Type)
+	assert.Equal(t, "shop.v1.Node", node.Fields[1].Type)
+}
+
+func TestNewMessageType_Proto2(t *testing.T) {
+	fd := compileTestFile(t, "legacy.proto", map[string]string{"legacy.proto": legacyProto})
+	mt := NewMessageType(fd.Messages().Get(0))
+
+	require.Len(t, mt.Fields, 3)
+	assert.True(t, mt.Fields[0].IsRequired)
+	assert.Empty(t, mt.Fields[0].DefaultValue)
+	assert.Equal(t, "20", mt.Fields[1].DefaultValue)
+	assert.Equal(t, "pageSize", mt.Fields[1].Options.JSONName)
+	assert.Equal(t, "none", mt.Fields[2].DefaultValue)
+	assert.True(t, mt.Fields[2].IsOptional)
+}
+
+const commentedProto = `// Copyright notice, detached from the package
+
+syntax = "proto3";
+package notes.v1;
+
+// Notes stores notes
+// across devices
+service Notes {
+  // Archive hides a note
+  rpc Archive(Note) returns (Note); // Archived notes can be restored
+}
+
+// Obsolete comment, separated from Note
+
+// A single note
+message Note {
+  string id = 1; // Server assigned
+  // Current state
+  State state = 2;
+
+  enum State {
+    STATE_UNSPECIFIED = 0;
+    // Shown in lists
+    STATE_ACTIVE = 1;
+    STATE_ARCHIVED = 2; // Hidden from lists
+  }
+}
+`
+
+func TestProtoDefinition_DescribeComments(t *testing.T) {
+	fd := compileTestFile(t, "notes.proto", map[string]string{"notes.proto": commentedProto})
+	pd := NewProtoDefinition("/protos/notes.proto", commentedProto)
+	pd.Describe(fd)
+
+	require.Len(t, pd.Services, 1)
+	service := pd.Services[0]
+	assert.Equal(t, "Notes stores notes\nacross devices", service.Description)
+	assert.Equal(t, &Comments{Leading: "Notes stores notes\nacross devices"}, service.Comments)
+
+	method := service.Methods[0]
+	assert.Equal(t, "Archive hides a note", method.Description)
+	assert.Equal(t, &Comments{
+		Leading:  "Archive hides a note",
+		Trailing: "Archived notes can be restored",
+	}, method.Comments)
+
+	// Detached comments are kept apart from the description
+	note := pd.Messages[0]
+	assert.Equal(t, "A single note", note.Description)
+	assert.Equal(t, []string{"Obsolete comment, separated from Note"}, note.Comments.Detached)
+
+	// Trailing comments describe declarations without a leading one
+	require.Len(t, note.Fields, 2)
+	assert.Equal(t, "Server assigned", note.Fields[0].Description)
+	assert.Equal(t, "Current state", note.Fields[1].Description)
+
+	require.Len(t, note.Enums, 1)
+	values := note.Enums[0].Values
+	assert.Nil(t, note.Enums[0].Comments)
+	assert.Nil(t, values[0].Comments)
+	assert.Empty(t, values[0].Description)
+	assert.Equal(t, "Shown in lists", values[1].Description)
+	assert.Equal(t, "Hidden from lists", values[2].Description)
+}
+
+const annotationsProto = `syntax = "proto3";
+package acme.api;
+
+import "google/protobuf/descriptor.proto";
+
+message HttpRule {
+  string get = 1;
+  string post = 2;
+  string body = 3;
+}
+
+enum FieldBehavior {
+  FIELD_BEHAVIOR_UNSPECIFIED = 0;
+  REQUIRED = 2;
+}
+
+extend google.protobuf.FileOptions {
+  bool internal = 50001;
+}
+extend google.protobuf.ServiceOptions {
+  string default_host = 50002;
+}
+extend google.protobuf.MethodOptions {
+  HttpRule http = 50003;
+}
+extend google.protobuf.MessageOptions {
+  string resource = 50004;
+}
+extend google.protobuf.FieldOptions {
+  repeated FieldBehavior field_behavior = 50005;
+}
+`
+
+const ordersProto = `syntax = "proto3";
+package acme.v1;
+
+import "acme/api/annotations.proto";
+
+option go_package = "acme/v1;orders";
+option (acme.api.internal) = true;
+
+service Orders {
+  option (acme.api.default_host) = "orders.acme.dev";
+  rpc CreateOrder(CreateOrderRequest) returns (Order) {
+    option (acme.api.http) = { post: "/v1/orders" body: "*" };
+  }
+}
+
+message CreateOrderRequest {
+  string sku = 1 [(acme.api.field_behavior) = REQUIRED];
+  string note = 2 [deprecated = true];
+}
+
+message Order {
+  option (acme.api.resource) = "acme.dev/Order";
+  string id = 1;
+}
+`
+
+func TestProtoDefinition_DescribeOptions(t *testing.T) {
+	fd := compileTestFile(t, "acme/v1/orders.proto", map[string]string{
+		"acme/v1/orders.proto":       ordersProto,
+		"acme/api/annotations.proto": annotationsProto,
+	})
+	pd := NewProtoDefinition("/protos/acme/v1/orders.proto", ordersProto)
+	pd.Describe(fd)
+
+	assert.Equal(t, map[string]any{"go_package": "acme/v1;orders", "(acme.api.internal)": true}, pd.FileOptions)
+	require.Len(t, pd.Services, 1)
+	service := pd.Services[0]
+	assert.Equal(t, map[string]any{"(acme.api.default_host)": "orders.acme.dev"}, service.Options)
+	method := service.Methods[0]
+	assert.Equal(t, map[string]any{
+		"(acme.api.http)": map[string]any{"post": "/v1/orders", "body": "*"},
+	}, method.Options)
+
+	fields := method.InputType.Fields
+	assert.Equal(t, map[string]any{"(acme.api.field_behavior)": []any{"REQUIRED"}}, fields[0].Options.CustomOptions)
+	assert.False(t, fields[0].Options.Deprecated)
+	assert.True(t, fields[1].Options.Deprecated)
+	assert.Nil(t, fields[1].Options.CustomOptions, "standard options are not custom")
+	assert.Nil(t, method.InputType.Options)
+	assert.Equal(t, map[string]any{"(acme.api.resource)": "acme.dev/Order"}, method.OutputType.Options)
+
+	// Leaving out standard options does not change the compiled file
+	assert.True(t, fd.Messages().Get(0).Fields().Get(1).Options().(*descriptorpb.FieldOptions).GetDeprecated())
+}
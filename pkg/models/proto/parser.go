@@ -1,13 +1,14 @@
 package proto
 
 import (
-	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"log/slog"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"strings"
+
+	"protodesk/pkg/logging"
 
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/reflect/protodesc"
@@ -19,12 +20,15 @@ import (
 // Parser handles the parsing of proto files
 type Parser struct {
 	importPaths []string // List of paths to search for imports
+	logger      *slog.Logger
 }
 
-// NewParser creates a new Parser instance
-func NewParser(importPaths []string) *Parser {
+// NewParser creates a new Parser instance logging to logger; a nil logger
+// discards the output
+func NewParser(importPaths []string, logger *slog.Logger) *Parser {
 	return &Parser{
 		importPaths: importPaths,
+		logger:      logger,
 	}
 }
 
@@ -79,79 +83,8 @@ func (p *Parser) parseFileWithVisitedGraph(filePath string, visited map[string]b
 	pd.Imports = importList
 	pd.DependencyGraph = dependencyGraph
 
-	// Extract enums
-	enums := fileDesc.Enums()
-	for i := 0; i < enums.Len(); i++ {
-		enum := enums.Get(i)
-		enumType := EnumType{
-			Name:        string(enum.Name()),
-			Description: p.extractComments(enum.ParentFile(), int32(enum.Index())),
-		}
-		for j := 0; j < enum.Values().Len(); j++ {
-			val := enum.Values().Get(j)
-			enumType.Values = append(enumType.Values, EnumValue{
-				Name:        string(val.Name()),
-				Number:      int32(val.Number()),
-				Description: p.extractComments(val.ParentFile(), int32(val.Index())),
-			})
-		}
-		pd.Enums = append(pd.Enums, enumType)
-	}
-
-	// Extract file options
-	if fileDesc.Options() != nil {
-		if bytes, err := json.Marshal(fileDesc.Options()); err == nil {
-			pd.FileOptions = string(bytes)
-		} else {
-			pd.FileOptions = "<error marshaling file options>"
-		}
-	}
-
-	// Extract services
-	services := fileDesc.Services()
-	for i := 0; i < services.Len(); i++ {
-		svc := services.Get(i)
-		service := Service{
-			Name:        string(svc.FullName()),
-			Description: p.extractComments(svc.ParentFile(), int32(svc.Index())),
-		}
-
-		// Extract methods
-		methods := svc.Methods()
-		for j := 0; j < methods.Len(); j++ {
-			method := methods.Get(j)
-			methodDesc := Method{
-				Name:            string(method.Name()),
-				Description:     p.extractComments(method.ParentFile(), int32(method.Index())),
-				ClientStreaming: method.IsStreamingClient(),
-				ServerStreaming: method.IsStreamingServer(),
-			}
-
-			// Extract input type
-			inputType := method.Input()
-			if inputType != nil {
-				methodDesc.InputType = p.extractMessageType(inputType)
-			}
-
-			// Extract output type
-			outputType := method.Output()
-			if outputType != nil {
-				methodDesc.OutputType = p.extractMessageType(outputType)
-			}
-
-			service.Methods = append(service.Methods, methodDesc)
-		}
-
-		pd.AddService(service)
-	}
-
-	// Extract messages
-	messages := fileDesc.Messages()
-	for i := 0; i < messages.Len(); i++ {
-		msg := messages.Get(i)
-		messageType := p.extractMessageType(msg)
-		pd.Messages = append(pd.Messages, messageType)
-	}
+	// Extract options, services, messages and enums
+	pd.Describe(fileDesc)
 
 	return pd, nil
 }
@@ -218,6 +151,7 @@ func (p *Parser) parseProtoFile(filePath string, content []byte) (protoreflect.F
 		"--proto_path=" + tmpDir,  // Add the temp directory
 		"--descriptor_set_out=" + filepath.Join(tmpDir, "descriptor.pb"),
 		"--include_imports",
+		"--include_source_info",
 	}
 
 	// Add all import paths
@@ -228,7 +162,7 @@ func (p *Parser) parseProtoFile(filePath string, content []byte) (protoreflect.F
 	// Add the main proto file
 	args = append(args, tmpFile)
 
-	fmt.Printf("[DEBUG] Running protoc with args: %v\n", args)
+	logging.OrDiscard(p.logger).Debug("Running protoc", "args", args)
 
 	// Run protoc and surface errors clearly
 	cmd := exec.Command("protoc", args...)
@@ -319,72 +253,3 @@ func (p *Parser) resolveImport(importPath string) (*descriptorpb.FileDescriptorP
 
 	return nil, fmt.Errorf("import %s not found", importPath)
 }
-
-// extractMessageType extracts message type information from a MessageDescriptor
-func (p *Parser) extractMessageType(msg protoreflect.MessageDescriptor) MessageType {
-	mt := MessageType{
-		Name:        string(msg.FullName()),
-		Description: p.extractComments(msg.ParentFile(), int32(msg.Index())),
-		Fields:      make([]MessageField, 0),
-	}
-
-	// Get all fields from the message
-	fields := msg.Fields()
-	for i := 0; i < fields.Len(); i++ {
-		field := fields.Get(i)
-		mf := MessageField{
-			Name:        string(field.Name()),
-			Number:      int32(field.Number()),
-			Type:        p.getFieldTypeName(field),
-			IsRepeated:  field.Cardinality() == protoreflect.Repeated,
-			IsRequired:  field.Cardinality() == protoreflect.Required,
-			Description: p.extractComments(field.ParentFile(), int32(field.Index())),
-			Options: FieldOption{
-				JSONName: string(field.JSONName()),
-			},
-		}
-
-		// If this is a message type, recursively extract its fields
-		if field.Kind() == protoreflect.MessageKind {
-			msgType := field.Message()
-			if msgType != nil {
-				// Only extract fields if it's not a well-known type
-				if !strings.HasPrefix(string(msgType.FullName()), "google.protobuf.") {
-					nestedMsg := p.extractMessageType(msgType)
-					mf.Type = nestedMsg.Name
-				}
-			}
-		}
-
-		mt.Fields = append(mt.Fields, mf)
-	}
-
-	return mt
-}
-
-// getFieldTypeName returns a string representation of the field type
-func (p *Parser) getFieldTypeName(field protoreflect.FieldDescriptor) string {
-	if field.IsMap() {
-		keyType := p.getFieldTypeName(field.MapKey())
-		valueType := p.getFieldTypeName(field.MapValue())
-		return fmt.Sprintf("map<%s, %s>", keyType, valueType)
-	}
-
-	switch field.Kind() {
-	case protoreflect.MessageKind, protoreflect.GroupKind:
-		return string(field.Message().FullName())
-	case protoreflect.EnumKind:
-		return string(field.Enum().FullName())
-	default:
-		return field.Kind().String()
-	}
-}
-
-// extractComments extracts comments from the source location
-func (p *Parser) extractComments(file protoreflect.FileDescriptor, index int32) string {
-	// This is a placeholder. In a real implementation, we would:
-	// 1. Get the source code info from the FileDescriptorProto
-	// 2. Find the location for the given path
-	// 3. Extract and format the comments
-	return ""
-}
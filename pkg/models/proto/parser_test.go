@@ -62,13 +62,13 @@ service TestService {
 	require.Len(t, result.Services, 1)
 	service := result.Services[0]
 	assert.Equal(t, "TestService", service.Name)
-	assert.Equal(t, "", service.Description)
+	assert.Equal(t, "TestService is a test service", service.Description)
 
 	// Verify method
 	require.Len(t, service.Methods, 1)
 	method := service.Methods[0]
 	assert.Equal(t, "TestMethod", method.Name)
-	assert.Equal(t, "", method.Description)
+	assert.Equal(t, "TestMethod is a test method", method.Description)
 	assert.Equal(t, "Empty", method.InputType.Name)
 	assert.Equal(t, "Empty", method.OutputType.Name)
 }
@@ -204,7 +204,7 @@ service MainService {
 	err = os.WriteFile(mainProtoFile, []byte(mainProto), 0644)
 	require.NoError(t, err)
 
-	parser := NewParser([]string{tmpDir})
+	parser := NewParser([]string{tmpDir}, nil)
 	result, err := parser.ParseFile(mainProtoFile)
 	require.NoError(t, err)
 	require.NotNil(t, result)
@@ -278,7 +278,7 @@ func TestParser_CircularImportChain(t *testing.T) {
 	require.NoError(t, os.WriteFile(bFile, []byte(bProto), 0644))
 	require.NoError(t, os.WriteFile(cFile, []byte(cProto), 0644))
 
-	parser := NewParser([]string{tmpDir})
+	parser := NewParser([]string{tmpDir}, nil)
 	_, err = parser.ParseFile(aFile)
 	assert.Error(t, err)
 	// Check for protoc's recursive import error message
@@ -294,7 +294,7 @@ func TestParser_MalformedFieldType(t *testing.T) {
 	file := filepath.Join(tmpDir, "bad.proto")
 	require.NoError(t, os.WriteFile(file, []byte(malformedProto), 0644))
 
-	parser := NewParser([]string{tmpDir})
+	parser := NewParser([]string{tmpDir}, nil)
 	_, err = parser.ParseFile(file)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "protoc failed")
@@ -318,7 +318,7 @@ func TestParser_LargeProtoFile(t *testing.T) {
 	file := filepath.Join(tmpDir, "large.proto")
 	require.NoError(t, os.WriteFile(file, []byte(largeProto), 0644))
 
-	parser := NewParser([]string{tmpDir})
+	parser := NewParser([]string{tmpDir}, nil)
 	result, err := parser.ParseFile(file)
 	require.NoError(t, err)
 	require.NotNil(t, result)
@@ -343,7 +343,7 @@ func TestParser_ImportFromMultipleLocations(t *testing.T) {
 	mainFile := filepath.Join(tmpDir1, "main.proto")
 	require.NoError(t, os.WriteFile(mainFile, []byte(mainProto), 0644))
 
-	parser := NewParser([]string{tmpDir1, tmpDir2})
+	parser := NewParser([]string{tmpDir1, tmpDir2}, nil)
 	result, err := parser.ParseFile(mainFile)
 	require.NoError(t, err)
 	require.NotNil(t, result)
@@ -359,7 +359,7 @@ func TestParser_EnumExtractionEdgeCase(t *testing.T) {
 	file := filepath.Join(tmpDir, "enum.proto")
 	require.NoError(t, os.WriteFile(file, []byte(protoContent), 0644))
 
-	parser := NewParser([]string{tmpDir})
+	parser := NewParser([]string{tmpDir}, nil)
 	result, err := parser.ParseFile(file)
 	require.NoError(t, err)
 	require.NotNil(t, result)
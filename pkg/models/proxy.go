@@ -0,0 +1,70 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"protodesk/pkg/secrets"
+)
+
+// ProxyMode selects how a profile reaches its server through a proxy
+type ProxyMode string
+
+const (
+	// ProxyNone connects directly
+	ProxyNone ProxyMode = ""
+	// ProxyEnvironment uses HTTPS_PROXY and NO_PROXY; HTTPS_PROXY may be an
+	// http:// or socks5:// URL
+	ProxyEnvironment ProxyMode = "environment"
+	// ProxyHTTP tunnels through an HTTP proxy with CONNECT
+	ProxyHTTP ProxyMode = "http"
+	// ProxySOCKS5 tunnels through a SOCKS5 proxy
+	ProxySOCKS5 ProxyMode = "socks5"
+)
+
+// ErrInvalidProxyConfig is returned when a proxy is missing required values
+var ErrInvalidProxyConfig = errors.New("invalid proxy configuration")
+
+// ProxyConfig configures the proxy of a server profile. The password is
+// encrypted at rest and masked in exports.
+type ProxyConfig struct {
+	Mode ProxyMode `json:"mode"`
+	// Address is the host:port of an http or socks5 proxy
+	Address  string `json:"address,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+// IsDirect reports whether the config leaves connections unproxied
+func (c *ProxyConfig) IsDirect() bool {
+	return c == nil || c.Mode == ProxyNone
+}
+
+// Validate checks that the config has the values its mode requires
+func (c *ProxyConfig) Validate() error {
+	switch c.Mode {
+	case ProxyNone, ProxyEnvironment:
+		return nil
+	case ProxyHTTP, ProxySOCKS5:
+		if _, port, err := net.SplitHostPort(c.Address); err != nil || port == "" {
+			return fmt.Errorf("%w: proxy address must be host:port", ErrInvalidProxyConfig)
+		}
+		if c.Password != "" && c.Username == "" {
+			return fmt.Errorf("%w: a proxy password requires a username", ErrInvalidProxyConfig)
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w: unknown mode %q", ErrInvalidProxyConfig, c.Mode)
+	}
+}
+
+// Masked returns a copy of the config with the password masked
+func (c *ProxyConfig) Masked() *ProxyConfig {
+	if c == nil {
+		return nil
+	}
+	masked := *c
+	masked.Password = secrets.Mask(c.Password)
+	return &masked
+}
@@ -0,0 +1,107 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Collection groups saved requests so they can be run together
+type Collection struct {
+	ID          string    `json:"id" db:"id"`
+	Name        string    `json:"name" db:"name"`
+	Description string    `json:"description" db:"description"`
+	CreatedAt   time.Time `json:"createdAt" db:"created_at"`
+	UpdatedAt   time.Time `json:"updatedAt" db:"updated_at"`
+}
+
+// NewCollection creates a new collection with default values
+func NewCollection(name, description string) *Collection {
+	now := time.Now()
+	return &Collection{
+		ID:          uuid.New().String(),
+		Name:        name,
+		Description: description,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+}
+
+// Validate checks if the collection has valid values
+func (c *Collection) Validate() error {
+	if c.Name == "" {
+		return ErrEmptyCollectionName
+	}
+	return nil
+}
+
+// SavedRequest is a stored gRPC call that can be replayed and asserted on.
+// RequestJSON holds the request body in RequestFormat.
+type SavedRequest struct {
+	ID              string           `json:"id" db:"id"`
+	CollectionID    string           `json:"collectionId" db:"collection_id"`
+	ServerProfileID string           `json:"serverProfileId" db:"server_profile_id"`
+	Name            string           `json:"name" db:"name"`
+	ServiceName     string           `json:"serviceName" db:"service_name"`
+	MethodName      string           `json:"methodName" db:"method_name"`
+	RequestJSON     string           `json:"requestJson" db:"request_json"`
+	RequestFormat   MessageFormat    `json:"requestFormat,omitempty" db:"request_format"`
+	ResponseFormat  MessageFormat    `json:"responseFormat,omitempty" db:"response_format"`
+	HeadersJSON     string           `json:"headersJson" db:"headers_json"`
+	Position        int              `json:"position" db:"position"`
+	Assertions      []Assertion      `json:"assertions" db:"-"`
+	AssertionsJSON  string           `json:"-" db:"assertions_json"`
+	Extractions     []ExtractionRule `json:"extractions" db:"-"`
+	ExtractionsJSON string           `json:"-" db:"extractions_json"`
+	CreatedAt       time.Time        `json:"createdAt" db:"created_at"`
+	UpdatedAt       time.Time        `json:"updatedAt" db:"updated_at"`
+}
+
+// NewSavedRequest creates a new saved request with default values
+func NewSavedRequest(collectionID, serverProfileID, name, serviceName, methodName string) *SavedRequest {
+	now := time.Now()
+	return &SavedRequest{
+		ID:              uuid.New().String(),
+		CollectionID:    collectionID,
+		ServerProfileID: serverProfileID,
+		Name:            name,
+		ServiceName:     serviceName,
+		MethodName:      methodName,
+		RequestJSON:     "{}",
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+}
+
+// Validate checks if the saved request has valid values
+func (r *SavedRequest) Validate() error {
+	if r.Name == "" {
+		return ErrEmptyRequestName
+	}
+	if r.CollectionID == "" {
+		return ErrCollectionNotFound
+	}
+	if r.ServerProfileID == "" {
+		return ErrProfileNotFound
+	}
+	if r.ServiceName == "" || r.MethodName == "" {
+		return ErrEmptyMethod
+	}
+	if err := r.RequestFormat.Validate(); err != nil {
+		return err
+	}
+	if err := r.ResponseFormat.Validate(); err != nil {
+		return err
+	}
+	for _, a := range r.Assertions {
+		if err := a.Validate(); err != nil {
+			return err
+		}
+	}
+	for _, e := range r.Extractions {
+		if err := e.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
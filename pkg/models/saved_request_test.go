@@ -0,0 +1,52 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSavedRequest_Validate(t *testing.T) {
+	valid := NewSavedRequest("collection-id", "profile-id", "get user", "test.Users", "Get")
+	assert.NoError(t, valid.Validate())
+	assert.Equal(t, "{}", valid.RequestJSON)
+
+	noName := *valid
+	noName.Name = ""
+	assert.Equal(t, ErrEmptyRequestName, noName.Validate())
+
+	noMethod := *valid
+	noMethod.MethodName = ""
+	assert.Equal(t, ErrEmptyMethod, noMethod.Validate())
+
+	badAssertion := *valid
+	badAssertion.Assertions = []Assertion{{Type: AssertionLatency}}
+	assert.ErrorIs(t, badAssertion.Validate(), ErrInvalidAssertion)
+}
+
+func TestAssertion_Validate(t *testing.T) {
+	tests := []struct {
+		name      string
+		assertion Assertion
+		wantErr   bool
+	}{
+		{"status", Assertion{Type: AssertionStatus, Expected: "OK"}, false},
+		{"status without code", Assertion{Type: AssertionStatus}, true},
+		{"jsonpath", Assertion{Type: AssertionJSONPath, Expression: "$.id"}, false},
+		{"cel without expression", Assertion{Type: AssertionCEL}, true},
+		{"header", Assertion{Type: AssertionHeader, Expression: "content-type"}, false},
+		{"latency", Assertion{Type: AssertionLatency, MaxLatencyMs: 100}, false},
+		{"latency without threshold", Assertion{Type: AssertionLatency}, true},
+		{"unknown type", Assertion{Type: "bogus"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.assertion.Validate()
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidAssertion)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
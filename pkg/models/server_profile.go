@@ -1,30 +1,149 @@
 package models
 
 import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
+	"protodesk/pkg/secrets"
+
 	"github.com/google/uuid"
 )
 
-// Header represents a key-value pair for headers
+// Header represents a key-value pair for headers. Secret header values are
+// encrypted at rest and masked in exports.
 type Header struct {
-	Key   string `json:"key"`
-	Value string `json:"value"`
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Secret bool   `json:"secret,omitempty"`
 }
 
+// MaskHeaders returns a copy of headers with secret values masked
+func MaskHeaders(headers []Header) []Header {
+	if headers == nil {
+		return nil
+	}
+	masked := make([]Header, len(headers))
+	for i, h := range headers {
+		if h.Secret {
+			h.Value = secrets.Mask(h.Value)
+		}
+		masked[i] = h
+	}
+	return masked
+}
+
+// TargetKind selects how a server profile addresses its server
+type TargetKind string
+
+const (
+	// TargetTCP dials Host and Port; profiles without a kind are tcp
+	TargetTCP TargetKind = "tcp"
+	// TargetUnix dials the Unix domain socket at Target
+	TargetUnix TargetKind = "unix"
+	// TargetRaw passes Target to gRPC unchanged, e.g. dns:///host:port
+	TargetRaw TargetKind = "raw"
+)
+
+// Protocol selects the wire protocol a profile speaks to its server
+type Protocol string
+
+const (
+	// ProtocolGRPC is native gRPC over HTTP/2; profiles without a protocol
+	// speak native gRPC
+	ProtocolGRPC Protocol = "grpc"
+	// ProtocolGRPCWeb is gRPC-Web with binary message frames
+	ProtocolGRPCWeb Protocol = "grpc-web"
+	// ProtocolGRPCWebText is gRPC-Web with base64 encoded frames
+	ProtocolGRPCWebText Protocol = "grpc-web-text"
+	// ProtocolConnect is the Connect protocol with binary protobuf messages
+	ProtocolConnect Protocol = "connect"
+	// ProtocolConnectJSON is the Connect protocol with JSON messages
+	ProtocolConnectJSON Protocol = "connect-json"
+)
+
 // ServerProfile represents a gRPC server connection profile
 type ServerProfile struct {
-	ID              string    `json:"id" db:"id"`
-	Name            string    `json:"name" db:"name"`
-	Host            string    `json:"host" db:"host"`
-	Port            int       `json:"port" db:"port"`
-	TLSEnabled      bool      `json:"tlsEnabled" db:"tls_enabled"`
-	CertificatePath *string   `json:"certificatePath,omitempty" db:"certificate_path"`
-	UseReflection   bool      `json:"useReflection" db:"use_reflection"`
-	CreatedAt       time.Time `json:"createdAt" db:"created_at"`
-	UpdatedAt       time.Time `json:"updatedAt" db:"updated_at"`
-	Headers         []Header  `json:"headers" db:"-"`
-	HeadersJSON     string    `json:"headers_json" db:"headers_json"`
+	ID              string      `json:"id" db:"id"`
+	Name            string      `json:"name" db:"name"`
+	Host            string      `json:"host" db:"host"`
+	Port            int         `json:"port" db:"port"`
+	TLSEnabled      bool        `json:"tlsEnabled" db:"tls_enabled"`
+	CertificatePath *string     `json:"certificatePath,omitempty" db:"certificate_path"`
+	UseReflection   bool        `json:"useReflection" db:"use_reflection"`
+	CreatedAt       time.Time   `json:"createdAt" db:"created_at"`
+	UpdatedAt       time.Time   `json:"updatedAt" db:"updated_at"`
+	Headers         []Header    `json:"headers" db:"-"`
+	HeadersJSON     string      `json:"headers_json" db:"headers_json"`
+	Auth            *AuthConfig `json:"auth,omitempty" db:"-"`
+	AuthJSON        string      `json:"-" db:"auth_json"`
+	// ConnectTimeoutMs bounds how long a connection may take to become
+	// ready; zero uses DefaultConnectTimeout
+	ConnectTimeoutMs int `json:"connectTimeoutMs,omitempty" db:"connect_timeout_ms"`
+	// Transport tunes keepalive, message sizes, compression and flow control
+	Transport     *TransportSettings `json:"transport,omitempty" db:"-"`
+	TransportJSON string             `json:"-" db:"transport_json"`
+	// TargetKind and Target address servers that are not reached by Host
+	// and Port: Target is the socket path for unix and the gRPC target
+	// string for raw
+	TargetKind TargetKind `json:"targetKind,omitempty" db:"target_kind"`
+	Target     string     `json:"target,omitempty" db:"target"`
+	// Authority overrides the :authority header, e.g. for servers behind an
+	// ingress that routes by host name
+	Authority string `json:"authority,omitempty" db:"authority"`
+	// Protocol is the wire protocol of the server. gRPC-Web and Connect
+	// servers are called over plain HTTP requests; a raw target is then
+	// the base URL of the server.
+	Protocol Protocol `json:"protocol,omitempty" db:"protocol"`
+	// Proxy routes the connection through an HTTP or SOCKS5 proxy
+	Proxy     *ProxyConfig `json:"proxy,omitempty" db:"-"`
+	ProxyJSON string       `json:"-" db:"proxy_json"`
+	// JSONOptions are the defaults for how requests to the server are read
+	// from JSON and responses written to it
+	JSONOptions     *JSONOptions `json:"jsonOptions,omitempty" db:"-"`
+	JSONOptionsJSON string       `json:"-" db:"json_options_json"`
+}
+
+// DefaultConnectTimeout is how long a connection may take to become ready
+// when the profile does not set a timeout
+const DefaultConnectTimeout = 10 * time.Second
+
+// IsTCP reports whether the server is addressed by host and port
+func (s *ServerProfile) IsTCP() bool {
+	return s.TargetKind == "" || s.TargetKind == TargetTCP
+}
+
+// DialTarget returns the gRPC target string of the server
+func (s *ServerProfile) DialTarget() string {
+	switch s.TargetKind {
+	case TargetUnix:
+		// A leading @ names a socket in the abstract namespace
+		if name, ok := strings.CutPrefix(s.Target, "@"); ok {
+			return "unix-abstract:" + name
+		}
+		return "unix://" + s.Target
+	case TargetRaw:
+		return s.Target
+	default:
+		return net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
+	}
+}
+
+// IsNativeGRPC reports whether the server speaks native gRPC
+func (s *ServerProfile) IsNativeGRPC() bool {
+	return s.Protocol == "" || s.Protocol == ProtocolGRPC
+}
+
+// ConnectTimeout returns how long a connection to the server may take to
+// become ready
+func (s *ServerProfile) ConnectTimeout() time.Duration {
+	if s.ConnectTimeoutMs <= 0 {
+		return DefaultConnectTimeout
+	}
+	return time.Duration(s.ConnectTimeoutMs) * time.Millisecond
 }
 
 // NewServerProfile creates a new server profile with default values
@@ -46,11 +165,54 @@ func (s *ServerProfile) Validate() error {
 	if s.Name == "" {
 		return ErrEmptyName
 	}
-	if s.Host == "" {
-		return ErrEmptyHost
+	switch s.TargetKind {
+	case "", TargetTCP:
+		if s.Host == "" {
+			return ErrEmptyHost
+		}
+		if s.Port < 1 || s.Port > 65535 {
+			return ErrInvalidPort
+		}
+	case TargetUnix:
+		if s.Target == "" {
+			return ErrEmptyTarget
+		}
+		if !strings.HasPrefix(s.Target, "@") && !filepath.IsAbs(s.Target) {
+			return ErrRelativeSocketPath
+		}
+	case TargetRaw:
+		if s.Target == "" {
+			return ErrEmptyTarget
+		}
+	default:
+		return fmt.Errorf("%w: %q", ErrInvalidTargetKind, s.TargetKind)
+	}
+	switch s.Protocol {
+	case "", ProtocolGRPC:
+	case ProtocolGRPCWeb, ProtocolGRPCWebText, ProtocolConnect, ProtocolConnectJSON:
+		if s.TargetKind == TargetRaw && !strings.HasPrefix(s.Target, "http://") && !strings.HasPrefix(s.Target, "https://") {
+			return fmt.Errorf("%w: the raw target of a %s profile must be an http:// or https:// URL", ErrInvalidProtocol, s.Protocol)
+		}
+	default:
+		return fmt.Errorf("%w: %q", ErrInvalidProtocol, s.Protocol)
+	}
+	if s.ConnectTimeoutMs < 0 {
+		return ErrInvalidConnectTimeout
+	}
+	if s.Auth != nil {
+		if err := s.Auth.Validate(); err != nil {
+			return err
+		}
+	}
+	if s.Transport != nil {
+		if err := s.Transport.Validate(); err != nil {
+			return err
+		}
 	}
-	if s.Port < 1 || s.Port > 65535 {
-		return ErrInvalidPort
+	if s.Proxy != nil {
+		if err := s.Proxy.Validate(); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -2,6 +2,7 @@ package models
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -80,6 +81,17 @@ func TestServerProfile_Validate(t *testing.T) {
 			},
 			wantErr: ErrInvalidPort,
 		},
+		{
+			name: "negative connect timeout",
+			profile: &ServerProfile{
+				ID:               "test-id",
+				Name:             "test-server",
+				Host:             "localhost",
+				Port:             50051,
+				ConnectTimeoutMs: -1,
+			},
+			wantErr: ErrInvalidConnectTimeout,
+		},
 		{
 			name: "valid with TLS",
 			profile: &ServerProfile{
@@ -92,6 +104,54 @@ func TestServerProfile_Validate(t *testing.T) {
 			},
 			wantErr: nil,
 		},
+		{
+			name: "unix socket without host and port",
+			profile: &ServerProfile{
+				ID:         "test-id",
+				Name:       "sidecar",
+				TargetKind: TargetUnix,
+				Target:     "/var/run/sidecar.sock",
+			},
+			wantErr: nil,
+		},
+		{
+			name: "unix socket without path",
+			profile: &ServerProfile{
+				ID:         "test-id",
+				Name:       "sidecar",
+				TargetKind: TargetUnix,
+			},
+			wantErr: ErrEmptyTarget,
+		},
+		{
+			name: "relative unix socket path",
+			profile: &ServerProfile{
+				ID:         "test-id",
+				Name:       "sidecar",
+				TargetKind: TargetUnix,
+				Target:     "run/sidecar.sock",
+			},
+			wantErr: ErrRelativeSocketPath,
+		},
+		{
+			name: "raw target",
+			profile: &ServerProfile{
+				ID:         "test-id",
+				Name:       "balanced",
+				TargetKind: TargetRaw,
+				Target:     "dns:///api.example.com:443",
+			},
+			wantErr: nil,
+		},
+		{
+			name: "raw target without target",
+			profile: &ServerProfile{
+				ID:         "test-id",
+				Name:       "balanced",
+				TargetKind: TargetRaw,
+			},
+			wantErr: ErrEmptyTarget,
+		},
 	}
 
 	for _, tt := range tests {
@@ -106,7 +166,53 @@ func TestServerProfile_Validate(t *testing.T) {
 	}
 }
 
+func TestServerProfile_ValidateProtocol(t *testing.T) {
+	profile := NewServerProfile("web", "localhost", 8080)
+	for _, protocol := range []Protocol{"", ProtocolGRPC, ProtocolGRPCWeb, ProtocolGRPCWebText, ProtocolConnect, ProtocolConnectJSON} {
+		profile.Protocol = protocol
+		assert.NoError(t, profile.Validate(), protocol)
+	}
+	profile.Protocol = "websocket"
+	assert.ErrorIs(t, profile.Validate(), ErrInvalidProtocol)
+
+	// A raw target of an HTTP protocol is the base URL of the server
+	profile.Protocol = ProtocolConnect
+	profile.TargetKind = TargetRaw
+	profile.Target = "https://api.example.com/rpc"
+	assert.NoError(t, profile.Validate())
+	profile.Target = "dns:///api.example.com:443"
+	assert.ErrorIs(t, profile.Validate(), ErrInvalidProtocol)
+	assert.False(t, profile.IsNativeGRPC())
+}
+
 // Helper function to get string pointer
 func strPtr(s string) *string {
 	return &s
 }
+
+func TestServerProfile_ConnectTimeout(t *testing.T) {
+	profile := NewServerProfile("test-server", "localhost", 50051)
+	assert.Equal(t, DefaultConnectTimeout, profile.ConnectTimeout())
+
+	profile.ConnectTimeoutMs = 30000
+	assert.Equal(t, 30*time.Second, profile.ConnectTimeout())
+}
+
+func TestServerProfile_DialTarget(t *testing.T) {
+	tests := []struct {
+		profile ServerProfile
+		want    string
+	}{
+		{ServerProfile{Host: "localhost", Port: 50051}, "localhost:50051"},
+		{ServerProfile{TargetKind: TargetTCP, Host: "::1", Port: 50051}, "[::1]:50051"},
+		{ServerProfile{TargetKind: TargetUnix, Target: "/var/run/sidecar.sock"}, "unix:///var/run/sidecar.sock"},
+		{ServerProfile{TargetKind: TargetUnix, Target: "@sidecar"}, "unix-abstract:sidecar"},
+		{ServerProfile{TargetKind: TargetRaw, Target: "dns:///api.example.com:443"}, "dns:///api.example.com:443"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, tt.profile.DialTarget())
+	}
+
+	invalid := &ServerProfile{Name: "test-server", TargetKind: "pipe", Target: "x"}
+	assert.ErrorIs(t, invalid.Validate(), ErrInvalidTargetKind)
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+)
+
+// CompressionGzip compresses requests with gzip
+const CompressionGzip = "gzip"
+
+// ErrInvalidTransportSettings is returned when transport settings have values out of range
+var ErrInvalidTransportSettings = errors.New("invalid transport settings")
+
+// minInitialWindowSize is the smallest window gRPC accepts; smaller values
+// are ignored in favour of dynamic flow control
+const minInitialWindowSize = 64 * 1024
+
+// TransportSettings tune the HTTP/2 transport of a server profile. Zero
+// values keep the gRPC defaults.
+type TransportSettings struct {
+	// KeepaliveTimeMs is how long a connection may be idle before the client
+	// pings the server; zero disables keepalive pings
+	KeepaliveTimeMs int64 `json:"keepaliveTimeMs,omitempty"`
+	// KeepaliveTimeoutMs is how long to wait for the ping to be acknowledged
+	// before closing the connection
+	KeepaliveTimeoutMs int64 `json:"keepaliveTimeoutMs,omitempty"`
+	// KeepaliveWithoutCalls sends pings even when no call is in progress
+	KeepaliveWithoutCalls bool `json:"keepaliveWithoutCalls,omitempty"`
+
+	// MaxSendMessageBytes and MaxReceiveMessageBytes limit message sizes;
+	// gRPC receives at most 4 MB by default
+	MaxSendMessageBytes    int `json:"maxSendMessageBytes,omitempty"`
+	MaxReceiveMessageBytes int `json:"maxReceiveMessageBytes,omitempty"`
+
+	// Compression names the compressor used for requests: empty or gzip
+	Compression string `json:"compression,omitempty"`
+	// UserAgent is appended to the user agent the app sends with every call
+	UserAgent string `json:"userAgent,omitempty"`
+
+	// InitialWindowSize and InitialConnWindowSize set the HTTP/2 flow control
+	// windows of each stream and of the connection, at least 64 KB
+	InitialWindowSize     int32 `json:"initialWindowSize,omitempty"`
+	InitialConnWindowSize int32 `json:"initialConnWindowSize,omitempty"`
+}
+
+// IsZero reports whether the settings leave every gRPC default unchanged
+func (t *TransportSettings) IsZero() bool {
+	return t == nil || *t == TransportSettings{}
+}
+
+// Validate checks that the settings are within the ranges gRPC accepts
+func (t *TransportSettings) Validate() error {
+	if t.KeepaliveTimeMs < 0 || t.KeepaliveTimeoutMs < 0 {
+		return fmt.Errorf("%w: keepalive durations cannot be negative", ErrInvalidTransportSettings)
+	}
+	if t.KeepaliveTimeoutMs > 0 && t.KeepaliveTimeMs == 0 {
+		return fmt.Errorf("%w: a keepalive timeout requires a keepalive time", ErrInvalidTransportSettings)
+	}
+	if t.MaxSendMessageBytes < 0 || t.MaxReceiveMessageBytes < 0 {
+		return fmt.Errorf("%w: message sizes cannot be negative", ErrInvalidTransportSettings)
+	}
+	if t.Compression != "" && t.Compression != CompressionGzip {
+		return fmt.Errorf("%w: unsupported compression %q", ErrInvalidTransportSettings, t.Compression)
+	}
+	if t.InitialWindowSize != 0 && t.InitialWindowSize < minInitialWindowSize ||
+		t.InitialConnWindowSize != 0 && t.InitialConnWindowSize < minInitialWindowSize {
+		return fmt.Errorf("%w: window sizes must be at least %d bytes", ErrInvalidTransportSettings, minInitialWindowSize)
+	}
+	return nil
+}
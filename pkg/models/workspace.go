@@ -0,0 +1,43 @@
+package models
+
+import (
+	"errors"
+	"path/filepath"
+)
+
+// DefaultWorkspaceName is the workspace backed by ~/.protodesk/protodesk.db.
+// It always exists and cannot be removed.
+const DefaultWorkspaceName = "default"
+
+var (
+	// ErrEmptyWorkspaceName is returned when the workspace name is empty
+	ErrEmptyWorkspaceName = errors.New("workspace name cannot be empty")
+
+	// ErrWorkspaceNotFound is returned when a workspace cannot be found
+	ErrWorkspaceNotFound = errors.New("workspace not found")
+
+	// ErrWorkspaceExists is returned when a workspace name is already taken
+	ErrWorkspaceExists = errors.New("workspace already exists")
+
+	// ErrRelativeWorkspacePath is returned when a workspace database path is not absolute
+	ErrRelativeWorkspacePath = errors.New("workspace database path must be absolute")
+)
+
+// Workspace is a named database holding its own profiles, collections,
+// environments and secrets, isolated from every other workspace
+type Workspace struct {
+	Name string `json:"name"`
+	// Path is the absolute path of the SQLite database file
+	Path string `json:"path"`
+}
+
+// Validate checks that the workspace has a name and an absolute database path
+func (w *Workspace) Validate() error {
+	if w.Name == "" {
+		return ErrEmptyWorkspaceName
+	}
+	if !filepath.IsAbs(w.Path) {
+		return ErrRelativeWorkspacePath
+	}
+	return nil
+}
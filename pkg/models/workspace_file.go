@@ -0,0 +1,88 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// WorkspaceFileVersion is the version of the workspace file format written
+// by this build. Files with a newer version are rejected on import.
+const WorkspaceFileVersion = 1
+
+// ErrUnsupportedWorkspaceVersion is returned when importing a workspace file
+// written by a newer version of protodesk
+var ErrUnsupportedWorkspaceVersion = errors.New("unsupported workspace file version")
+
+// WorkspaceFile is a portable snapshot of server profiles and the collections
+// that use them, meant to be checked into a service repository and shared.
+// Records reference each other by name rather than ID so that a file can be
+// merged into any local database.
+type WorkspaceFile struct {
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exportedAt"`
+	// SecretsStripped is true when secret values were left out of the file.
+	// Empty secret values then keep whatever the importing database has.
+	SecretsStripped bool                  `json:"secretsStripped,omitempty"`
+	Profiles        []WorkspaceProfile    `json:"profiles"`
+	Collections     []WorkspaceCollection `json:"collections,omitempty"`
+}
+
+// WorkspaceProfile is a server profile together with its proto paths and
+// per-request headers
+type WorkspaceProfile struct {
+	Name            string `json:"name"`
+	Host            string `json:"host"`
+	Port            int    `json:"port"`
+	TLSEnabled      bool   `json:"tlsEnabled,omitempty"`
+	CertificatePath string `json:"certificatePath,omitempty"`
+	UseReflection   bool   `json:"useReflection,omitempty"`
+	// ConnectTimeoutMs is omitted for the default timeout
+	ConnectTimeoutMs int                `json:"connectTimeoutMs,omitempty"`
+	Transport        *TransportSettings `json:"transport,omitempty"`
+	JSONOptions      *JSONOptions       `json:"jsonOptions,omitempty"`
+	// TargetKind, Target and Authority are omitted for host and port targets
+	TargetKind TargetKind `json:"targetKind,omitempty"`
+	Target     string     `json:"target,omitempty"`
+	Authority  string     `json:"authority,omitempty"`
+	// Protocol is omitted for native gRPC
+	Protocol Protocol `json:"protocol,omitempty"`
+	// Proxy carries its password unless secrets are stripped
+	Proxy   *ProxyConfig `json:"proxy,omitempty"`
+	Headers []Header     `json:"headers,omitempty"`
+	Auth    *AuthConfig  `json:"auth,omitempty"`
+	// ProtoPaths are proto folders, relative to the workspace file where possible
+	ProtoPaths     []string                  `json:"protoPaths,omitempty"`
+	RequestHeaders []WorkspaceRequestHeaders `json:"requestHeaders,omitempty"`
+}
+
+// WorkspaceRequestHeaders are the per-request headers of one method
+type WorkspaceRequestHeaders struct {
+	Service    string          `json:"service"`
+	Method     string          `json:"method"`
+	Headers    json.RawMessage `json:"headers"`
+	SecretKeys []string        `json:"secretKeys,omitempty"`
+}
+
+// WorkspaceCollection is a collection with its saved requests in run order
+type WorkspaceCollection struct {
+	Name        string             `json:"name"`
+	Description string             `json:"description,omitempty"`
+	Requests    []WorkspaceRequest `json:"requests"`
+}
+
+// WorkspaceRequest is a saved request. Profile names the server profile it
+// is sent to. Request is the JSON request body, or a string holding the body
+// of another RequestFormat.
+type WorkspaceRequest struct {
+	Name           string           `json:"name"`
+	Profile        string           `json:"profile"`
+	Service        string           `json:"service"`
+	Method         string           `json:"method"`
+	Request        json.RawMessage  `json:"request,omitempty"`
+	RequestFormat  MessageFormat    `json:"requestFormat,omitempty"`
+	ResponseFormat MessageFormat    `json:"responseFormat,omitempty"`
+	Headers        json.RawMessage  `json:"headers,omitempty"`
+	Assertions     []Assertion      `json:"assertions,omitempty"`
+	Extractions    []ExtractionRule `json:"extractions,omitempty"`
+}
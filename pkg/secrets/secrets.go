@@ -0,0 +1,172 @@
+// Package secrets encrypts sensitive values, such as header values and auth
+// tokens, before they are written to the local database.
+//
+// Values are sealed with AES-256-GCM. The key is either derived from a user
+// passphrase with scrypt or read from a key file. Encrypted values are stored
+// as strings prefixed with "enc:v1:" so that they can live in the same
+// columns and JSON documents as plain values.
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// Prefix marks a value encrypted by a Box
+const Prefix = "enc:v1:"
+
+// MaskedValue replaces secret values in exports, reports and logs
+const MaskedValue = "********"
+
+// KeySize is the size in bytes of an AES-256 key
+const KeySize = 32
+
+// SaltSize is the size in bytes of the salt used to derive a key from a passphrase
+const SaltSize = 16
+
+// verifierPlaintext is encrypted with the key when secret storage is set up,
+// so that a wrong passphrase can be detected before anything is decrypted
+const verifierPlaintext = "protodesk-secrets"
+
+var (
+	// ErrLocked is returned when a secret has to be encrypted or decrypted
+	// but no key has been provided
+	ErrLocked = errors.New("secret storage is locked")
+
+	// ErrWrongKey is returned when the passphrase or key file does not match
+	// the key secret storage was set up with
+	ErrWrongKey = errors.New("incorrect passphrase or key file")
+
+	// ErrInvalidKey is returned when a key file does not hold a 32 byte key
+	ErrInvalidKey = errors.New("key must be 32 bytes")
+)
+
+// Box encrypts and decrypts values with a single key
+type Box struct {
+	aead cipher.AEAD
+}
+
+// NewBox creates a Box from a 32 byte key
+func NewBox(key []byte) (*Box, error) {
+	if len(key) != KeySize {
+		return nil, ErrInvalidKey
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Box{aead: aead}, nil
+}
+
+// NewSalt returns a random salt for DeriveKey
+func NewSalt() ([]byte, error) {
+	salt := make([]byte, SaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+// DeriveKey derives a 32 byte key from a passphrase with scrypt
+func DeriveKey(passphrase string, salt []byte) ([]byte, error) {
+	if passphrase == "" {
+		return nil, fmt.Errorf("passphrase cannot be empty")
+	}
+	return scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, KeySize)
+}
+
+// ReadKeyFile reads a key file holding either 32 raw bytes or the key encoded
+// as hex or base64
+func ReadKeyFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+	if len(data) == KeySize {
+		return data, nil
+	}
+	text := strings.TrimSpace(string(data))
+	if key, err := hex.DecodeString(text); err == nil && len(key) == KeySize {
+		return key, nil
+	}
+	if key, err := base64.StdEncoding.DecodeString(text); err == nil && len(key) == KeySize {
+		return key, nil
+	}
+	return nil, ErrInvalidKey
+}
+
+// Encrypt seals a value. Empty and already encrypted values are returned unchanged.
+func (b *Box) Encrypt(plaintext string) (string, error) {
+	if plaintext == "" || IsEncrypted(plaintext) {
+		return plaintext, nil
+	}
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := b.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return Prefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt opens a value produced by Encrypt. Values without the prefix are
+// returned unchanged.
+func (b *Box) Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, Prefix))
+	if err != nil {
+		return "", fmt.Errorf("malformed encrypted value: %w", err)
+	}
+	n := b.aead.NonceSize()
+	if len(sealed) < n {
+		return "", fmt.Errorf("malformed encrypted value")
+	}
+	plain, err := b.aead.Open(nil, sealed[:n], sealed[n:], nil)
+	if err != nil {
+		return "", ErrWrongKey
+	}
+	return string(plain), nil
+}
+
+// Verifier returns a value to store alongside the data so that the key can be
+// checked later with Verify
+func (b *Box) Verifier() (string, error) {
+	return b.Encrypt(verifierPlaintext)
+}
+
+// Verify checks that the box's key is the one that produced verifier
+func (b *Box) Verify(verifier string) error {
+	plain, err := b.Decrypt(verifier)
+	if err != nil || plain != verifierPlaintext {
+		return ErrWrongKey
+	}
+	return nil
+}
+
+// IsEncrypted reports whether a value was produced by Encrypt
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, Prefix)
+}
+
+// Mask hides a secret value, keeping empty values empty so that it is still
+// visible whether a value was set
+func Mask(value string) string {
+	if value == "" {
+		return ""
+	}
+	return MaskedValue
+}
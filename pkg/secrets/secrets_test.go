@@ -0,0 +1,119 @@
+package secrets
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testKey() []byte {
+	key := make([]byte, KeySize)
+	for i := range key {
+		key[i] = byte(i)
+	}
+	return key
+}
+
+func TestBox_EncryptDecrypt(t *testing.T) {
+	box, err := NewBox(testKey())
+	require.NoError(t, err)
+
+	sealed, err := box.Encrypt("Bearer prod-token")
+	require.NoError(t, err)
+	assert.True(t, IsEncrypted(sealed))
+	assert.NotContains(t, sealed, "prod-token")
+
+	again, err := box.Encrypt("Bearer prod-token")
+	require.NoError(t, err)
+	assert.NotEqual(t, sealed, again, "each encryption uses a fresh nonce")
+
+	// Encrypting twice is a no-op
+	same, err := box.Encrypt(sealed)
+	require.NoError(t, err)
+	assert.Equal(t, sealed, same)
+
+	plain, err := box.Decrypt(sealed)
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer prod-token", plain)
+
+	plain, err = box.Decrypt("not encrypted")
+	require.NoError(t, err)
+	assert.Equal(t, "not encrypted", plain)
+
+	empty, err := box.Encrypt("")
+	require.NoError(t, err)
+	assert.Equal(t, "", empty)
+}
+
+func TestBox_WrongKey(t *testing.T) {
+	box, err := NewBox(testKey())
+	require.NoError(t, err)
+	verifier, err := box.Verifier()
+	require.NoError(t, err)
+	sealed, err := box.Encrypt("secret")
+	require.NoError(t, err)
+
+	other := testKey()
+	other[0] = 0xff
+	wrong, err := NewBox(other)
+	require.NoError(t, err)
+	assert.ErrorIs(t, wrong.Verify(verifier), ErrWrongKey)
+	_, err = wrong.Decrypt(sealed)
+	assert.ErrorIs(t, err, ErrWrongKey)
+	assert.NoError(t, box.Verify(verifier))
+
+	_, err = NewBox([]byte("short"))
+	assert.ErrorIs(t, err, ErrInvalidKey)
+}
+
+func TestDeriveKey(t *testing.T) {
+	salt, err := NewSalt()
+	require.NoError(t, err)
+
+	a, err := DeriveKey("correct horse", salt)
+	require.NoError(t, err)
+	b, err := DeriveKey("correct horse", salt)
+	require.NoError(t, err)
+	c, err := DeriveKey("battery staple", salt)
+	require.NoError(t, err)
+	assert.Len(t, a, KeySize)
+	assert.Equal(t, a, b)
+	assert.NotEqual(t, a, c)
+
+	_, err = DeriveKey("", salt)
+	assert.Error(t, err)
+}
+
+func TestReadKeyFile(t *testing.T) {
+	dir := t.TempDir()
+	key := testKey()
+	for name, content := range map[string][]byte{
+		"raw":    key,
+		"hex":    []byte(hex.EncodeToString(key) + "\n"),
+		"base64": []byte(base64.StdEncoding.EncodeToString(key)),
+	} {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, content, 0600))
+		got, err := ReadKeyFile(path)
+		require.NoError(t, err, name)
+		assert.Equal(t, key, got, name)
+	}
+
+	path := filepath.Join(dir, "bad")
+	require.NoError(t, os.WriteFile(path, []byte("too short"), 0600))
+	_, err := ReadKeyFile(path)
+	assert.ErrorIs(t, err, ErrInvalidKey)
+
+	_, err = ReadKeyFile(filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+}
+
+func TestMask(t *testing.T) {
+	assert.Equal(t, MaskedValue, Mask("token"))
+	assert.Equal(t, "", Mask(""))
+}
@@ -0,0 +1,185 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"protodesk/pkg/models"
+
+	"github.com/PaesslerAG/jsonpath"
+	"github.com/google/cel-go/cel"
+)
+
+// EvaluateAssertions checks every assertion against an invocation result
+func EvaluateAssertions(result *InvocationResult, assertions []models.Assertion) []models.AssertionResult {
+	results := make([]models.AssertionResult, 0, len(assertions))
+	for _, a := range assertions {
+		passed, msg := evaluateAssertion(result, a)
+		results = append(results, models.AssertionResult{
+			Assertion: a,
+			Passed:    passed,
+			Message:   msg,
+		})
+	}
+	return results
+}
+
+func evaluateAssertion(result *InvocationResult, a models.Assertion) (bool, string) {
+	if err := a.Validate(); err != nil {
+		return false, err.Error()
+	}
+	switch a.Type {
+	case models.AssertionStatus:
+		return assertStatus(result, a.Expected)
+	case models.AssertionJSONPath:
+		return assertJSONPath(result, a.Expression, a.Expected)
+	case models.AssertionCEL:
+		return assertCEL(result, a.Expression)
+	case models.AssertionHeader:
+		return assertMetadata("header", result.Headers, a.Expression, a.Expected)
+	case models.AssertionTrailer:
+		return assertMetadata("trailer", result.Trailers, a.Expression, a.Expected)
+	case models.AssertionLatency:
+		if result.LatencyMs > a.MaxLatencyMs {
+			return false, fmt.Sprintf("latency %dms exceeds %dms", result.LatencyMs, a.MaxLatencyMs)
+		}
+		return true, ""
+	}
+	return false, fmt.Sprintf("unsupported assertion type %q", a.Type)
+}
+
+// assertStatus accepts the expected code as a number, an upper snake case
+// name (NOT_FOUND) or a Go style name (NotFound). CANCELLED may also be
+// spelled the Go way, Canceled.
+func assertStatus(result *InvocationResult, expected string) (bool, string) {
+	expected = strings.TrimSpace(expected)
+	if n, err := strconv.Atoi(expected); err == nil {
+		if n == result.StatusCode {
+			return true, ""
+		}
+	} else if normalizeStatusName(expected) == normalizeStatusName(result.StatusName) {
+		return true, ""
+	}
+	return false, fmt.Sprintf("expected status %s, got %s", expected, result.StatusName)
+}
+
+func normalizeStatusName(name string) string {
+	name = strings.ToUpper(strings.ReplaceAll(name, "_", ""))
+	if name == "CANCELED" {
+		return "CANCELLED"
+	}
+	return name
+}
+
+// assertJSONPath checks that the path resolves and, when expected is set, that
+// the value equals expected. Expected is parsed as JSON when possible so that
+// numbers, booleans and objects compare by value; otherwise it is a string.
+func assertJSONPath(result *InvocationResult, path, expected string) (bool, string) {
+	value, err := jsonPathValue(result.ResponseJSON, path)
+	if err != nil {
+		return false, err.Error()
+	}
+	if expected == "" {
+		return true, ""
+	}
+	var want any
+	if err := json.Unmarshal([]byte(expected), &want); err != nil {
+		want = expected
+	}
+	if reflect.DeepEqual(value, want) {
+		return true, ""
+	}
+	got, _ := json.Marshal(value)
+	return false, fmt.Sprintf("%s: expected %s, got %s", path, expected, got)
+}
+
+// jsonPathValue resolves a JSONPath expression against a JSON document
+func jsonPathValue(doc, path string) (any, error) {
+	var v any
+	if err := json.Unmarshal([]byte(doc), &v); err != nil {
+		return nil, fmt.Errorf("response is not valid JSON: %w", err)
+	}
+	value, err := jsonpath.Get(path, v)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return value, nil
+}
+
+// assertCEL evaluates a boolean CEL expression. The expression can refer to
+// response (the decoded body), status (name), code (number), headers,
+// trailers and latencyMs.
+func assertCEL(result *InvocationResult, expr string) (bool, string) {
+	env, err := cel.NewEnv(
+		cel.Variable("response", cel.DynType),
+		cel.Variable("status", cel.StringType),
+		cel.Variable("code", cel.IntType),
+		cel.Variable("headers", cel.MapType(cel.StringType, cel.StringType)),
+		cel.Variable("trailers", cel.MapType(cel.StringType, cel.StringType)),
+		cel.Variable("latencyMs", cel.IntType),
+	)
+	if err != nil {
+		return false, fmt.Sprintf("failed to create CEL environment: %v", err)
+	}
+	ast, iss := env.Compile(expr)
+	if iss.Err() != nil {
+		return false, fmt.Sprintf("invalid CEL expression: %v", iss.Err())
+	}
+	prg, err := env.Program(ast)
+	if err != nil {
+		return false, fmt.Sprintf("invalid CEL expression: %v", err)
+	}
+
+	var response any
+	if result.ResponseJSON != "" {
+		if err := json.Unmarshal([]byte(result.ResponseJSON), &response); err != nil {
+			return false, fmt.Sprintf("response is not valid JSON: %v", err)
+		}
+	}
+	out, _, err := prg.Eval(map[string]any{
+		"response":  response,
+		"status":    result.StatusName,
+		"code":      result.StatusCode,
+		"headers":   joinMetadata(result.Headers),
+		"trailers":  joinMetadata(result.Trailers),
+		"latencyMs": result.LatencyMs,
+	})
+	if err != nil {
+		return false, fmt.Sprintf("CEL evaluation failed: %v", err)
+	}
+	passed, ok := out.Value().(bool)
+	if !ok {
+		return false, fmt.Sprintf("CEL expression must return a bool, got %v", out.Type())
+	}
+	if !passed {
+		return false, fmt.Sprintf("%s evaluated to false", expr)
+	}
+	return true, ""
+}
+
+func assertMetadata(kind string, md map[string][]string, key, expected string) (bool, string) {
+	values, ok := md[strings.ToLower(key)]
+	if !ok {
+		return false, fmt.Sprintf("%s %q not present", kind, key)
+	}
+	if expected == "" {
+		return true, ""
+	}
+	for _, v := range values {
+		if v == expected {
+			return true, ""
+		}
+	}
+	return false, fmt.Sprintf("%s %q: expected %q, got %q", kind, key, expected, strings.Join(values, ", "))
+}
+
+func joinMetadata(md map[string][]string) map[string]string {
+	out := make(map[string]string, len(md))
+	for k, v := range md {
+		out[k] = strings.Join(v, ", ")
+	}
+	return out
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"testing"
+
+	"protodesk/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvaluateAssertions(t *testing.T) {
+	result := &InvocationResult{
+		ResponseJSON: `{"user":{"id":42,"name":"ada","tags":["admin","dev"]},"active":true}`,
+		StatusCode:   0,
+		StatusName:   "OK",
+		Headers:      map[string][]string{"content-type": {"application/grpc"}},
+		Trailers:     map[string][]string{"x-request-id": {"abc"}},
+		LatencyMs:    120,
+	}
+
+	tests := []struct {
+		name      string
+		assertion models.Assertion
+		passed    bool
+	}{
+		{"status by name", models.Assertion{Type: models.AssertionStatus, Expected: "OK"}, true},
+		{"status by number", models.Assertion{Type: models.AssertionStatus, Expected: "0"}, true},
+		{"status mismatch", models.Assertion{Type: models.AssertionStatus, Expected: "NOT_FOUND"}, false},
+		{"jsonpath number", models.Assertion{Type: models.AssertionJSONPath, Expression: "$.user.id", Expected: "42"}, true},
+		{"jsonpath string", models.Assertion{Type: models.AssertionJSONPath, Expression: "$.user.name", Expected: "ada"}, true},
+		{"jsonpath array index", models.Assertion{Type: models.AssertionJSONPath, Expression: "$.user.tags[1]", Expected: `"dev"`}, true},
+		{"jsonpath presence", models.Assertion{Type: models.AssertionJSONPath, Expression: "$.active"}, true},
+		{"jsonpath missing", models.Assertion{Type: models.AssertionJSONPath, Expression: "$.missing"}, false},
+		{"jsonpath mismatch", models.Assertion{Type: models.AssertionJSONPath, Expression: "$.user.id", Expected: "7"}, false},
+		{"cel true", models.Assertion{Type: models.AssertionCEL, Expression: `response.user.name == "ada" && status == "OK"`}, true},
+		{"cel false", models.Assertion{Type: models.AssertionCEL, Expression: `size(response.user.tags) > 5`}, false},
+		{"cel metadata", models.Assertion{Type: models.AssertionCEL, Expression: `trailers["x-request-id"] == "abc" && latencyMs < 500`}, true},
+		{"cel not bool", models.Assertion{Type: models.AssertionCEL, Expression: `response.user.id`}, false},
+		{"cel invalid", models.Assertion{Type: models.AssertionCEL, Expression: `response.(`}, false},
+		{"header present", models.Assertion{Type: models.AssertionHeader, Expression: "Content-Type"}, true},
+		{"header value", models.Assertion{Type: models.AssertionHeader, Expression: "content-type", Expected: "application/json"}, false},
+		{"trailer present", models.Assertion{Type: models.AssertionTrailer, Expression: "x-request-id", Expected: "abc"}, true},
+		{"trailer missing", models.Assertion{Type: models.AssertionTrailer, Expression: "grpc-status-details-bin"}, false},
+		{"latency under", models.Assertion{Type: models.AssertionLatency, MaxLatencyMs: 200}, true},
+		{"latency over", models.Assertion{Type: models.AssertionLatency, MaxLatencyMs: 100}, false},
+		{"invalid assertion", models.Assertion{Type: "bogus"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := EvaluateAssertions(result, []models.Assertion{tt.assertion})
+			require.Len(t, results, 1)
+			assert.Equal(t, tt.passed, results[0].Passed, results[0].Message)
+			if !tt.passed {
+				assert.NotEmpty(t, results[0].Message)
+			}
+		})
+	}
+}
+
+func TestEvaluateAssertions_ErrorStatus(t *testing.T) {
+	result := &InvocationResult{StatusCode: 5, StatusName: "NOT_FOUND", StatusMessage: "no such user"}
+
+	results := EvaluateAssertions(result, []models.Assertion{
+		{Type: models.AssertionStatus, Expected: "NotFound"},
+		{Type: models.AssertionCEL, Expression: `code == 5`},
+	})
+	for _, r := range results {
+		assert.True(t, r.Passed, r.Message)
+	}
+	assert.Error(t, result.Err())
+}
+
+func TestStatusName(t *testing.T) {
+	assert.Equal(t, "OK", statusName(0))
+	assert.Equal(t, "NOT_FOUND", statusName(5))
+	assert.Equal(t, "DEADLINE_EXCEEDED", statusName(4))
+	assert.Equal(t, "UNAVAILABLE", statusName(14))
+	assert.Equal(t, "CANCELLED", statusName(1))
+	assert.Equal(t, "CODE(42)", statusName(42))
+
+	cancelled := &InvocationResult{StatusCode: 1, StatusName: statusName(1)}
+	for _, expected := range []string{"CANCELLED", "CANCELED", "Canceled", "1"} {
+		passed, message := assertStatus(cancelled, expected)
+		assert.True(t, passed, message)
+	}
+}
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"strings"
+	"time"
+
+	"protodesk/pkg/models"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// defaultCommandTokenTTL is how long a bare token printed by an auth
+	// command is reused when the profile does not set CacheSeconds
+	defaultCommandTokenTTL = 5 * time.Minute
+	// authCommandTimeout bounds how long an auth command may run
+	authCommandTimeout = 30 * time.Second
+	// authRequestTimeout bounds a request to an OAuth2 token endpoint
+	authRequestTimeout = 30 * time.Second
+)
+
+// NewAuthTokenSource returns a token source for the auth provider of a
+// profile. Tokens are cached and only fetched again once they expire.
+func NewAuthTokenSource(cfg *models.AuthConfig) (oauth2.TokenSource, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	// Token requests outlive the call that triggers them, so they use a
+	// background context with a client that cannot hang forever
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{Timeout: authRequestTimeout})
+
+	switch cfg.Type {
+	case models.AuthOAuth2ClientCredentials:
+		cc := &clientcredentials.Config{
+			ClientID:       cfg.ClientID,
+			ClientSecret:   cfg.ClientSecret,
+			TokenURL:       cfg.TokenURL,
+			Scopes:         cfg.Scopes,
+			EndpointParams: url.Values(cfg.EndpointParams),
+		}
+		return cc.TokenSource(ctx), nil
+	case models.AuthOAuth2RefreshToken:
+		oc := &oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			Endpoint:     oauth2.Endpoint{TokenURL: cfg.TokenURL},
+			Scopes:       cfg.Scopes,
+		}
+		// Rotated refresh tokens are kept by the token source for the
+		// lifetime of the connection
+		return oc.TokenSource(ctx, &oauth2.Token{RefreshToken: cfg.RefreshToken}), nil
+	case models.AuthCommand:
+		ttl := defaultCommandTokenTTL
+		if cfg.CacheSeconds > 0 {
+			ttl = time.Duration(cfg.CacheSeconds) * time.Second
+		}
+		return oauth2.ReuseTokenSource(nil, &commandTokenSource{
+			command: cfg.Command,
+			args:    cfg.Args,
+			ttl:     ttl,
+		}), nil
+	}
+	return nil, fmt.Errorf("%w: no provider for type %q", models.ErrInvalidAuthConfig, cfg.Type)
+}
+
+// AuthDialOptions returns the dial options that attach the profile's auth
+// token to every call, or nil when the profile has no auth provider
+func AuthDialOptions(cfg *models.AuthConfig) ([]grpc.DialOption, error) {
+	creds, err := AuthCredentials(cfg)
+	if err != nil || creds == nil {
+		return nil, err
+	}
+	return []grpc.DialOption{grpc.WithPerRPCCredentials(creds)}, nil
+}
+
+// AuthCredentials returns the per-call credentials carrying the profile's
+// auth token, or nil when the profile has no auth provider
+func AuthCredentials(cfg *models.AuthConfig) (credentials.PerRPCCredentials, error) {
+	if cfg == nil || cfg.Type == models.AuthNone {
+		return nil, nil
+	}
+	source, err := NewAuthTokenSource(cfg)
+	if err != nil {
+		return nil, err
+	}
+	header := strings.ToLower(cfg.HeaderName)
+	if header == "" {
+		header = "authorization"
+	}
+	return &tokenCredentials{source: source, header: header}, nil
+}
+
+// tokenCredentials injects a token from a token source as call metadata
+type tokenCredentials struct {
+	source oauth2.TokenSource
+	header string
+}
+
+// GetRequestMetadata returns the metadata for a call. The authorization
+// header carries "<type> <token>"; custom headers carry the bare token.
+// The call stops waiting for a token once its context is done; the token
+// request itself carries on so that later calls can use the token.
+func (c *tokenCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	type result struct {
+		token *oauth2.Token
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		token, err := c.source.Token()
+		done <- result{token, err}
+	}()
+
+	var token *oauth2.Token
+	select {
+	case res := <-done:
+		if res.err != nil {
+			return nil, status.Errorf(codes.Unauthenticated, "failed to obtain auth token: %v", res.err)
+		}
+		token = res.token
+	case <-ctx.Done():
+		// DeadlineExceeded or Cancelled, as for any other call that ends early
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+	value := token.AccessToken
+	if c.header == "authorization" {
+		value = token.Type() + " " + token.AccessToken
+	}
+	return map[string]string{c.header: value}, nil
+}
+
+// RequireTransportSecurity returns false so that tokens can also be sent to
+// plaintext servers, e.g. a local development server
+func (c *tokenCredentials) RequireTransportSecurity() bool {
+	return false
+}
+
+// commandTokenSource runs a local command that prints a token
+type commandTokenSource struct {
+	command string
+	args    []string
+	ttl     time.Duration
+}
+
+// Token runs the command and parses its output
+func (s *commandTokenSource) Token() (*oauth2.Token, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), authCommandTimeout)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, s.command, s.args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("auth command failed: %w: %s", err, msg)
+		}
+		return nil, fmt.Errorf("auth command failed: %w", err)
+	}
+	return parseCommandToken(out, s.ttl)
+}
+
+// parseCommandToken accepts either a bare token, which is cached for ttl, or
+// a JSON token response with access_token and optionally token_type and
+// expires_in or expiry
+func parseCommandToken(out []byte, ttl time.Duration) (*oauth2.Token, error) {
+	trimmed := bytes.TrimSpace(out)
+	if len(trimmed) == 0 {
+		return nil, fmt.Errorf("auth command printed no token")
+	}
+	if trimmed[0] != '{' {
+		return &oauth2.Token{
+			AccessToken: string(trimmed),
+			TokenType:   "Bearer",
+			Expiry:      time.Now().Add(ttl),
+		}, nil
+	}
+
+	var resp struct {
+		AccessToken string    `json:"access_token"`
+		TokenType   string    `json:"token_type"`
+		ExpiresIn   int64     `json:"expires_in"`
+		Expiry      time.Time `json:"expiry"`
+	}
+	if err := json.Unmarshal(trimmed, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse auth command output: %w", err)
+	}
+	if resp.AccessToken == "" {
+		return nil, fmt.Errorf("auth command output has no access_token")
+	}
+	token := &oauth2.Token{
+		AccessToken: resp.AccessToken,
+		TokenType:   resp.TokenType,
+		Expiry:      resp.Expiry,
+	}
+	switch {
+	case resp.ExpiresIn > 0:
+		token.Expiry = time.Now().Add(time.Duration(resp.ExpiresIn) * time.Second)
+	case token.Expiry.IsZero():
+		token.Expiry = time.Now().Add(ttl)
+	}
+	return token, nil
+}
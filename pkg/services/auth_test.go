@@ -0,0 +1,234 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"protodesk/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// startTokenServer starts an OAuth2 token endpoint that issues access-1,
+// access-2, ... and records the grant type of the last request
+func startTokenServer(t *testing.T, expiresIn int) (*httptest.Server, *int32, *atomic.Value) {
+	t.Helper()
+	var hits int32
+	var grant atomic.Value
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		grant.Store(r.PostForm.Get("grant_type"))
+		n := atomic.AddInt32(&hits, 1)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "access-" + string(rune('0'+n)),
+			"token_type":   "Bearer",
+			"expires_in":   expiresIn,
+		})
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &hits, &grant
+}
+
+func TestNewAuthTokenSource_ClientCredentials(t *testing.T) {
+	srv, hits, grant := startTokenServer(t, 3600)
+
+	source, err := NewAuthTokenSource(&models.AuthConfig{
+		Type:         models.AuthOAuth2ClientCredentials,
+		TokenURL:     srv.URL,
+		ClientID:     "client",
+		ClientSecret: "secret",
+		Scopes:       []string{"read"},
+	})
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		token, err := source.Token()
+		require.NoError(t, err)
+		assert.Equal(t, "access-1", token.AccessToken)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(hits), "token should be cached until expiry")
+	assert.Equal(t, "client_credentials", grant.Load())
+}
+
+func TestNewAuthTokenSource_RefreshToken(t *testing.T) {
+	// Tokens that expire within the oauth2 expiry delta are fetched again
+	srv, hits, grant := startTokenServer(t, 1)
+
+	source, err := NewAuthTokenSource(&models.AuthConfig{
+		Type:         models.AuthOAuth2RefreshToken,
+		TokenURL:     srv.URL,
+		ClientID:     "client",
+		RefreshToken: "refresh",
+	})
+	require.NoError(t, err)
+
+	first, err := source.Token()
+	require.NoError(t, err)
+	second, err := source.Token()
+	require.NoError(t, err)
+	assert.Equal(t, "access-1", first.AccessToken)
+	assert.Equal(t, "access-2", second.AccessToken)
+	assert.Equal(t, int32(2), atomic.LoadInt32(hits))
+	assert.Equal(t, "refresh_token", grant.Load())
+}
+
+func TestNewAuthTokenSource_Command(t *testing.T) {
+	source, err := NewAuthTokenSource(&models.AuthConfig{
+		Type:    models.AuthCommand,
+		Command: "echo",
+		Args:    []string{"cli-token"},
+	})
+	require.NoError(t, err)
+	token, err := source.Token()
+	require.NoError(t, err)
+	assert.Equal(t, "cli-token", token.AccessToken)
+	assert.Equal(t, "Bearer", token.Type())
+
+	source, err = NewAuthTokenSource(&models.AuthConfig{Type: models.AuthCommand, Command: "false"})
+	require.NoError(t, err)
+	_, err = source.Token()
+	assert.ErrorContains(t, err, "auth command failed")
+
+	_, err = NewAuthTokenSource(&models.AuthConfig{Type: models.AuthCommand})
+	assert.ErrorIs(t, err, models.ErrInvalidAuthConfig)
+}
+
+func TestParseCommandToken(t *testing.T) {
+	token, err := parseCommandToken([]byte("  abc\n"), time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, "abc", token.AccessToken)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), token.Expiry, 5*time.Second)
+
+	token, err = parseCommandToken([]byte(`{"access_token":"xyz","token_type":"Bearer","expires_in":120}`), time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, "xyz", token.AccessToken)
+	assert.WithinDuration(t, time.Now().Add(2*time.Minute), token.Expiry, 5*time.Second)
+
+	expiry := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	token, err = parseCommandToken([]byte(`{"access_token":"xyz","expiry":"2030-01-01T00:00:00Z"}`), time.Minute)
+	require.NoError(t, err)
+	assert.True(t, expiry.Equal(token.Expiry))
+
+	_, err = parseCommandToken([]byte(""), time.Minute)
+	assert.Error(t, err)
+	_, err = parseCommandToken([]byte(`{"token":"x"}`), time.Minute)
+	assert.Error(t, err)
+}
+
+func TestAuthDialOptions_InjectsMetadata(t *testing.T) {
+	srv, hits, _ := startTokenServer(t, 3600)
+
+	var seen []string
+	var custom []string
+	addr := startTestServer(t, grpc.UnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		seen = append(seen, md.Get("authorization")...)
+		custom = append(custom, md.Get("x-api-key")...)
+		return handler(ctx, req)
+	}))
+
+	opts, err := AuthDialOptions(&models.AuthConfig{
+		Type:     models.AuthOAuth2ClientCredentials,
+		TokenURL: srv.URL,
+		ClientID: "client",
+	})
+	require.NoError(t, err)
+	require.Len(t, opts, 1)
+
+	client := healthpb.NewHealthClient(dialTestServer(t, addr, opts...))
+	for i := 0; i < 2; i++ {
+		_, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "test.Service"})
+		require.NoError(t, err)
+	}
+	assert.Equal(t, []string{"Bearer access-1", "Bearer access-1"}, seen)
+	assert.Equal(t, int32(1), atomic.LoadInt32(hits))
+
+	// Custom headers carry the bare token
+	opts, err = AuthDialOptions(&models.AuthConfig{
+		Type:       models.AuthCommand,
+		Command:    "echo",
+		Args:       []string{"key-1"},
+		HeaderName: "X-Api-Key",
+	})
+	require.NoError(t, err)
+	client = healthpb.NewHealthClient(dialTestServer(t, addr, opts...))
+	_, err = client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "test.Service"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"key-1"}, custom)
+
+	opts, err = AuthDialOptions(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, opts)
+}
+
+func TestTokenCredentials_HonoursCallContext(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	t.Cleanup(srv.Close)
+	t.Cleanup(func() { close(release) })
+
+	creds, err := AuthCredentials(&models.AuthConfig{
+		Type:     models.AuthOAuth2ClientCredentials,
+		TokenURL: srv.URL,
+		ClientID: "client",
+	})
+	require.NoError(t, err)
+
+	// A hanging token endpoint does not hold the call past its deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = creds.GetRequestMetadata(ctx)
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	// A cancelled call is reported as cancelled, not as an unreachable server
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	_, err = creds.GetRequestMetadata(ctx)
+	assert.Equal(t, codes.Canceled, status.Code(err))
+	assert.Equal(t, "CANCELLED", statusName(status.Code(err)))
+}
+
+func TestSQLiteStore_ProfileAuth(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	profile := models.NewServerProfile("auth", "localhost", 50051)
+	profile.Auth = &models.AuthConfig{
+		Type:     models.AuthOAuth2ClientCredentials,
+		TokenURL: "https://auth.example.com/token",
+		ClientID: "client",
+		Scopes:   []string{"a", "b"},
+	}
+	require.NoError(t, store.Create(ctx, profile))
+
+	got, err := store.Get(ctx, profile.ID)
+	require.NoError(t, err)
+	require.NotNil(t, got.Auth)
+	assert.Equal(t, *profile.Auth, *got.Auth)
+
+	got.Auth = nil
+	require.NoError(t, store.Update(ctx, got))
+	got, err = store.Get(ctx, profile.ID)
+	require.NoError(t, err)
+	assert.Nil(t, got.Auth)
+
+	profile.Auth = &models.AuthConfig{Type: models.AuthOAuth2RefreshToken, TokenURL: "https://auth.example.com/token"}
+	assert.ErrorIs(t, store.Update(ctx, profile), models.ErrInvalidAuthConfig)
+}
@@ -0,0 +1,514 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	channelzpb "google.golang.org/grpc/channelz/grpc_channelz_v1"
+	channelzservice "google.golang.org/grpc/channelz/service"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// channelzTimeout bounds the queries of a remote channelz service
+const channelzTimeout = 5 * time.Second
+
+// ErrNoChannelz is returned for a profile whose connection is not tracked by
+// channelz, e.g. a gRPC-Web or Connect profile
+var ErrNoChannelz = errors.New("no channelz data for this connection")
+
+// ChannelzEvent is an entry of the trace of a channel or subchannel
+type ChannelzEvent struct {
+	Description string `json:"description"`
+	// Severity is CT_INFO, CT_WARNING or CT_ERROR
+	Severity  string    `json:"severity"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// failure reports whether the event is a warning or an error
+func (e ChannelzEvent) failure() bool {
+	return e.Severity == channelzpb.ChannelTraceEvent_CT_WARNING.String() ||
+		e.Severity == channelzpb.ChannelTraceEvent_CT_ERROR.String()
+}
+
+// ChannelzSocket describes a transport of a channel or server
+type ChannelzSocket struct {
+	ID            int64  `json:"id"`
+	LocalAddress  string `json:"localAddress,omitempty"`
+	RemoteAddress string `json:"remoteAddress,omitempty"`
+	// Security names the TLS cipher suite or other security in use; empty
+	// for plaintext
+	Security            string     `json:"security,omitempty"`
+	StreamsStarted      int64      `json:"streamsStarted"`
+	StreamsSucceeded    int64      `json:"streamsSucceeded"`
+	StreamsFailed       int64      `json:"streamsFailed"`
+	MessagesSent        int64      `json:"messagesSent"`
+	MessagesReceived    int64      `json:"messagesReceived"`
+	KeepAlivesSent      int64      `json:"keepAlivesSent"`
+	LastMessageSent     *time.Time `json:"lastMessageSent,omitempty"`
+	LastMessageReceived *time.Time `json:"lastMessageReceived,omitempty"`
+}
+
+// ChannelzChannel describes a channel or subchannel with its call counts
+type ChannelzChannel struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name,omitempty"`
+	// Target is the dial target of a channel and the address a subchannel
+	// connects to
+	Target          string            `json:"target"`
+	State           string            `json:"state"`
+	CallsStarted    int64             `json:"callsStarted"`
+	CallsSucceeded  int64             `json:"callsSucceeded"`
+	CallsFailed     int64             `json:"callsFailed"`
+	LastCallStarted *time.Time        `json:"lastCallStarted,omitempty"`
+	Events          []ChannelzEvent   `json:"events,omitempty"`
+	Channels        []ChannelzChannel `json:"channels,omitempty"`
+	Subchannels     []ChannelzChannel `json:"subchannels,omitempty"`
+	Sockets         []ChannelzSocket  `json:"sockets,omitempty"`
+}
+
+// ChannelzServer describes a gRPC server with its call counts
+type ChannelzServer struct {
+	ID              int64           `json:"id"`
+	Name            string          `json:"name,omitempty"`
+	ListenAddresses []string        `json:"listenAddresses,omitempty"`
+	CallsStarted    int64           `json:"callsStarted"`
+	CallsSucceeded  int64           `json:"callsSucceeded"`
+	CallsFailed     int64           `json:"callsFailed"`
+	LastCallStarted *time.Time      `json:"lastCallStarted,omitempty"`
+	Events          []ChannelzEvent `json:"events,omitempty"`
+}
+
+// ConnectionDetails is the client-side channelz view of a profile's
+// connection
+type ConnectionDetails struct {
+	ProfileID string `json:"profileId"`
+	// State is the connectivity state the manager last saw
+	State string `json:"state"`
+	// ConnectError is why the last connection attempt failed, if it did
+	ConnectError string          `json:"connectError,omitempty"`
+	Channel      ChannelzChannel `json:"channel"`
+	// Addresses are the resolved addresses of the subchannels
+	Addresses []string `json:"addresses"`
+	// LastFailure is the most recent warning or error traced by the channel
+	// or its subchannels
+	LastFailure *ChannelzEvent `json:"lastFailure,omitempty"`
+}
+
+// RemoteChannelz is what the channelz service of a server reports
+type RemoteChannelz struct {
+	ProfileID string           `json:"profileId"`
+	Servers   []ChannelzServer `json:"servers"`
+	// Channels are the channels the server itself dialled
+	Channels []ChannelzChannel `json:"channels"`
+}
+
+// channelzSource is the part of the channelz service used here. The
+// in-process service implements it directly; remoteChannelz adapts a client.
+type channelzSource interface {
+	GetTopChannels(context.Context, *channelzpb.GetTopChannelsRequest) (*channelzpb.GetTopChannelsResponse, error)
+	GetServers(context.Context, *channelzpb.GetServersRequest) (*channelzpb.GetServersResponse, error)
+	GetChannel(context.Context, *channelzpb.GetChannelRequest) (*channelzpb.GetChannelResponse, error)
+	GetSubchannel(context.Context, *channelzpb.GetSubchannelRequest) (*channelzpb.GetSubchannelResponse, error)
+	GetSocket(context.Context, *channelzpb.GetSocketRequest) (*channelzpb.GetSocketResponse, error)
+}
+
+// channelzCapture receives the channelz service implementation instead of a
+// server registering it
+type channelzCapture struct {
+	service channelzSource
+}
+
+func (c *channelzCapture) RegisterService(_ *grpc.ServiceDesc, impl any) {
+	c.service = impl.(channelzSource)
+}
+
+// localChannelz is the channelz service of this process. It is called
+// directly rather than served, so querying it does not add a channel.
+var localChannelz = func() channelzSource {
+	var capture channelzCapture
+	channelzservice.RegisterChannelzServiceToServer(&capture)
+	return capture.service
+}()
+
+// channelzDialMu serializes creating connections with finding their channel
+// in channelz, which only knows channels by target
+var channelzDialMu sync.Mutex
+
+// newestTopChannel returns the ID of the most recently created top channel
+// dialled to target, or zero if there is none
+func newestTopChannel(ctx context.Context, source channelzSource, target string) (int64, error) {
+	var newest int64
+	err := listTopChannels(ctx, source, func(channel *channelzpb.Channel) {
+		if channel.GetData().GetTarget() == target {
+			newest = max(newest, channel.GetRef().GetChannelId())
+		}
+	})
+	return newest, err
+}
+
+// listTopChannels passes every top channel to fn, page by page
+func listTopChannels(ctx context.Context, source channelzSource, fn func(*channelzpb.Channel)) error {
+	var start int64
+	for {
+		resp, err := source.GetTopChannels(ctx, &channelzpb.GetTopChannelsRequest{StartChannelId: start})
+		if err != nil {
+			return err
+		}
+		for _, channel := range resp.GetChannel() {
+			fn(channel)
+			start = channel.GetRef().GetChannelId() + 1
+		}
+		if resp.GetEnd() || len(resp.GetChannel()) == 0 {
+			return nil
+		}
+	}
+}
+
+// listServers passes every server to fn, page by page
+func listServers(ctx context.Context, source channelzSource, fn func(*channelzpb.Server)) error {
+	var start int64
+	for {
+		resp, err := source.GetServers(ctx, &channelzpb.GetServersRequest{StartServerId: start})
+		if err != nil {
+			return err
+		}
+		for _, server := range resp.GetServer() {
+			fn(server)
+			start = server.GetRef().GetServerId() + 1
+		}
+		if resp.GetEnd() || len(resp.GetServer()) == 0 {
+			return nil
+		}
+	}
+}
+
+// loadChannel fetches a channel with its nested channels, subchannels and
+// sockets
+func loadChannel(ctx context.Context, source channelzSource, id int64) (ChannelzChannel, error) {
+	resp, err := source.GetChannel(ctx, &channelzpb.GetChannelRequest{ChannelId: id})
+	if err != nil {
+		return ChannelzChannel{}, err
+	}
+	channel := resp.GetChannel()
+	result := channelFromData(id, channel.GetRef().GetName(), channel.GetData())
+	return result, loadChildren(ctx, source, &result, channel.GetChannelRef(), channel.GetSubchannelRef(), channel.GetSocketRef())
+}
+
+// loadSubchannel fetches a subchannel with its children and sockets
+func loadSubchannel(ctx context.Context, source channelzSource, id int64) (ChannelzChannel, error) {
+	resp, err := source.GetSubchannel(ctx, &channelzpb.GetSubchannelRequest{SubchannelId: id})
+	if err != nil {
+		return ChannelzChannel{}, err
+	}
+	subchannel := resp.GetSubchannel()
+	result := channelFromData(id, subchannel.GetRef().GetName(), subchannel.GetData())
+	return result, loadChildren(ctx, source, &result, subchannel.GetChannelRef(), subchannel.GetSubchannelRef(), subchannel.GetSocketRef())
+}
+
+// loadChildren fetches the referenced channels, subchannels and sockets
+// into result. Entities that went away since the parent was read are
+// skipped.
+func loadChildren(ctx context.Context, source channelzSource, result *ChannelzChannel,
+	channels []*channelzpb.ChannelRef, subchannels []*channelzpb.SubchannelRef, sockets []*channelzpb.SocketRef) error {
+	for _, ref := range channels {
+		child, err := loadChannel(ctx, source, ref.GetChannelId())
+		if skipErr(err) != nil {
+			return err
+		} else if err == nil {
+			result.Channels = append(result.Channels, child)
+		}
+	}
+	for _, ref := range subchannels {
+		child, err := loadSubchannel(ctx, source, ref.GetSubchannelId())
+		if skipErr(err) != nil {
+			return err
+		} else if err == nil {
+			result.Subchannels = append(result.Subchannels, child)
+		}
+	}
+	// Subchannels and sockets come from maps; keep them in creation order
+	sort.Slice(result.Subchannels, func(i, j int) bool { return result.Subchannels[i].ID < result.Subchannels[j].ID })
+	for _, ref := range sockets {
+		socket, err := loadSocket(ctx, source, ref.GetSocketId())
+		if skipErr(err) != nil {
+			return err
+		} else if err == nil {
+			result.Sockets = append(result.Sockets, socket)
+		}
+	}
+	sort.Slice(result.Sockets, func(i, j int) bool { return result.Sockets[i].ID < result.Sockets[j].ID })
+	return nil
+}
+
+// skipErr drops a NotFound error, which means the entity was closed
+func skipErr(err error) error {
+	if status.Code(err) == codes.NotFound {
+		return nil
+	}
+	return err
+}
+
+// loadSocket fetches a socket
+func loadSocket(ctx context.Context, source channelzSource, id int64) (ChannelzSocket, error) {
+	resp, err := source.GetSocket(ctx, &channelzpb.GetSocketRequest{SocketId: id})
+	if err != nil {
+		return ChannelzSocket{}, err
+	}
+	socket := resp.GetSocket()
+	data := socket.GetData()
+	result := ChannelzSocket{
+		ID:                  id,
+		LocalAddress:        channelzAddress(socket.GetLocal()),
+		RemoteAddress:       channelzAddress(socket.GetRemote()),
+		StreamsStarted:      data.GetStreamsStarted(),
+		StreamsSucceeded:    data.GetStreamsSucceeded(),
+		StreamsFailed:       data.GetStreamsFailed(),
+		MessagesSent:        data.GetMessagesSent(),
+		MessagesReceived:    data.GetMessagesReceived(),
+		KeepAlivesSent:      data.GetKeepAlivesSent(),
+		LastMessageSent:     channelzTime(data.GetLastMessageSentTimestamp()),
+		LastMessageReceived: channelzTime(data.GetLastMessageReceivedTimestamp()),
+	}
+	if tls := socket.GetSecurity().GetTls(); tls != nil {
+		result.Security = tls.GetStandardName()
+		if result.Security == "" {
+			result.Security = tls.GetOtherName()
+		}
+	} else if other := socket.GetSecurity().GetOther(); other != nil {
+		result.Security = other.GetName()
+	}
+	return result, nil
+}
+
+// channelFromData converts the data shared by channels and subchannels
+func channelFromData(id int64, name string, data *channelzpb.ChannelData) ChannelzChannel {
+	result := ChannelzChannel{
+		ID:              id,
+		Name:            name,
+		Target:          data.GetTarget(),
+		State:           data.GetState().GetState().String(),
+		CallsStarted:    data.GetCallsStarted(),
+		CallsSucceeded:  data.GetCallsSucceeded(),
+		CallsFailed:     data.GetCallsFailed(),
+		LastCallStarted: channelzTime(data.GetLastCallStartedTimestamp()),
+	}
+	result.Events = channelzEvents(data.GetTrace())
+	return result
+}
+
+// channelzEvents converts a channel trace
+func channelzEvents(trace *channelzpb.ChannelTrace) []ChannelzEvent {
+	var events []ChannelzEvent
+	for _, event := range trace.GetEvents() {
+		events = append(events, ChannelzEvent{
+			Description: event.GetDescription(),
+			Severity:    event.GetSeverity().String(),
+			Timestamp:   event.GetTimestamp().AsTime(),
+		})
+	}
+	return events
+}
+
+// channelzTime converts a timestamp, treating an unset one as nil
+func channelzTime(ts *timestamppb.Timestamp) *time.Time {
+	if ts == nil || !ts.IsValid() || ts.AsTime().UnixNano() <= 0 {
+		return nil
+	}
+	t := ts.AsTime()
+	return &t
+}
+
+// channelzAddress formats a socket address
+func channelzAddress(addr *channelzpb.Address) string {
+	switch {
+	case addr.GetTcpipAddress() != nil:
+		ip := net.IP(addr.GetTcpipAddress().GetIpAddress())
+		return net.JoinHostPort(ip.String(), strconv.Itoa(int(addr.GetTcpipAddress().GetPort())))
+	case addr.GetUdsAddress() != nil:
+		return "unix:" + addr.GetUdsAddress().GetFilename()
+	default:
+		return addr.GetOtherAddress().GetName()
+	}
+}
+
+// lastFailure returns the most recent warning or error traced by channel or
+// any channel below it
+func lastFailure(channel ChannelzChannel) *ChannelzEvent {
+	var last *ChannelzEvent
+	for i, event := range channel.Events {
+		if event.failure() && (last == nil || !event.Timestamp.Before(last.Timestamp)) {
+			last = &channel.Events[i]
+		}
+	}
+	for _, children := range [][]ChannelzChannel{channel.Channels, channel.Subchannels} {
+		for _, child := range children {
+			if failure := lastFailure(child); failure != nil && (last == nil || failure.Timestamp.After(last.Timestamp)) {
+				last = failure
+			}
+		}
+	}
+	return last
+}
+
+// channelAddresses returns the distinct subchannel addresses of channel and
+// its nested channels
+func channelAddresses(channel ChannelzChannel) []string {
+	addresses := []string{}
+	seen := make(map[string]bool)
+	var walk func(ChannelzChannel)
+	walk = func(channel ChannelzChannel) {
+		for _, subchannel := range channel.Subchannels {
+			if subchannel.Target != "" && !seen[subchannel.Target] {
+				seen[subchannel.Target] = true
+				addresses = append(addresses, subchannel.Target)
+			}
+		}
+		for _, child := range channel.Channels {
+			walk(child)
+		}
+	}
+	walk(channel)
+	return addresses
+}
+
+// ConnectionDetails returns the client-side channelz data of a native gRPC
+// profile's connection: its subchannels, resolved addresses, call counts,
+// sockets and trace events
+func (m *ServerProfileManager) ConnectionDetails(ctx context.Context, profileID string) (*ConnectionDetails, error) {
+	if _, err := m.GetConnection(profileID); err != nil {
+		return nil, err
+	}
+	tracker, ok := m.GetGRPCClient().(interface{ ChannelzID(id string) (int64, bool) })
+	if !ok {
+		return nil, ErrNoChannelz
+	}
+	channelID, ok := tracker.ChannelzID(profileID)
+	if !ok {
+		return nil, ErrNoChannelz
+	}
+
+	channel, err := loadChannel(ctx, localChannelz, channelID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read channelz data: %w", err)
+	}
+	details := &ConnectionDetails{
+		ProfileID:   profileID,
+		State:       m.ConnectionState(profileID).String(),
+		Channel:     channel,
+		Addresses:   channelAddresses(channel),
+		LastFailure: lastFailure(channel),
+	}
+	m.mu.RLock()
+	if err := m.connectErrors[profileID]; err != nil {
+		details.ConnectError = err.Error()
+	}
+	m.mu.RUnlock()
+	return details, nil
+}
+
+// RemoteChannelz queries the grpc.channelz.v1 service of a connected
+// profile's server for its servers and the channels it dialled
+func (m *ServerProfileManager) RemoteChannelz(ctx context.Context, profileID string) (*RemoteChannelz, error) {
+	conn, err := m.GetConnection(profileID)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, channelzTimeout)
+	defer cancel()
+
+	source := remoteChannelz{client: channelzpb.NewChannelzClient(conn)}
+	result := &RemoteChannelz{ProfileID: profileID, Servers: []ChannelzServer{}, Channels: []ChannelzChannel{}}
+	var servers []*channelzpb.Server
+	if err := listServers(ctx, source, func(server *channelzpb.Server) { servers = append(servers, server) }); err != nil {
+		return nil, channelzError(err)
+	}
+	for _, server := range servers {
+		converted, err := serverFromProto(ctx, source, server)
+		if err != nil {
+			return nil, channelzError(err)
+		}
+		result.Servers = append(result.Servers, converted)
+	}
+
+	var channelIDs []int64
+	if err := listTopChannels(ctx, source, func(channel *channelzpb.Channel) {
+		channelIDs = append(channelIDs, channel.GetRef().GetChannelId())
+	}); err != nil {
+		return nil, channelzError(err)
+	}
+	for _, id := range channelIDs {
+		channel, err := loadChannel(ctx, source, id)
+		if skipErr(err) != nil {
+			return nil, channelzError(err)
+		} else if err == nil {
+			result.Channels = append(result.Channels, channel)
+		}
+	}
+	return result, nil
+}
+
+// serverFromProto converts a server, fetching its listen sockets
+func serverFromProto(ctx context.Context, source channelzSource, server *channelzpb.Server) (ChannelzServer, error) {
+	data := server.GetData()
+	result := ChannelzServer{
+		ID:              server.GetRef().GetServerId(),
+		Name:            server.GetRef().GetName(),
+		CallsStarted:    data.GetCallsStarted(),
+		CallsSucceeded:  data.GetCallsSucceeded(),
+		CallsFailed:     data.GetCallsFailed(),
+		LastCallStarted: channelzTime(data.GetLastCallStartedTimestamp()),
+		Events:          channelzEvents(data.GetTrace()),
+	}
+	for _, ref := range server.GetListenSocket() {
+		socket, err := loadSocket(ctx, source, ref.GetSocketId())
+		if skipErr(err) != nil {
+			return ChannelzServer{}, err
+		} else if err == nil {
+			result.ListenAddresses = append(result.ListenAddresses, socket.LocalAddress)
+		}
+	}
+	sort.Strings(result.ListenAddresses)
+	return result, nil
+}
+
+// channelzError describes a failed remote channelz query
+func channelzError(err error) error {
+	if status.Code(err) == codes.Unimplemented {
+		return errors.New("the server does not expose grpc.channelz.v1.Channelz")
+	}
+	return fmt.Errorf("channelz query failed: %s", status.Convert(err).Message())
+}
+
+// remoteChannelz adapts a channelz client to channelzSource
+type remoteChannelz struct {
+	client channelzpb.ChannelzClient
+}
+
+func (r remoteChannelz) GetTopChannels(ctx context.Context, req *channelzpb.GetTopChannelsRequest) (*channelzpb.GetTopChannelsResponse, error) {
+	return r.client.GetTopChannels(ctx, req)
+}
+
+func (r remoteChannelz) GetServers(ctx context.Context, req *channelzpb.GetServersRequest) (*channelzpb.GetServersResponse, error) {
+	return r.client.GetServers(ctx, req)
+}
+
+func (r remoteChannelz) GetChannel(ctx context.Context, req *channelzpb.GetChannelRequest) (*channelzpb.GetChannelResponse, error) {
+	return r.client.GetChannel(ctx, req)
+}
+
+func (r remoteChannelz) GetSubchannel(ctx context.Context, req *channelzpb.GetSubchannelRequest) (*channelzpb.GetSubchannelResponse, error) {
+	return r.client.GetSubchannel(ctx, req)
+}
+
+func (r remoteChannelz) GetSocket(ctx context.Context, req *channelzpb.GetSocketRequest) (*channelzpb.GetSocketResponse, error) {
+	return r.client.GetSocket(ctx, req)
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"protodesk/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	channelzservice "google.golang.org/grpc/channelz/service"
+)
+
+// connectProfile connects a new profile for addr with manager
+func connectProfile(t *testing.T, manager *ServerProfileManager, name, addr string) string {
+	t.Helper()
+	host, port := splitTestAddr(t, addr)
+	profile := models.NewServerProfile(name, host, port)
+	ctx := context.Background()
+	require.NoError(t, manager.GetStore().Create(ctx, profile))
+	require.NoError(t, manager.Connect(ctx, profile.ID))
+	return profile.ID
+}
+
+func TestServerProfileManager_ConnectionDetails(t *testing.T) {
+	addr := startTestServer(t)
+	store, cleanup := setupTestStore(t)
+	t.Cleanup(cleanup)
+	manager := NewServerProfileManager(store, nil)
+	t.Cleanup(manager.DisconnectAll)
+	ctx := context.Background()
+
+	first := connectProfile(t, manager, "first", addr)
+	second := connectProfile(t, manager, "second", addr)
+	require.NoError(t, manager.WaitForReady(ctx, first))
+	_, err := manager.Invoke(ctx, first, InvocationRequest{
+		ServiceName: "grpc.health.v1.Health",
+		MethodName:  "Check",
+		RequestJSON: `{"service":"test.Service"}`,
+	})
+	require.NoError(t, err)
+
+	details, err := manager.ConnectionDetails(ctx, first)
+	require.NoError(t, err)
+	assert.Equal(t, "READY", details.State)
+	assert.Equal(t, []string{addr}, details.Addresses)
+	assert.Nil(t, details.LastFailure)
+	// The invocation and the reflection of the profile's services
+	assert.Positive(t, details.Channel.CallsStarted)
+	assert.Positive(t, details.Channel.CallsSucceeded)
+	assert.NotEmpty(t, details.Channel.Events)
+	require.Len(t, details.Channel.Subchannels, 1)
+	subchannel := details.Channel.Subchannels[0]
+	assert.Equal(t, "READY", subchannel.State)
+	require.NotEmpty(t, subchannel.Sockets)
+	assert.Equal(t, addr, subchannel.Sockets[0].RemoteAddress)
+	assert.Positive(t, subchannel.Sockets[0].StreamsSucceeded)
+
+	// Profiles dialling the same target are told apart
+	other, err := manager.ConnectionDetails(ctx, second)
+	require.NoError(t, err)
+	assert.NotEqual(t, details.Channel.ID, other.Channel.ID)
+
+	_, err = manager.ConnectionDetails(ctx, "not-connected")
+	assert.Error(t, err)
+}
+
+func TestServerProfileManager_ConnectionDetailsFailure(t *testing.T) {
+	// Nothing listens on a closed listener's address
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := lis.Addr().String()
+	lis.Close()
+
+	store, cleanup := setupTestStore(t)
+	t.Cleanup(cleanup)
+	manager := NewServerProfileManager(store, nil)
+	t.Cleanup(manager.DisconnectAll)
+	profileID := connectProfile(t, manager, "down", addr)
+
+	var details *ConnectionDetails
+	require.Eventually(t, func() bool {
+		details, err = manager.ConnectionDetails(context.Background(), profileID)
+		return err == nil && details.LastFailure != nil
+	}, 5*time.Second, 20*time.Millisecond)
+	assert.Equal(t, "CT_WARNING", details.LastFailure.Severity)
+	assert.Contains(t, details.LastFailure.Description, addr)
+	assert.Equal(t, []string{addr}, details.Addresses)
+}
+
+func TestServerProfileManager_RemoteChannelz(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := grpc.NewServer()
+	channelzservice.RegisterChannelzServiceToServer(s)
+	go func() { _ = s.Serve(lis) }()
+	t.Cleanup(s.Stop)
+	addr := lis.Addr().String()
+
+	store, cleanup := setupTestStore(t)
+	t.Cleanup(cleanup)
+	manager := NewServerProfileManager(store, nil)
+	t.Cleanup(manager.DisconnectAll)
+	ctx := context.Background()
+	profileID := connectProfile(t, manager, "channelz", addr)
+	require.NoError(t, manager.WaitForReady(ctx, profileID))
+
+	remote, err := manager.RemoteChannelz(ctx, profileID)
+	require.NoError(t, err)
+	assert.Equal(t, profileID, remote.ProfileID)
+	var listening bool
+	for _, server := range remote.Servers {
+		for _, listenAddr := range server.ListenAddresses {
+			listening = listening || listenAddr == addr
+		}
+	}
+	assert.True(t, listening, "no server listening on %s in %+v", addr, remote.Servers)
+
+	// The test server does not expose channelz
+	otherID := connectProfile(t, manager, "plain", startTestServer(t))
+	require.NoError(t, manager.WaitForReady(ctx, otherID))
+	_, err = manager.RemoteChannelz(ctx, otherID)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "does not expose grpc.channelz.v1.Channelz")
+}
+
+func TestServerProfileManager_ConnectionDetailsWeb(t *testing.T) {
+	_, server := startWebBridge(t)
+	store, cleanup := setupTestStore(t)
+	t.Cleanup(cleanup)
+	manager := NewServerProfileManager(store, nil)
+	t.Cleanup(manager.DisconnectAll)
+	ctx := context.Background()
+
+	host, port := splitTestAddr(t, strings.TrimPrefix(server.URL, "http://"))
+	profile := models.NewServerProfile("web", host, port)
+	profile.Protocol = models.ProtocolGRPCWeb
+	require.NoError(t, store.Create(ctx, profile))
+	require.NoError(t, manager.Connect(ctx, profile.ID))
+
+	_, err := manager.ConnectionDetails(ctx, profile.ID)
+	assert.ErrorIs(t, err, ErrNoChannelz)
+}
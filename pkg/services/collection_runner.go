@@ -0,0 +1,256 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sync"
+	"time"
+
+	"protodesk/pkg/models"
+)
+
+// RequestInvoker executes a gRPC call against a server profile
+type RequestInvoker interface {
+	Invoke(ctx context.Context, profileID string, req InvocationRequest) (*InvocationResult, error)
+}
+
+// RunOptions controls how a collection is executed
+type RunOptions struct {
+	// Parallel runs the requests concurrently instead of in order
+	Parallel bool `json:"parallel"`
+	// Concurrency caps the number of requests in flight when Parallel is set.
+	// Zero or less runs every request at once.
+	Concurrency int `json:"concurrency"`
+	// EnvironmentID selects the environment whose variables seed the run.
+	// Values extracted during the run are saved back to it.
+	EnvironmentID string `json:"environmentId,omitempty"`
+	// Variables seed the run and take precedence over the environment
+	Variables map[string]string `json:"variables,omitempty"`
+}
+
+// RequestRunResult is the outcome of running one saved request
+type RequestRunResult struct {
+	RequestID   string                   `json:"requestId"`
+	Name        string                   `json:"name"`
+	ServiceName string                   `json:"serviceName"`
+	MethodName  string                   `json:"methodName"`
+	Passed      bool                     `json:"passed"`
+	Error       string                   `json:"error,omitempty"`
+	Result      *InvocationResult        `json:"result,omitempty"`
+	Assertions  []models.AssertionResult `json:"assertions"`
+	Extracted   map[string]string        `json:"extracted,omitempty"`
+	DurationMs  int64                    `json:"durationMs"`
+}
+
+// RunReport summarises a collection run
+type RunReport struct {
+	CollectionID   string             `json:"collectionId"`
+	CollectionName string             `json:"collectionName"`
+	StartedAt      time.Time          `json:"startedAt"`
+	DurationMs     int64              `json:"durationMs"`
+	Total          int                `json:"total"`
+	Passed         int                `json:"passed"`
+	Failed         int                `json:"failed"`
+	Errored        int                `json:"errored"`
+	Results        []RequestRunResult `json:"results"`
+	// Variables holds every variable in scope when the run finished
+	Variables map[string]string `json:"variables"`
+}
+
+// Success reports whether every request in the run passed
+func (r *RunReport) Success() bool {
+	return r.Failed == 0 && r.Errored == 0
+}
+
+// CollectionRunner executes saved requests and checks their assertions
+type CollectionRunner struct {
+	store   ServerProfileStore
+	invoker RequestInvoker
+}
+
+// NewCollectionRunner creates a new CollectionRunner
+func NewCollectionRunner(store ServerProfileStore, invoker RequestInvoker) *CollectionRunner {
+	return &CollectionRunner{
+		store:   store,
+		invoker: invoker,
+	}
+}
+
+// RunCollection runs every saved request of a collection
+func (r *CollectionRunner) RunCollection(ctx context.Context, collectionID string, opts RunOptions) (*RunReport, error) {
+	collection, err := r.store.GetCollection(ctx, collectionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get collection: %w", err)
+	}
+	requests, err := r.store.ListSavedRequests(ctx, collectionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list saved requests: %w", err)
+	}
+
+	report, err := r.runWithEnvironment(ctx, requests, opts)
+	if err != nil {
+		return nil, err
+	}
+	report.CollectionID = collection.ID
+	report.CollectionName = collection.Name
+	return report, nil
+}
+
+// RunSavedRequest runs a single saved request, resolving and storing variables
+// the same way a collection run does
+func (r *CollectionRunner) RunSavedRequest(ctx context.Context, requestID string, opts RunOptions) (*RequestRunResult, error) {
+	req, err := r.store.GetSavedRequest(ctx, requestID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get saved request: %w", err)
+	}
+	report, err := r.runWithEnvironment(ctx, []*models.SavedRequest{req}, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &report.Results[0], nil
+}
+
+// runWithEnvironment seeds the run with the variables of opts.EnvironmentID
+// and saves the values extracted during the run back to that environment
+func (r *CollectionRunner) runWithEnvironment(ctx context.Context, requests []*models.SavedRequest, opts RunOptions) (*RunReport, error) {
+	if opts.EnvironmentID == "" {
+		return r.RunRequests(ctx, requests, opts), nil
+	}
+
+	env, err := r.store.GetEnvironment(ctx, opts.EnvironmentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get environment: %w", err)
+	}
+	vars := maps.Clone(env.Variables)
+	if vars == nil {
+		vars = map[string]string{}
+	}
+	maps.Copy(vars, opts.Variables)
+	opts.Variables = vars
+
+	report := r.RunRequests(ctx, requests, opts)
+
+	extracted := map[string]string{}
+	for _, res := range report.Results {
+		maps.Copy(extracted, res.Extracted)
+	}
+	if len(extracted) > 0 {
+		if env.Variables == nil {
+			env.Variables = map[string]string{}
+		}
+		maps.Copy(env.Variables, extracted)
+		env.UpdatedAt = time.Now()
+		if err := r.store.UpdateEnvironment(ctx, env); err != nil {
+			return nil, fmt.Errorf("failed to save extracted variables: %w", err)
+		}
+	}
+
+	// Reports are shown and exported, so secret variables are masked
+	report.Variables = env.MaskVariables(report.Variables)
+	for i := range report.Results {
+		report.Results[i].Extracted = env.MaskVariables(report.Results[i].Extracted)
+	}
+	return report, nil
+}
+
+// RunRequests runs the given requests and returns a report with one result
+// per request, in the order the requests were given. Variables extracted by a
+// request are visible to the requests after it; in a parallel run they are
+// only visible to requests that start after the extraction.
+func (r *CollectionRunner) RunRequests(ctx context.Context, requests []*models.SavedRequest, opts RunOptions) *RunReport {
+	scope := NewVariableScope(opts.Variables)
+	report := &RunReport{
+		StartedAt: time.Now(),
+		Total:     len(requests),
+		Results:   make([]RequestRunResult, len(requests)),
+	}
+
+	if opts.Parallel {
+		limit := opts.Concurrency
+		if limit <= 0 || limit > len(requests) {
+			limit = len(requests)
+		}
+		sem := make(chan struct{}, max(limit, 1))
+		var wg sync.WaitGroup
+		for i, req := range requests {
+			wg.Add(1)
+			go func(i int, req *models.SavedRequest) {
+				defer wg.Done()
+				sem <- struct{}{}
+				defer func() { <-sem }()
+				report.Results[i] = r.runRequest(ctx, req, scope)
+			}(i, req)
+		}
+		wg.Wait()
+	} else {
+		for i, req := range requests {
+			report.Results[i] = r.runRequest(ctx, req, scope)
+		}
+	}
+
+	for _, res := range report.Results {
+		switch {
+		case res.Error != "":
+			report.Errored++
+		case res.Passed:
+			report.Passed++
+		default:
+			report.Failed++
+		}
+	}
+	report.Variables = scope.Snapshot()
+	report.DurationMs = time.Since(report.StartedAt).Milliseconds()
+	return report
+}
+
+func (r *CollectionRunner) runRequest(ctx context.Context, req *models.SavedRequest, scope *VariableScope) (res RequestRunResult) {
+	res = RequestRunResult{
+		RequestID:   req.ID,
+		Name:        req.Name,
+		ServiceName: req.ServiceName,
+		MethodName:  req.MethodName,
+		Assertions:  []models.AssertionResult{},
+	}
+	start := time.Now()
+	defer func() { res.DurationMs = time.Since(start).Milliseconds() }()
+
+	invocation, err := ExpandInvocation(req.ServiceName, req.MethodName, req.RequestJSON, req.HeadersJSON, scope.Snapshot())
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	invocation.RequestFormat, invocation.ResponseFormat = req.RequestFormat, req.ResponseFormat
+	result, err := r.invoker.Invoke(ctx, req.ServerProfileID, invocation)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	res.Result = result
+
+	assertions := req.Assertions
+	if len(assertions) == 0 {
+		// Without explicit assertions a request passes when the call succeeds
+		assertions = []models.Assertion{{Type: models.AssertionStatus, Expected: "OK"}}
+	}
+	res.Assertions = EvaluateAssertions(result, assertions)
+	res.Passed = true
+	for _, a := range res.Assertions {
+		if !a.Passed {
+			res.Passed = false
+			break
+		}
+	}
+
+	if len(req.Extractions) > 0 {
+		extracted, err := ApplyExtractions(result, req.Extractions)
+		for name, value := range extracted {
+			scope.Set(name, value)
+		}
+		res.Extracted = extracted
+		if err != nil {
+			res.Error = err.Error()
+		}
+	}
+	return res
+}
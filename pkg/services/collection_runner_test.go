@@ -0,0 +1,234 @@
+package services
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"protodesk/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeInvoker returns canned results keyed by method name
+type fakeInvoker struct {
+	mu       sync.Mutex
+	results  map[string]*InvocationResult
+	calls    []InvocationRequest
+	inFlight int32
+	maxSeen  int32
+	delay    time.Duration
+}
+
+func (f *fakeInvoker) Invoke(ctx context.Context, profileID string, req InvocationRequest) (*InvocationResult, error) {
+	n := atomic.AddInt32(&f.inFlight, 1)
+	defer atomic.AddInt32(&f.inFlight, -1)
+	for {
+		seen := atomic.LoadInt32(&f.maxSeen)
+		if n <= seen || atomic.CompareAndSwapInt32(&f.maxSeen, seen, n) {
+			break
+		}
+	}
+	time.Sleep(f.delay)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, req)
+	res, ok := f.results[req.MethodName]
+	if !ok {
+		return nil, fmt.Errorf("method not found: %s", req.MethodName)
+	}
+	return res, nil
+}
+
+func newRunnerFixture(t *testing.T) (*SQLiteStore, *models.Collection, *models.ServerProfile, func()) {
+	store, cleanup := setupTestStore(t)
+	ctx := context.Background()
+
+	profile := models.NewServerProfile("runner-server", "localhost", 50051)
+	require.NoError(t, store.Create(ctx, profile))
+	collection := models.NewCollection("smoke", "smoke checks")
+	require.NoError(t, store.CreateCollection(ctx, collection))
+	return store, collection, profile, cleanup
+}
+
+func TestCollectionRunner_RunCollection(t *testing.T) {
+	store, collection, profile, cleanup := newRunnerFixture(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	ok := models.NewSavedRequest(collection.ID, profile.ID, "get user", "test.Users", "Get")
+	ok.Position = 0
+	ok.HeadersJSON = `{"authorization":"Bearer t"}`
+	ok.Assertions = []models.Assertion{
+		{Type: models.AssertionStatus, Expected: "OK"},
+		{Type: models.AssertionJSONPath, Expression: "$.id", Expected: "1"},
+	}
+	failing := models.NewSavedRequest(collection.ID, profile.ID, "list users", "test.Users", "List")
+	failing.Position = 1
+	failing.Assertions = []models.Assertion{{Type: models.AssertionLatency, MaxLatencyMs: 10}}
+	missing := models.NewSavedRequest(collection.ID, profile.ID, "delete user", "test.Users", "Delete")
+	missing.Position = 2
+	for _, r := range []*models.SavedRequest{ok, failing, missing} {
+		require.NoError(t, store.CreateSavedRequest(ctx, r))
+	}
+
+	invoker := &fakeInvoker{results: map[string]*InvocationResult{
+		"Get":  {ResponseJSON: `{"id":1}`, StatusName: "OK", LatencyMs: 3},
+		"List": {ResponseJSON: `{}`, StatusName: "OK", LatencyMs: 50},
+	}}
+	runner := NewCollectionRunner(store, invoker)
+
+	report, err := runner.RunCollection(ctx, collection.ID, RunOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "smoke", report.CollectionName)
+	assert.Equal(t, 3, report.Total)
+	assert.Equal(t, 1, report.Passed)
+	assert.Equal(t, 1, report.Failed)
+	assert.Equal(t, 1, report.Errored)
+	assert.False(t, report.Success())
+
+	require.Len(t, report.Results, 3)
+	assert.Equal(t, "get user", report.Results[0].Name)
+	assert.True(t, report.Results[0].Passed)
+	assert.Len(t, report.Results[0].Assertions, 2)
+	assert.False(t, report.Results[1].Passed)
+	assert.Contains(t, report.Results[2].Error, "method not found")
+
+	// Headers from the saved request are sent as metadata
+	require.NotEmpty(t, invoker.calls)
+	assert.Equal(t, []string{"Bearer t"}, invoker.calls[0].Metadata.Get("authorization"))
+}
+
+func TestCollectionRunner_Parallel(t *testing.T) {
+	store, collection, profile, cleanup := newRunnerFixture(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	var requests []*models.SavedRequest
+	for i := 0; i < 6; i++ {
+		r := models.NewSavedRequest(collection.ID, profile.ID, fmt.Sprintf("req-%d", i), "test.Users", "Get")
+		r.Position = i
+		requests = append(requests, r)
+	}
+
+	invoker := &fakeInvoker{
+		results: map[string]*InvocationResult{"Get": {ResponseJSON: `{}`, StatusName: "OK"}},
+		delay:   20 * time.Millisecond,
+	}
+	runner := NewCollectionRunner(store, invoker)
+
+	report := runner.RunRequests(ctx, requests, RunOptions{Parallel: true, Concurrency: 2})
+	assert.True(t, report.Success())
+	assert.Equal(t, 6, report.Passed)
+	assert.LessOrEqual(t, atomic.LoadInt32(&invoker.maxSeen), int32(2))
+	assert.Greater(t, atomic.LoadInt32(&invoker.maxSeen), int32(1))
+	for i, res := range report.Results {
+		assert.Equal(t, fmt.Sprintf("req-%d", i), res.Name)
+	}
+}
+
+func TestRunReport_JUnitXML(t *testing.T) {
+	report := &RunReport{
+		CollectionName: "smoke",
+		StartedAt:      time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		DurationMs:     1500,
+		Total:          3,
+		Passed:         1,
+		Failed:         1,
+		Errored:        1,
+		Results: []RequestRunResult{
+			{Name: "ok", ServiceName: "test.Users", MethodName: "Get", Passed: true, DurationMs: 10},
+			{Name: "bad", ServiceName: "test.Users", MethodName: "List", DurationMs: 20, Assertions: []models.AssertionResult{
+				{Assertion: models.Assertion{Type: models.AssertionStatus, Expected: "OK"}, Message: "expected status OK, got NOT_FOUND"},
+			}},
+			{Name: "broken", ServiceName: "test.Users", MethodName: "Delete", Error: "method not found"},
+		},
+	}
+
+	data, err := report.JUnitXML()
+	require.NoError(t, err)
+
+	var doc junitTestSuites
+	require.NoError(t, xml.Unmarshal(data, &doc))
+	assert.Equal(t, 3, doc.Tests)
+	assert.Equal(t, 1, doc.Failures)
+	assert.Equal(t, 1, doc.Errors)
+	require.Len(t, doc.Suites, 1)
+	suite := doc.Suites[0]
+	assert.Equal(t, "1.500", suite.Time)
+	require.Len(t, suite.Cases, 3)
+	assert.Nil(t, suite.Cases[0].Failure)
+	assert.Equal(t, "test.Users/List", suite.Cases[1].ClassName)
+	require.NotNil(t, suite.Cases[1].Failure)
+	assert.Contains(t, suite.Cases[1].Failure.Body, "got NOT_FOUND")
+	require.NotNil(t, suite.Cases[2].Error)
+	assert.Equal(t, "method not found", suite.Cases[2].Error.Message)
+}
+
+func TestCollectionRunner_Chaining(t *testing.T) {
+	store, collection, profile, cleanup := newRunnerFixture(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	env := models.NewEnvironment("staging")
+	env.Variables = map[string]string{"user": "ada", "token": "stale"}
+	require.NoError(t, store.CreateEnvironment(ctx, env))
+
+	login := models.NewSavedRequest(collection.ID, profile.ID, "login", "test.Auth", "CreateSession")
+	login.RequestJSON = `{"user":"{{user}}"}`
+	login.Extractions = []models.ExtractionRule{
+		{Source: models.ExtractFromJSONPath, Expression: "$.token", Variable: "token"},
+		{Source: models.ExtractFromTrailer, Expression: "x-session-id", Variable: "sessionId"},
+	}
+	get := models.NewSavedRequest(collection.ID, profile.ID, "get profile", "test.Users", "Get")
+	get.Position = 1
+	get.RequestJSON = `{"session":"{{sessionId}}"}`
+	get.HeadersJSON = `{"authorization":"Bearer {{token}}"}`
+	undefined := models.NewSavedRequest(collection.ID, profile.ID, "undefined", "test.Users", "Get")
+	undefined.Position = 2
+	undefined.RequestJSON = `{"id":"{{nope}}"}`
+	for _, r := range []*models.SavedRequest{login, get, undefined} {
+		require.NoError(t, store.CreateSavedRequest(ctx, r))
+	}
+
+	invoker := &fakeInvoker{results: map[string]*InvocationResult{
+		"CreateSession": {
+			ResponseJSON: `{"token":"fresh"}`,
+			StatusName:   "OK",
+			Trailers:     map[string][]string{"x-session-id": {"s-9"}},
+		},
+		"Get": {ResponseJSON: `{}`, StatusName: "OK"},
+	}}
+	runner := NewCollectionRunner(store, invoker)
+
+	report, err := runner.RunCollection(ctx, collection.ID, RunOptions{EnvironmentID: env.ID})
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Passed)
+	assert.Equal(t, 1, report.Errored)
+	assert.Contains(t, report.Results[2].Error, "nope")
+
+	// The environment seeds the first request and extracted values feed the next
+	require.Len(t, invoker.calls, 2)
+	assert.Equal(t, `{"user":"ada"}`, invoker.calls[0].RequestJSON)
+	assert.Equal(t, `{"session":"s-9"}`, invoker.calls[1].RequestJSON)
+	assert.Equal(t, []string{"Bearer fresh"}, invoker.calls[1].Metadata.Get("authorization"))
+	assert.Equal(t, map[string]string{"token": "fresh", "sessionId": "s-9"}, report.Results[0].Extracted)
+	assert.Equal(t, "fresh", report.Variables["token"])
+
+	// Extracted values are saved back to the environment
+	saved, err := store.GetEnvironment(ctx, env.ID)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"user": "ada", "token": "fresh", "sessionId": "s-9"}, saved.Variables)
+
+	// A single request run sees the stored values
+	res, err := runner.RunSavedRequest(ctx, get.ID, RunOptions{EnvironmentID: env.ID})
+	require.NoError(t, err)
+	assert.True(t, res.Passed)
+	assert.Equal(t, `{"session":"s-9"}`, invoker.calls[2].RequestJSON)
+}
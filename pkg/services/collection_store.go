@@ -0,0 +1,249 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"protodesk/pkg/models"
+)
+
+// CreateCollection inserts a new collection
+func (s *SQLiteStore) CreateCollection(ctx context.Context, c *models.Collection) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	query := `
+		INSERT INTO collections (id, name, description, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+	`
+	_, err := s.q.ExecContext(ctx, query, c.ID, c.Name, c.Description, c.CreatedAt, c.UpdatedAt)
+	return err
+}
+
+// GetCollection returns a collection by ID
+func (s *SQLiteStore) GetCollection(ctx context.Context, id string) (*models.Collection, error) {
+	var c models.Collection
+	query := `SELECT * FROM collections WHERE id = ?`
+	if err := s.q.GetContext(ctx, &c, query, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrCollectionNotFound
+		}
+		return nil, err
+	}
+	return &c, nil
+}
+
+// ListCollections returns all collections ordered by name
+func (s *SQLiteStore) ListCollections(ctx context.Context) ([]*models.Collection, error) {
+	var collections []*models.Collection
+	query := `SELECT * FROM collections ORDER BY name`
+	if err := s.q.SelectContext(ctx, &collections, query); err != nil {
+		return nil, err
+	}
+	return collections, nil
+}
+
+// UpdateCollection updates the name and description of a collection
+func (s *SQLiteStore) UpdateCollection(ctx context.Context, c *models.Collection) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	query := `UPDATE collections SET name = ?, description = ?, updated_at = ? WHERE id = ?`
+	result, err := s.q.ExecContext(ctx, query, c.Name, c.Description, c.UpdatedAt, c.ID)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return models.ErrCollectionNotFound
+	}
+	return nil
+}
+
+// DeleteCollection deletes a collection and, through the foreign key, its saved requests
+func (s *SQLiteStore) DeleteCollection(ctx context.Context, id string) error {
+	result, err := s.q.ExecContext(ctx, `DELETE FROM collections WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return models.ErrCollectionNotFound
+	}
+	return nil
+}
+
+// CreateSavedRequest inserts a new saved request
+func (s *SQLiteStore) CreateSavedRequest(ctx context.Context, r *models.SavedRequest) error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+	if err := marshalSavedRequest(r); err != nil {
+		return err
+	}
+	query := `
+		INSERT INTO saved_requests (
+			id, collection_id, server_profile_id, name, service_name, method_name,
+			request_json, request_format, response_format, headers_json, position,
+			assertions_json, extractions_json, created_at, updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	_, err := s.q.ExecContext(ctx, query,
+		r.ID,
+		r.CollectionID,
+		r.ServerProfileID,
+		r.Name,
+		r.ServiceName,
+		r.MethodName,
+		r.RequestJSON,
+		r.RequestFormat,
+		r.ResponseFormat,
+		r.HeadersJSON,
+		r.Position,
+		r.AssertionsJSON,
+		r.ExtractionsJSON,
+		r.CreatedAt,
+		r.UpdatedAt,
+	)
+	return err
+}
+
+// GetSavedRequest returns a saved request by ID
+func (s *SQLiteStore) GetSavedRequest(ctx context.Context, id string) (*models.SavedRequest, error) {
+	var r models.SavedRequest
+	query := `SELECT * FROM saved_requests WHERE id = ?`
+	if err := s.q.GetContext(ctx, &r, query, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrSavedRequestNotFound
+		}
+		return nil, err
+	}
+	unmarshalSavedRequest(&r)
+	return &r, nil
+}
+
+// ListSavedRequests returns the saved requests of a collection in run order
+func (s *SQLiteStore) ListSavedRequests(ctx context.Context, collectionID string) ([]*models.SavedRequest, error) {
+	var requests []*models.SavedRequest
+	query := `SELECT * FROM saved_requests WHERE collection_id = ? ORDER BY position, created_at`
+	if err := s.q.SelectContext(ctx, &requests, query, collectionID); err != nil {
+		return nil, err
+	}
+	for _, r := range requests {
+		unmarshalSavedRequest(r)
+	}
+	return requests, nil
+}
+
+// UpdateSavedRequest updates an existing saved request
+func (s *SQLiteStore) UpdateSavedRequest(ctx context.Context, r *models.SavedRequest) error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+	if err := marshalSavedRequest(r); err != nil {
+		return err
+	}
+	query := `
+		UPDATE saved_requests SET
+			collection_id = ?,
+			server_profile_id = ?,
+			name = ?,
+			service_name = ?,
+			method_name = ?,
+			request_json = ?,
+			request_format = ?,
+			response_format = ?,
+			headers_json = ?,
+			position = ?,
+			assertions_json = ?,
+			extractions_json = ?,
+			updated_at = ?
+		WHERE id = ?
+	`
+	result, err := s.q.ExecContext(ctx, query,
+		r.CollectionID,
+		r.ServerProfileID,
+		r.Name,
+		r.ServiceName,
+		r.MethodName,
+		r.RequestJSON,
+		r.RequestFormat,
+		r.ResponseFormat,
+		r.HeadersJSON,
+		r.Position,
+		r.AssertionsJSON,
+		r.ExtractionsJSON,
+		r.UpdatedAt,
+		r.ID,
+	)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return models.ErrSavedRequestNotFound
+	}
+	return nil
+}
+
+// DeleteSavedRequest deletes a saved request by ID
+func (s *SQLiteStore) DeleteSavedRequest(ctx context.Context, id string) error {
+	result, err := s.q.ExecContext(ctx, `DELETE FROM saved_requests WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return models.ErrSavedRequestNotFound
+	}
+	return nil
+}
+
+// marshalSavedRequest serializes the JSON-backed columns of a saved request
+func marshalSavedRequest(r *models.SavedRequest) error {
+	assertions := r.Assertions
+	if assertions == nil {
+		assertions = []models.Assertion{}
+	}
+	data, err := json.Marshal(assertions)
+	if err != nil {
+		return fmt.Errorf("failed to marshal assertions: %w", err)
+	}
+	r.AssertionsJSON = string(data)
+
+	extractions := r.Extractions
+	if extractions == nil {
+		extractions = []models.ExtractionRule{}
+	}
+	data, err = json.Marshal(extractions)
+	if err != nil {
+		return fmt.Errorf("failed to marshal extractions: %w", err)
+	}
+	r.ExtractionsJSON = string(data)
+	return nil
+}
+
+// unmarshalSavedRequest restores the JSON-backed fields of a saved request
+func unmarshalSavedRequest(r *models.SavedRequest) {
+	if r.AssertionsJSON != "" {
+		_ = json.Unmarshal([]byte(r.AssertionsJSON), &r.Assertions)
+	}
+	if r.ExtractionsJSON != "" {
+		_ = json.Unmarshal([]byte(r.ExtractionsJSON), &r.Extractions)
+	}
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"protodesk/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteStore_CollectionCRUD(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	profile := models.NewServerProfile("test-server", "localhost", 50051)
+	require.NoError(t, store.Create(ctx, profile))
+
+	// Create
+	collection := models.NewCollection("smoke", "smoke checks")
+	require.NoError(t, store.CreateCollection(ctx, collection))
+	assert.Equal(t, models.ErrEmptyCollectionName, store.CreateCollection(ctx, models.NewCollection("", "")))
+
+	// Get and list
+	got, err := store.GetCollection(ctx, collection.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "smoke", got.Name)
+	collections, err := store.ListCollections(ctx)
+	require.NoError(t, err)
+	assert.Len(t, collections, 1)
+
+	// Update
+	collection.Name = "regression"
+	collection.UpdatedAt = time.Now()
+	require.NoError(t, store.UpdateCollection(ctx, collection))
+	got, err = store.GetCollection(ctx, collection.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "regression", got.Name)
+
+	// Saved requests
+	second := models.NewSavedRequest(collection.ID, profile.ID, "second", "test.Svc", "B")
+	second.Position = 2
+	first := models.NewSavedRequest(collection.ID, profile.ID, "first", "test.Svc", "A")
+	first.Position = 1
+	first.Assertions = []models.Assertion{
+		{Type: models.AssertionStatus, Expected: "OK"},
+		{Type: models.AssertionLatency, MaxLatencyMs: 500},
+	}
+	require.NoError(t, store.CreateSavedRequest(ctx, second))
+	require.NoError(t, store.CreateSavedRequest(ctx, first))
+
+	requests, err := store.ListSavedRequests(ctx, collection.ID)
+	require.NoError(t, err)
+	require.Len(t, requests, 2)
+	assert.Equal(t, "first", requests[0].Name)
+	assert.Equal(t, first.Assertions, requests[0].Assertions)
+
+	first.RequestJSON = `{"id":1}`
+	first.Assertions = nil
+	require.NoError(t, store.UpdateSavedRequest(ctx, first))
+	gotReq, err := store.GetSavedRequest(ctx, first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, `{"id":1}`, gotReq.RequestJSON)
+	assert.Empty(t, gotReq.Assertions)
+
+	// Requests kept in other formats round-trip with their formats
+	first.RequestJSON = `id: 1`
+	first.RequestFormat = models.FormatPrototext
+	first.ResponseFormat = models.FormatYAML
+	require.NoError(t, store.UpdateSavedRequest(ctx, first))
+	gotReq, err = store.GetSavedRequest(ctx, first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, `id: 1`, gotReq.RequestJSON)
+	assert.Equal(t, models.FormatPrototext, gotReq.RequestFormat)
+	assert.Equal(t, models.FormatYAML, gotReq.ResponseFormat)
+
+	// Invalid assertions are rejected
+	bad := models.NewSavedRequest(collection.ID, profile.ID, "bad", "test.Svc", "C")
+	bad.Assertions = []models.Assertion{{Type: models.AssertionJSONPath}}
+	assert.ErrorIs(t, store.CreateSavedRequest(ctx, bad), models.ErrInvalidAssertion)
+	bad.Assertions = nil
+	bad.ResponseFormat = "xml"
+	assert.ErrorIs(t, store.CreateSavedRequest(ctx, bad), models.ErrInvalidMessageFormat)
+
+	// Delete
+	require.NoError(t, store.DeleteSavedRequest(ctx, second.ID))
+	assert.Equal(t, models.ErrSavedRequestNotFound, store.DeleteSavedRequest(ctx, second.ID))
+
+	// Deleting the collection removes its requests
+	require.NoError(t, store.DeleteCollection(ctx, collection.ID))
+	_, err = store.GetCollection(ctx, collection.ID)
+	assert.Equal(t, models.ErrCollectionNotFound, err)
+	_, err = store.GetSavedRequest(ctx, first.ID)
+	assert.Equal(t, models.ErrSavedRequestNotFound, err)
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"protodesk/pkg/models"
+)
+
+// CreateEnvironment inserts a new environment
+func (s *SQLiteStore) CreateEnvironment(ctx context.Context, env *models.Environment) error {
+	if err := env.Validate(); err != nil {
+		return err
+	}
+	if err := s.marshalEnvironment(env); err != nil {
+		return err
+	}
+	query := `
+		INSERT INTO environments (id, name, variables_json, secrets_json, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`
+	_, err := s.q.ExecContext(ctx, query, env.ID, env.Name, env.VariablesJSON, env.SecretsJSON, env.CreatedAt, env.UpdatedAt)
+	return err
+}
+
+// GetEnvironment returns an environment by ID
+func (s *SQLiteStore) GetEnvironment(ctx context.Context, id string) (*models.Environment, error) {
+	var env models.Environment
+	query := `SELECT * FROM environments WHERE id = ?`
+	if err := s.q.GetContext(ctx, &env, query, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrEnvironmentNotFound
+		}
+		return nil, err
+	}
+	s.unmarshalEnvironment(&env)
+	return &env, nil
+}
+
+// ListEnvironments returns all environments ordered by name
+func (s *SQLiteStore) ListEnvironments(ctx context.Context) ([]*models.Environment, error) {
+	var envs []*models.Environment
+	query := `SELECT * FROM environments ORDER BY name`
+	if err := s.q.SelectContext(ctx, &envs, query); err != nil {
+		return nil, err
+	}
+	for _, env := range envs {
+		s.unmarshalEnvironment(env)
+	}
+	return envs, nil
+}
+
+// UpdateEnvironment updates the name and variables of an environment
+func (s *SQLiteStore) UpdateEnvironment(ctx context.Context, env *models.Environment) error {
+	if err := env.Validate(); err != nil {
+		return err
+	}
+	if err := s.marshalEnvironment(env); err != nil {
+		return err
+	}
+	query := `UPDATE environments SET name = ?, variables_json = ?, secrets_json = ?, updated_at = ? WHERE id = ?`
+	result, err := s.q.ExecContext(ctx, query, env.Name, env.VariablesJSON, env.SecretsJSON, env.UpdatedAt, env.ID)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return models.ErrEnvironmentNotFound
+	}
+	return nil
+}
+
+// DeleteEnvironment deletes an environment by ID
+func (s *SQLiteStore) DeleteEnvironment(ctx context.Context, id string) error {
+	result, err := s.q.ExecContext(ctx, `DELETE FROM environments WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return models.ErrEnvironmentNotFound
+	}
+	return nil
+}
+
+// marshalEnvironment serializes the variables of an environment, encrypting
+// the values of secret variables
+func (s *SQLiteStore) marshalEnvironment(env *models.Environment) error {
+	vars := make(map[string]string, len(env.Variables))
+	for name, value := range env.Variables {
+		if env.IsSecret(name) {
+			sealed, err := s.sealSecret(value)
+			if err != nil {
+				return fmt.Errorf("variable %s: %w", name, err)
+			}
+			value = sealed
+		}
+		vars[name] = value
+	}
+	data, err := json.Marshal(vars)
+	if err != nil {
+		return fmt.Errorf("failed to marshal variables: %w", err)
+	}
+	env.VariablesJSON = string(data)
+
+	secretNames := env.Secrets
+	if secretNames == nil {
+		secretNames = []string{}
+	}
+	data, err = json.Marshal(secretNames)
+	if err != nil {
+		return fmt.Errorf("failed to marshal secret names: %w", err)
+	}
+	env.SecretsJSON = string(data)
+	return nil
+}
+
+// unmarshalEnvironment restores the variables of an environment
+func (s *SQLiteStore) unmarshalEnvironment(env *models.Environment) {
+	env.Variables = map[string]string{}
+	if env.VariablesJSON != "" {
+		_ = json.Unmarshal([]byte(env.VariablesJSON), &env.Variables)
+	}
+	env.Secrets = []string{}
+	if env.SecretsJSON != "" {
+		_ = json.Unmarshal([]byte(env.SecretsJSON), &env.Secrets)
+	}
+	for name, value := range env.Variables {
+		env.Variables[name] = s.openSecret(value)
+	}
+}
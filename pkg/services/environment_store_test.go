@@ -0,0 +1,64 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"protodesk/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteStore_EnvironmentOperations(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	env := models.NewEnvironment("local")
+	env.Variables["host"] = "localhost"
+	require.NoError(t, store.CreateEnvironment(ctx, env))
+
+	got, err := store.GetEnvironment(ctx, env.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "local", got.Name)
+	assert.Equal(t, map[string]string{"host": "localhost"}, got.Variables)
+
+	got.Variables["token"] = "abc"
+	got.UpdatedAt = time.Now()
+	require.NoError(t, store.UpdateEnvironment(ctx, got))
+
+	envs, err := store.ListEnvironments(ctx)
+	require.NoError(t, err)
+	require.Len(t, envs, 1)
+	assert.Equal(t, "abc", envs[0].Variables["token"])
+
+	bad := models.NewEnvironment("bad")
+	bad.Variables["not valid"] = "x"
+	assert.ErrorIs(t, store.CreateEnvironment(ctx, bad), models.ErrInvalidVariableName)
+
+	require.NoError(t, store.DeleteEnvironment(ctx, env.ID))
+	_, err = store.GetEnvironment(ctx, env.ID)
+	assert.Equal(t, models.ErrEnvironmentNotFound, err)
+	assert.Equal(t, models.ErrEnvironmentNotFound, store.DeleteEnvironment(ctx, env.ID))
+}
+
+func TestSQLiteStore_SavedRequestExtractions(t *testing.T) {
+	store, collection, profile, cleanup := newRunnerFixture(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	req := models.NewSavedRequest(collection.ID, profile.ID, "login", "test.Auth", "CreateSession")
+	req.Extractions = []models.ExtractionRule{
+		{Source: models.ExtractFromJSONPath, Expression: "$.token", Variable: "token"},
+	}
+	require.NoError(t, store.CreateSavedRequest(ctx, req))
+
+	got, err := store.GetSavedRequest(ctx, req.ID)
+	require.NoError(t, err)
+	assert.Equal(t, req.Extractions, got.Extractions)
+
+	req.Extractions = append(req.Extractions, models.ExtractionRule{Source: "bogus", Expression: "x", Variable: "y"})
+	assert.ErrorIs(t, store.UpdateSavedRequest(ctx, req), models.ErrInvalidExtraction)
+}
@@ -3,12 +3,18 @@ package services
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sync"
 	"time"
 
+	"protodesk/pkg/logging"
+	"protodesk/pkg/models"
+
 	"github.com/jhump/protoreflect/desc"
 	"github.com/jhump/protoreflect/grpcreflect"
 	"google.golang.org/grpc"
@@ -19,55 +25,87 @@ import (
 	"google.golang.org/protobuf/types/descriptorpb"
 )
 
-// GRPCClientManager defines the interface for managing gRPC client connections
+// errConnectionClosed is returned when waiting for a connection that is closed
+var errConnectionClosed = errors.New("connection was closed before it became ready")
+
+// GRPCClientManager defines the interface for managing gRPC client
+// connections. Connections are keyed by an ID, normally the server profile
+// ID, so that profiles pointing at the same target each get a connection
+// dialled with their own options.
 type GRPCClientManager interface {
-	Connect(ctx context.Context, target string, useTLS bool, certPath string) error
-	Disconnect(target string) error
-	GetConnection(target string) (*grpc.ClientConn, error)
-	ListServicesAndMethods(conn *grpc.ClientConn) (map[string][]string, error)
-	GetMethodInputDescriptor(conn *grpc.ClientConn, serviceName, methodName string) ([]FieldDescriptor, error)
+	Connect(ctx context.Context, id string, target string, opts ConnectOptions) error
+	Disconnect(id string) error
+	GetConnection(id string) (*grpc.ClientConn, error)
+	ListServicesAndMethods(conn grpc.ClientConnInterface) (map[string][]string, error)
+	GetMethodInputDescriptor(conn grpc.ClientConnInterface, serviceName, methodName string) ([]FieldDescriptor, error)
+}
+
+// ConnectOptions describes how a connection is dialled
+type ConnectOptions struct {
+	UseTLS   bool
+	CertPath string
+	// DialOptions are applied after the defaults, e.g. per-RPC credentials
+	DialOptions []grpc.DialOption
+	// Reconnect is the backoff for re-establishing a dropped connection; the
+	// zero value uses DefaultReconnectPolicy
+	Reconnect ReconnectPolicy
+	// Timeout bounds how long the connection may take to become ready; zero
+	// uses models.DefaultConnectTimeout
+	Timeout time.Duration
+	// OnStateChange is called from a background watcher with each
+	// connectivity transition
+	OnStateChange func(conn *grpc.ClientConn, state connectivity.State)
+	// OnReady is called once, when the connection first becomes ready
+	OnReady func(conn *grpc.ClientConn)
+	// OnTimeout is called if the connection is not ready within Timeout. If
+	// it is nil the connection keeps retrying.
+	OnTimeout func(conn *grpc.ClientConn, err error)
 }
 
 // DefaultGRPCClientManager manages gRPC client connections
 type DefaultGRPCClientManager struct {
+	logger      *slog.Logger
+	mu          sync.RWMutex
 	connections map[string]*grpc.ClientConn
-	contexts    map[string]context.Context
+	// contexts carry the outgoing metadata of each connection for reflection calls
+	contexts map[string]context.Context
+	// channelzIDs hold the channelz channel ID of each connection
+	channelzIDs map[string]int64
 }
 
-// NewGRPCClientManager creates a new DefaultGRPCClientManager
-func NewGRPCClientManager() *DefaultGRPCClientManager {
+// NewGRPCClientManager creates a new DefaultGRPCClientManager logging to
+// logger; a nil logger discards the output
+func NewGRPCClientManager(logger *slog.Logger) *DefaultGRPCClientManager {
 	return &DefaultGRPCClientManager{
+		logger:      logging.OrDiscard(logger),
 		connections: make(map[string]*grpc.ClientConn),
 		contexts:    make(map[string]context.Context),
+		channelzIDs: make(map[string]int64),
 	}
 }
 
-// debugPrintConnections prints the current state of all connections
-func (m *DefaultGRPCClientManager) debugPrintConnections() {
-	fmt.Printf("[DEBUG] Current connections state:\n")
-	for target, conn := range m.connections {
-		fmt.Printf("[DEBUG] - Target: %s, Connection: %p, State: %v\n", target, conn, conn.GetState())
-	}
-}
-
-// Connect establishes a gRPC connection to the specified server
-func (m *DefaultGRPCClientManager) Connect(ctx context.Context, target string, useTLS bool, certPath string) error {
-	fmt.Printf("[DEBUG] Starting connection to %s (TLS: %v)\n", target, useTLS)
+// Connect creates a gRPC connection to target and stores it under id,
+// replacing and closing any previous connection with that ID. It does not
+// wait for the server: the connection is dialled in the background and its
+// progress reported through the callbacks in connectOpts.
+func (m *DefaultGRPCClientManager) Connect(ctx context.Context, id string, target string, connectOpts ConnectOptions) error {
+	logger := m.logger.With("connection", id, "target", target)
+	logger.Debug("Starting connection", "tls", connectOpts.UseTLS)
 	var opts []grpc.DialOption
 
-	// Add default options for HTTP/2
+	// Add default options for HTTP/2. Proxies are configured per profile and
+	// applied through DialOptions, never picked up by gRPC itself.
 	opts = append(opts,
 		grpc.WithDefaultServiceConfig(`{"loadBalancingPolicy":"round_robin"}`),
 		grpc.WithNoProxy(),
-		grpc.WithBlock(), // Block until connection is established
+		grpc.WithStatsHandler(wireCaptureHandler{}),
 	)
 
-	if useTLS {
-		if certPath != "" {
+	if connectOpts.UseTLS {
+		if connectOpts.CertPath != "" {
 			// TODO: Implement custom certificate loading
 			return fmt.Errorf("custom certificates not implemented yet")
 		}
-		fmt.Printf("[DEBUG] Using TLS with system certificates\n")
 		// Use system root certificates with more permissive settings for production servers
 		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{
 			MinVersion: tls.VersionTLS12,
@@ -77,87 +115,142 @@ func (m *DefaultGRPCClientManager) Connect(ctx context.Context, target string, u
 			InsecureSkipVerify: true,
 		})))
 	} else {
-		fmt.Printf("[DEBUG] Using insecure connection\n")
 		opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
 	}
+	reconnect := connectOpts.Reconnect
+	if reconnect == (ReconnectPolicy{}) {
+		reconnect = DefaultReconnectPolicy()
+	}
+	opts = append(opts, reconnect.dialOption())
+	opts = append(opts, connectOpts.DialOptions...)
+
+	channelzDialMu.Lock()
+	conn, err := grpc.NewClient(target, opts...)
+	var channelzID int64
+	if err == nil {
+		channelzID, _ = newestTopChannel(ctx, localChannelz, target)
+	}
+	channelzDialMu.Unlock()
+	if err != nil {
+		logger.Error("Connection failed", "error", err)
+		return fmt.Errorf("failed to connect to %s: %w", target, err)
+	}
+
+	// Store a context that keeps the caller's metadata but outlives its
+	// cancellation
+	m.mu.Lock()
+	previous := m.connections[id]
+	m.connections[id] = conn
+	m.contexts[id] = context.WithoutCancel(ctx)
+	if channelzID != 0 {
+		m.channelzIDs[id] = channelzID
+	} else {
+		delete(m.channelzIDs, id)
+	}
+	logger.Debug("Connection stored", "connections", len(m.connections))
+	m.mu.Unlock()
+
+	if previous != nil {
+		_ = previous.Close()
+	}
 
-	// Create a timeout context for the connection attempt
-	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	// Leave the idle state now rather than on the first call
+	conn.Connect()
+	go watchConnection(logger, conn, connectOpts.OnStateChange)
+	go awaitReady(logger, conn, target, connectOpts)
+	return nil
+}
+
+// awaitReady waits for conn to become ready and reports the outcome through
+// OnReady or OnTimeout
+func awaitReady(logger *slog.Logger, conn *grpc.ClientConn, target string, connectOpts ConnectOptions) {
+	timeout := connectOpts.Timeout
+	if timeout <= 0 {
+		timeout = models.DefaultConnectTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	fmt.Printf("[DEBUG] Attempting to establish connection...\n")
-	// Create connection
-	conn, err := grpc.DialContext(timeoutCtx, target, opts...)
-	if err != nil {
-		if err == context.DeadlineExceeded {
-			fmt.Printf("[ERROR] Connection timeout after 5 seconds\n")
-			return fmt.Errorf("connection timeout: server at %s did not respond within 5 seconds. Please check if the server is running and accessible", target)
+	err := waitForReady(ctx, conn)
+	switch {
+	case err == nil:
+		logger.Debug("Connection is ready")
+		if connectOpts.OnReady != nil {
+			connectOpts.OnReady(conn)
+		}
+	case errors.Is(err, context.DeadlineExceeded):
+		logger.Error("Connection timed out", "timeout", timeout)
+		if connectOpts.OnTimeout != nil {
+			connectOpts.OnTimeout(conn, fmt.Errorf("connection timeout: server at %s did not become ready within %v. Please check if the server is running and accessible", target, timeout))
 		}
-		fmt.Printf("[ERROR] Connection failed: %v\n", err)
-		return fmt.Errorf("failed to connect to %s: %w", target, err)
 	}
+}
 
-	fmt.Printf("[DEBUG] Connection established, waiting for ready state...\n")
-	// Wait for connection to be ready
-	ready := make(chan struct{})
-	go func() {
-		for {
-			state := conn.GetState()
-			fmt.Printf("[DEBUG] Connection state: %v\n", state)
-			if state == connectivity.Ready {
-				close(ready)
-				return
-			}
-			if state == connectivity.Shutdown || state == connectivity.TransientFailure {
-				fmt.Printf("[ERROR] Connection failed to become ready, final state: %v\n", state)
-				return
-			}
-			time.Sleep(100 * time.Millisecond)
+// waitForReady blocks until conn is ready, conn is closed or ctx is done
+func waitForReady(ctx context.Context, conn *grpc.ClientConn) error {
+	for state := conn.GetState(); state != connectivity.Ready; state = conn.GetState() {
+		if state == connectivity.Shutdown {
+			return errConnectionClosed
 		}
-	}()
-
-	select {
-	case <-ready:
-		// Connection is ready - store the original context without timeout
-		fmt.Printf("[DEBUG] Connection is ready, storing context and connection\n")
-		m.connections[target] = conn
-		m.contexts[target] = ctx
-		m.debugPrintConnections()
-		return nil
-	case <-timeoutCtx.Done():
-		// Context timed out or was cancelled
-		fmt.Printf("[ERROR] Connection timeout while waiting for ready state\n")
-		conn.Close()
-		if timeoutCtx.Err() == context.DeadlineExceeded {
-			return fmt.Errorf("connection timeout: server at %s did not become ready within 5 seconds. Please check if the server is running and accessible", target)
+		if state == connectivity.Idle {
+			conn.Connect()
+		}
+		if !conn.WaitForStateChange(ctx, state) {
+			return ctx.Err()
 		}
-		return fmt.Errorf("connection cancelled: %w", timeoutCtx.Err())
 	}
+	return nil
 }
 
-// Disconnect closes the connection to the specified server
-func (m *DefaultGRPCClientManager) Disconnect(target string) error {
-	if conn, exists := m.connections[target]; exists {
-		err := conn.Close()
-		if err != nil {
+// Disconnect closes the connection stored under id
+func (m *DefaultGRPCClientManager) Disconnect(id string) error {
+	m.mu.Lock()
+	conn, exists := m.connections[id]
+	delete(m.connections, id)
+	delete(m.contexts, id)
+	delete(m.channelzIDs, id)
+	m.mu.Unlock()
+
+	if exists {
+		if err := conn.Close(); err != nil {
 			return fmt.Errorf("failed to close connection: %w", err)
 		}
-		delete(m.connections, target)
 	}
 	return nil
 }
 
-// GetConnection returns an existing connection for the specified target
-func (m *DefaultGRPCClientManager) GetConnection(target string) (*grpc.ClientConn, error) {
-	fmt.Printf("[DEBUG] GetConnection called for target: %s\n", target)
-	m.debugPrintConnections()
+// GetConnection returns the connection stored under id
+func (m *DefaultGRPCClientManager) GetConnection(id string) (*grpc.ClientConn, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 
-	if conn, exists := m.connections[target]; exists {
-		fmt.Printf("[DEBUG] Found connection %p for target %s\n", conn, target)
+	if conn, exists := m.connections[id]; exists {
 		return conn, nil
 	}
-	fmt.Printf("[ERROR] No connection found for target: %s\n", target)
-	return nil, fmt.Errorf("no connection found for target: %s", target)
+	return nil, fmt.Errorf("no connection found for %s", id)
+}
+
+// ChannelzID returns the channelz channel ID of the connection stored under
+// id, for reading its channelz data
+func (m *DefaultGRPCClientManager) ChannelzID(id string) (int64, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	channelzID, exists := m.channelzIDs[id]
+	return channelzID, exists
+}
+
+// connectionContext returns the context stored with conn, or a background
+// context for connections this manager did not dial
+func (m *DefaultGRPCClientManager) connectionContext(conn grpc.ClientConnInterface) context.Context {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for id, storedConn := range m.connections {
+		if storedConn == conn {
+			return m.contexts[id]
+		}
+	}
+	m.logger.Debug("No context found for connection, using background context")
+	return context.Background()
 }
 
 // findProtobufIncludePath finds the protobuf include path by running protoc --version
@@ -213,51 +306,27 @@ func findProtobufIncludePath() (string, error) {
 }
 
 // ListServicesAndMethods uses gRPC reflection to list all services and their methods for a given connection
-func (m *DefaultGRPCClientManager) ListServicesAndMethods(conn *grpc.ClientConn) (map[string][]string, error) {
-	fmt.Printf("[DEBUG] Starting ListServicesAndMethods for connection %p\n", conn)
-	m.debugPrintConnections()
-
-	// Find the context for this connection
-	var ctx context.Context
-	var target string
-	for t, storedConn := range m.connections {
-		fmt.Printf("[DEBUG] Checking connection %p against stored connection %p for target %s\n", conn, storedConn, t)
-		if storedConn == conn {
-			ctx = m.contexts[t]
-			target = t
-			fmt.Printf("[DEBUG] Found matching connection for target %s\n", t)
-			break
-		}
-	}
-	if ctx == nil {
-		fmt.Printf("[WARN] No context found for connection %p, using background context\n", conn)
-		ctx = context.Background()
-	} else {
-		fmt.Printf("[DEBUG] Using context for target: %s\n", target)
-	}
+func (m *DefaultGRPCClientManager) ListServicesAndMethods(conn grpc.ClientConnInterface) (map[string][]string, error) {
+	ctx := m.connectionContext(conn)
 
 	// Create a reflection client with the context that has headers
-	fmt.Printf("[DEBUG] Creating reflection client\n")
 	rc := grpcreflect.NewClient(ctx, reflectpb.NewServerReflectionClient(conn))
 	defer rc.Reset()
 
 	// First, try to list services
-	fmt.Printf("[DEBUG] Attempting to list services\n")
 	services, err := rc.ListServices()
 	if err != nil {
-		fmt.Printf("[ERROR] Failed to list services: %v\n", err)
 		return nil, fmt.Errorf("failed to list services: %w", err)
 	}
 
-	fmt.Printf("[DEBUG] Found %d services\n", len(services))
+	m.logger.Debug("Listed services via reflection", "services", len(services))
 
 	result := make(map[string][]string)
 	for _, service := range services {
-		fmt.Printf("[DEBUG] Processing service: %s\n", service)
 		// Get service descriptor
 		svcDesc, err := rc.ResolveService(service)
 		if err != nil {
-			fmt.Printf("[WARN] Failed to resolve service %s: %v\n", service, err)
+			m.logger.Warn("Failed to resolve service", "service", service, "error", err)
 			// Add the service with an empty methods list
 			result[service] = []string{}
 			continue
@@ -267,13 +336,11 @@ func (m *DefaultGRPCClientManager) ListServicesAndMethods(conn *grpc.ClientConn)
 		methods := make([]string, 0)
 		for _, method := range svcDesc.GetMethods() {
 			methods = append(methods, method.GetName())
-			fmt.Printf("[DEBUG] Found method: %s\n", method.GetName())
 		}
 
 		result[service] = methods
 	}
 
-	fmt.Printf("[DEBUG] Successfully listed all services and methods\n")
 	return result, nil
 }
 
@@ -354,7 +421,7 @@ func buildFieldDescriptors(msgDesc *desc.MessageDescriptor) []FieldDescriptor {
 }
 
 // GetMethodInputDescriptor uses reflection to get the input type fields for a given service/method
-func (m *DefaultGRPCClientManager) GetMethodInputDescriptor(conn *grpc.ClientConn, serviceName, methodName string) ([]FieldDescriptor, error) {
+func (m *DefaultGRPCClientManager) GetMethodInputDescriptor(conn grpc.ClientConnInterface, serviceName, methodName string) ([]FieldDescriptor, error) {
 	ctx := context.Background()
 	rc := grpcreflect.NewClient(ctx, reflectpb.NewServerReflectionClient(conn))
 	defer rc.Reset()
@@ -2,22 +2,35 @@ package services
 
 import (
 	"context"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strconv"
+	"sync"
 	"testing"
 
+	"protodesk/pkg/models"
+
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
 )
 
 func TestNewGRPCClientManager(t *testing.T) {
-	manager := NewGRPCClientManager()
+	manager := NewGRPCClientManager(nil)
 	assert.NotNil(t, manager)
 	assert.NotNil(t, manager.connections)
 	assert.Empty(t, manager.connections)
 }
 
 func TestDefaultGRPCClientManager_ConnectionOperations(t *testing.T) {
-	manager := NewGRPCClientManager()
+	manager := NewGRPCClientManager(nil)
 	ctx := context.Background()
+	addr := startTestServer(t)
 
 	// Test initial state
 	_, err := manager.GetConnection("test-server")
@@ -25,42 +38,176 @@ func TestDefaultGRPCClientManager_ConnectionOperations(t *testing.T) {
 	assert.Contains(t, err.Error(), "no connection found")
 
 	// Test insecure connection
-	err = manager.Connect(ctx, "localhost:50051", false, "")
+	err = manager.Connect(ctx, "profile-1", addr, ConnectOptions{})
 	require.NoError(t, err)
 
 	// Test getting connection
-	conn, err := manager.GetConnection("localhost:50051")
+	conn, err := manager.GetConnection("profile-1")
 	require.NoError(t, err)
 	assert.NotNil(t, conn)
 
 	// Test disconnecting
-	err = manager.Disconnect("localhost:50051")
+	err = manager.Disconnect("profile-1")
 	require.NoError(t, err)
 
 	// Verify connection is removed
-	_, err = manager.GetConnection("localhost:50051")
+	_, err = manager.GetConnection("profile-1")
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "no connection found")
 }
 
 func TestDefaultGRPCClientManager_TLSConnection(t *testing.T) {
-	manager := NewGRPCClientManager()
+	manager := NewGRPCClientManager(nil)
 	ctx := context.Background()
 
-	// Test TLS without certificate
-	err := manager.Connect(ctx, "localhost:50051", true, "")
-	require.NoError(t, err)
-
 	// Test TLS with certificate (should fail as not implemented)
-	err = manager.Connect(ctx, "localhost:50052", true, "cert.pem")
+	err := manager.Connect(ctx, "profile-1", "localhost:50052", ConnectOptions{UseTLS: true, CertPath: "cert.pem"})
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "custom certificates not implemented")
 }
 
 func TestDefaultGRPCClientManager_DisconnectNonExistent(t *testing.T) {
-	manager := NewGRPCClientManager()
+	manager := NewGRPCClientManager(nil)
 
 	// Test disconnecting non-existent connection
 	err := manager.Disconnect("non-existent")
 	assert.NoError(t, err) // Should not return error as per current implementation
 }
+
+func TestDefaultGRPCClientManager_ConnectionsPerID(t *testing.T) {
+	manager := NewGRPCClientManager(nil)
+	addr := startTestServer(t)
+
+	// Two IDs for the same target get separate connections with their own metadata
+	ctxA := metadata.AppendToOutgoingContext(context.Background(), "x-profile", "a")
+	ctxB := metadata.AppendToOutgoingContext(context.Background(), "x-profile", "b")
+	require.NoError(t, manager.Connect(ctxA, "a", addr, ConnectOptions{}))
+	require.NoError(t, manager.Connect(ctxB, "b", addr, ConnectOptions{}))
+
+	connA, err := manager.GetConnection("a")
+	require.NoError(t, err)
+	connB, err := manager.GetConnection("b")
+	require.NoError(t, err)
+	assert.NotSame(t, connA, connB)
+	md, _ := metadata.FromOutgoingContext(manager.connectionContext(connB))
+	assert.Equal(t, []string{"b"}, md.Get("x-profile"))
+
+	// Reconnecting an ID replaces its connection
+	require.NoError(t, manager.Connect(ctxA, "a", addr, ConnectOptions{}))
+	replaced, err := manager.GetConnection("a")
+	require.NoError(t, err)
+	assert.NotSame(t, connA, replaced)
+
+	require.NoError(t, manager.Disconnect("a"))
+	_, err = manager.GetConnection("b")
+	assert.NoError(t, err, "disconnecting one ID leaves the other connected")
+	require.NoError(t, manager.Disconnect("b"))
+}
+
+// splitTestAddr splits a test server address into host and port
+func splitTestAddr(t *testing.T, addr string) (string, int) {
+	t.Helper()
+	host, portText, err := net.SplitHostPort(addr)
+	require.NoError(t, err)
+	port, err := strconv.Atoi(portText)
+	require.NoError(t, err)
+	return host, port
+}
+
+// TestServerProfileManager_ConcurrentConnections exercises Connect, Invoke and
+// Disconnect from many goroutines; run with -race
+func TestServerProfileManager_ConcurrentConnections(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+	manager := NewServerProfileManager(store, nil)
+	defer manager.DisconnectAll()
+	ctx := context.Background()
+
+	addr := startTestServer(t)
+	host, port := splitTestAddr(t, addr)
+	var profiles []*models.ServerProfile
+	for i := 0; i < 4; i++ {
+		p := models.NewServerProfile(fmt.Sprintf("profile-%d", i), host, port)
+		p.Headers = []models.Header{{Key: "x-profile", Value: p.Name}}
+		require.NoError(t, store.Create(ctx, p))
+		profiles = append(profiles, p)
+	}
+
+	var wg sync.WaitGroup
+	for round := 0; round < 3; round++ {
+		for _, p := range profiles {
+			wg.Add(1)
+			go func(profileID string, disconnect bool) {
+				defer wg.Done()
+				if err := manager.Connect(ctx, profileID); err != nil {
+					t.Errorf("connect: %v", err)
+					return
+				}
+				result, err := manager.Invoke(ctx, profileID, InvocationRequest{
+					ServiceName: "grpc.health.v1.Health",
+					MethodName:  "Check",
+					RequestJSON: `{}`,
+				})
+				// A concurrent Disconnect may win the race; that is not a failure
+				if err == nil {
+					assert.Equal(t, "OK", result.StatusName)
+				}
+				_ = manager.IsConnected(profileID)
+				if disconnect {
+					_ = manager.Disconnect(ctx, profileID)
+				}
+			}(p.ID, round%2 == 1)
+		}
+	}
+	wg.Wait()
+
+	for _, p := range profiles {
+		require.NoError(t, manager.Connect(ctx, p.ID))
+		require.NoError(t, manager.WaitForReady(ctx, p.ID))
+		assert.True(t, manager.IsConnected(p.ID))
+	}
+	manager.DisconnectAll()
+	for _, p := range profiles {
+		assert.False(t, manager.IsConnected(p.ID))
+	}
+}
+
+func TestServerProfileManager_UnixSocketTarget(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "sidecar.sock")
+	lis, err := net.Listen("unix", socketPath)
+	require.NoError(t, err)
+	recorder := &headerRecorder{}
+	s := grpc.NewServer(grpc.StatsHandler(recorder))
+	hs := health.NewServer()
+	hs.SetServingStatus("test.Service", healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(s, hs)
+	reflection.Register(s)
+	go func() { _ = s.Serve(lis) }()
+	defer s.Stop()
+
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+	manager := NewServerProfileManager(store, nil)
+	defer manager.DisconnectAll()
+	ctx := context.Background()
+
+	profile := models.NewServerProfile("sidecar", "", 0)
+	profile.TargetKind = models.TargetUnix
+	profile.Target = socketPath
+	profile.Authority = "api.example.com"
+	require.NoError(t, store.Create(ctx, profile))
+
+	require.NoError(t, manager.Connect(ctx, profile.ID))
+	require.NoError(t, manager.WaitForReady(ctx, profile.ID))
+	result, err := manager.Invoke(ctx, profile.ID, InvocationRequest{
+		ServiceName: "grpc.health.v1.Health",
+		MethodName:  "Check",
+		RequestJSON: `{"service":"test.Service"}`,
+	})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"status":"SERVING"}`, result.ResponseJSON)
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	assert.Equal(t, "api.example.com", recorder.authority)
+}
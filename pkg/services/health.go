@@ -0,0 +1,342 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// settingHealthPollInterval holds the health polling interval in seconds
+const settingHealthPollInterval = "health.poll_interval"
+
+const (
+	// healthCheckTimeout bounds a single health check
+	healthCheckTimeout = 5 * time.Second
+	// healthWatchRetry is how long a failed health watch waits before it
+	// subscribes again
+	healthWatchRetry = 2 * time.Second
+	// minHealthPollInterval is the shortest polling interval accepted
+	minHealthPollInterval = time.Second
+)
+
+// ErrInvalidHealthPollInterval is returned for a polling interval that is
+// neither zero nor at least minHealthPollInterval
+var ErrInvalidHealthPollInterval = errors.New("health poll interval must be zero or at least one second")
+
+// healthStatusUnknown is reported when a check could not obtain a status
+var healthStatusUnknown = healthpb.HealthCheckResponse_UNKNOWN.String()
+
+// HealthStatus is the result of a grpc.health.v1 check of a profile
+type HealthStatus struct {
+	ProfileID string `json:"profileId"`
+	// Service is the checked service; empty checks the server as a whole
+	Service string `json:"service"`
+	// Status is SERVING, NOT_SERVING, SERVICE_UNKNOWN or UNKNOWN
+	Status string `json:"status"`
+	// Error is why the check failed, e.g. a server without the health service
+	Error string `json:"error,omitempty"`
+	// Watching is true for results pushed by a health watch
+	Watching  bool      `json:"watching,omitempty"`
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+// Healthy reports whether the service is serving
+func (s HealthStatus) Healthy() bool {
+	return s.Status == healthpb.HealthCheckResponse_SERVING.String()
+}
+
+// healthKey identifies a checked service of a profile
+type healthKey struct {
+	profileID string
+	service   string
+}
+
+// healthWatch is a running Watch subscription
+type healthWatch struct {
+	cancel context.CancelFunc
+}
+
+// healthMonitor holds the health watches, the polling loop and the latest
+// health results of a ServerProfileManager
+type healthMonitor struct {
+	mu       sync.Mutex
+	listener func(HealthStatus)
+	latest   map[healthKey]HealthStatus
+	watches  map[healthKey]*healthWatch
+	// stopPolling ends the polling loop, if one runs
+	stopPolling context.CancelFunc
+}
+
+// SetHealthListener registers a function told about every health result,
+// from checks, watches and polling. It is called from background goroutines
+// and must not block.
+func (m *ServerProfileManager) SetHealthListener(listener func(HealthStatus)) {
+	m.health.mu.Lock()
+	defer m.health.mu.Unlock()
+	m.health.listener = listener
+}
+
+// recordHealth keeps a result for the dashboard and passes it to the listener
+func (m *ServerProfileManager) recordHealth(result HealthStatus) {
+	m.health.mu.Lock()
+	if m.health.latest == nil {
+		m.health.latest = make(map[healthKey]HealthStatus)
+	}
+	m.health.latest[healthKey{result.ProfileID, result.Service}] = result
+	listener := m.health.listener
+	m.health.mu.Unlock()
+	if listener != nil {
+		listener(result)
+	}
+}
+
+// HealthDashboard returns the latest health result of every profile and
+// service that was checked, watched or polled, ordered by profile and service
+func (m *ServerProfileManager) HealthDashboard() []HealthStatus {
+	m.health.mu.Lock()
+	defer m.health.mu.Unlock()
+	results := make([]HealthStatus, 0, len(m.health.latest))
+	for _, result := range m.health.latest {
+		results = append(results, result)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].ProfileID != results[j].ProfileID {
+			return results[i].ProfileID < results[j].ProfileID
+		}
+		return results[i].Service < results[j].Service
+	})
+	return results
+}
+
+// CheckHealth asks the server of a connected profile for the health of a
+// service with grpc.health.v1 Check; an empty service checks the server as
+// a whole. A failed check is reported in the result; the error is reserved
+// for a profile without a connection.
+func (m *ServerProfileManager) CheckHealth(ctx context.Context, profileID, service string) (HealthStatus, error) {
+	conn, err := m.GetConnection(profileID)
+	if err != nil {
+		return HealthStatus{}, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	result := HealthStatus{ProfileID: profileID, Service: service}
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+	result.CheckedAt = time.Now()
+	if err != nil {
+		result.Status = healthStatusUnknown
+		result.Error = healthError(err)
+	} else {
+		result.Status = resp.GetStatus().String()
+	}
+	m.recordHealth(result)
+	return result, nil
+}
+
+// healthError describes a failed health call
+func healthError(err error) string {
+	if status.Code(err) == codes.Unimplemented {
+		return "the server does not implement grpc.health.v1.Health"
+	}
+	return status.Convert(err).Message()
+}
+
+// WatchHealth subscribes to the health of a service with grpc.health.v1
+// Watch and passes every status the server sends to the health listener. A
+// subscription that fails is renewed until StopHealthWatch is called or the
+// profile is disconnected. Watching a service twice has no effect.
+func (m *ServerProfileManager) WatchHealth(profileID, service string) error {
+	if _, err := m.GetConnection(profileID); err != nil {
+		return err
+	}
+	key := healthKey{profileID, service}
+	m.health.mu.Lock()
+	defer m.health.mu.Unlock()
+	if _, exists := m.health.watches[key]; exists {
+		return nil
+	}
+	if m.health.watches == nil {
+		m.health.watches = make(map[healthKey]*healthWatch)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	watch := &healthWatch{cancel: cancel}
+	m.health.watches[key] = watch
+	go m.runHealthWatch(ctx, key, watch)
+	return nil
+}
+
+// StopHealthWatch ends the health watch of a service, if there is one
+func (m *ServerProfileManager) StopHealthWatch(profileID, service string) {
+	key := healthKey{profileID, service}
+	m.health.mu.Lock()
+	watch := m.health.watches[key]
+	delete(m.health.watches, key)
+	m.health.mu.Unlock()
+	if watch != nil {
+		watch.cancel()
+	}
+}
+
+// runHealthWatch follows the health of a service until ctx is cancelled,
+// the profile has no connection or the server turns out to have no health
+// service
+func (m *ServerProfileManager) runHealthWatch(ctx context.Context, key healthKey, watch *healthWatch) {
+	defer m.endHealthWatch(key, watch)
+	for {
+		conn, err := m.GetConnection(key.profileID)
+		if err != nil {
+			m.recordHealth(HealthStatus{ProfileID: key.profileID, Service: key.service, Status: healthStatusUnknown,
+				Error: err.Error(), Watching: true, CheckedAt: time.Now()})
+			return
+		}
+
+		stream, err := healthpb.NewHealthClient(conn).Watch(ctx, &healthpb.HealthCheckRequest{Service: key.service})
+		for err == nil {
+			var resp *healthpb.HealthCheckResponse
+			if resp, err = stream.Recv(); err == nil {
+				m.recordHealth(HealthStatus{ProfileID: key.profileID, Service: key.service, Status: resp.GetStatus().String(),
+					Watching: true, CheckedAt: time.Now()})
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		m.recordHealth(HealthStatus{ProfileID: key.profileID, Service: key.service, Status: healthStatusUnknown,
+			Error: healthError(err), Watching: true, CheckedAt: time.Now()})
+		if status.Code(err) == codes.Unimplemented {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(healthWatchRetry):
+		}
+	}
+}
+
+// endHealthWatch forgets a watch that ended, unless it was replaced
+func (m *ServerProfileManager) endHealthWatch(key healthKey, watch *healthWatch) {
+	watch.cancel()
+	m.health.mu.Lock()
+	defer m.health.mu.Unlock()
+	if m.health.watches[key] == watch {
+		delete(m.health.watches, key)
+	}
+}
+
+// stopHealthMonitoring ends all health watches and the polling loop
+func (m *ServerProfileManager) stopHealthMonitoring() {
+	m.health.mu.Lock()
+	watches := m.health.watches
+	m.health.watches = nil
+	stopPolling := m.health.stopPolling
+	m.health.stopPolling = nil
+	m.health.mu.Unlock()
+
+	for _, watch := range watches {
+		watch.cancel()
+	}
+	if stopPolling != nil {
+		stopPolling()
+	}
+}
+
+// GetHealthPollInterval returns how often connected profiles are polled for
+// their health; zero means polling is off
+func (m *ServerProfileManager) GetHealthPollInterval(ctx context.Context) (time.Duration, error) {
+	value, err := m.store.GetSetting(ctx, settingHealthPollInterval)
+	if err != nil || value == "" {
+		return 0, err
+	}
+	seconds, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid stored health poll interval: %w", err)
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// SetHealthPollInterval saves the health polling interval, rounded to whole
+// seconds, and restarts polling with it; zero turns polling off
+func (m *ServerProfileManager) SetHealthPollInterval(ctx context.Context, interval time.Duration) error {
+	if interval != 0 && interval < minHealthPollInterval {
+		return ErrInvalidHealthPollInterval
+	}
+	interval = interval.Round(time.Second)
+	if err := m.store.SetSetting(ctx, settingHealthPollInterval, strconv.Itoa(int(interval/time.Second))); err != nil {
+		return err
+	}
+	m.StartHealthPolling(interval)
+	return nil
+}
+
+// StartHealthPolling checks the health of every connected profile now and
+// then every interval, replacing any running polling loop. Each poll checks
+// the server as a whole and every service that was checked or watched
+// before. A zero interval stops polling.
+func (m *ServerProfileManager) StartHealthPolling(interval time.Duration) {
+	m.health.mu.Lock()
+	defer m.health.mu.Unlock()
+	if m.health.stopPolling != nil {
+		m.health.stopPolling()
+		m.health.stopPolling = nil
+	}
+	if interval <= 0 {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.health.stopPolling = cancel
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			m.pollHealth(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// pollHealth checks the health of the connected profiles concurrently
+func (m *ServerProfileManager) pollHealth(ctx context.Context) {
+	m.mu.RLock()
+	checks := make(map[string]map[string]bool, len(m.activeClients))
+	for profileID := range m.activeClients {
+		checks[profileID] = map[string]bool{"": true}
+	}
+	m.mu.RUnlock()
+
+	m.health.mu.Lock()
+	for key := range m.health.latest {
+		if checked, ok := checks[key.profileID]; ok {
+			checked[key.service] = true
+		}
+	}
+	m.health.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for profileID, checked := range checks {
+		for service := range checked {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := m.CheckHealth(ctx, profileID, service); err != nil {
+					m.logger.Debug("Skipping health check", "profile", profileID, "error", err)
+				}
+			}()
+		}
+	}
+	wg.Wait()
+}
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"protodesk/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// startHealthServer serves a health service whose statuses the test controls
+func startHealthServer(t *testing.T) (string, *health.Server) {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := grpc.NewServer()
+	hs := health.NewServer()
+	hs.SetServingStatus("test.Service", healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(s, hs)
+	go func() { _ = s.Serve(lis) }()
+	t.Cleanup(s.Stop)
+	return lis.Addr().String(), hs
+}
+
+// connectHealthProfile connects a manager to addr and returns the profile ID
+// and a channel of the health results it reports
+func connectHealthProfile(t *testing.T, addr string) (*ServerProfileManager, string, chan HealthStatus) {
+	t.Helper()
+	store, cleanup := setupTestStore(t)
+	t.Cleanup(cleanup)
+	manager := NewServerProfileManager(store, nil)
+	t.Cleanup(manager.DisconnectAll)
+	results := make(chan HealthStatus, 100)
+	manager.SetHealthListener(func(result HealthStatus) { results <- result })
+
+	host, port := splitTestAddr(t, addr)
+	profile := models.NewServerProfile("health", host, port)
+	ctx := context.Background()
+	require.NoError(t, store.Create(ctx, profile))
+	require.NoError(t, manager.Connect(ctx, profile.ID))
+	require.NoError(t, manager.WaitForReady(ctx, profile.ID))
+	return manager, profile.ID, results
+}
+
+// nextHealth waits for the next health result of service
+func nextHealth(t *testing.T, results chan HealthStatus, service string) HealthStatus {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case result := <-results:
+			if result.Service == service {
+				return result
+			}
+		case <-timeout:
+			t.Fatalf("no health result for %q", service)
+		}
+	}
+}
+
+func TestServerProfileManager_CheckHealth(t *testing.T) {
+	addr, hs := startHealthServer(t)
+	manager, profileID, results := connectHealthProfile(t, addr)
+	ctx := context.Background()
+
+	result, err := manager.CheckHealth(ctx, profileID, "test.Service")
+	require.NoError(t, err)
+	assert.True(t, result.Healthy())
+	assert.Equal(t, result, nextHealth(t, results, "test.Service"))
+
+	hs.SetServingStatus("test.Service", healthpb.HealthCheckResponse_NOT_SERVING)
+	result, err = manager.CheckHealth(ctx, profileID, "test.Service")
+	require.NoError(t, err)
+	assert.Equal(t, "NOT_SERVING", result.Status)
+
+	result, err = manager.CheckHealth(ctx, profileID, "missing.Service")
+	require.NoError(t, err)
+	assert.Equal(t, "UNKNOWN", result.Status)
+	assert.NotEmpty(t, result.Error)
+
+	dashboard := manager.HealthDashboard()
+	require.Len(t, dashboard, 2)
+	assert.Equal(t, "missing.Service", dashboard[0].Service)
+	assert.Equal(t, "NOT_SERVING", dashboard[1].Status)
+
+	_, err = manager.CheckHealth(ctx, "not-connected", "")
+	assert.Error(t, err)
+}
+
+func TestServerProfileManager_CheckHealthUnimplemented(t *testing.T) {
+	// A server without any services
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := grpc.NewServer()
+	go func() { _ = s.Serve(lis) }()
+	t.Cleanup(s.Stop)
+
+	manager, profileID, _ := connectHealthProfile(t, lis.Addr().String())
+	result, err := manager.CheckHealth(context.Background(), profileID, "")
+	require.NoError(t, err)
+	assert.False(t, result.Healthy())
+	assert.Contains(t, result.Error, "does not implement grpc.health.v1.Health")
+}
+
+func TestServerProfileManager_WatchHealth(t *testing.T) {
+	addr, hs := startHealthServer(t)
+	manager, profileID, results := connectHealthProfile(t, addr)
+
+	require.NoError(t, manager.WatchHealth(profileID, "test.Service"))
+	// A second watch of the same service is ignored
+	require.NoError(t, manager.WatchHealth(profileID, "test.Service"))
+
+	result := nextHealth(t, results, "test.Service")
+	assert.True(t, result.Watching)
+	assert.Equal(t, "SERVING", result.Status)
+
+	hs.SetServingStatus("test.Service", healthpb.HealthCheckResponse_NOT_SERVING)
+	assert.Equal(t, "NOT_SERVING", nextHealth(t, results, "test.Service").Status)
+
+	manager.StopHealthWatch(profileID, "test.Service")
+	hs.SetServingStatus("test.Service", healthpb.HealthCheckResponse_SERVING)
+	select {
+	case result := <-results:
+		t.Fatalf("unexpected health result after the watch stopped: %+v", result)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	assert.Error(t, manager.WatchHealth("not-connected", ""))
+}
+
+func TestServerProfileManager_HealthPolling(t *testing.T) {
+	addr, _ := startHealthServer(t)
+	manager, profileID, results := connectHealthProfile(t, addr)
+	ctx := context.Background()
+
+	interval, err := manager.GetHealthPollInterval(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, interval)
+
+	assert.ErrorIs(t, manager.SetHealthPollInterval(ctx, 500*time.Millisecond), ErrInvalidHealthPollInterval)
+	require.NoError(t, manager.SetHealthPollInterval(ctx, time.Second))
+	interval, err = manager.GetHealthPollInterval(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, time.Second, interval)
+
+	// Polling checks the server straight away
+	result := nextHealth(t, results, "")
+	assert.Equal(t, profileID, result.ProfileID)
+	assert.True(t, result.Healthy())
+
+	require.NoError(t, manager.SetHealthPollInterval(ctx, 0))
+	for len(results) > 0 {
+		<-results
+	}
+	select {
+	case result := <-results:
+		t.Fatalf("unexpected health result after polling stopped: %+v", result)
+	case <-time.After(1500 * time.Millisecond):
+	}
+}
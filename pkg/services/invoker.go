@@ -0,0 +1,341 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"protodesk/pkg/models"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/grpcreflect"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	reflectpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// InvocationRequest describes a gRPC call built from JSON
+type InvocationRequest struct {
+	ServiceName string
+	MethodName  string
+	// RequestJSON is a JSON object, or a JSON array of objects for client and
+	// bidirectional streaming methods. With another RequestFormat it holds
+	// the request in that format, see ConvertMessages.
+	RequestJSON   string
+	RequestFormat models.MessageFormat
+	// ResponseFormat renders the response into the result's ResponseText
+	// unless it is JSON
+	ResponseFormat models.MessageFormat
+	// JSONOptions control how the request is read from JSON and the response
+	// written to it; nil uses the defaults of the protobuf JSON mapping
+	JSONOptions *models.JSONOptions
+	// Types finds the payload types of Any fields that the server's
+	// reflection does not know, such as those of a profile's proto
+	// definitions; it is searched before reflection
+	Types    MessageFinder
+	Metadata metadata.MD
+	// Capture records the headers and message frames of the call in the
+	// result's Capture
+	Capture bool
+}
+
+// InvocationResult holds the outcome of a gRPC call
+type InvocationResult struct {
+	// ResponseJSON is a JSON object, or a JSON array for server streaming methods
+	ResponseJSON  string              `json:"responseJson"`
+	StatusCode    int                 `json:"statusCode"`
+	StatusName    string              `json:"statusName"`
+	StatusMessage string              `json:"statusMessage,omitempty"`
+	Headers       map[string][]string `json:"headers"`
+	Trailers      map[string][]string `json:"trailers"`
+	LatencyMs     int64               `json:"latencyMs"`
+	// ResponseText is the response in the request's ResponseFormat, if that
+	// is not JSON
+	ResponseText string `json:"responseText,omitempty"`
+	// Capture is what went on the wire, if the request asked for it
+	Capture *WireCapture `json:"capture,omitempty"`
+}
+
+// Err returns the call status as an error, or nil if the call succeeded
+func (r *InvocationResult) Err() error {
+	if codes.Code(r.StatusCode) == codes.OK {
+		return nil
+	}
+	return status.Error(codes.Code(r.StatusCode), r.StatusMessage)
+}
+
+// MetadataFromJSON builds outgoing metadata from a JSON object of header
+// names to values, the format used by per-request headers and saved requests
+func MetadataFromJSON(headersJSON string) (metadata.MD, error) {
+	md := metadata.New(nil)
+	if strings.TrimSpace(headersJSON) == "" {
+		return md, nil
+	}
+	var headers map[string]string
+	if err := json.Unmarshal([]byte(headersJSON), &headers); err != nil {
+		return md, fmt.Errorf("invalid headers JSON: %w", err)
+	}
+	for k, v := range headers {
+		md.Append(k, v)
+	}
+	return md, nil
+}
+
+// InvokeMethod resolves a method through server reflection and calls it with
+// dynamic messages built from JSON. conn is a native gRPC connection or a
+// WebConn. A non-OK gRPC status is reported in the
+// result; the returned error is reserved for failures outside the call itself,
+// such as an unknown method or malformed request JSON.
+func InvokeMethod(ctx context.Context, conn grpc.ClientConnInterface, req InvocationRequest) (*InvocationResult, error) {
+	rc := grpcreflect.NewClient(ctx, reflectpb.NewServerReflectionClient(conn))
+	defer rc.Reset()
+
+	svcDesc, err := rc.ResolveService(req.ServiceName)
+	if err != nil {
+		return nil, fmt.Errorf("service not found: %w", err)
+	}
+	mDesc := svcDesc.FindMethodByName(req.MethodName)
+	if mDesc == nil {
+		return nil, fmt.Errorf("method not found: %s", req.MethodName)
+	}
+
+	md := req.Metadata
+	if md == nil {
+		md = metadata.New(nil)
+	}
+	ctx = metadata.NewOutgoingContext(ctx, md)
+
+	// Use the full service name from the service descriptor
+	methodFullName := fmt.Sprintf("/%s/%s", svcDesc.GetFullyQualifiedName(), mDesc.GetName())
+
+	if err := req.ResponseFormat.Validate(); err != nil {
+		return nil, err
+	}
+	types := newTypeResolver(req.Types, reflectedMessages(rc))
+	requestJSON := req.RequestJSON
+	if !req.RequestFormat.IsJSON() {
+		requestJSON, err = convertMessages(mDesc.GetInputType(), req.RequestJSON, req.RequestFormat, models.FormatJSON, mDesc.IsClientStreaming(), types)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse request: %w", err)
+		}
+	}
+
+	// Reflection above is not part of the capture
+	var capture *WireCapture
+	var opts []grpc.CallOption
+	if req.Capture {
+		capture = &WireCapture{}
+		ctx = withWireCapture(ctx, capture)
+		opts = append(opts, grpc.ForceCodecV2(captureCodec{capture: capture}))
+	}
+
+	var jsonOpts models.JSONOptions
+	if req.JSONOptions != nil {
+		jsonOpts = *req.JSONOptions
+	}
+	var result *InvocationResult
+	if !mDesc.IsClientStreaming() && !mDesc.IsServerStreaming() {
+		result, err = invokeUnary(ctx, conn, mDesc, methodFullName, requestJSON, jsonOpts, types, opts...)
+	} else {
+		result, err = invokeStream(ctx, conn, mDesc, methodFullName, requestJSON, jsonOpts, types, opts...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !req.ResponseFormat.IsJSON() && result.ResponseJSON != "" {
+		result.ResponseText, err = convertMessages(mDesc.GetOutputType(), result.ResponseJSON, models.FormatJSON, req.ResponseFormat, mDesc.IsServerStreaming(), types)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render response: %w", err)
+		}
+	}
+	result.ResponseJSON = indentJSON(result.ResponseJSON, jsonOpts)
+	if capture == nil {
+		return result, nil
+	}
+	capture.finish(methodFullName, md, result)
+	capture.decode(mDesc.GetInputType(), mDesc.GetOutputType(), types)
+	result.Capture = capture
+	return result, nil
+}
+
+func invokeUnary(ctx context.Context, conn grpc.ClientConnInterface, mDesc *desc.MethodDescriptor, methodFullName, requestJSON string, jsonOpts models.JSONOptions, types *typeResolver, opts ...grpc.CallOption) (*InvocationResult, error) {
+	reqMsg, err := unmarshalMessage(mDesc.GetInputType(), []byte(requestJSON), jsonOpts, types)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal request: %w", err)
+	}
+	respMsg := dynamicpb.NewMessage(mDesc.GetOutputType().UnwrapMessage())
+
+	var header, trailer metadata.MD
+	start := time.Now()
+	opts = append(opts, grpc.Header(&header), grpc.Trailer(&trailer))
+	callErr := conn.Invoke(ctx, methodFullName, reqMsg, respMsg, opts...)
+	result := newInvocationResult(time.Since(start), header, trailer, callErr)
+	if callErr != nil {
+		return result, nil
+	}
+
+	respJSON, err := marshalMessage(respMsg, jsonOpts, types)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal response: %w", err)
+	}
+	result.ResponseJSON = string(respJSON)
+	return result, nil
+}
+
+func invokeStream(ctx context.Context, conn grpc.ClientConnInterface, mDesc *desc.MethodDescriptor, methodFullName, requestJSON string, jsonOpts models.JSONOptions, types *typeResolver, opts ...grpc.CallOption) (*InvocationResult, error) {
+	// Build all request messages before opening the stream so malformed input
+	// never reaches the server
+	var requests []*dynamicpb.Message
+	if mDesc.IsClientStreaming() {
+		var arr []json.RawMessage
+		if err := json.Unmarshal([]byte(requestJSON), &arr); err != nil {
+			return nil, fmt.Errorf("expected JSON array for client streaming: %w", err)
+		}
+		for _, msgBytes := range arr {
+			msg, err := unmarshalMessage(mDesc.GetInputType(), msgBytes, jsonOpts, types)
+			if err != nil {
+				return nil, fmt.Errorf("failed to unmarshal stream message: %w", err)
+			}
+			requests = append(requests, msg)
+		}
+	} else {
+		msg, err := unmarshalMessage(mDesc.GetInputType(), []byte(requestJSON), jsonOpts, types)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal request: %w", err)
+		}
+		requests = append(requests, msg)
+	}
+
+	streamDesc := &grpc.StreamDesc{
+		ClientStreams: mDesc.IsClientStreaming(),
+		ServerStreams: mDesc.IsServerStreaming(),
+	}
+	start := time.Now()
+	stream, err := conn.NewStream(ctx, streamDesc, methodFullName, opts...)
+	if err != nil {
+		return newInvocationResult(time.Since(start), nil, nil, err), nil
+	}
+
+	var callErr error
+	for _, msg := range requests {
+		if err := stream.SendMsg(msg); err != nil {
+			// io.EOF means the server ended the stream; the real status is
+			// returned by RecvMsg below
+			if !errors.Is(err, io.EOF) {
+				callErr = err
+			}
+			break
+		}
+	}
+	if callErr == nil {
+		if err := stream.CloseSend(); err != nil {
+			callErr = err
+		}
+	}
+
+	var responses []json.RawMessage
+	for callErr == nil {
+		respMsg := dynamicpb.NewMessage(mDesc.GetOutputType().UnwrapMessage())
+		if err := stream.RecvMsg(respMsg); err != nil {
+			if !errors.Is(err, io.EOF) {
+				callErr = err
+			}
+			break
+		}
+		respJSON, err := marshalMessage(respMsg, jsonOpts, types)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal response: %w", err)
+		}
+		responses = append(responses, respJSON)
+		if !mDesc.IsServerStreaming() {
+			break
+		}
+	}
+
+	header, _ := stream.Header()
+	result := newInvocationResult(time.Since(start), header, stream.Trailer(), callErr)
+	if callErr != nil {
+		return result, nil
+	}
+
+	if !mDesc.IsServerStreaming() {
+		if len(responses) == 0 {
+			return nil, fmt.Errorf("failed to receive response: stream closed without a message")
+		}
+		result.ResponseJSON = string(responses[0])
+		return result, nil
+	}
+	if responses == nil {
+		responses = []json.RawMessage{}
+	}
+	finalJSON, err := json.Marshal(responses)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal responses array: %w", err)
+	}
+	result.ResponseJSON = string(finalJSON)
+	return result, nil
+}
+
+// newInvocationResult fills in the status and metadata parts of a result
+func newInvocationResult(latency time.Duration, header, trailer metadata.MD, callErr error) *InvocationResult {
+	st := status.Convert(callErr)
+	message := st.Message()
+	if st.Code() == codes.ResourceExhausted && strings.Contains(message, "larger than max") {
+		message += " (the message size limits can be raised in the server profile's transport settings)"
+	}
+	return &InvocationResult{
+		StatusCode:    int(st.Code()),
+		StatusName:    statusName(st.Code()),
+		StatusMessage: message,
+		Headers:       metadataToMap(header),
+		Trailers:      metadataToMap(trailer),
+		LatencyMs:     latency.Milliseconds(),
+	}
+}
+
+// statusNames are the canonical names of the status codes in the gRPC
+// specification. They differ from codes.Code.String(), which spells
+// CANCELLED as Canceled.
+var statusNames = [...]string{
+	codes.OK:                 "OK",
+	codes.Canceled:           "CANCELLED",
+	codes.Unknown:            "UNKNOWN",
+	codes.InvalidArgument:    "INVALID_ARGUMENT",
+	codes.DeadlineExceeded:   "DEADLINE_EXCEEDED",
+	codes.NotFound:           "NOT_FOUND",
+	codes.AlreadyExists:      "ALREADY_EXISTS",
+	codes.PermissionDenied:   "PERMISSION_DENIED",
+	codes.ResourceExhausted:  "RESOURCE_EXHAUSTED",
+	codes.FailedPrecondition: "FAILED_PRECONDITION",
+	codes.Aborted:            "ABORTED",
+	codes.OutOfRange:         "OUT_OF_RANGE",
+	codes.Unimplemented:      "UNIMPLEMENTED",
+	codes.Internal:           "INTERNAL",
+	codes.Unavailable:        "UNAVAILABLE",
+	codes.DataLoss:           "DATA_LOSS",
+	codes.Unauthenticated:    "UNAUTHENTICATED",
+}
+
+// statusName returns the canonical upper snake case name of a status code,
+// e.g. NOT_FOUND, as used by the gRPC specification
+func statusName(c codes.Code) string {
+	if int(c) < len(statusNames) {
+		return statusNames[c]
+	}
+	return fmt.Sprintf("CODE(%d)", uint32(c))
+}
+
+func metadataToMap(md metadata.MD) map[string][]string {
+	out := make(map[string][]string, len(md))
+	for k, v := range md {
+		out[k] = append([]string(nil), v...)
+	}
+	return out
+}
@@ -0,0 +1,103 @@
+package services
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+)
+
+// startTestServer starts an in-process gRPC server exposing the health
+// service and server reflection, and returns its address
+func startTestServer(t *testing.T, opts ...grpc.ServerOption) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	s := grpc.NewServer(opts...)
+	hs := health.NewServer()
+	hs.SetServingStatus("test.Service", healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(s, hs)
+	reflection.Register(s)
+
+	go func() { _ = s.Serve(lis) }()
+	t.Cleanup(s.Stop)
+	return lis.Addr().String()
+}
+
+func dialTestServer(t *testing.T, addr string, opts ...grpc.DialOption) *grpc.ClientConn {
+	t.Helper()
+	opts = append([]grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}, opts...)
+	conn, err := grpc.NewClient(addr, opts...)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestInvokeMethod_Unary(t *testing.T) {
+	conn := dialTestServer(t, startTestServer(t))
+	ctx := context.Background()
+
+	result, err := InvokeMethod(ctx, conn, InvocationRequest{
+		ServiceName: "grpc.health.v1.Health",
+		MethodName:  "Check",
+		RequestJSON: `{"service":"test.Service"}`,
+		Metadata:    metadata.Pairs("x-test", "1"),
+	})
+	require.NoError(t, err)
+	assert.NoError(t, result.Err())
+	assert.Equal(t, "OK", result.StatusName)
+	assert.JSONEq(t, `{"status":"SERVING"}`, result.ResponseJSON)
+	assert.Contains(t, result.Headers, "content-type")
+
+	// A gRPC error status is part of the result, not an invocation error
+	result, err = InvokeMethod(ctx, conn, InvocationRequest{
+		ServiceName: "grpc.health.v1.Health",
+		MethodName:  "Check",
+		RequestJSON: `{"service":"unknown"}`,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "NOT_FOUND", result.StatusName)
+	assert.Error(t, result.Err())
+	assert.Empty(t, result.ResponseJSON)
+}
+
+func TestInvokeMethod_Errors(t *testing.T) {
+	conn := dialTestServer(t, startTestServer(t))
+	ctx := context.Background()
+
+	_, err := InvokeMethod(ctx, conn, InvocationRequest{ServiceName: "missing.Service", MethodName: "Check"})
+	assert.ErrorContains(t, err, "service not found")
+
+	_, err = InvokeMethod(ctx, conn, InvocationRequest{ServiceName: "grpc.health.v1.Health", MethodName: "Missing"})
+	assert.ErrorContains(t, err, "method not found")
+
+	_, err = InvokeMethod(ctx, conn, InvocationRequest{
+		ServiceName: "grpc.health.v1.Health",
+		MethodName:  "Check",
+		RequestJSON: `{"service":`,
+	})
+	assert.ErrorContains(t, err, "failed to unmarshal request")
+}
+
+func TestMetadataFromJSON(t *testing.T) {
+	md, err := MetadataFromJSON(`{"Authorization":"Bearer x","x-id":"1"}`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Bearer x"}, md.Get("authorization"))
+	assert.Equal(t, []string{"1"}, md.Get("x-id"))
+
+	md, err = MetadataFromJSON("")
+	require.NoError(t, err)
+	assert.Empty(t, md)
+
+	_, err = MetadataFromJSON(`[1,2]`)
+	assert.Error(t, err)
+}
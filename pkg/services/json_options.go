@@ -0,0 +1,60 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"protodesk/pkg/models"
+
+	"github.com/jhump/protoreflect/desc"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// unmarshalMessage reads a message of type md from JSON, resolving the
+// payloads of Any fields with types. Fields the message does not define are
+// rejected unless the options allow them.
+func unmarshalMessage(md *desc.MessageDescriptor, data []byte, opts models.JSONOptions, types *typeResolver) (*dynamicpb.Message, error) {
+	msg := dynamicpb.NewMessage(md.UnwrapMessage())
+	unmarshal := protojson.UnmarshalOptions{DiscardUnknown: opts.AllowUnknownFields, Resolver: types}
+	if err := unmarshal.Unmarshal(data, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// marshalMessage writes a message as compact JSON, resolving the payloads
+// of Any fields with types. Indent is left to indentJSON so that streamed
+// responses are indented as one array.
+func marshalMessage(msg proto.Message, opts models.JSONOptions, types *typeResolver) ([]byte, error) {
+	marshal := protojson.MarshalOptions{
+		EmitUnpopulated: opts.EmitDefaults,
+		UseProtoNames:   opts.UseProtoNames,
+		UseEnumNumbers:  opts.EnumsAsNumbers,
+		Resolver:        types,
+	}
+	data, err := marshal.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	// protojson varies its whitespace between builds on purpose; compacting
+	// keeps responses stable
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// indentJSON indents JSON written by marshalMessage if the options ask for it
+func indentJSON(data string, opts models.JSONOptions) string {
+	if !opts.Indent || data == "" {
+		return data
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, []byte(data), "", "  "); err != nil {
+		return data
+	}
+	return buf.String()
+}
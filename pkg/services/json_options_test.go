@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"protodesk/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInvokeMethod_JSONOptions(t *testing.T) {
+	conn := dialTestServer(t, startTestServer(t))
+	ctx := context.Background()
+	check := InvocationRequest{
+		ServiceName: "grpc.health.v1.Health",
+		MethodName:  "Check",
+		RequestJSON: `{"service":"test.Service"}`,
+	}
+
+	check.JSONOptions = &models.JSONOptions{EnumsAsNumbers: true, Indent: true}
+	result, err := InvokeMethod(ctx, conn, check)
+	require.NoError(t, err)
+	require.NoError(t, result.Err())
+	assert.Equal(t, "{\n  \"status\": 1\n}", result.ResponseJSON)
+
+	// Unknown request fields are rejected unless allowed
+	check.RequestJSON = `{"service":"test.Service","region":"eu"}`
+	check.JSONOptions = nil
+	_, err = InvokeMethod(ctx, conn, check)
+	assert.ErrorContains(t, err, "failed to unmarshal request")
+	check.JSONOptions = &models.JSONOptions{AllowUnknownFields: true}
+	result, err = InvokeMethod(ctx, conn, check)
+	require.NoError(t, err)
+	assert.Equal(t, `{"status":"SERVING"}`, result.ResponseJSON)
+
+	// The reflection response leaves valid_host empty
+	list := InvocationRequest{
+		ServiceName: "grpc.reflection.v1alpha.ServerReflection",
+		MethodName:  "ServerReflectionInfo",
+		RequestJSON: `[{"listServices":""}]`,
+	}
+	result, err = InvokeMethod(ctx, conn, list)
+	require.NoError(t, err)
+	assert.NotContains(t, result.ResponseJSON, "validHost")
+	assert.Contains(t, result.ResponseJSON, `"listServicesResponse"`)
+	list.JSONOptions = &models.JSONOptions{EmitDefaults: true, UseProtoNames: true}
+	result, err = InvokeMethod(ctx, conn, list)
+	require.NoError(t, err)
+	assert.Contains(t, result.ResponseJSON, `"valid_host":""`)
+	assert.Contains(t, result.ResponseJSON, `"list_services_response"`)
+}
+
+func TestServerProfileManager_JSONOptions(t *testing.T) {
+	addr := startTestServer(t)
+	store, cleanup := setupTestStore(t)
+	t.Cleanup(cleanup)
+	manager := NewServerProfileManager(store, nil)
+	t.Cleanup(manager.DisconnectAll)
+	ctx := context.Background()
+	profileID := connectProfile(t, manager, "json", addr)
+
+	profile, err := store.Get(ctx, profileID)
+	require.NoError(t, err)
+	assert.Nil(t, profile.JSONOptions)
+	profile.JSONOptions = &models.JSONOptions{EnumsAsNumbers: true}
+	require.NoError(t, store.Update(ctx, profile))
+	stored, err := store.Get(ctx, profileID)
+	require.NoError(t, err)
+	assert.Equal(t, profile.JSONOptions, stored.JSONOptions)
+
+	check := InvocationRequest{
+		ServiceName: "grpc.health.v1.Health",
+		MethodName:  "Check",
+		RequestJSON: `{"service":"test.Service"}`,
+	}
+	result, err := manager.Invoke(ctx, profileID, check)
+	require.NoError(t, err)
+	assert.Equal(t, `{"status":1}`, result.ResponseJSON)
+
+	// Options of the request replace those of the profile
+	check.JSONOptions = &models.JSONOptions{}
+	result, err = manager.Invoke(ctx, profileID, check)
+	require.NoError(t, err)
+	assert.Equal(t, `{"status":"SERVING"}`, result.ResponseJSON)
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Body    string `xml:",chardata"`
+}
+
+// JUnitXML renders the report in the JUnit XML format understood by CI systems
+func (r *RunReport) JUnitXML() ([]byte, error) {
+	suite := junitTestSuite{
+		Name:      r.CollectionName,
+		Tests:     r.Total,
+		Failures:  r.Failed,
+		Errors:    r.Errored,
+		Time:      junitSeconds(r.DurationMs),
+		Timestamp: r.StartedAt.Format(time.RFC3339),
+	}
+	for _, res := range r.Results {
+		tc := junitTestCase{
+			Name:      res.Name,
+			ClassName: fmt.Sprintf("%s/%s", res.ServiceName, res.MethodName),
+			Time:      junitSeconds(res.DurationMs),
+		}
+		switch {
+		case res.Error != "":
+			tc.Error = &junitMessage{Message: res.Error, Type: "error", Body: res.Error}
+		case !res.Passed:
+			var lines []string
+			for _, a := range res.Assertions {
+				if !a.Passed {
+					lines = append(lines, fmt.Sprintf("[%s] %s", a.Assertion.Type, a.Message))
+				}
+			}
+			tc.Failure = &junitMessage{
+				Message: fmt.Sprintf("%d assertion(s) failed", len(lines)),
+				Type:    "assertion",
+				Body:    strings.Join(lines, "\n"),
+			}
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+
+	doc := junitTestSuites{
+		Name:     r.CollectionName,
+		Tests:    r.Total,
+		Failures: r.Failed,
+		Errors:   r.Errored,
+		Time:     suite.Time,
+		Suites:   []junitTestSuite{suite},
+	}
+	out, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal JUnit report: %w", err)
+	}
+	return append([]byte(xml.Header), out...), nil
+}
+
+func junitSeconds(ms int64) string {
+	return fmt.Sprintf("%.3f", float64(ms)/1000)
+}
@@ -0,0 +1,191 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"protodesk/pkg/models/proto"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/protoparse"
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/jhump/protoreflect/grpcreflect"
+	reflectpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+)
+
+// Encodings of the protobuf bytes handled by the decode utility
+const (
+	BytesBase64 = "base64"
+	BytesHex    = "hex"
+	// BytesFile names a file holding the raw bytes
+	BytesFile = "file"
+)
+
+// ErrMessageNotFound is returned when a message type is in neither the
+// proto definitions of a profile nor its server's reflection
+var ErrMessageNotFound = errors.New("message type not found")
+
+// reflectedDefinitionDir is the directory of the placeholder definitions
+// stored for services found through reflection, see storeReflectedServices
+const reflectedDefinitionDir = "reflection/"
+
+// DecodeBytes turns base64 or hex text, or the name of a file, into bytes.
+// Base64 may be standard or URL safe and padded or not; hex may contain
+// whitespace and colons, as in hex dumps of log lines.
+func DecodeBytes(input, encoding string) ([]byte, error) {
+	switch encoding {
+	case BytesBase64:
+		input = strings.Join(strings.Fields(input), "")
+		for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+			if data, err := enc.DecodeString(input); err == nil {
+				return data, nil
+			}
+		}
+		return nil, fmt.Errorf("invalid base64 input")
+	case BytesHex:
+		input = strings.Join(strings.Fields(strings.ReplaceAll(input, ":", " ")), "")
+		input = strings.TrimPrefix(strings.TrimPrefix(input, "0x"), "0X")
+		data, err := hex.DecodeString(input)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hex input: %w", err)
+		}
+		return data, nil
+	case BytesFile:
+		data, err := os.ReadFile(input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", input, err)
+		}
+		return data, nil
+	}
+	return nil, fmt.Errorf("unknown byte encoding %q", encoding)
+}
+
+// EncodeBytes turns bytes into base64 or hex text
+func EncodeBytes(data []byte, encoding string) (string, error) {
+	switch encoding {
+	case BytesBase64:
+		return base64.StdEncoding.EncodeToString(data), nil
+	case BytesHex:
+		return hex.EncodeToString(data), nil
+	}
+	return "", fmt.Errorf("unknown byte encoding %q", encoding)
+}
+
+// DecodeRaw breaks serialized protobuf bytes down by field number and wire
+// type without a schema
+func DecodeRaw(data []byte) ([]WireField, error) {
+	return wireFields(data, nil, 0)
+}
+
+// DecodeMessage decodes serialized bytes as the message type name, e.g.
+// acme.v1.Order, and returns the message as JSON. The message type and the
+// payload types of its Any fields are resolved as by ResolveMessage.
+func (m *ServerProfileManager) DecodeMessage(ctx context.Context, profileID, name string, data []byte) (string, error) {
+	md, err := m.ResolveMessage(ctx, profileID, name)
+	if err != nil {
+		return "", err
+	}
+	msg := dynamic.NewMessage(md)
+	if err := msg.Unmarshal(data); err != nil {
+		return "", fmt.Errorf("failed to decode %s: %w", name, err)
+	}
+	types, release := m.messageTypes(ctx, profileID)
+	defer release()
+	out, err := msg.MarshalJSONPB(&jsonpb.Marshaler{AnyResolver: types})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal %s: %w", name, err)
+	}
+	return string(out), nil
+}
+
+// EncodeMessage serializes the JSON of a message of type name. See
+// ResolveMessage.
+func (m *ServerProfileManager) EncodeMessage(ctx context.Context, profileID, name, messageJSON string) ([]byte, error) {
+	md, err := m.ResolveMessage(ctx, profileID, name)
+	if err != nil {
+		return nil, err
+	}
+	types, release := m.messageTypes(ctx, profileID)
+	defer release()
+	msg := dynamic.NewMessage(md)
+	if err := msg.UnmarshalJSONPB(&jsonpb.Unmarshaler{AnyResolver: types}, []byte(messageJSON)); err != nil {
+		return nil, fmt.Errorf("invalid %s JSON: %w", name, err)
+	}
+	data, err := msg.Marshal()
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s: %w", name, err)
+	}
+	return data, nil
+}
+
+// ResolveMessage finds a message type by its fully-qualified name in the
+// proto definitions of a profile or, if the profile is connected, through
+// its server's reflection
+func (m *ServerProfileManager) ResolveMessage(ctx context.Context, profileID, name string) (*desc.MessageDescriptor, error) {
+	name = strings.TrimPrefix(name, ".")
+	defs, err := m.store.ListProtoDefinitionsByProfile(ctx, profileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list proto definitions: %w", err)
+	}
+	if md := m.findDefinedMessage(defs, name); md != nil {
+		return md, nil
+	}
+
+	conn, err := m.GetConnection(profileID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s is not in the proto definitions of the profile", ErrMessageNotFound, name)
+	}
+	rc := grpcreflect.NewClient(ctx, reflectpb.NewServerReflectionClient(conn))
+	defer rc.Reset()
+	md, err := rc.ResolveMessage(name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s is not in the proto definitions of the profile or its server's reflection: %v", ErrMessageNotFound, name, err)
+	}
+	return md, nil
+}
+
+// findDefinedMessage compiles the stored proto definitions, resolving their
+// imports among each other, and returns the message type name, or nil.
+// Definitions that do not compile are skipped.
+func (m *ServerProfileManager) findDefinedMessage(defs []*proto.ProtoDefinition, name string) *desc.MessageDescriptor {
+	parser := protoparse.Parser{Accessor: definitionAccessor(defs)}
+	for _, def := range defs {
+		if strings.HasPrefix(def.FilePath, reflectedDefinitionDir) || !strings.Contains(def.Content, messageSimpleName(name)) {
+			continue
+		}
+		fds, err := parser.ParseFiles(def.FilePath)
+		if err != nil {
+			m.logger.Debug("Skipping proto definition", "file", def.FilePath, "error", err)
+			continue
+		}
+		if md := fds[0].FindMessage(name); md != nil {
+			return md
+		}
+	}
+	return nil
+}
+
+// definitionAccessor opens stored proto definitions by their path or by an
+// import path their path ends with
+func definitionAccessor(defs []*proto.ProtoDefinition) protoparse.FileAccessor {
+	return func(filename string) (io.ReadCloser, error) {
+		for _, def := range defs {
+			if def.FilePath == filename || strings.HasSuffix(def.FilePath, "/"+filename) {
+				return io.NopCloser(strings.NewReader(def.Content)), nil
+			}
+		}
+		return nil, os.ErrNotExist
+	}
+}
+
+// messageSimpleName returns the last part of a fully-qualified name
+func messageSimpleName(name string) string {
+	return name[strings.LastIndex(name, ".")+1:]
+}
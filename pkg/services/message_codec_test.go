@@ -0,0 +1,131 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"protodesk/pkg/models"
+	"protodesk/pkg/models/proto"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	protobuf "google.golang.org/protobuf/proto"
+)
+
+const ordersProto = `syntax = "proto3";
+package acme.v1;
+
+import "common/money.proto";
+import "google/protobuf/timestamp.proto";
+
+message Order {
+  message Item {
+    string sku = 1;
+    acme.common.Money price = 2;
+  }
+  string id = 1;
+  repeated Item items = 2;
+  google.protobuf.Timestamp created_at = 3;
+}
+`
+
+const moneyProto = `syntax = "proto3";
+package acme.common;
+
+message Money {
+  string currency = 1;
+  int64 units = 2;
+}
+`
+
+func TestServerProfileManager_DecodeMessage(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	t.Cleanup(cleanup)
+	manager := NewServerProfileManager(store, nil)
+	ctx := context.Background()
+	profile := models.NewServerProfile("orders", "localhost", 50051)
+	require.NoError(t, store.Create(ctx, profile))
+	protoPath := &proto.ProtoPath{ID: "protos", ServerProfileID: profile.ID, Path: "/protos"}
+	require.NoError(t, store.CreateProtoPath(ctx, protoPath))
+	for _, def := range []*proto.ProtoDefinition{
+		proto.NewProtoDefinition("/protos/acme/orders.proto", ordersProto),
+		proto.NewProtoDefinition("/protos/common/money.proto", moneyProto),
+	} {
+		def.ServerProfileID, def.ProtoPathID = profile.ID, protoPath.ID
+		require.NoError(t, store.CreateProtoDefinition(ctx, def))
+	}
+
+	orderJSON := `{"id":"o-1","items":[{"sku":"A7","price":{"currency":"EUR","units":"12"}}],"createdAt":"2024-05-01T10:00:00Z"}`
+	data, err := manager.EncodeMessage(ctx, profile.ID, "acme.v1.Order", orderJSON)
+	require.NoError(t, err)
+	decoded, err := manager.DecodeMessage(ctx, profile.ID, "acme.v1.Order", data)
+	require.NoError(t, err)
+	assert.JSONEq(t, orderJSON, decoded)
+
+	// Nested and imported types resolve too
+	data, err = manager.EncodeMessage(ctx, profile.ID, "acme.v1.Order.Item", `{"sku":"B2"}`)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("\n\x02B2"), data)
+
+	_, err = manager.DecodeMessage(ctx, profile.ID, "acme.v1.Missing", data)
+	assert.ErrorIs(t, err, ErrMessageNotFound)
+	_, err = manager.EncodeMessage(ctx, profile.ID, "acme.common.Money", `{"units":"many"}`)
+	assert.ErrorContains(t, err, "invalid acme.common.Money JSON")
+}
+
+func TestServerProfileManager_DecodeMessageReflection(t *testing.T) {
+	addr := startTestServer(t)
+	store, cleanup := setupTestStore(t)
+	t.Cleanup(cleanup)
+	manager := NewServerProfileManager(store, nil)
+	t.Cleanup(manager.DisconnectAll)
+	ctx := context.Background()
+	profileID := connectProfile(t, manager, "reflection", addr)
+
+	data, err := protobuf.Marshal(&healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_NOT_SERVING})
+	require.NoError(t, err)
+	decoded, err := manager.DecodeMessage(ctx, profileID, "grpc.health.v1.HealthCheckResponse", data)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"status":"NOT_SERVING"}`, decoded)
+}
+
+func TestDecodeBytes(t *testing.T) {
+	want := []byte{0x08, 0x96, 0x01, 0xff}
+	for _, input := range []string{"CJYB/w==", "CJYB/w", "CJYB_w"} {
+		data, err := DecodeBytes(input, BytesBase64)
+		require.NoError(t, err, input)
+		assert.Equal(t, want, data, input)
+	}
+	for _, input := range []string{"089601ff", "08 96 01 ff", "08:96:01:FF", "0x089601ff"} {
+		data, err := DecodeBytes(input, BytesHex)
+		require.NoError(t, err, input)
+		assert.Equal(t, want, data, input)
+	}
+	path := filepath.Join(t.TempDir(), "message.bin")
+	require.NoError(t, os.WriteFile(path, want, 0o600))
+	data, err := DecodeBytes(path, BytesFile)
+	require.NoError(t, err)
+	assert.Equal(t, want, data)
+
+	_, err = DecodeBytes("zz", BytesHex)
+	assert.Error(t, err)
+	_, err = DecodeBytes("089601", "octal")
+	assert.Error(t, err)
+
+	encoded, err := EncodeBytes(want, BytesBase64)
+	require.NoError(t, err)
+	assert.Equal(t, base64.StdEncoding.EncodeToString(want), encoded)
+}
+
+func TestDecodeRaw(t *testing.T) {
+	fields, err := DecodeRaw([]byte{0x08, 0x96, 0x01, 0x12, 0x02, 'h', 'i'})
+	require.NoError(t, err)
+	assert.Equal(t, []WireField{
+		{Number: 1, WireType: "varint", Unknown: true, Offset: 0, Length: 3, Value: "150"},
+		{Number: 2, WireType: "len", Unknown: true, Offset: 3, Length: 4, Value: `"hi"`},
+	}, fields)
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"protodesk/pkg/models"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic"
+	"sigs.k8s.io/yaml"
+)
+
+// prototextSeparator separates the messages of a prototext stream
+var prototextSeparator = regexp.MustCompile(`(?m)^---[ \t]*$`)
+
+// ConvertMessages converts the text of messages of type md between formats.
+// With multiple, as for streaming calls, text holds a list of messages: a
+// JSON array, a YAML sequence, or prototext messages separated by lines of
+// three dashes.
+func ConvertMessages(md *desc.MessageDescriptor, text string, from, to models.MessageFormat, multiple bool) (string, error) {
+	return convertMessages(md, text, from, to, multiple, newTypeResolver())
+}
+
+// convertMessages converts messages like ConvertMessages, resolving the
+// payloads of Any fields with types
+func convertMessages(md *desc.MessageDescriptor, text string, from, to models.MessageFormat, multiple bool, types *typeResolver) (string, error) {
+	if err := from.Validate(); err != nil {
+		return "", err
+	}
+	if err := to.Validate(); err != nil {
+		return "", err
+	}
+	msgs, err := parseMessages(md, text, from, multiple, types)
+	if err != nil {
+		return "", err
+	}
+	return formatMessages(msgs, to, multiple, types)
+}
+
+// parseMessages reads one message, or a list of them with multiple, in a
+// format
+func parseMessages(md *desc.MessageDescriptor, text string, format models.MessageFormat, multiple bool, types *typeResolver) ([]*dynamic.Message, error) {
+	if format == models.FormatPrototext {
+		parts := []string{text}
+		if multiple {
+			parts = prototextSeparator.Split(text, -1)
+		}
+		var msgs []*dynamic.Message
+		for _, part := range parts {
+			if multiple && strings.TrimSpace(part) == "" {
+				continue
+			}
+			msg := dynamic.NewMessage(md)
+			if err := msg.UnmarshalText([]byte(part)); err != nil {
+				return nil, fmt.Errorf("invalid %s prototext: %w", md.GetFullyQualifiedName(), err)
+			}
+			msgs = append(msgs, msg)
+		}
+		return msgs, nil
+	}
+
+	data := []byte(text)
+	if format == models.FormatYAML {
+		var err error
+		if data, err = yaml.YAMLToJSON(data); err != nil {
+			return nil, fmt.Errorf("invalid YAML: %w", err)
+		}
+	}
+	items := []json.RawMessage{data}
+	if multiple {
+		if err := json.Unmarshal(data, &items); err != nil {
+			return nil, fmt.Errorf("expected a list of %s messages: %w", md.GetFullyQualifiedName(), err)
+		}
+	}
+	msgs := make([]*dynamic.Message, 0, len(items))
+	for _, item := range items {
+		msg := dynamic.NewMessage(md)
+		if err := msg.UnmarshalJSONPB(&jsonpb.Unmarshaler{AnyResolver: types}, item); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", md.GetFullyQualifiedName(), err)
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
+
+// formatMessages writes one message, or a list of them with multiple, in a
+// format. JSON and prototext are indented for reading.
+func formatMessages(msgs []*dynamic.Message, format models.MessageFormat, multiple bool, types *typeResolver) (string, error) {
+	if format == models.FormatPrototext {
+		parts := make([]string, 0, len(msgs))
+		for _, msg := range msgs {
+			text, err := msg.MarshalTextIndent()
+			if err != nil {
+				return "", fmt.Errorf("failed to marshal %s: %w", msg.GetMessageDescriptor().GetFullyQualifiedName(), err)
+			}
+			parts = append(parts, strings.TrimSuffix(string(text), "\n")+"\n")
+		}
+		return strings.Join(parts, "---\n"), nil
+	}
+
+	items := make([]json.RawMessage, 0, len(msgs))
+	for _, msg := range msgs {
+		data, err := msg.MarshalJSONPB(&jsonpb.Marshaler{AnyResolver: types})
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal %s: %w", msg.GetMessageDescriptor().GetFullyQualifiedName(), err)
+		}
+		items = append(items, data)
+	}
+	data := []byte("null")
+	if multiple {
+		data, _ = json.Marshal(items)
+	} else if len(items) > 0 {
+		data = items[0]
+	}
+	if format == models.FormatYAML {
+		out, err := yaml.JSONToYAML(data)
+		if err != nil {
+			return "", fmt.Errorf("failed to write YAML: %w", err)
+		}
+		return string(out), nil
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, data, "", "  "); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// ConvertMessage converts the text of messages of the type name between
+// formats, resolving the type like DecodeMessage. See ConvertMessages.
+func (m *ServerProfileManager) ConvertMessage(ctx context.Context, profileID, name, text string, from, to models.MessageFormat, multiple bool) (string, error) {
+	md, err := m.ResolveMessage(ctx, profileID, name)
+	if err != nil {
+		return "", err
+	}
+	types, release := m.messageTypes(ctx, profileID)
+	defer release()
+	return convertMessages(md, text, from, to, multiple, types)
+}
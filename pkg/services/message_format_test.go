@@ -0,0 +1,71 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"protodesk/pkg/models"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestConvertMessages(t *testing.T) {
+	md, err := desc.LoadMessageDescriptorForMessage(&structpb.Struct{})
+	require.NoError(t, err)
+	messageJSON := `{"team":"payments","retries":3,"tags":["a","b"]}`
+
+	text, err := ConvertMessages(md, messageJSON, models.FormatJSON, models.FormatPrototext, false)
+	require.NoError(t, err)
+	assert.Contains(t, text, `key: "team"`)
+
+	yamlText, err := ConvertMessages(md, text, models.FormatPrototext, models.FormatYAML, false)
+	require.NoError(t, err)
+	assert.Contains(t, yamlText, "team: payments\n")
+
+	back, err := ConvertMessages(md, yamlText, models.FormatYAML, models.FormatJSON, false)
+	require.NoError(t, err)
+	assert.JSONEq(t, messageJSON, back)
+
+	// Lists of messages for streaming calls
+	stream, err := ConvertMessages(md, `[{"a":1},{"b":2}]`, models.FormatJSON, models.FormatPrototext, true)
+	require.NoError(t, err)
+	assert.Contains(t, stream, "---\n")
+	back, err = ConvertMessages(md, stream, models.FormatPrototext, models.FormatJSON, true)
+	require.NoError(t, err)
+	assert.JSONEq(t, `[{"a":1},{"b":2}]`, back)
+	back, err = ConvertMessages(md, "- a: 1\n- b: 2\n", models.FormatYAML, models.FormatJSON, true)
+	require.NoError(t, err)
+	assert.JSONEq(t, `[{"a":1},{"b":2}]`, back)
+
+	_, err = ConvertMessages(md, `fields: {`, models.FormatPrototext, models.FormatJSON, false)
+	assert.ErrorContains(t, err, "invalid google.protobuf.Struct prototext")
+	_, err = ConvertMessages(md, `{}`, models.FormatJSON, "xml", false)
+	assert.ErrorIs(t, err, models.ErrInvalidMessageFormat)
+}
+
+func TestInvokeMethod_Formats(t *testing.T) {
+	conn := dialTestServer(t, startTestServer(t))
+
+	result, err := InvokeMethod(context.Background(), conn, InvocationRequest{
+		ServiceName:    "grpc.health.v1.Health",
+		MethodName:     "Check",
+		RequestJSON:    `service: "test.Service"`,
+		RequestFormat:  models.FormatPrototext,
+		ResponseFormat: models.FormatYAML,
+	})
+	require.NoError(t, err)
+	require.NoError(t, result.Err())
+	assert.JSONEq(t, `{"status":"SERVING"}`, result.ResponseJSON)
+	assert.Equal(t, "status: SERVING\n", result.ResponseText)
+
+	_, err = InvokeMethod(context.Background(), conn, InvocationRequest{
+		ServiceName:   "grpc.health.v1.Health",
+		MethodName:    "Check",
+		RequestJSON:   `service: [`,
+		RequestFormat: models.FormatYAML,
+	})
+	assert.ErrorContains(t, err, "failed to parse request")
+}
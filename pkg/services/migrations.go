@@ -0,0 +1,402 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// migration is a numbered schema change. Migrations run in version order,
+// each in its own transaction, and are recorded in schema_migrations so that
+// they are applied exactly once.
+//
+// Databases created before schema_migrations existed already contain some
+// of these changes, so every migration must be safe to apply to a schema
+// that already has it: use IF NOT EXISTS and addColumnIfMissing.
+type migration struct {
+	version int
+	name    string
+	up      func(tx *sqlx.Tx) error
+}
+
+// migrations lists every schema change in order. Append new migrations to
+// the end; never edit or renumber one that has been released.
+var migrations = []migration{
+	{1, "initial schema", migrateInitialSchema},
+	{2, "proto_paths.last_scanned as DATETIME", migrateLastScannedColumn},
+	{3, "collections and saved requests", migrateCollections},
+	{4, "request chaining and environments", migrateEnvironments},
+	{5, "server profile auth providers", migrateProfileAuth},
+	{6, "encrypted secrets", migrateSecrets},
+	{7, "server profile connect timeout", migrateConnectTimeout},
+	{8, "server profile transport settings", migrateTransportSettings},
+	{9, "server profile targets and authority", migrateProfileTargets},
+	{10, "server profile proxies", migrateProfileProxy},
+	{11, "server profile protocols", migrateProfileProtocol},
+	{12, "saved request message formats", migrateRequestFormats},
+	{13, "server profile JSON options", migrateJSONOptions},
+}
+
+// latestSchemaVersion is the version of a fully migrated database
+func latestSchemaVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+// migrate brings the database schema up to date
+func migrate(db *sqlx.DB) error {
+	ctx := context.Background()
+	if _, err := db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at DATETIME NOT NULL
+		)
+	`); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	current, err := schemaVersion(db)
+	if err != nil {
+		return err
+	}
+	if current > latestSchemaVersion() {
+		return fmt.Errorf("database schema version %d is newer than this version of protodesk supports (%d)", current, latestSchemaVersion())
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if err := applyMigration(ctx, db, m); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.version, m.name, err)
+		}
+	}
+	return nil
+}
+
+// schemaVersion returns the highest applied migration, or 0 for a new database
+func schemaVersion(db *sqlx.DB) (int, error) {
+	var version sql.NullInt64
+	if err := db.Get(&version, `SELECT MAX(version) FROM schema_migrations`); err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return int(version.Int64), nil
+}
+
+// applyMigration runs one migration in a transaction on a dedicated
+// connection. Foreign keys are switched off while it runs, as SQLite requires
+// for table rebuilds, and checked before the transaction commits.
+func applyMigration(ctx context.Context, db *sqlx.DB, m migration) error {
+	conn, err := db.Connx(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// PRAGMA foreign_keys has no effect inside a transaction
+	if _, err := conn.ExecContext(ctx, `PRAGMA foreign_keys = OFF`); err != nil {
+		return err
+	}
+	defer conn.ExecContext(ctx, `PRAGMA foreign_keys = ON`)
+
+	tx, err := conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := m.up(tx); err != nil {
+		return err
+	}
+
+	var violations int
+	if err := tx.Get(&violations, `SELECT COUNT(*) FROM pragma_foreign_key_check`); err != nil {
+		return fmt.Errorf("failed to check foreign keys: %w", err)
+	}
+	if violations > 0 {
+		return fmt.Errorf("migration left %d foreign key violations", violations)
+	}
+
+	if _, err := tx.Exec(
+		`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`,
+		m.version, m.name, time.Now(),
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// addColumnIfMissing adds a column to a table created by an older version of
+// the schema
+func addColumnIfMissing(tx *sqlx.Tx, table, column, definition string) error {
+	var count int
+	err := tx.Get(&count, `SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, table, column)
+	if err != nil {
+		return fmt.Errorf("failed to check column %s.%s: %w", table, column, err)
+	}
+	if count > 0 {
+		return nil
+	}
+	_, err = tx.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, column, definition))
+	if err != nil {
+		return fmt.Errorf("failed to add column %s.%s: %w", table, column, err)
+	}
+	return nil
+}
+
+// migrateInitialSchema creates the tables that predate schema_migrations
+func migrateInitialSchema(tx *sqlx.Tx) error {
+	_, err := tx.Exec(`
+	CREATE TABLE IF NOT EXISTS server_profiles (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		host TEXT NOT NULL,
+		port INTEGER NOT NULL,
+		tls_enabled BOOLEAN DEFAULT FALSE,
+		certificate_path TEXT,
+		use_reflection BOOLEAN DEFAULT FALSE,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL,
+		headers_json TEXT DEFAULT '[]'
+	);
+	CREATE INDEX IF NOT EXISTS idx_server_profiles_name ON server_profiles(name);
+
+	CREATE TABLE IF NOT EXISTS proto_paths (
+		id TEXT PRIMARY KEY,
+		server_profile_id TEXT NOT NULL,
+		path TEXT NOT NULL,
+		hash TEXT,
+		last_scanned DATETIME,
+		UNIQUE(server_profile_id, path),
+		FOREIGN KEY(server_profile_id) REFERENCES server_profiles(id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS idx_proto_paths_profile ON proto_paths(server_profile_id);
+
+	CREATE TABLE IF NOT EXISTS proto_definitions (
+		id TEXT PRIMARY KEY,
+		file_path TEXT NOT NULL,
+		content TEXT NOT NULL,
+		imports TEXT,
+		services TEXT,
+		messages TEXT,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL,
+		description TEXT,
+		version TEXT,
+		server_profile_id TEXT,
+		proto_path_id TEXT,
+		last_parsed DATETIME,
+		error TEXT,
+		enums TEXT,
+		file_options TEXT,
+		FOREIGN KEY(server_profile_id) REFERENCES server_profiles(id) ON DELETE CASCADE,
+		FOREIGN KEY(proto_path_id) REFERENCES proto_paths(id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS idx_proto_definitions_profile ON proto_definitions(server_profile_id);
+	CREATE INDEX IF NOT EXISTS idx_proto_definitions_path ON proto_definitions(proto_path_id);
+
+	CREATE TABLE IF NOT EXISTS per_request_headers (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		server_profile_id TEXT NOT NULL,
+		service_name TEXT NOT NULL,
+		method_name TEXT NOT NULL,
+		headers_json TEXT NOT NULL DEFAULT '[]',
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(server_profile_id, service_name, method_name),
+		FOREIGN KEY(server_profile_id) REFERENCES server_profiles(id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS idx_per_request_headers_profile ON per_request_headers(server_profile_id);
+	`)
+	if err != nil {
+		return err
+	}
+	// headers_json was added to server_profiles after the first release
+	return addColumnIfMissing(tx, "server_profiles", "headers_json", "TEXT DEFAULT '[]'")
+}
+
+// migrateLastScannedColumn updates the last_scanned column type from TEXT to DATETIME
+func migrateLastScannedColumn(tx *sqlx.Tx) error {
+	// Check if the column exists and is TEXT type
+	var columnType string
+	err := tx.Get(&columnType, `
+		SELECT type FROM pragma_table_info('proto_paths')
+		WHERE name = 'last_scanned'
+	`)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// Column doesn't exist, no need to migrate
+			return nil
+		}
+		return fmt.Errorf("failed to check column type: %w", err)
+	}
+	if columnType != "TEXT" {
+		return nil
+	}
+
+	// Rebuild the table with the correct column type, converting TEXT
+	// timestamps to DATETIME
+	steps := []struct {
+		desc  string
+		query string
+	}{
+		{"create temporary table", `
+			CREATE TABLE proto_paths_new (
+				id TEXT PRIMARY KEY,
+				server_profile_id TEXT NOT NULL,
+				path TEXT NOT NULL,
+				hash TEXT,
+				last_scanned DATETIME,
+				UNIQUE(server_profile_id, path),
+				FOREIGN KEY(server_profile_id) REFERENCES server_profiles(id) ON DELETE CASCADE
+			)
+		`},
+		{"copy data", `
+			INSERT INTO proto_paths_new (id, server_profile_id, path, hash, last_scanned)
+			SELECT
+				id,
+				server_profile_id,
+				path,
+				hash,
+				CASE
+					WHEN last_scanned IS NULL THEN NULL
+					WHEN last_scanned = '' THEN NULL
+					ELSE datetime(last_scanned)
+				END as last_scanned
+			FROM proto_paths
+		`},
+		{"drop old table", `DROP TABLE proto_paths`},
+		{"rename new table", `ALTER TABLE proto_paths_new RENAME TO proto_paths`},
+		{"recreate index", `CREATE INDEX IF NOT EXISTS idx_proto_paths_profile ON proto_paths(server_profile_id)`},
+	}
+	for _, step := range steps {
+		if _, err := tx.Exec(step.query); err != nil {
+			return fmt.Errorf("failed to %s: %w", step.desc, err)
+		}
+	}
+	return nil
+}
+
+// migrateCollections adds collections of saved requests
+func migrateCollections(tx *sqlx.Tx) error {
+	_, err := tx.Exec(`
+	CREATE TABLE IF NOT EXISTS collections (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_collections_name ON collections(name);
+
+	CREATE TABLE IF NOT EXISTS saved_requests (
+		id TEXT PRIMARY KEY,
+		collection_id TEXT NOT NULL,
+		server_profile_id TEXT NOT NULL,
+		name TEXT NOT NULL,
+		service_name TEXT NOT NULL,
+		method_name TEXT NOT NULL,
+		request_json TEXT NOT NULL DEFAULT '{}',
+		headers_json TEXT NOT NULL DEFAULT '',
+		position INTEGER NOT NULL DEFAULT 0,
+		assertions_json TEXT NOT NULL DEFAULT '[]',
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL,
+		FOREIGN KEY(collection_id) REFERENCES collections(id) ON DELETE CASCADE,
+		FOREIGN KEY(server_profile_id) REFERENCES server_profiles(id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS idx_saved_requests_collection ON saved_requests(collection_id);
+	`)
+	return err
+}
+
+// migrateEnvironments adds extraction rules to saved requests and the
+// environments their values are stored in
+func migrateEnvironments(tx *sqlx.Tx) error {
+	if err := addColumnIfMissing(tx, "saved_requests", "extractions_json", "TEXT NOT NULL DEFAULT '[]'"); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`
+	CREATE TABLE IF NOT EXISTS environments (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL UNIQUE,
+		variables_json TEXT NOT NULL DEFAULT '{}',
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL
+	);
+	`)
+	return err
+}
+
+// migrateProfileAuth adds auth provider settings to server profiles
+func migrateProfileAuth(tx *sqlx.Tx) error {
+	return addColumnIfMissing(tx, "server_profiles", "auth_json", "TEXT NOT NULL DEFAULT ''")
+}
+
+// migrateSecrets adds secret flags to variables and per-request headers, and
+// the settings table that holds the key salt and verifier
+func migrateSecrets(tx *sqlx.Tx) error {
+	if err := addColumnIfMissing(tx, "environments", "secrets_json", "TEXT NOT NULL DEFAULT '[]'"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(tx, "per_request_headers", "secret_keys_json", "TEXT NOT NULL DEFAULT '[]'"); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`
+	CREATE TABLE IF NOT EXISTS app_settings (
+		key TEXT PRIMARY KEY,
+		value TEXT NOT NULL
+	);
+	`)
+	return err
+}
+
+// migrateConnectTimeout adds the connect timeout to server profiles
+func migrateConnectTimeout(tx *sqlx.Tx) error {
+	return addColumnIfMissing(tx, "server_profiles", "connect_timeout_ms", "INTEGER NOT NULL DEFAULT 0")
+}
+
+// migrateTransportSettings adds transport settings to server profiles
+func migrateTransportSettings(tx *sqlx.Tx) error {
+	return addColumnIfMissing(tx, "server_profiles", "transport_json", "TEXT NOT NULL DEFAULT ''")
+}
+
+// migrateProfileTargets adds unix and raw targets and the authority override
+// to server profiles
+func migrateProfileTargets(tx *sqlx.Tx) error {
+	for _, column := range []string{"target_kind", "target", "authority"} {
+		if err := addColumnIfMissing(tx, "server_profiles", column, "TEXT NOT NULL DEFAULT ''"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateProfileProxy adds proxy settings to server profiles
+func migrateProfileProxy(tx *sqlx.Tx) error {
+	return addColumnIfMissing(tx, "server_profiles", "proxy_json", "TEXT NOT NULL DEFAULT ''")
+}
+
+// migrateProfileProtocol adds the wire protocol to server profiles
+func migrateProfileProtocol(tx *sqlx.Tx) error {
+	return addColumnIfMissing(tx, "server_profiles", "protocol", "TEXT NOT NULL DEFAULT ''")
+}
+
+// migrateRequestFormats adds the request and response formats to saved
+// requests
+func migrateRequestFormats(tx *sqlx.Tx) error {
+	for _, column := range []string{"request_format", "response_format"} {
+		if err := addColumnIfMissing(tx, "saved_requests", column, "TEXT NOT NULL DEFAULT ''"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateJSONOptions adds JSON marshalling options to server profiles
+func migrateJSONOptions(tx *sqlx.Tx) error {
+	return addColumnIfMissing(tx, "server_profiles", "json_options_json", "TEXT NOT NULL DEFAULT ''")
+}
@@ -0,0 +1,259 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// historicSchemas are the schemas of released versions that predate
+// schema_migrations, oldest first. Each entry is applied on top of the
+// previous ones to build that version's database.
+var historicSchemas = []struct {
+	name string
+	sql  string
+}{
+	{"first release", `
+	CREATE TABLE server_profiles (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		host TEXT NOT NULL,
+		port INTEGER NOT NULL,
+		tls_enabled BOOLEAN DEFAULT FALSE,
+		certificate_path TEXT,
+		use_reflection BOOLEAN DEFAULT FALSE,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL
+	);
+	CREATE TABLE proto_paths (
+		id TEXT PRIMARY KEY,
+		server_profile_id TEXT NOT NULL,
+		path TEXT NOT NULL,
+		hash TEXT,
+		last_scanned TEXT,
+		UNIQUE(server_profile_id, path),
+		FOREIGN KEY(server_profile_id) REFERENCES server_profiles(id) ON DELETE CASCADE
+	);
+	CREATE TABLE proto_definitions (
+		id TEXT PRIMARY KEY,
+		file_path TEXT NOT NULL,
+		content TEXT NOT NULL,
+		imports TEXT,
+		services TEXT,
+		messages TEXT,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL,
+		description TEXT,
+		version TEXT,
+		server_profile_id TEXT,
+		proto_path_id TEXT,
+		last_parsed DATETIME,
+		error TEXT,
+		enums TEXT,
+		file_options TEXT,
+		FOREIGN KEY(server_profile_id) REFERENCES server_profiles(id) ON DELETE CASCADE,
+		FOREIGN KEY(proto_path_id) REFERENCES proto_paths(id) ON DELETE CASCADE
+	);
+	INSERT INTO server_profiles (id, name, host, port, created_at, updated_at)
+		VALUES ('p1', 'legacy', 'localhost', 50051, '2024-01-01 00:00:00', '2024-01-01 00:00:00');
+	INSERT INTO proto_paths (id, server_profile_id, path, hash, last_scanned)
+		VALUES ('pp1', 'p1', '/protos', 'abc', '2024-01-02T03:04:05Z');
+	INSERT INTO proto_definitions (id, file_path, content, created_at, updated_at, server_profile_id, proto_path_id)
+		VALUES ('d1', '/protos/a.proto', 'syntax = "proto3";', '2024-01-01 00:00:00', '2024-01-01 00:00:00', 'p1', 'pp1');
+	`},
+	{"profile headers and per-request headers", `
+	ALTER TABLE server_profiles ADD COLUMN headers_json TEXT DEFAULT '[]';
+	CREATE TABLE per_request_headers (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		server_profile_id TEXT NOT NULL,
+		service_name TEXT NOT NULL,
+		method_name TEXT NOT NULL,
+		headers_json TEXT NOT NULL DEFAULT '[]',
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(server_profile_id, service_name, method_name),
+		FOREIGN KEY(server_profile_id) REFERENCES server_profiles(id) ON DELETE CASCADE
+	);
+	`},
+	{"collections", `
+	CREATE TABLE collections (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL
+	);
+	CREATE TABLE saved_requests (
+		id TEXT PRIMARY KEY,
+		collection_id TEXT NOT NULL,
+		server_profile_id TEXT NOT NULL,
+		name TEXT NOT NULL,
+		service_name TEXT NOT NULL,
+		method_name TEXT NOT NULL,
+		request_json TEXT NOT NULL DEFAULT '{}',
+		headers_json TEXT NOT NULL DEFAULT '',
+		position INTEGER NOT NULL DEFAULT 0,
+		assertions_json TEXT NOT NULL DEFAULT '[]',
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL,
+		FOREIGN KEY(collection_id) REFERENCES collections(id) ON DELETE CASCADE,
+		FOREIGN KEY(server_profile_id) REFERENCES server_profiles(id) ON DELETE CASCADE
+	);
+	INSERT INTO collections (id, name, created_at, updated_at)
+		VALUES ('c1', 'smoke', '2024-01-01 00:00:00', '2024-01-01 00:00:00');
+	INSERT INTO saved_requests (id, collection_id, server_profile_id, name, service_name, method_name, created_at, updated_at)
+		VALUES ('r1', 'c1', 'p1', 'get', 'svc', 'Get', '2024-01-01 00:00:00', '2024-01-01 00:00:00');
+	`},
+	{"environments", `
+	ALTER TABLE saved_requests ADD COLUMN extractions_json TEXT NOT NULL DEFAULT '[]';
+	CREATE TABLE environments (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL UNIQUE,
+		variables_json TEXT NOT NULL DEFAULT '{}',
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL
+	);
+	`},
+	{"auth providers", `
+	ALTER TABLE server_profiles ADD COLUMN auth_json TEXT NOT NULL DEFAULT '';
+	`},
+	{"encrypted secrets", `
+	ALTER TABLE environments ADD COLUMN secrets_json TEXT NOT NULL DEFAULT '[]';
+	ALTER TABLE per_request_headers ADD COLUMN secret_keys_json TEXT NOT NULL DEFAULT '[]';
+	CREATE TABLE app_settings (
+		key TEXT PRIMARY KEY,
+		value TEXT NOT NULL
+	);
+	`},
+}
+
+// createHistoricDatabase builds the database of the n-th historic version
+func createHistoricDatabase(t *testing.T, dir string, n int) {
+	t.Helper()
+	db, err := sqlx.Connect("sqlite3", filepath.Join(dir, "protodesk.db"))
+	require.NoError(t, err)
+	defer db.Close()
+	for _, schema := range historicSchemas[:n+1] {
+		_, err := db.Exec(schema.sql)
+		require.NoError(t, err, schema.name)
+	}
+}
+
+func TestMigrate_UpgradesEveryHistoricSchema(t *testing.T) {
+	for i, historic := range historicSchemas {
+		t.Run(historic.name, func(t *testing.T) {
+			dir := t.TempDir()
+			createHistoricDatabase(t, dir, i)
+
+			store, err := NewSQLiteStore(dir)
+			require.NoError(t, err)
+			defer store.db.Close()
+			ctx := context.Background()
+
+			version, err := schemaVersion(store.db)
+			require.NoError(t, err)
+			assert.Equal(t, latestSchemaVersion(), version)
+
+			// Existing rows survive and are readable through the store
+			profile, err := store.Get(ctx, "p1")
+			require.NoError(t, err)
+			assert.Equal(t, "legacy", profile.Name)
+			assert.Nil(t, profile.Auth)
+
+			path, err := store.GetProtoPath(ctx, "pp1")
+			require.NoError(t, err)
+			assert.Equal(t, "/protos", path.Path)
+
+			var definitions int
+			require.NoError(t, store.db.Get(&definitions, `SELECT COUNT(*) FROM proto_definitions WHERE proto_path_id = 'pp1'`))
+			assert.Equal(t, 1, definitions, "rebuilding proto_paths must not cascade to proto_definitions")
+
+			var columnType string
+			require.NoError(t, store.db.Get(&columnType, `SELECT type FROM pragma_table_info('proto_paths') WHERE name = 'last_scanned'`))
+			assert.Equal(t, "DATETIME", columnType)
+
+			if i >= 2 {
+				requests, err := store.ListSavedRequests(ctx, "c1")
+				require.NoError(t, err)
+				require.Len(t, requests, 1)
+				assert.Empty(t, requests[0].Extractions)
+			}
+
+			// Every table the current code uses is present
+			for table, columns := range map[string][]string{
+				"server_profiles":     {"headers_json", "auth_json"},
+				"per_request_headers": {"secret_keys_json"},
+				"saved_requests":      {"extractions_json", "request_format", "response_format"},
+				"environments":        {"secrets_json"},
+				"app_settings":        {"key", "value"},
+			} {
+				for _, column := range columns {
+					var count int
+					require.NoError(t, store.db.Get(&count, `SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, table, column))
+					assert.Equal(t, 1, count, "%s.%s", table, column)
+				}
+			}
+		})
+	}
+}
+
+func TestMigrate_IsIdempotent(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewSQLiteStore(dir)
+	require.NoError(t, err)
+	store.db.Close()
+
+	store, err = NewSQLiteStore(dir)
+	require.NoError(t, err)
+	defer store.db.Close()
+
+	var applied int
+	require.NoError(t, store.db.Get(&applied, `SELECT COUNT(*) FROM schema_migrations`))
+	assert.Equal(t, len(migrations), applied)
+}
+
+func TestMigrate_RejectsNewerSchema(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewSQLiteStore(dir)
+	require.NoError(t, err)
+	_, err = store.db.Exec(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, 'future', CURRENT_TIMESTAMP)`, latestSchemaVersion()+1)
+	require.NoError(t, err)
+	store.db.Close()
+
+	_, err = NewSQLiteStore(dir)
+	assert.ErrorContains(t, err, "newer")
+}
+
+func TestMigrate_FailedMigrationRollsBack(t *testing.T) {
+	dir := t.TempDir()
+	db, err := sqlx.Connect("sqlite3", filepath.Join(dir, "protodesk.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	original := migrations
+	defer func() { migrations = original }()
+	migrations = append(append([]migration{}, original...), migration{
+		version: latestSchemaVersion() + 1,
+		name:    "broken",
+		up: func(tx *sqlx.Tx) error {
+			if _, err := tx.Exec(`CREATE TABLE half_done (id TEXT)`); err != nil {
+				return err
+			}
+			return errors.New("boom")
+		},
+	})
+
+	err = migrate(db)
+	assert.ErrorContains(t, err, "broken")
+
+	var tables int
+	require.NoError(t, db.Get(&tables, `SELECT COUNT(*) FROM sqlite_master WHERE name = 'half_done'`))
+	assert.Zero(t, tables, "the failed migration's changes are rolled back")
+	version, err := schemaVersion(db)
+	require.NoError(t, err)
+	assert.Equal(t, len(original), version, "earlier migrations stay applied")
+}
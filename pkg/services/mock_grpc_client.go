@@ -8,31 +8,50 @@ import (
 
 // MockGRPCClientManager is a mock implementation of GRPCClientManager for testing
 type MockGRPCClientManager struct {
-	ConnectFunc       func(ctx context.Context, target string, useTLS bool, certPath string) error
-	DisconnectFunc    func(target string) error
-	GetConnectionFunc func(target string) (*grpc.ClientConn, error)
+	ConnectFunc       func(ctx context.Context, id string, target string, opts ConnectOptions) error
+	DisconnectFunc    func(id string) error
+	GetConnectionFunc func(id string) (*grpc.ClientConn, error)
+
+	ListServicesAndMethodsFunc   func(conn grpc.ClientConnInterface) (map[string][]string, error)
+	GetMethodInputDescriptorFunc func(conn grpc.ClientConnInterface, serviceName, methodName string) ([]FieldDescriptor, error)
 }
 
 // Connect calls the mock ConnectFunc if set
-func (m *MockGRPCClientManager) Connect(ctx context.Context, target string, useTLS bool, certPath string) error {
+func (m *MockGRPCClientManager) Connect(ctx context.Context, id string, target string, opts ConnectOptions) error {
 	if m.ConnectFunc != nil {
-		return m.ConnectFunc(ctx, target, useTLS, certPath)
+		return m.ConnectFunc(ctx, id, target, opts)
 	}
 	return nil
 }
 
 // Disconnect calls the mock DisconnectFunc if set
-func (m *MockGRPCClientManager) Disconnect(target string) error {
+func (m *MockGRPCClientManager) Disconnect(id string) error {
 	if m.DisconnectFunc != nil {
-		return m.DisconnectFunc(target)
+		return m.DisconnectFunc(id)
 	}
 	return nil
 }
 
 // GetConnection calls the mock GetConnectionFunc if set
-func (m *MockGRPCClientManager) GetConnection(target string) (*grpc.ClientConn, error) {
+func (m *MockGRPCClientManager) GetConnection(id string) (*grpc.ClientConn, error) {
 	if m.GetConnectionFunc != nil {
-		return m.GetConnectionFunc(target)
+		return m.GetConnectionFunc(id)
 	}
 	return &grpc.ClientConn{}, nil
 }
+
+// ListServicesAndMethods calls the mock ListServicesAndMethodsFunc if set
+func (m *MockGRPCClientManager) ListServicesAndMethods(conn grpc.ClientConnInterface) (map[string][]string, error) {
+	if m.ListServicesAndMethodsFunc != nil {
+		return m.ListServicesAndMethodsFunc(conn)
+	}
+	return map[string][]string{}, nil
+}
+
+// GetMethodInputDescriptor calls the mock GetMethodInputDescriptorFunc if set
+func (m *MockGRPCClientManager) GetMethodInputDescriptor(conn grpc.ClientConnInterface, serviceName, methodName string) ([]FieldDescriptor, error) {
+	if m.GetMethodInputDescriptorFunc != nil {
+		return m.GetMethodInputDescriptorFunc(conn, serviceName, methodName)
+	}
+	return nil, nil
+}
@@ -3,8 +3,8 @@ package services
 import (
 	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
+	"log/slog"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -13,26 +13,32 @@ import (
 
 	"github.com/google/uuid"
 	pbproto "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
 	"google.golang.org/protobuf/types/descriptorpb"
 
+	"protodesk/pkg/logging"
 	"protodesk/pkg/models/proto"
 )
 
 // ProtoParser handles parsing of proto files
 type ProtoParser struct {
-	store ServerProfileStore
+	store  ServerProfileStore
+	logger *slog.Logger
 }
 
-// NewProtoParser creates a new ProtoParser
-func NewProtoParser(store ServerProfileStore) *ProtoParser {
+// NewProtoParser creates a new ProtoParser logging to logger; a nil logger
+// discards the output
+func NewProtoParser(store ServerProfileStore, logger *slog.Logger) *ProtoParser {
 	return &ProtoParser{
-		store: store,
+		store:  store,
+		logger: logging.OrDiscard(logger),
 	}
 }
 
 // ScanAndParseProtoPath scans a directory for proto files and parses them
 func (p *ProtoParser) ScanAndParseProtoPath(ctx context.Context, serverProfileId string, protoPathId string, path string) error {
-	fmt.Printf("[DEBUG] Scanning proto path: %s\n", path)
+	logger := p.logger.With("protoPath", path)
+	logger.Debug("Scanning proto path")
 
 	// Find all proto files
 	var protoFiles []string
@@ -45,7 +51,6 @@ func (p *ProtoParser) ScanAndParseProtoPath(ctx context.Context, serverProfileId
 			return filepath.SkipDir
 		}
 		if !info.IsDir() && strings.HasSuffix(info.Name(), ".proto") {
-			fmt.Printf("[DEBUG] Found proto file: %s\n", path)
 			protoFiles = append(protoFiles, path)
 		}
 		return nil
@@ -54,7 +59,7 @@ func (p *ProtoParser) ScanAndParseProtoPath(ctx context.Context, serverProfileId
 		return fmt.Errorf("failed to walk directory: %w", err)
 	}
 
-	fmt.Printf("[DEBUG] Total proto files to parse: %d\n", len(protoFiles))
+	logger.Debug("Found proto files", "files", len(protoFiles))
 
 	// Build import paths
 	var importPaths []string
@@ -77,17 +82,15 @@ func (p *ProtoParser) ScanAndParseProtoPath(ctx context.Context, serverProfileId
 	importPaths = append(importPaths, baseDir)
 	importPaths = append(importPaths, path)
 
-	fmt.Printf("[DEBUG] Using import paths: %v\n", importPaths)
+	logger.Debug("Using import paths", "importPaths", importPaths)
 
 	successfullyParsed := 0
 	// Parse each proto file
 	for _, file := range protoFiles {
-		fmt.Printf("[DEBUG] Parsing file: %s\n", file)
-
 		// Create a temporary file for the descriptor set
 		tmpFile, err := os.CreateTemp("", "protoc-*.desc")
 		if err != nil {
-			fmt.Printf("[DEBUG] Failed to create temporary file: %v\n", err)
+			logger.Error("Failed to create temporary file", "error", err)
 			continue
 		}
 		tmpFile.Close()
@@ -97,13 +100,14 @@ func (p *ProtoParser) ScanAndParseProtoPath(ctx context.Context, serverProfileId
 		args := []string{
 			"--descriptor_set_out=" + tmpFile.Name(),
 			"--include_imports",
+			"--include_source_info",
 		}
 		for _, importPath := range importPaths {
 			args = append(args, "-I"+importPath)
 		}
 		args = append(args, file)
 
-		fmt.Printf("[DEBUG] Running protoc with args: %v\n", args)
+		logger.Debug("Running protoc", "args", args)
 
 		// Run protoc command
 		cmd := exec.CommandContext(ctx, "protoc", args...)
@@ -111,38 +115,35 @@ func (p *ProtoParser) ScanAndParseProtoPath(ctx context.Context, serverProfileId
 		cmd.Stderr = &stderr
 		err = cmd.Run()
 		if err != nil {
-			fmt.Printf("[DEBUG] Failed to run protoc: %v\n", err)
-			fmt.Printf("[DEBUG] protoc stderr output: %s\n", stderr.String())
+			logger.Warn("Failed to run protoc", "file", file, "error", err, "stderr", stderr.String())
 			continue
 		}
 
 		// Read the descriptor set from the temporary file
 		output, err := os.ReadFile(tmpFile.Name())
 		if err != nil {
-			fmt.Printf("[DEBUG] Failed to read descriptor set file: %v\n", err)
+			logger.Warn("Failed to read descriptor set file", "file", file, "error", err)
 			continue
 		}
 
-		fmt.Printf("[DEBUG] Read %d bytes from descriptor set file\n", len(output))
-		fmt.Printf("[DEBUG] protoc stderr output: %s\n", stderr.String())
-
 		// Parse descriptor set
 		descriptorSet := &descriptorpb.FileDescriptorSet{}
 		if err := pbproto.Unmarshal(output, descriptorSet); err != nil {
-			fmt.Printf("[DEBUG] Failed to unmarshal descriptor set: %v\n", err)
+			logger.Warn("Failed to unmarshal descriptor set", "file", file, "error", err)
+			continue
+		}
+		files, err := protodesc.NewFiles(descriptorSet)
+		if err != nil {
+			logger.Warn("Failed to link descriptor set", "file", file, "error", err)
 			continue
 		}
-
-		fmt.Printf("[DEBUG] Successfully parsed descriptor set with %d files\n", len(descriptorSet.File))
 
 		// Process each file descriptor
 		for _, fileDesc := range descriptorSet.File {
-			fmt.Printf("[DEBUG] Processing file descriptor: %s\n", fileDesc.GetName())
-
 			// Read the original proto file content
 			content, err := os.ReadFile(file)
 			if err != nil {
-				fmt.Printf("[DEBUG] Failed to read proto file: %v\n", err)
+				logger.Warn("Failed to read proto file", "file", file, "error", err)
 				continue
 			}
 
@@ -159,285 +160,27 @@ func (p *ProtoParser) ScanAndParseProtoPath(ctx context.Context, serverProfileId
 				FilePath:        filePath,
 				Content:         string(content),
 				Imports:         fileDesc.GetDependency(),
-				Services:        make([]proto.Service, 0),
-				Enums:           make([]proto.EnumType, 0),
 				CreatedAt:       time.Now(),
 				UpdatedAt:       time.Now(),
 				ServerProfileID: serverProfileId,
 				ProtoPathID:     protoPathId,
 			}
 
-			fmt.Printf("[DEBUG] Created proto definition object - ID: %s, FilePath: %s\n", def.ID, def.FilePath)
-
-			// Extract services and methods
-			for _, service := range fileDesc.GetService() {
-				// Get the full service name including package
-				serviceName := service.GetName()
-				if fileDesc.GetPackage() != "" {
-					serviceName = fileDesc.GetPackage() + "." + serviceName
-				}
-
-				svc := proto.Service{
-					Name:    serviceName,
-					Methods: make([]proto.Method, 0),
-				}
-
-				for _, method := range service.GetMethod() {
-					// Find input message type
-					var inputType proto.MessageType
-					inputTypeName := method.GetInputType()
-					// Remove the leading dot if present
-					if strings.HasPrefix(inputTypeName, ".") {
-						inputTypeName = inputTypeName[1:]
-					}
-					// Find the message in the descriptor set
-					for _, msg := range descriptorSet.File {
-						for _, message := range msg.GetMessageType() {
-							fullMessageName := msg.GetPackage()
-							if fullMessageName != "" {
-								fullMessageName += "."
-							}
-							fullMessageName += message.GetName()
-							if fullMessageName == inputTypeName {
-								inputType = proto.MessageType{
-									Name:   inputTypeName,
-									Fields: make([]proto.MessageField, 0),
-								}
-								for _, field := range message.GetField() {
-									fieldType := protoTypeToString(field.GetType())
-									if field.GetType() == descriptorpb.FieldDescriptorProto_TYPE_MESSAGE {
-										fieldType = field.GetTypeName()
-										if strings.HasPrefix(fieldType, ".") {
-											fieldType = fieldType[1:]
-										}
-										// Handle Google well-known types
-										if strings.HasPrefix(fieldType, "google.protobuf.") {
-											// For well-known types, we need to keep the full type name
-											fieldType = fieldType
-											// Add the well-known type to imports if not already present
-											wellKnownType := strings.TrimPrefix(fieldType, "google.protobuf.")
-											wellKnownProto := fmt.Sprintf("google/protobuf/%s.proto", strings.ToLower(wellKnownType))
-											found := false
-											for _, imp := range def.Imports {
-												if imp == wellKnownProto {
-													found = true
-													break
-												}
-											}
-											if !found {
-												def.Imports = append(def.Imports, wellKnownProto)
-											}
-										}
-									} else if field.GetType() == descriptorpb.FieldDescriptorProto_TYPE_ENUM {
-										fieldType = field.GetTypeName()
-										if strings.HasPrefix(fieldType, ".") {
-											fieldType = fieldType[1:]
-										}
-									}
-
-									msgField := proto.MessageField{
-										Name:       field.GetName(),
-										Number:     int32(field.GetNumber()),
-										Type:       fieldType,
-										IsRepeated: field.GetLabel() == descriptorpb.FieldDescriptorProto_LABEL_REPEATED,
-										IsRequired: field.GetLabel() == descriptorpb.FieldDescriptorProto_LABEL_REQUIRED,
-										Options: proto.FieldOption{
-											JSONName: field.GetJsonName(),
-										},
-									}
-									inputType.Fields = append(inputType.Fields, msgField)
-								}
-								break
-							}
-						}
-					}
-
-					// Find output message type
-					var outputType proto.MessageType
-					outputTypeName := method.GetOutputType()
-					// Remove the leading dot if present
-					if strings.HasPrefix(outputTypeName, ".") {
-						outputTypeName = outputTypeName[1:]
-					}
-					// Find the message in the descriptor set
-					for _, msg := range descriptorSet.File {
-						for _, message := range msg.GetMessageType() {
-							fullMessageName := msg.GetPackage()
-							if fullMessageName != "" {
-								fullMessageName += "."
-							}
-							fullMessageName += message.GetName()
-							if fullMessageName == outputTypeName {
-								outputType = proto.MessageType{
-									Name:   outputTypeName,
-									Fields: make([]proto.MessageField, 0),
-								}
-								for _, field := range message.GetField() {
-									fieldType := protoTypeToString(field.GetType())
-									if field.GetType() == descriptorpb.FieldDescriptorProto_TYPE_MESSAGE {
-										fieldType = field.GetTypeName()
-										if strings.HasPrefix(fieldType, ".") {
-											fieldType = fieldType[1:]
-										}
-										// Handle Google well-known types
-										if strings.HasPrefix(fieldType, "google.protobuf.") {
-											// For well-known types, we need to keep the full type name
-											fieldType = fieldType
-											// Add the well-known type to imports if not already present
-											wellKnownType := strings.TrimPrefix(fieldType, "google.protobuf.")
-											wellKnownProto := fmt.Sprintf("google/protobuf/%s.proto", strings.ToLower(wellKnownType))
-											found := false
-											for _, imp := range def.Imports {
-												if imp == wellKnownProto {
-													found = true
-													break
-												}
-											}
-											if !found {
-												def.Imports = append(def.Imports, wellKnownProto)
-											}
-										}
-									} else if field.GetType() == descriptorpb.FieldDescriptorProto_TYPE_ENUM {
-										fieldType = field.GetTypeName()
-										if strings.HasPrefix(fieldType, ".") {
-											fieldType = fieldType[1:]
-										}
-									}
-
-									msgField := proto.MessageField{
-										Name:       field.GetName(),
-										Number:     int32(field.GetNumber()),
-										Type:       fieldType,
-										IsRepeated: field.GetLabel() == descriptorpb.FieldDescriptorProto_LABEL_REPEATED,
-										IsRequired: field.GetLabel() == descriptorpb.FieldDescriptorProto_LABEL_REQUIRED,
-										Options: proto.FieldOption{
-											JSONName: field.GetJsonName(),
-										},
-									}
-									outputType.Fields = append(outputType.Fields, msgField)
-								}
-								break
-							}
-						}
-					}
-
-					svc.Methods = append(svc.Methods, proto.Method{
-						Name:            method.GetName(),
-						InputType:       inputType,
-						OutputType:      outputType,
-						ClientStreaming: method.GetClientStreaming(),
-						ServerStreaming: method.GetServerStreaming(),
-					})
-				}
-
-				def.Services = append(def.Services, svc)
-			}
-
-			fmt.Printf("[DEBUG] Found %d services\n", len(def.Services))
-			if len(def.Services) > 0 {
-				fmt.Printf("[DEBUG] First service has %d methods\n", len(def.Services[0].Methods))
-			}
-
-			// Extract enums
-			for _, enum := range fileDesc.GetEnumType() {
-				enumDef := proto.EnumType{
-					Name:   enum.GetName(),
-					Values: make([]proto.EnumValue, 0),
-				}
-
-				for _, value := range enum.GetValue() {
-					enumDef.Values = append(enumDef.Values, proto.EnumValue{
-						Name:   value.GetName(),
-						Number: int32(value.GetNumber()),
-					})
-				}
-
-				def.Enums = append(def.Enums, enumDef)
-			}
-
-			fmt.Printf("[DEBUG] Found %d enums\n", len(def.Enums))
-
-			// Extract messages
-			for _, message := range fileDesc.GetMessageType() {
-				msgType := proto.MessageType{
-					Name:   message.GetName(),
-					Fields: make([]proto.MessageField, 0),
-				}
-
-				for _, field := range message.GetField() {
-					fieldType := protoTypeToString(field.GetType())
-					if field.GetType() == descriptorpb.FieldDescriptorProto_TYPE_MESSAGE {
-						fieldType = field.GetTypeName()
-						if strings.HasPrefix(fieldType, ".") {
-							fieldType = fieldType[1:]
-						}
-						// Handle Google well-known types
-						if strings.HasPrefix(fieldType, "google.protobuf.") {
-							// For well-known types, we need to keep the full type name
-							fieldType = fieldType
-							// Add the well-known type to imports if not already present
-							wellKnownType := strings.TrimPrefix(fieldType, "google.protobuf.")
-							wellKnownProto := fmt.Sprintf("google/protobuf/%s.proto", strings.ToLower(wellKnownType))
-							found := false
-							for _, imp := range def.Imports {
-								if imp == wellKnownProto {
-									found = true
-									break
-								}
-							}
-							if !found {
-								def.Imports = append(def.Imports, wellKnownProto)
-							}
-						}
-					} else if field.GetType() == descriptorpb.FieldDescriptorProto_TYPE_ENUM {
-						fieldType = field.GetTypeName()
-						if strings.HasPrefix(fieldType, ".") {
-							fieldType = fieldType[1:]
-						}
-					}
-
-					msgField := proto.MessageField{
-						Name:       field.GetName(),
-						Number:     int32(field.GetNumber()),
-						Type:       fieldType,
-						IsRepeated: field.GetLabel() == descriptorpb.FieldDescriptorProto_LABEL_REPEATED,
-						IsRequired: field.GetLabel() == descriptorpb.FieldDescriptorProto_LABEL_REQUIRED,
-						Options: proto.FieldOption{
-							JSONName: field.GetJsonName(),
-						},
-					}
-
-					msgType.Fields = append(msgType.Fields, msgField)
-				}
-
-				def.Messages = append(def.Messages, msgType)
+			// Extract options, services, messages and enums
+			fd, err := files.FindFileByPath(fileDesc.GetName())
+			if err != nil {
+				logger.Warn("Failed to find file descriptor", "file", fileDesc.GetName(), "error", err)
+				continue
 			}
+			def.Describe(fd)
 
-			fmt.Printf("[DEBUG] Found %d messages\n", len(def.Messages))
-
-			// Extract file options
-			if opts := fileDesc.GetOptions(); opts != nil {
-				fileOptionsMap := make(map[string]interface{})
-				if opts.JavaPackage != nil {
-					fileOptionsMap["java_package"] = opts.GetJavaPackage()
-				}
-				if opts.GoPackage != nil {
-					fileOptionsMap["go_package"] = opts.GetGoPackage()
-				}
-				if opts.CsharpNamespace != nil {
-					fileOptionsMap["csharp_namespace"] = opts.GetCsharpNamespace()
-				}
-				if len(fileOptionsMap) > 0 {
-					if b, err := json.Marshal(fileOptionsMap); err == nil {
-						def.FileOptions = string(b)
-					}
-				}
-			}
+			logger.Debug("Parsed proto file", "file", def.FilePath, "services", len(def.Services),
+				"messages", len(def.Messages), "enums", len(def.Enums))
 
 			// Check if proto definition already exists
 			existingDefs, err := p.store.ListProtoDefinitionsByProfile(ctx, serverProfileId)
 			if err != nil {
-				fmt.Printf("[DEBUG] Failed to list proto definitions: %v\n", err)
+				logger.Warn("Failed to list proto definitions", "error", err)
 				continue
 			}
 
@@ -452,7 +195,6 @@ func (p *ProtoParser) ScanAndParseProtoPath(ctx context.Context, serverProfileId
 			}
 
 			if existingDef != nil {
-				fmt.Printf("[DEBUG] Found existing definition, updating...\n")
 				def.ID = existingDef.ID
 				def.CreatedAt = existingDef.CreatedAt
 				// Delete any duplicate definitions with the same file path
@@ -461,33 +203,29 @@ func (p *ProtoParser) ScanAndParseProtoPath(ctx context.Context, serverProfileId
 						normalizedExistingPath, _ := filepath.Abs(d.FilePath)
 						normalizedNewPath, _ := filepath.Abs(def.FilePath)
 						if normalizedExistingPath == normalizedNewPath {
-							fmt.Printf("[DEBUG] Deleting duplicate definition with ID: %s\n", d.ID)
 							err = p.store.DeleteProtoDefinition(ctx, d.ID)
 							if err != nil {
-								fmt.Printf("[DEBUG] Failed to delete duplicate definition: %v\n", err)
+								logger.Warn("Failed to delete duplicate proto definition", "id", d.ID, "error", err)
 							}
 						}
 					}
 				}
 				err = p.store.UpdateProtoDefinition(ctx, def)
 				if err != nil {
-					fmt.Printf("[DEBUG] Failed to update proto definition: %v\n", err)
+					logger.Warn("Failed to update proto definition", "file", def.FilePath, "error", err)
 					continue
 				}
-				fmt.Printf("[DEBUG] Successfully updated proto definition for %s\n", def.FilePath)
 			} else {
-				fmt.Printf("[DEBUG] Creating new proto definition...\n")
 				err = p.store.CreateProtoDefinition(ctx, def)
 				if err != nil {
-					fmt.Printf("[DEBUG] Failed to create proto definition: %v\n", err)
+					logger.Warn("Failed to create proto definition", "file", def.FilePath, "error", err)
 					continue
 				}
-				fmt.Printf("[DEBUG] Successfully created proto definition for %s\n", def.FilePath)
 			}
 			successfullyParsed++
 		}
 	}
 
-	fmt.Printf("[INFO] Successfully parsed and saved %d proto definitions out of %d files\n", successfullyParsed, len(protoFiles))
+	logger.Info("Parsed proto path", "definitions", successfullyParsed, "files", len(protoFiles))
 	return nil
 }
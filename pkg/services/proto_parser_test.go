@@ -102,10 +102,100 @@ func (m *MockServerProfileStore) GetPerRequestHeaders(ctx context.Context, serve
 	return nil, nil
 }
 
+func (m *MockServerProfileStore) Close() error {
+	return nil
+}
+
+func (m *MockServerProfileStore) ListPerRequestHeaders(ctx context.Context, serverProfileID string) ([]*models.PerRequestHeaders, error) {
+	return nil, nil
+}
+
 func (m *MockServerProfileStore) DeletePerRequestHeaders(ctx context.Context, serverProfileID, serviceName, methodName string) error {
 	return nil
 }
 
+func (m *MockServerProfileStore) CreateCollection(ctx context.Context, c *models.Collection) error {
+	return nil
+}
+
+func (m *MockServerProfileStore) GetCollection(ctx context.Context, id string) (*models.Collection, error) {
+	return nil, nil
+}
+
+func (m *MockServerProfileStore) ListCollections(ctx context.Context) ([]*models.Collection, error) {
+	return nil, nil
+}
+
+func (m *MockServerProfileStore) UpdateCollection(ctx context.Context, c *models.Collection) error {
+	return nil
+}
+
+func (m *MockServerProfileStore) DeleteCollection(ctx context.Context, id string) error {
+	return nil
+}
+
+func (m *MockServerProfileStore) CreateSavedRequest(ctx context.Context, r *models.SavedRequest) error {
+	return nil
+}
+
+func (m *MockServerProfileStore) GetSavedRequest(ctx context.Context, id string) (*models.SavedRequest, error) {
+	return nil, nil
+}
+
+func (m *MockServerProfileStore) ListSavedRequests(ctx context.Context, collectionID string) ([]*models.SavedRequest, error) {
+	return nil, nil
+}
+
+func (m *MockServerProfileStore) UpdateSavedRequest(ctx context.Context, r *models.SavedRequest) error {
+	return nil
+}
+
+func (m *MockServerProfileStore) DeleteSavedRequest(ctx context.Context, id string) error {
+	return nil
+}
+
+func (m *MockServerProfileStore) CreateEnvironment(ctx context.Context, env *models.Environment) error {
+	return nil
+}
+
+func (m *MockServerProfileStore) GetEnvironment(ctx context.Context, id string) (*models.Environment, error) {
+	return nil, nil
+}
+
+func (m *MockServerProfileStore) ListEnvironments(ctx context.Context) ([]*models.Environment, error) {
+	return nil, nil
+}
+
+func (m *MockServerProfileStore) UpdateEnvironment(ctx context.Context, env *models.Environment) error {
+	return nil
+}
+
+func (m *MockServerProfileStore) DeleteEnvironment(ctx context.Context, id string) error {
+	return nil
+}
+
+func (m *MockServerProfileStore) GetSetting(ctx context.Context, key string) (string, error) {
+	return "", nil
+}
+
+func (m *MockServerProfileStore) SetSetting(ctx context.Context, key, value string) error {
+	return nil
+}
+
+func (m *MockServerProfileStore) UnlockSecrets(ctx context.Context, passphrase string) error {
+	return nil
+}
+
+func (m *MockServerProfileStore) UnlockSecretsWithKeyFile(ctx context.Context, path string) error {
+	return nil
+}
+
+func (m *MockServerProfileStore) LockSecrets() {}
+
+func (m *MockServerProfileStore) GetSecretsStatus(ctx context.Context) (SecretsStatus, error) {
+	return SecretsStatus{}, nil
+}
+
 func TestProtoParser_ScanAndParseProtoPath(t *testing.T) {
 	// Create a temporary directory for test proto files
 	tempDir, err := os.MkdirTemp("", "proto-test-*")
@@ -142,7 +232,7 @@ enum TestEnum {
 
 	// Create mock store
 	mockStore := new(MockServerProfileStore)
-	parser := NewProtoParser(mockStore)
+	parser := NewProtoParser(mockStore, nil)
 
 	// Test case: successful parsing and storage
 	t.Run("successful parsing and storage", func(t *testing.T) {
@@ -170,7 +260,7 @@ enum TestEnum {
 
 		// Create a new mock store for this test case
 		mockStore = new(MockServerProfileStore)
-		parser = NewProtoParser(mockStore)
+		parser = NewProtoParser(mockStore, nil)
 
 		// Remove leading slash to match parser's fileDesc.GetName()
 		relPath := testProtoPath
@@ -206,7 +296,7 @@ enum TestEnum {
 
 		// Create a new mock store for this test case
 		mockStore = new(MockServerProfileStore)
-		parser = NewProtoParser(mockStore)
+		parser = NewProtoParser(mockStore, nil)
 
 		// Create an invalid proto file
 		invalidProtoContent := `invalid proto content`
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"protodesk/pkg/models"
+
+	"golang.org/x/net/http/httpproxy"
+	"golang.org/x/net/proxy"
+	"google.golang.org/grpc"
+)
+
+// ProxyDialOptions returns the dial options that route a connection through
+// the profile's proxy, or nil when it connects directly
+func ProxyDialOptions(cfg *models.ProxyConfig) []grpc.DialOption {
+	if cfg.IsDirect() {
+		return nil
+	}
+	dialer := &proxyDialer{config: *cfg}
+	return []grpc.DialOption{grpc.WithContextDialer(dialer.DialContext)}
+}
+
+// proxyDialer opens connections through an HTTP CONNECT or SOCKS5 proxy
+type proxyDialer struct {
+	config models.ProxyConfig
+	direct net.Dialer
+}
+
+// DialContext connects to addr, a host:port, through the proxy chosen for it
+func (d *proxyDialer) DialContext(ctx context.Context, addr string) (net.Conn, error) {
+	proxyURL, err := d.proxyFor(addr)
+	if err != nil {
+		return nil, err
+	}
+	if proxyURL == nil {
+		return d.direct.DialContext(ctx, "tcp", addr)
+	}
+	switch proxyURL.Scheme {
+	case "http", "https":
+		return d.dialConnect(ctx, proxyURL, addr)
+	case "socks5", "socks5h":
+		return d.dialSOCKS5(ctx, proxyURL, addr)
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q", proxyURL.Scheme)
+	}
+}
+
+// proxyFor returns the proxy to reach addr through, or nil to connect directly
+func (d *proxyDialer) proxyFor(addr string) (*url.URL, error) {
+	var scheme string
+	switch d.config.Mode {
+	case models.ProxyEnvironment:
+		return httpproxy.FromEnvironment().ProxyFunc()(&url.URL{Scheme: "https", Host: addr})
+	case models.ProxyHTTP:
+		scheme = "http"
+	case models.ProxySOCKS5:
+		scheme = "socks5"
+	default:
+		return nil, nil
+	}
+	proxyURL := &url.URL{Scheme: scheme, Host: d.config.Address}
+	if d.config.Username != "" {
+		proxyURL.User = url.UserPassword(d.config.Username, d.config.Password)
+	}
+	return proxyURL, nil
+}
+
+// dialConnect opens a tunnel to addr with an HTTP CONNECT request
+func (d *proxyDialer) dialConnect(ctx context.Context, proxyURL *url.URL, addr string) (net.Conn, error) {
+	conn, err := d.direct.DialContext(ctx, "tcp", proxyURL.Host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach proxy %s: %w", proxyURL.Host, err)
+	}
+	// The handshake is bounded by the dial deadline
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if user := proxyURL.User; user != nil {
+		password, _ := user.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to send CONNECT to proxy %s: %w", proxyURL.Host, err)
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to read CONNECT response from proxy %s: %w", proxyURL.Host, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy %s refused CONNECT to %s: %s", proxyURL.Host, addr, resp.Status)
+	}
+	if br.Buffered() > 0 {
+		// The server spoke first; keep what was read with the response
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+	return conn, nil
+}
+
+// dialSOCKS5 connects to addr through a SOCKS5 proxy
+func (d *proxyDialer) dialSOCKS5(ctx context.Context, proxyURL *url.URL, addr string) (net.Conn, error) {
+	var auth *proxy.Auth
+	if user := proxyURL.User; user != nil {
+		password, _ := user.Password()
+		auth = &proxy.Auth{User: user.Username(), Password: password}
+	}
+	dialer, err := proxy.SOCKS5("tcp", proxyURL.Host, auth, &d.direct)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := dialer.(proxy.ContextDialer).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect through SOCKS5 proxy %s: %w", proxyURL.Host, err)
+	}
+	return conn, nil
+}
+
+// bufferedConn is a connection whose first bytes were read into a buffer
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
@@ -0,0 +1,253 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"protodesk/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testProxy is a local stand-in for a corporate proxy. It records the
+// targets it was asked to reach.
+type testProxy struct {
+	addr string
+
+	mu      sync.Mutex
+	targets []string
+}
+
+func (p *testProxy) record(target string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.targets = append(p.targets, target)
+}
+
+func (p *testProxy) seen() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.targets...)
+}
+
+// serveProxy accepts connections until the test ends, handing each to handle
+func serveProxy(t *testing.T, handle func(conn net.Conn)) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { lis.Close() })
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			go handle(conn)
+		}
+	}()
+	return lis.Addr().String()
+}
+
+// tunnel copies between the client and a connection to target
+func tunnel(client net.Conn, target string) {
+	upstream, err := net.Dial("tcp", target)
+	if err != nil {
+		client.Close()
+		return
+	}
+	go func() {
+		_, _ = io.Copy(upstream, client)
+		upstream.Close()
+	}()
+	_, _ = io.Copy(client, upstream)
+	client.Close()
+}
+
+// startConnectProxy starts an HTTP CONNECT proxy requiring basic auth
+func startConnectProxy(t *testing.T, username, password string) *testProxy {
+	p := &testProxy{}
+	wantAuth := "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+	p.addr = serveProxy(t, func(conn net.Conn) {
+		req, err := http.ReadRequest(bufio.NewReader(conn))
+		if err != nil || req.Method != http.MethodConnect {
+			conn.Close()
+			return
+		}
+		if req.Header.Get("Proxy-Authorization") != wantAuth {
+			_, _ = io.WriteString(conn, "HTTP/1.1 407 Proxy Authentication Required\r\n\r\n")
+			conn.Close()
+			return
+		}
+		p.record(req.Host)
+		_, _ = io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+		tunnel(conn, req.Host)
+	})
+	return p
+}
+
+// startSOCKS5Proxy starts a SOCKS5 proxy requiring username/password auth
+func startSOCKS5Proxy(t *testing.T, username, password string) *testProxy {
+	p := &testProxy{}
+	p.addr = serveProxy(t, func(conn net.Conn) {
+		r := bufio.NewReader(conn)
+		// Greeting: version, methods; answer username/password (0x02)
+		header := make([]byte, 2)
+		if _, err := io.ReadFull(r, header); err != nil {
+			conn.Close()
+			return
+		}
+		if _, err := io.ReadFull(r, make([]byte, header[1])); err != nil {
+			conn.Close()
+			return
+		}
+		_, _ = conn.Write([]byte{5, 2})
+
+		// Username/password sub-negotiation
+		readField := func() string {
+			n, _ := r.ReadByte()
+			field := make([]byte, n)
+			_, _ = io.ReadFull(r, field)
+			return string(field)
+		}
+		_, _ = r.ReadByte()
+		if readField() != username || readField() != password {
+			_, _ = conn.Write([]byte{1, 1})
+			conn.Close()
+			return
+		}
+		_, _ = conn.Write([]byte{1, 0})
+
+		// Request: version, CONNECT, reserved, address type, address, port
+		request := make([]byte, 4)
+		if _, err := io.ReadFull(r, request); err != nil || request[1] != 1 {
+			conn.Close()
+			return
+		}
+		var host string
+		switch request[3] {
+		case 1:
+			ip := make([]byte, 4)
+			_, _ = io.ReadFull(r, ip)
+			host = net.IP(ip).String()
+		case 3:
+			host = readField()
+		default:
+			conn.Close()
+			return
+		}
+		portBytes := make([]byte, 2)
+		_, _ = io.ReadFull(r, portBytes)
+		target := net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(portBytes))))
+		p.record(target)
+		_, _ = conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+		tunnel(conn, target)
+	})
+	return p
+}
+
+// invokeThroughProxy connects a profile for the test server through proxy
+// and runs a health check
+func invokeThroughProxy(t *testing.T, proxy *models.ProxyConfig) string {
+	t.Helper()
+	_, port := splitTestAddr(t, startTestServer(t))
+	store, cleanup := setupTestStore(t)
+	t.Cleanup(cleanup)
+	manager := NewServerProfileManager(store, nil)
+	t.Cleanup(manager.DisconnectAll)
+	ctx := context.Background()
+
+	profile := models.NewServerProfile("behind-proxy", "localhost", port)
+	profile.Proxy = proxy
+	require.NoError(t, store.UnlockSecrets(ctx, "passphrase"))
+	require.NoError(t, store.Create(ctx, profile))
+
+	// The proxy password is encrypted at rest
+	var proxyJSON string
+	require.NoError(t, store.db.QueryRow(`SELECT proxy_json FROM server_profiles WHERE id = ?`, profile.ID).Scan(&proxyJSON))
+	assert.NotContains(t, proxyJSON, proxy.Password)
+	assert.Contains(t, proxyJSON, proxy.Address)
+
+	require.NoError(t, manager.Connect(ctx, profile.ID))
+	require.NoError(t, manager.WaitForReady(ctx, profile.ID))
+	result, err := manager.Invoke(ctx, profile.ID, InvocationRequest{
+		ServiceName: "grpc.health.v1.Health",
+		MethodName:  "Check",
+		RequestJSON: `{"service":"test.Service"}`,
+	})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"status":"SERVING"}`, result.ResponseJSON)
+	return net.JoinHostPort("localhost", strconv.Itoa(port))
+}
+
+func TestProxy_HTTPConnect(t *testing.T) {
+	proxy := startConnectProxy(t, "alice", "s3cret")
+	target := invokeThroughProxy(t, &models.ProxyConfig{
+		Mode:     models.ProxyHTTP,
+		Address:  proxy.addr,
+		Username: "alice",
+		Password: "s3cret",
+	})
+	// The host name is resolved by the proxy, not locally
+	assert.Contains(t, proxy.seen(), target)
+}
+
+func TestProxy_HTTPConnectRejected(t *testing.T) {
+	proxy := startConnectProxy(t, "alice", "s3cret")
+	dialer := &proxyDialer{config: models.ProxyConfig{Mode: models.ProxyHTTP, Address: proxy.addr, Username: "alice", Password: "wrong"}}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err := dialer.DialContext(ctx, "localhost:1")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "407")
+	assert.Empty(t, proxy.seen())
+}
+
+func TestProxy_SOCKS5(t *testing.T) {
+	proxy := startSOCKS5Proxy(t, "bob", "hunter2")
+	target := invokeThroughProxy(t, &models.ProxyConfig{
+		Mode:     models.ProxySOCKS5,
+		Address:  proxy.addr,
+		Username: "bob",
+		Password: "hunter2",
+	})
+	assert.Contains(t, proxy.seen(), target)
+}
+
+func TestProxy_Environment(t *testing.T) {
+	t.Setenv("HTTPS_PROXY", "http://proxy.corp:3128")
+	t.Setenv("NO_PROXY", "internal.corp")
+	dialer := &proxyDialer{config: models.ProxyConfig{Mode: models.ProxyEnvironment}}
+
+	proxyURL, err := dialer.proxyFor("api.example.com:443")
+	require.NoError(t, err)
+	require.NotNil(t, proxyURL)
+	assert.Equal(t, "proxy.corp:3128", proxyURL.Host)
+
+	proxyURL, err = dialer.proxyFor("db.internal.corp:443")
+	require.NoError(t, err)
+	assert.Nil(t, proxyURL)
+}
+
+func TestProxyConfig_Validate(t *testing.T) {
+	assert.NoError(t, (&models.ProxyConfig{Mode: models.ProxyEnvironment}).Validate())
+	assert.NoError(t, (&models.ProxyConfig{Mode: models.ProxySOCKS5, Address: "proxy:1080"}).Validate())
+	for _, invalid := range []*models.ProxyConfig{
+		{Mode: models.ProxyHTTP},
+		{Mode: models.ProxyHTTP, Address: "proxy"},
+		{Mode: models.ProxySOCKS5, Address: "proxy:1080", Password: "x"},
+		{Mode: "ftp"},
+	} {
+		assert.ErrorIs(t, invalid.Validate(), models.ErrInvalidProxyConfig)
+	}
+	assert.Nil(t, ProxyDialOptions(nil))
+}
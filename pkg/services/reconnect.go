@@ -0,0 +1,133 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/connectivity"
+)
+
+// settingReconnectPolicy holds the reconnect policy as JSON
+const settingReconnectPolicy = "connection.reconnect"
+
+// ErrInvalidReconnectPolicy is returned when a reconnect policy has values out of range
+var ErrInvalidReconnectPolicy = errors.New("invalid reconnect policy")
+
+// ReconnectPolicy is the exponential backoff used to re-establish dropped
+// connections: the first retry waits BaseDelayMs, each further retry
+// Multiplier times longer, up to MaxDelayMs, randomized by Jitter.
+type ReconnectPolicy struct {
+	BaseDelayMs int64   `json:"baseDelayMs"`
+	Multiplier  float64 `json:"multiplier"`
+	Jitter      float64 `json:"jitter"`
+	MaxDelayMs  int64   `json:"maxDelayMs"`
+}
+
+// DefaultReconnectPolicy returns the policy used until another one is saved
+func DefaultReconnectPolicy() ReconnectPolicy {
+	return ReconnectPolicy{
+		BaseDelayMs: 1000,
+		Multiplier:  1.6,
+		Jitter:      0.2,
+		MaxDelayMs:  30000,
+	}
+}
+
+// Validate checks that the policy describes a usable backoff
+func (p ReconnectPolicy) Validate() error {
+	if p.BaseDelayMs <= 0 {
+		return fmt.Errorf("%w: base delay must be positive", ErrInvalidReconnectPolicy)
+	}
+	if p.Multiplier < 1 {
+		return fmt.Errorf("%w: multiplier must be at least 1", ErrInvalidReconnectPolicy)
+	}
+	if p.Jitter < 0 || p.Jitter > 1 {
+		return fmt.Errorf("%w: jitter must be between 0 and 1", ErrInvalidReconnectPolicy)
+	}
+	if p.MaxDelayMs < p.BaseDelayMs {
+		return fmt.Errorf("%w: max delay must not be below the base delay", ErrInvalidReconnectPolicy)
+	}
+	return nil
+}
+
+// dialOption applies the policy to a connection
+func (p ReconnectPolicy) dialOption() grpc.DialOption {
+	return grpc.WithConnectParams(grpc.ConnectParams{
+		Backoff: backoff.Config{
+			BaseDelay:  time.Duration(p.BaseDelayMs) * time.Millisecond,
+			Multiplier: p.Multiplier,
+			Jitter:     p.Jitter,
+			MaxDelay:   time.Duration(p.MaxDelayMs) * time.Millisecond,
+		},
+		MinConnectTimeout: 20 * time.Second,
+	})
+}
+
+// GetReconnectPolicy returns the saved reconnect policy, or the default one
+func (m *ServerProfileManager) GetReconnectPolicy(ctx context.Context) (ReconnectPolicy, error) {
+	value, err := m.store.GetSetting(ctx, settingReconnectPolicy)
+	if err != nil || value == "" {
+		return DefaultReconnectPolicy(), err
+	}
+	var policy ReconnectPolicy
+	if err := json.Unmarshal([]byte(value), &policy); err != nil {
+		return DefaultReconnectPolicy(), fmt.Errorf("invalid stored reconnect policy: %w", err)
+	}
+	return policy, nil
+}
+
+// SetReconnectPolicy saves the reconnect policy used by later connections
+func (m *ServerProfileManager) SetReconnectPolicy(ctx context.Context, policy ReconnectPolicy) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+	data, err := json.Marshal(policy)
+	if err != nil {
+		return err
+	}
+	return m.store.SetSetting(ctx, settingReconnectPolicy, string(data))
+}
+
+// ConnectionStateEvent reports a connectivity transition of a profile
+type ConnectionStateEvent struct {
+	ProfileID string `json:"profileId"`
+	// State is IDLE, CONNECTING, READY, TRANSIENT_FAILURE or SHUTDOWN
+	State string `json:"state"`
+	// Connected is true while calls can be made without reconnecting
+	Connected bool `json:"connected"`
+	// Error is why the connection was closed, if it failed
+	Error string `json:"error,omitempty"`
+}
+
+// isUsable reports whether a connection in this state serves calls. An idle
+// connection reconnects transparently on the next call.
+func isUsable(state connectivity.State) bool {
+	return state == connectivity.Ready || state == connectivity.Idle
+}
+
+// watchConnection follows the state of conn until it is closed, reporting
+// every transition to onChange. An idle connection is told to reconnect
+// straight away, so a dropped connection comes back without waiting for a
+// call; retries then follow the connection's backoff.
+func watchConnection(logger *slog.Logger, conn *grpc.ClientConn, onChange func(*grpc.ClientConn, connectivity.State)) {
+	state := conn.GetState()
+	for conn.WaitForStateChange(context.Background(), state) {
+		state = conn.GetState()
+		if state == connectivity.Shutdown {
+			return
+		}
+		logger.Debug("Connection state changed", "state", state.String())
+		if onChange != nil {
+			onChange(conn, state)
+		}
+		if state == connectivity.Idle {
+			conn.Connect()
+		}
+	}
+}
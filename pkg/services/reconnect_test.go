@@ -0,0 +1,173 @@
+package services
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"protodesk/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// serveHealthOn serves the health service on addr until stopped
+func serveHealthOn(t *testing.T, addr string) *grpc.Server {
+	t.Helper()
+	lis, err := net.Listen("tcp", addr)
+	require.NoError(t, err)
+	s := grpc.NewServer()
+	healthpb.RegisterHealthServer(s, health.NewServer())
+	go func() { _ = s.Serve(lis) }()
+	t.Cleanup(s.Stop)
+	return s
+}
+
+// stateRecorder collects the connection state events of a manager
+type stateRecorder struct {
+	mu     sync.Mutex
+	events []ConnectionStateEvent
+}
+
+func (r *stateRecorder) record(event ConnectionStateEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+// seen reports whether an event with the given state was recorded
+func (r *stateRecorder) seen(state string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, event := range r.events {
+		if event.State == state {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *stateRecorder) reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = nil
+}
+
+func TestServerProfileManager_Reconnects(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+	manager := NewServerProfileManager(store, nil)
+	defer manager.DisconnectAll()
+	ctx := context.Background()
+	require.NoError(t, manager.SetReconnectPolicy(ctx, ReconnectPolicy{BaseDelayMs: 50, Multiplier: 1.5, MaxDelayMs: 200}))
+
+	recorder := &stateRecorder{}
+	manager.SetStateListener(recorder.record)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := lis.Addr().String()
+	require.NoError(t, lis.Close())
+	server := serveHealthOn(t, addr)
+
+	host, port := splitTestAddr(t, addr)
+	profile := models.NewServerProfile("flaky", host, port)
+	require.NoError(t, store.Create(ctx, profile))
+	require.NoError(t, manager.Connect(ctx, profile.ID))
+	require.NoError(t, manager.WaitForReady(ctx, profile.ID))
+	assert.True(t, manager.IsConnected(profile.ID))
+	assert.True(t, recorder.seen("READY"))
+
+	// Stopping the server drops the connection, which keeps retrying
+	recorder.reset()
+	server.Stop()
+	assert.Eventually(t, func() bool { return !manager.IsConnected(profile.ID) }, 5*time.Second, 10*time.Millisecond)
+	assert.Eventually(t, func() bool { return recorder.seen("TRANSIENT_FAILURE") }, 5*time.Second, 10*time.Millisecond)
+
+	// The connection comes back on its own once the server is up again
+	recorder.reset()
+	serveHealthOn(t, addr)
+	assert.Eventually(t, func() bool { return manager.IsConnected(profile.ID) }, 5*time.Second, 10*time.Millisecond)
+	assert.True(t, recorder.seen("READY"))
+	assert.Equal(t, "READY", manager.ConnectionState(profile.ID).String())
+
+	recorder.reset()
+	require.NoError(t, manager.Disconnect(ctx, profile.ID))
+	assert.True(t, recorder.seen("SHUTDOWN"))
+	assert.Equal(t, "SHUTDOWN", manager.ConnectionState(profile.ID).String())
+}
+
+func TestReconnectPolicy(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+	manager := NewServerProfileManager(store, nil)
+	ctx := context.Background()
+
+	policy, err := manager.GetReconnectPolicy(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, DefaultReconnectPolicy(), policy)
+
+	custom := ReconnectPolicy{BaseDelayMs: 250, Multiplier: 2, Jitter: 0.1, MaxDelayMs: 5000}
+	require.NoError(t, manager.SetReconnectPolicy(ctx, custom))
+	policy, err = manager.GetReconnectPolicy(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, custom, policy)
+
+	for _, invalid := range []ReconnectPolicy{
+		{BaseDelayMs: 0, Multiplier: 2, MaxDelayMs: 100},
+		{BaseDelayMs: 100, Multiplier: 0.5, MaxDelayMs: 100},
+		{BaseDelayMs: 100, Multiplier: 2, Jitter: 2, MaxDelayMs: 100},
+		{BaseDelayMs: 100, Multiplier: 2, MaxDelayMs: 50},
+	} {
+		assert.ErrorIs(t, manager.SetReconnectPolicy(ctx, invalid), ErrInvalidReconnectPolicy)
+	}
+}
+
+func TestServerProfileManager_ConnectDoesNotBlock(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+	manager := NewServerProfileManager(store, nil)
+	defer manager.DisconnectAll()
+	ctx := context.Background()
+	recorder := &stateRecorder{}
+	manager.SetStateListener(recorder.record)
+
+	// The listener accepts connections but never speaks HTTP/2
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer lis.Close()
+	host, port := splitTestAddr(t, lis.Addr().String())
+	profile := models.NewServerProfile("silent", host, port)
+	profile.ConnectTimeoutMs = 300
+	require.NoError(t, store.Create(ctx, profile))
+
+	start := time.Now()
+	require.NoError(t, manager.Connect(ctx, profile.ID))
+	assert.Less(t, time.Since(start), 250*time.Millisecond)
+	assert.True(t, recorder.seen("CONNECTING"))
+	assert.False(t, manager.IsConnected(profile.ID))
+
+	// The attempt is abandoned after the profile's connect timeout
+	err = manager.WaitForReady(ctx, profile.ID)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "connection timeout")
+	assert.Eventually(t, func() bool { return recorder.seen("SHUTDOWN") }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "SHUTDOWN", manager.ConnectionState(profile.ID).String())
+	_, err = manager.GetConnection(profile.ID)
+	assert.Error(t, err)
+
+	// A later server on the same profile connects normally
+	addr := startTestServer(t)
+	host, port = splitTestAddr(t, addr)
+	profile.Port = port
+	profile.Host = host
+	require.NoError(t, store.Update(ctx, profile))
+	require.NoError(t, manager.Connect(ctx, profile.ID))
+	require.NoError(t, manager.WaitForReady(ctx, profile.ID))
+	assert.True(t, manager.IsConnected(profile.ID))
+}
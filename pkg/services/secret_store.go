@@ -0,0 +1,271 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"protodesk/pkg/models"
+	"protodesk/pkg/secrets"
+)
+
+const (
+	// settingSecretsSalt holds the base64 salt used to derive the key from a passphrase
+	settingSecretsSalt = "secrets.salt"
+	// settingSecretsVerifier holds a value encrypted with the key, used to
+	// reject a wrong passphrase or key file
+	settingSecretsVerifier = "secrets.verifier"
+)
+
+// SecretsStatus describes the state of encrypted secret storage
+type SecretsStatus struct {
+	// Configured is true once a passphrase or key file has been set up
+	Configured bool `json:"configured"`
+	// Unlocked is true while secrets can be encrypted and decrypted
+	Unlocked bool `json:"unlocked"`
+}
+
+// GetSetting returns an application setting, or an empty string if unset
+func (s *SQLiteStore) GetSetting(ctx context.Context, key string) (string, error) {
+	var value string
+	err := s.q.GetContext(ctx, &value, `SELECT value FROM app_settings WHERE key = ?`, key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return value, err
+}
+
+// SetSetting stores an application setting
+func (s *SQLiteStore) SetSetting(ctx context.Context, key, value string) error {
+	query := `
+		INSERT INTO app_settings (key, value) VALUES (?, ?)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value
+	`
+	_, err := s.q.ExecContext(ctx, query, key, value)
+	return err
+}
+
+// UnlockSecrets derives the secret key from a passphrase. The first unlock
+// sets up secret storage with that passphrase; later unlocks fail with
+// secrets.ErrWrongKey if the passphrase differs.
+func (s *SQLiteStore) UnlockSecrets(ctx context.Context, passphrase string) error {
+	saltText, err := s.GetSetting(ctx, settingSecretsSalt)
+	if err != nil {
+		return err
+	}
+	var salt []byte
+	if saltText == "" {
+		if salt, err = secrets.NewSalt(); err != nil {
+			return err
+		}
+	} else if salt, err = base64.StdEncoding.DecodeString(saltText); err != nil {
+		return fmt.Errorf("invalid stored salt: %w", err)
+	}
+
+	key, err := secrets.DeriveKey(passphrase, salt)
+	if err != nil {
+		return err
+	}
+	box, err := secrets.NewBox(key)
+	if err != nil {
+		return err
+	}
+	if saltText == "" {
+		if err := s.SetSetting(ctx, settingSecretsSalt, base64.StdEncoding.EncodeToString(salt)); err != nil {
+			return err
+		}
+	}
+	return s.unlockWith(ctx, box)
+}
+
+// UnlockSecretsWithKeyFile reads the secret key from a key file instead of
+// deriving it from a passphrase
+func (s *SQLiteStore) UnlockSecretsWithKeyFile(ctx context.Context, path string) error {
+	key, err := secrets.ReadKeyFile(path)
+	if err != nil {
+		return err
+	}
+	box, err := secrets.NewBox(key)
+	if err != nil {
+		return err
+	}
+	return s.unlockWith(ctx, box)
+}
+
+// unlockWith checks box against the stored verifier, storing one on first use
+func (s *SQLiteStore) unlockWith(ctx context.Context, box *secrets.Box) error {
+	verifier, err := s.GetSetting(ctx, settingSecretsVerifier)
+	if err != nil {
+		return err
+	}
+	if verifier == "" {
+		if verifier, err = box.Verifier(); err != nil {
+			return err
+		}
+		if err := s.SetSetting(ctx, settingSecretsVerifier, verifier); err != nil {
+			return err
+		}
+	} else if err := box.Verify(verifier); err != nil {
+		return err
+	}
+
+	s.key.mu.Lock()
+	defer s.key.mu.Unlock()
+	s.key.box = box
+	return nil
+}
+
+// LockSecrets forgets the secret key. Secret values read afterwards stay
+// encrypted and saving new secret values fails with secrets.ErrLocked.
+func (s *SQLiteStore) LockSecrets() {
+	s.key.mu.Lock()
+	defer s.key.mu.Unlock()
+	s.key.box = nil
+}
+
+// GetSecretsStatus reports whether secret storage is set up and unlocked
+func (s *SQLiteStore) GetSecretsStatus(ctx context.Context) (SecretsStatus, error) {
+	verifier, err := s.GetSetting(ctx, settingSecretsVerifier)
+	if err != nil {
+		return SecretsStatus{}, err
+	}
+	s.key.mu.RLock()
+	defer s.key.mu.RUnlock()
+	return SecretsStatus{Configured: verifier != "", Unlocked: s.key.box != nil}, nil
+}
+
+// sealSecret encrypts a secret value for storage. Values that are already
+// encrypted are kept as they are, so records read while locked can be saved
+// again unchanged.
+func (s *SQLiteStore) sealSecret(value string) (string, error) {
+	if value == "" || secrets.IsEncrypted(value) {
+		return value, nil
+	}
+	s.key.mu.RLock()
+	defer s.key.mu.RUnlock()
+	if s.key.box == nil {
+		return "", secrets.ErrLocked
+	}
+	return s.key.box.Encrypt(value)
+}
+
+// openSecret decrypts a stored value. While locked, or if decryption fails,
+// the encrypted value is returned so that it is never mistaken for plain text.
+func (s *SQLiteStore) openSecret(value string) string {
+	if !secrets.IsEncrypted(value) {
+		return value
+	}
+	s.key.mu.RLock()
+	defer s.key.mu.RUnlock()
+	if s.key.box == nil {
+		return value
+	}
+	plain, err := s.key.box.Decrypt(value)
+	if err != nil {
+		return value
+	}
+	return plain
+}
+
+// sealHeaders returns a copy of headers with secret values encrypted
+func (s *SQLiteStore) sealHeaders(headers []models.Header) ([]models.Header, error) {
+	if headers == nil {
+		return nil, nil
+	}
+	sealed := make([]models.Header, len(headers))
+	for i, h := range headers {
+		if h.Secret {
+			value, err := s.sealSecret(h.Value)
+			if err != nil {
+				return nil, fmt.Errorf("header %s: %w", h.Key, err)
+			}
+			h.Value = value
+		}
+		sealed[i] = h
+	}
+	return sealed, nil
+}
+
+// openHeaders decrypts secret header values in place
+func (s *SQLiteStore) openHeaders(headers []models.Header) {
+	for i := range headers {
+		headers[i].Value = s.openSecret(headers[i].Value)
+	}
+}
+
+// sealAuth returns a copy of an auth config with its credentials encrypted
+func (s *SQLiteStore) sealAuth(auth *models.AuthConfig) (*models.AuthConfig, error) {
+	sealed := *auth
+	var err error
+	if sealed.ClientSecret, err = s.sealSecret(auth.ClientSecret); err != nil {
+		return nil, fmt.Errorf("client secret: %w", err)
+	}
+	if sealed.RefreshToken, err = s.sealSecret(auth.RefreshToken); err != nil {
+		return nil, fmt.Errorf("refresh token: %w", err)
+	}
+	return &sealed, nil
+}
+
+// openAuth decrypts the credentials of an auth config in place
+func (s *SQLiteStore) openAuth(auth *models.AuthConfig) {
+	auth.ClientSecret = s.openSecret(auth.ClientSecret)
+	auth.RefreshToken = s.openSecret(auth.RefreshToken)
+}
+
+// transformHeaderJSON applies fn to the values of the given keys in a headers
+// JSON document. Both the object form {"key": "value"} and the list form
+// [{"key": "value"}] used by the header editor are supported.
+func transformHeaderJSON(headersJSON string, keys []string, fn func(string) (string, error)) (string, error) {
+	if len(keys) == 0 || headersJSON == "" {
+		return headersJSON, nil
+	}
+	apply := func(obj map[string]string) error {
+		for k, v := range obj {
+			if !slices.Contains(keys, k) {
+				continue
+			}
+			out, err := fn(v)
+			if err != nil {
+				return fmt.Errorf("header %s: %w", k, err)
+			}
+			obj[k] = out
+		}
+		return nil
+	}
+
+	var list []map[string]string
+	if err := json.Unmarshal([]byte(headersJSON), &list); err == nil {
+		for _, obj := range list {
+			if err := apply(obj); err != nil {
+				return "", err
+			}
+		}
+		data, err := json.Marshal(list)
+		return string(data), err
+	}
+	var obj map[string]string
+	if err := json.Unmarshal([]byte(headersJSON), &obj); err != nil {
+		return "", fmt.Errorf("invalid headers JSON: %w", err)
+	}
+	if err := apply(obj); err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(obj)
+	return string(data), err
+}
+
+// MaskHeadersJSON masks the values of the given keys in a headers JSON
+// document. Documents that cannot be parsed are returned unchanged.
+func MaskHeadersJSON(headersJSON string, keys []string) string {
+	masked, err := transformHeaderJSON(headersJSON, keys, func(v string) (string, error) {
+		return secrets.Mask(v), nil
+	})
+	if err != nil {
+		return headersJSON
+	}
+	return masked
+}
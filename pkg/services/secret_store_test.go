@@ -0,0 +1,150 @@
+package services
+
+import (
+	"context"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"protodesk/pkg/models"
+	"protodesk/pkg/secrets"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteStore_UnlockSecrets(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	status, err := store.GetSecretsStatus(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, SecretsStatus{}, status)
+
+	// The first unlock sets up secret storage
+	require.NoError(t, store.UnlockSecrets(ctx, "correct horse"))
+	status, err = store.GetSecretsStatus(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, SecretsStatus{Configured: true, Unlocked: true}, status)
+
+	store.LockSecrets()
+	assert.ErrorIs(t, store.UnlockSecrets(ctx, "wrong"), secrets.ErrWrongKey)
+	status, err = store.GetSecretsStatus(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, SecretsStatus{Configured: true}, status)
+
+	require.NoError(t, store.UnlockSecrets(ctx, "correct horse"))
+
+	// A key file with a different key is rejected
+	keyFile := filepath.Join(t.TempDir(), "key")
+	require.NoError(t, os.WriteFile(keyFile, []byte(hex.EncodeToString(make([]byte, secrets.KeySize))), 0600))
+	assert.ErrorIs(t, store.UnlockSecretsWithKeyFile(ctx, keyFile), secrets.ErrWrongKey)
+}
+
+func TestSQLiteStore_SecretHeadersEncryptedAtRest(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	profile := models.NewServerProfile("prod", "api.example.com", 443)
+	profile.Headers = []models.Header{
+		{Key: "authorization", Value: "Bearer prod-token", Secret: true},
+		{Key: "x-tenant", Value: "acme"},
+	}
+	profile.Auth = &models.AuthConfig{
+		Type:         models.AuthOAuth2ClientCredentials,
+		TokenURL:     "https://auth.example.com/token",
+		ClientID:     "client",
+		ClientSecret: "client-secret",
+	}
+
+	// Secrets cannot be saved while locked
+	assert.ErrorIs(t, store.Create(ctx, profile), secrets.ErrLocked)
+
+	require.NoError(t, store.UnlockSecrets(ctx, "passphrase"))
+	require.NoError(t, store.Create(ctx, profile))
+	assert.Equal(t, "Bearer prod-token", profile.Headers[0].Value, "the caller's profile is not modified")
+
+	var headersJSON, authJSON string
+	require.NoError(t, store.db.QueryRow(`SELECT headers_json, auth_json FROM server_profiles WHERE id = ?`, profile.ID).Scan(&headersJSON, &authJSON))
+	assert.NotContains(t, headersJSON, "prod-token")
+	assert.Contains(t, headersJSON, "acme")
+	assert.Contains(t, headersJSON, secrets.Prefix)
+	assert.NotContains(t, authJSON, "client-secret")
+
+	got, err := store.Get(ctx, profile.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer prod-token", got.Headers[0].Value)
+	assert.Equal(t, "client-secret", got.Auth.ClientSecret)
+
+	// While locked, values stay encrypted and the profile cannot connect
+	store.LockSecrets()
+	got, err = store.Get(ctx, profile.ID)
+	require.NoError(t, err)
+	assert.True(t, secrets.IsEncrypted(got.Headers[0].Value))
+	assert.ErrorIs(t, checkProfileSecrets(got), secrets.ErrLocked)
+
+	// ...but can still be saved unchanged
+	got.Name = "prod-renamed"
+	require.NoError(t, store.Update(ctx, got))
+	require.NoError(t, store.UnlockSecrets(ctx, "passphrase"))
+	got, err = store.Get(ctx, profile.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "prod-renamed", got.Name)
+	assert.Equal(t, "Bearer prod-token", got.Headers[0].Value)
+
+	masked := models.MaskHeaders(got.Headers)
+	assert.Equal(t, secrets.MaskedValue, masked[0].Value)
+	assert.Equal(t, "acme", masked[1].Value)
+	assert.Equal(t, secrets.MaskedValue, got.Auth.Masked().ClientSecret)
+}
+
+func TestSQLiteStore_SecretVariablesAndPerRequestHeaders(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+	ctx := context.Background()
+	require.NoError(t, store.UnlockSecrets(ctx, "passphrase"))
+
+	env := models.NewEnvironment("prod")
+	env.Variables = map[string]string{"token": "prod-token", "host": "api"}
+	env.Secrets = []string{"token"}
+	require.NoError(t, store.CreateEnvironment(ctx, env))
+
+	var raw string
+	require.NoError(t, store.db.QueryRow(`SELECT variables_json FROM environments WHERE id = ?`, env.ID).Scan(&raw))
+	assert.NotContains(t, raw, "prod-token")
+
+	got, err := store.GetEnvironment(ctx, env.ID)
+	require.NoError(t, err)
+	assert.Equal(t, env.Variables, got.Variables)
+	assert.Equal(t, map[string]string{"token": secrets.MaskedValue, "host": "api"}, got.MaskVariables(got.Variables))
+
+	store.LockSecrets()
+	got, err = store.GetEnvironment(ctx, env.ID)
+	require.NoError(t, err)
+	_, err = ExpandVariables(`{"t":"{{token}}"}`, got.Variables)
+	assert.ErrorIs(t, err, secrets.ErrLocked)
+	require.NoError(t, store.UnlockSecrets(ctx, "passphrase"))
+
+	profile := models.NewServerProfile("p", "localhost", 50051)
+	require.NoError(t, store.Create(ctx, profile))
+	h := &models.PerRequestHeaders{
+		ServerProfileID: profile.ID,
+		ServiceName:     "svc",
+		MethodName:      "M",
+		HeadersJSON:     `[{"authorization":"Bearer t"},{"x-trace":"1"}]`,
+		SecretKeys:      []string{"authorization"},
+	}
+	require.NoError(t, store.UpsertPerRequestHeaders(ctx, h))
+	require.NoError(t, store.db.QueryRow(`SELECT headers_json FROM per_request_headers WHERE server_profile_id = ?`, profile.ID).Scan(&raw))
+	assert.False(t, strings.Contains(raw, "Bearer t"))
+
+	gotHeaders, err := store.GetPerRequestHeaders(ctx, profile.ID, "svc", "M")
+	require.NoError(t, err)
+	assert.JSONEq(t, h.HeadersJSON, gotHeaders.HeadersJSON)
+	assert.Equal(t, []string{"authorization"}, gotHeaders.SecretKeys)
+	assert.JSONEq(t, `[{"authorization":"********"},{"x-trace":"1"}]`, MaskHeadersJSON(gotHeaders.HeadersJSON, gotHeaders.SecretKeys))
+}
@@ -3,6 +3,8 @@ package services
 import (
 	"context"
 	"fmt"
+	"log/slog"
+	"net"
 	"os"
 	"path/filepath"
 	"sort"
@@ -13,11 +15,14 @@ import (
 	"github.com/google/uuid"
 	"github.com/jhump/protoreflect/grpcreflect"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
 	"google.golang.org/grpc/metadata"
 	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
 
+	"protodesk/pkg/logging"
 	"protodesk/pkg/models"
 	"protodesk/pkg/models/proto"
+	"protodesk/pkg/secrets"
 
 	"crypto/sha256"
 	"encoding/hex"
@@ -25,20 +30,45 @@ import (
 
 // ServerProfileManager handles server profile operations and maintains active connections
 type ServerProfileManager struct {
-	store         ServerProfileStore
-	grpcClient    GRPCClientManager
-	activeClients map[string]*grpc.ClientConn
-	mu            sync.RWMutex
-	protoParser   *ProtoParser
+	store      ServerProfileStore
+	logger     *slog.Logger
+	grpcClient GRPCClientManager
+	// activeClients holds a *grpc.ClientConn for native gRPC profiles and a
+	// *WebConn for gRPC-Web and Connect profiles
+	activeClients map[string]grpc.ClientConnInterface
+	// states holds the last connectivity state of each profile that is
+	// connected or connecting
+	states map[string]connectivity.State
+	// connectErrors holds why the last connection attempt of a profile failed
+	connectErrors map[string]error
+	// stateChanged is closed and replaced whenever states changes
+	stateChanged chan struct{}
+	// stateListener is told about connectivity transitions
+	stateListener func(ConnectionStateEvent)
+	// mu guards grpcClient, activeClients, the connection states and
+	// stateListener; it is never held while dialling
+	mu          sync.RWMutex
+	protoParser *ProtoParser
+	// profileLocks serialize Connect and Disconnect of each profile so that
+	// a slow dial of one profile does not block the others
+	profileLocks sync.Map
+	// health holds health watches, polling and the latest health results
+	health healthMonitor
 }
 
-// NewServerProfileManager creates a new server profile manager
-func NewServerProfileManager(store ServerProfileStore) *ServerProfileManager {
+// NewServerProfileManager creates a new server profile manager logging to
+// logger; a nil logger discards the output
+func NewServerProfileManager(store ServerProfileStore, logger *slog.Logger) *ServerProfileManager {
+	logger = logging.OrDiscard(logger)
 	return &ServerProfileManager{
 		store:         store,
-		grpcClient:    NewGRPCClientManager(),
-		activeClients: make(map[string]*grpc.ClientConn),
-		protoParser:   NewProtoParser(store),
+		logger:        logger,
+		grpcClient:    NewGRPCClientManager(logger),
+		activeClients: make(map[string]grpc.ClientConnInterface),
+		states:        make(map[string]connectivity.State),
+		connectErrors: make(map[string]error),
+		stateChanged:  make(chan struct{}),
+		protoParser:   NewProtoParser(store, logger),
 	}
 }
 
@@ -47,21 +77,38 @@ func (m *ServerProfileManager) GetStore() ServerProfileStore {
 	return m.store
 }
 
-// Connect establishes a gRPC connection to the specified server profile
+// profileLock returns the mutex serializing connection changes of a profile
+func (m *ServerProfileManager) profileLock(profileID string) *sync.Mutex {
+	lock, _ := m.profileLocks.LoadOrStore(profileID, &sync.Mutex{})
+	return lock.(*sync.Mutex)
+}
+
+// Connect starts a gRPC connection to the specified server profile. Each
+// profile gets its own connection, dialled with its own TLS, header and auth
+// settings, even when several profiles share a target.
+//
+// Connect does not wait for the server. The connection becomes ready in the
+// background and reports its progress to the state listener; if it is not
+// ready within the profile's connect timeout it is closed again. Use
+// WaitForReady to block until it can serve calls. gRPC-Web and Connect
+// profiles have no connection to wait for and are ready at once.
 func (m *ServerProfileManager) Connect(ctx context.Context, profileID string) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+	lock := m.profileLock(profileID)
+	lock.Lock()
+	defer lock.Unlock()
 
 	profile, err := m.store.Get(ctx, profileID)
 	if err != nil {
 		return fmt.Errorf("failed to get profile: %w", err)
 	}
 
-	// Build target address
-	target := fmt.Sprintf("%s:%d", profile.Host, profile.Port)
+	target := profile.DialTarget()
 
-	// Check if connection already exists
-	if _, exists := m.activeClients[profileID]; exists {
+	// A profile keeps its connection, even while it is reconnecting
+	m.mu.RLock()
+	_, exists := m.activeClients[profileID]
+	m.mu.RUnlock()
+	if exists {
 		return nil // Already connected
 	}
 
@@ -72,168 +119,463 @@ func (m *ServerProfileManager) Connect(ctx context.Context, profileID string) er
 	}
 
 	// Automatically enable TLS for port 443
-	useTLS := profile.TLSEnabled || profile.Port == 443
-
-	// Add headers to the context
-	ctxWithHeaders := ctx
-	if len(profile.Headers) > 0 {
-		md := metadata.New(nil)
-		for _, header := range profile.Headers {
-			md.Append(header.Key, header.Value)
+	useTLS := profile.TLSEnabled || profile.IsTCP() && profile.Port == 443
+
+	// Secrets read while secret storage is locked are still encrypted
+	if err := checkProfileSecrets(profile); err != nil {
+		return err
+	}
+
+	if !profile.IsNativeGRPC() {
+		if useTLS && certPath != "" {
+			return fmt.Errorf("failed to connect: custom certificates not implemented yet")
 		}
-		ctxWithHeaders = metadata.NewOutgoingContext(ctx, md)
+		return m.connectWeb(ctx, profile, useTLS)
 	}
 
-	if err := m.grpcClient.Connect(ctxWithHeaders, target, useTLS, certPath); err != nil {
+	md := profileHeaders(profile)
+	m.logger.Debug("Connecting profile", "profile", profileID, "target", target, "tls", useTLS, logging.Metadata("headers", md))
+
+	authOpts, err := AuthDialOptions(profile.Auth)
+	if err != nil {
+		return fmt.Errorf("failed to configure auth: %w", err)
+	}
+	dialOpts := append(TransportDialOptions(profile.Transport), authOpts...)
+	dialOpts = append(dialOpts, profileHeaderOptions(md)...)
+	// gRPC adds its own name and version after the app's user agent
+	dialOpts = append(dialOpts, grpc.WithUserAgent(userAgent(profile.Transport)))
+	if profile.Authority != "" {
+		dialOpts = append(dialOpts, grpc.WithAuthority(profile.Authority))
+	}
+	// Unix sockets are always reached directly
+	if profile.TargetKind != models.TargetUnix && !profile.Proxy.IsDirect() {
+		dialOpts = append(dialOpts, ProxyDialOptions(profile.Proxy)...)
+		if profile.IsTCP() {
+			// Leave resolving the host name to the proxy
+			target = "passthrough:///" + target
+		}
+	}
+
+	reconnect, err := m.GetReconnectPolicy(ctx)
+	if err != nil {
+		m.logger.Warn("Using the default reconnect policy", "error", err)
+	}
+
+	m.setState(profileID, connectivity.Connecting, nil)
+
+	grpcClient := m.GetGRPCClient()
+	if err := grpcClient.Connect(ctx, profileID, target, ConnectOptions{
+		UseTLS:      useTLS,
+		CertPath:    certPath,
+		DialOptions: dialOpts,
+		Reconnect:   reconnect,
+		Timeout:     profile.ConnectTimeout(),
+		OnStateChange: func(conn *grpc.ClientConn, state connectivity.State) {
+			m.updateState(profileID, conn, state)
+		},
+		OnReady: func(conn *grpc.ClientConn) {
+			if profile.UseReflection {
+				m.storeReflectedServices(context.WithoutCancel(ctx), profileID, conn)
+			}
+		},
+		OnTimeout: func(conn *grpc.ClientConn, err error) {
+			m.connectTimedOut(profileID, conn, err)
+		},
+	}); err != nil {
+		m.setState(profileID, connectivity.Shutdown, err)
 		return fmt.Errorf("failed to connect: %w", err)
 	}
 
-	conn, err := m.grpcClient.GetConnection(target)
+	conn, err := grpcClient.GetConnection(profileID)
 	if err != nil {
+		m.setState(profileID, connectivity.Shutdown, err)
 		return fmt.Errorf("failed to get connection: %w", err)
 	}
 
-	// If reflection is enabled, try to get services and methods
+	m.mu.Lock()
+	m.activeClients[profileID] = conn
+	m.mu.Unlock()
+	return nil
+}
+
+// profileHeaders returns the headers a profile sends with every call
+func profileHeaders(profile *models.ServerProfile) metadata.MD {
+	md := metadata.New(nil)
+	for _, header := range profile.Headers {
+		md.Append(header.Key, header.Value)
+	}
+	return md
+}
+
+// profileHeaderOptions returns the dial options that send a profile's
+// headers with every call of a native gRPC connection, as WebConn does for
+// the web protocols
+func profileHeaderOptions(headers metadata.MD) []grpc.DialOption {
+	if len(headers) == 0 {
+		return nil
+	}
+	withHeaders := func(ctx context.Context) context.Context {
+		md, _ := metadata.FromOutgoingContext(ctx)
+		return metadata.NewOutgoingContext(ctx, mergeHeaders(headers, md))
+	}
+	return []grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+			return invoker(withHeaders(ctx), method, req, reply, cc, opts...)
+		}),
+		grpc.WithChainStreamInterceptor(func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			return streamer(withHeaders(ctx), desc, cc, method, opts...)
+		}),
+	}
+}
+
+// mergeHeaders returns the metadata of a call with the headers of its
+// profile added. Keys the call sets itself keep only the call's values, so
+// headers the frontend already copied from the profile are not sent twice.
+func mergeHeaders(profile, call metadata.MD) metadata.MD {
+	md := call.Copy()
+	for key, values := range profile {
+		if _, ok := md[key]; !ok {
+			md[key] = append([]string(nil), values...)
+		}
+	}
+	return md
+}
+
+// connectWeb sets up a profile that speaks gRPC-Web or Connect. Its calls
+// are separate HTTP requests, so there is nothing to wait for: the profile is
+// ready at once and an unreachable server fails each call instead.
+func (m *ServerProfileManager) connectWeb(ctx context.Context, profile *models.ServerProfile, useTLS bool) error {
+	creds, err := AuthCredentials(profile.Auth)
+	if err != nil {
+		return fmt.Errorf("failed to configure auth: %w", err)
+	}
+	opts := WebConnOptions{
+		Protocol:    profile.Protocol,
+		BaseURL:     WebBaseURL(profile, useTLS),
+		Authority:   profile.Authority,
+		Header:      profileHeaders(profile),
+		Credentials: creds,
+		Transport:   profile.Transport,
+	}
+	switch {
+	case profile.TargetKind == models.TargetUnix:
+		var dialer net.Dialer
+		opts.Dial = func(ctx context.Context, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", profile.Target)
+		}
+	case !profile.Proxy.IsDirect():
+		opts.Dial = (&proxyDialer{config: *profile.Proxy}).DialContext
+	}
+	conn, err := NewWebConn(opts)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+
+	m.mu.Lock()
+	m.activeClients[profile.ID] = conn
+	m.mu.Unlock()
+	m.setState(profile.ID, connectivity.Ready, nil)
+
 	if profile.UseReflection {
-		services, err := m.grpcClient.ListServicesAndMethods(conn)
-		if err != nil {
-			// Log the error but don't fail the connection
-			fmt.Printf("[WARN] Failed to list services via reflection: %v\n", err)
-		} else {
-			// Get the reflection client
-			rc := grpcreflect.NewClient(ctx, reflectionpb.NewServerReflectionClient(conn))
-			defer rc.Reset()
-
-			// Store the services in the database
-			for serviceName, methods := range services {
-				// Get the service descriptor
-				svcDesc, err := rc.ResolveService(serviceName)
-				if err != nil {
-					fmt.Printf("[WARN] Failed to resolve service %s: %v\n", serviceName, err)
-					continue
-				}
+		go m.storeReflectedServices(context.WithoutCancel(ctx), profile.ID, conn)
+	}
+	return nil
+}
 
-				// Create a proto definition for each service
-				def := &proto.ProtoDefinition{
-					ID:              uuid.New().String(),
-					FilePath:        fmt.Sprintf("reflection/%s.proto", serviceName),
-					Content:         fmt.Sprintf("service %s {\n  // Methods: %v\n}", serviceName, methods),
-					Services:        []proto.Service{{Name: serviceName}},
-					Messages:        make([]proto.MessageType, 0),
-					Imports:         []string{"google/protobuf/timestamp.proto"},
-					CreatedAt:       time.Now(),
-					UpdatedAt:       time.Now(),
-					ServerProfileID: profileID,
-				}
+// storeReflectedServices lists the services of a ready connection through
+// reflection and stores them as proto definitions of the profile
+func (m *ServerProfileManager) storeReflectedServices(ctx context.Context, profileID string, conn grpc.ClientConnInterface) {
+	services, err := m.GetGRPCClient().ListServicesAndMethods(conn)
+	if err != nil {
+		// Log the error; the connection is still usable
+		m.logger.Warn("Failed to list services via reflection", "profile", profileID, "error", err)
+		return
+	}
 
-				// Extract message types from methods
-				for _, method := range methods {
-					// Get method descriptor
-					mDesc := svcDesc.FindMethodByName(method)
-					if mDesc == nil {
-						continue
-					}
+	// Get the reflection client
+	rc := grpcreflect.NewClient(ctx, reflectionpb.NewServerReflectionClient(conn))
+	defer rc.Reset()
 
-					// Get input type
-					inputType := mDesc.GetInputType()
-					if inputType != nil {
-						msg := proto.MessageType{
-							Name:   inputType.GetFullyQualifiedName(),
-							Fields: make([]proto.MessageField, 0),
-						}
-						// Only add if it's not already in the list
-						found := false
-						for _, existing := range def.Messages {
-							if existing.Name == msg.Name {
-								found = true
-								break
-							}
-						}
-						if !found {
-							def.Messages = append(def.Messages, msg)
-						}
-					}
+	// Store the services in the database
+	for serviceName, methods := range services {
+		// Get the service descriptor
+		svcDesc, err := rc.ResolveService(serviceName)
+		if err != nil {
+			m.logger.Warn("Failed to resolve service", "profile", profileID, "service", serviceName, "error", err)
+			continue
+		}
 
-					// Get output type
-					outputType := mDesc.GetOutputType()
-					if outputType != nil {
-						msg := proto.MessageType{
-							Name:   outputType.GetFullyQualifiedName(),
-							Fields: make([]proto.MessageField, 0),
-						}
-						// Only add if it's not already in the list
-						found := false
-						for _, existing := range def.Messages {
-							if existing.Name == msg.Name {
-								found = true
-								break
-							}
-						}
-						if !found {
-							def.Messages = append(def.Messages, msg)
-						}
-					}
-				}
+		// Create a proto definition for each service
+		def := &proto.ProtoDefinition{
+			ID:              uuid.New().String(),
+			FilePath:        fmt.Sprintf("reflection/%s.proto", serviceName),
+			Content:         fmt.Sprintf("service %s {\n  // Methods: %v\n}", serviceName, methods),
+			Services:        []proto.Service{{Name: serviceName}},
+			Messages:        make([]proto.MessageType, 0),
+			Imports:         []string{"google/protobuf/timestamp.proto"},
+			CreatedAt:       time.Now(),
+			UpdatedAt:       time.Now(),
+			ServerProfileID: profileID,
+		}
 
-				// Check if a proto definition with the same path already exists
-				existingDefs, err := m.store.ListProtoDefinitionsByProfile(ctx, profileID)
-				if err != nil {
-					fmt.Printf("[WARN] Failed to list proto definitions: %v\n", err)
-					continue
-				}
+		// Extract message types from methods
+		for _, method := range methods {
+			// Get method descriptor
+			mDesc := svcDesc.FindMethodByName(method)
+			if mDesc == nil {
+				continue
+			}
 
-				var existingDef *proto.ProtoDefinition
-				for _, d := range existingDefs {
-					if d.FilePath == def.FilePath {
-						existingDef = d
+			// Get input type
+			inputType := mDesc.GetInputType()
+			if inputType != nil {
+				msg := proto.MessageType{
+					Name:   inputType.GetFullyQualifiedName(),
+					Fields: make([]proto.MessageField, 0),
+				}
+				// Only add if it's not already in the list
+				found := false
+				for _, existing := range def.Messages {
+					if existing.Name == msg.Name {
+						found = true
 						break
 					}
 				}
+				if !found {
+					def.Messages = append(def.Messages, msg)
+				}
+			}
 
-				if existingDef != nil {
-					// Update existing definition
-					def.ID = existingDef.ID
-					def.CreatedAt = existingDef.CreatedAt
-					err = m.store.UpdateProtoDefinition(ctx, def)
-					if err != nil {
-						fmt.Printf("[WARN] Failed to update proto definition: %v\n", err)
-					}
-				} else {
-					// Create new definition
-					err = m.store.CreateProtoDefinition(ctx, def)
-					if err != nil {
-						fmt.Printf("[WARN] Failed to create proto definition: %v\n", err)
+			// Get output type
+			outputType := mDesc.GetOutputType()
+			if outputType != nil {
+				msg := proto.MessageType{
+					Name:   outputType.GetFullyQualifiedName(),
+					Fields: make([]proto.MessageField, 0),
+				}
+				// Only add if it's not already in the list
+				found := false
+				for _, existing := range def.Messages {
+					if existing.Name == msg.Name {
+						found = true
+						break
 					}
 				}
+				if !found {
+					def.Messages = append(def.Messages, msg)
+				}
 			}
 		}
-	}
 
-	m.activeClients[profileID] = conn
-	return nil
+		// Check if a proto definition with the same path already exists
+		existingDefs, err := m.store.ListProtoDefinitionsByProfile(ctx, profileID)
+		if err != nil {
+			m.logger.Warn("Failed to list proto definitions", "profile", profileID, "error", err)
+			continue
+		}
+
+		var existingDef *proto.ProtoDefinition
+		for _, d := range existingDefs {
+			if d.FilePath == def.FilePath {
+				existingDef = d
+				break
+			}
+		}
+
+		if existingDef != nil {
+			// Update existing definition
+			def.ID = existingDef.ID
+			def.CreatedAt = existingDef.CreatedAt
+			err = m.store.UpdateProtoDefinition(ctx, def)
+			if err != nil {
+				m.logger.Warn("Failed to update proto definition", "path", def.FilePath, "error", err)
+			}
+		} else {
+			// Create new definition
+			err = m.store.CreateProtoDefinition(ctx, def)
+			if err != nil {
+				m.logger.Warn("Failed to create proto definition", "path", def.FilePath, "error", err)
+			}
+		}
+	}
 }
 
-// Disconnect closes the gRPC connection for the specified profile
-func (m *ServerProfileManager) Disconnect(ctx context.Context, profileID string) error {
+// SetStateListener registers a function told about every connectivity
+// transition of a profile, including connects and disconnects. It is called
+// from background goroutines and must not block.
+func (m *ServerProfileManager) SetStateListener(listener func(ConnectionStateEvent)) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	m.stateListener = listener
+}
+
+// setState records the state of a profile whose connection is being made
+// or closed. Shutdown removes the profile's state; err is kept as the
+// reason its connection attempt failed.
+func (m *ServerProfileManager) setState(profileID string, state connectivity.State, err error) {
+	m.mu.Lock()
+	if state == connectivity.Shutdown {
+		delete(m.states, profileID)
+	} else {
+		m.states[profileID] = state
+	}
+	if err != nil {
+		m.connectErrors[profileID] = err
+	} else {
+		delete(m.connectErrors, profileID)
+	}
+	m.notifyStateChange()
+	m.mu.Unlock()
+	m.emitState(profileID, state, err)
+}
+
+// updateState records a state reported by the watcher of conn. Reports for
+// a connection that has since been replaced or closed are ignored.
+func (m *ServerProfileManager) updateState(profileID string, conn *grpc.ClientConn, state connectivity.State) {
+	m.mu.Lock()
+	_, connecting := m.states[profileID]
+	current, stored := m.activeClients[profileID]
+	if !connecting || (stored && current != conn) {
+		m.mu.Unlock()
+		return
+	}
+	m.states[profileID] = state
+	m.notifyStateChange()
+	m.mu.Unlock()
+	m.emitState(profileID, state, nil)
+}
+
+// connectTimedOut closes a connection that did not become ready in time
+func (m *ServerProfileManager) connectTimedOut(profileID string, conn *grpc.ClientConn, err error) {
+	lock := m.profileLock(profileID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	m.mu.Lock()
+	if m.activeClients[profileID] != conn {
+		m.mu.Unlock()
+		return
+	}
+	delete(m.activeClients, profileID)
+	m.mu.Unlock()
+	_ = m.GetGRPCClient().Disconnect(profileID)
+	m.setState(profileID, connectivity.Shutdown, err)
+}
+
+// notifyStateChange wakes up WaitForReady callers. The caller must hold m.mu.
+func (m *ServerProfileManager) notifyStateChange() {
+	close(m.stateChanged)
+	m.stateChanged = make(chan struct{})
+}
+
+// emitState passes a state to the listener, if any
+func (m *ServerProfileManager) emitState(profileID string, state connectivity.State, err error) {
+	m.mu.RLock()
+	listener := m.stateListener
+	m.mu.RUnlock()
+	if listener == nil {
+		return
+	}
+	event := ConnectionStateEvent{ProfileID: profileID, State: state.String(), Connected: isUsable(state)}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	listener(event)
+}
 
+// readyWaitGrace lets WaitForReady outlast the connect timeout, so that a
+// connection attempt that times out reports why it failed
+const readyWaitGrace = time.Second
+
+// WaitForReady blocks until the connection of a profile can serve calls. It
+// fails if the profile is not connected, if its connection attempt failed,
+// or if the connection is not ready within the profile's connect timeout.
+func (m *ServerProfileManager) WaitForReady(ctx context.Context, profileID string) error {
 	profile, err := m.store.Get(ctx, profileID)
 	if err != nil {
 		return fmt.Errorf("failed to get profile: %w", err)
 	}
+	ctx, cancel := context.WithTimeout(ctx, profile.ConnectTimeout()+readyWaitGrace)
+	defer cancel()
+
+	for {
+		m.mu.RLock()
+		state, connected := m.states[profileID]
+		connectErr := m.connectErrors[profileID]
+		changed := m.stateChanged
+		m.mu.RUnlock()
+
+		switch {
+		case connected && isUsable(state):
+			return nil
+		case connectErr != nil:
+			return connectErr
+		case !connected:
+			return fmt.Errorf("no active connection for profile %s", profileID)
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return fmt.Errorf("connection to %s is %v: %w", profile.DialTarget(), state, ctx.Err())
+		}
+	}
+}
 
-	target := fmt.Sprintf("%s:%d", profile.Host, profile.Port)
-	if err := m.grpcClient.Disconnect(target); err != nil {
+// ConnectionState returns the connectivity state of a profile's connection,
+// or SHUTDOWN if it has none
+func (m *ServerProfileManager) ConnectionState(profileID string) connectivity.State {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if state, ok := m.states[profileID]; ok {
+		return state
+	}
+	return connectivity.Shutdown
+}
+
+// checkProfileSecrets fails with secrets.ErrLocked if a header, auth
+// credential or proxy password of the profile could not be decrypted
+func checkProfileSecrets(profile *models.ServerProfile) error {
+	for _, h := range profile.Headers {
+		if secrets.IsEncrypted(h.Value) {
+			return fmt.Errorf("header %s: %w", h.Key, secrets.ErrLocked)
+		}
+	}
+	if profile.Auth != nil && (secrets.IsEncrypted(profile.Auth.ClientSecret) || secrets.IsEncrypted(profile.Auth.RefreshToken)) {
+		return fmt.Errorf("auth credentials: %w", secrets.ErrLocked)
+	}
+	if profile.Proxy != nil && secrets.IsEncrypted(profile.Proxy.Password) {
+		return fmt.Errorf("proxy password: %w", secrets.ErrLocked)
+	}
+	return nil
+}
+
+// Disconnect closes the gRPC connection for the specified profile
+func (m *ServerProfileManager) Disconnect(ctx context.Context, profileID string) error {
+	lock := m.profileLock(profileID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if _, err := m.store.Get(ctx, profileID); err != nil {
+		return fmt.Errorf("failed to get profile: %w", err)
+	}
+
+	if err := m.GetGRPCClient().Disconnect(profileID); err != nil {
 		return fmt.Errorf("failed to disconnect: %w", err)
 	}
 
+	m.mu.Lock()
+	conn := m.activeClients[profileID]
 	delete(m.activeClients, profileID)
+	m.mu.Unlock()
+	if web, ok := conn.(*WebConn); ok {
+		web.Close()
+	}
+	m.setState(profileID, connectivity.Shutdown, nil)
 	return nil
 }
 
-// GetConnection returns an active gRPC connection for the specified profile
-func (m *ServerProfileManager) GetConnection(profileID string) (*grpc.ClientConn, error) {
+// GetConnection returns the active connection of the specified profile
+func (m *ServerProfileManager) GetConnection(profileID string) (grpc.ClientConnInterface, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
@@ -244,27 +586,52 @@ func (m *ServerProfileManager) GetConnection(profileID string) (*grpc.ClientConn
 	return conn, nil
 }
 
-// IsConnected checks if a profile has an active connection
-func (m *ServerProfileManager) IsConnected(profileID string) bool {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+// Invoke calls a gRPC method on the active connection of a profile. A
+// request without JSON options uses those of the profile, and Any payloads
+// are also resolved from the profile's proto definitions.
+func (m *ServerProfileManager) Invoke(ctx context.Context, profileID string, req InvocationRequest) (*InvocationResult, error) {
+	conn, err := m.GetConnection(profileID)
+	if err != nil {
+		return nil, err
+	}
+	if req.JSONOptions == nil {
+		profile, err := m.store.Get(ctx, profileID)
+		if err != nil {
+			return nil, err
+		}
+		req.JSONOptions = profile.JSONOptions
+	}
+	if req.Types == nil {
+		req.Types = m.definedMessages(ctx, profileID)
+	}
+	return InvokeMethod(ctx, conn, req)
+}
 
-	_, exists := m.activeClients[profileID]
-	return exists
+// IsConnected checks if a profile has a connection that currently serves
+// calls. A connection that dropped and is reconnecting is not connected.
+func (m *ServerProfileManager) IsConnected(profileID string) bool {
+	return isUsable(m.ConnectionState(profileID))
 }
 
-// DisconnectAll closes all active connections
+// DisconnectAll closes all active connections and stops health watches
+// and polling
 func (m *ServerProfileManager) DisconnectAll() {
+	m.stopHealthMonitoring()
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	for id := range m.activeClients {
-		if profile, err := m.store.Get(context.Background(), id); err == nil {
-			target := fmt.Sprintf("%s:%d", profile.Host, profile.Port)
-			_ = m.grpcClient.Disconnect(target)
+	for id, conn := range m.activeClients {
+		if web, ok := conn.(*WebConn); ok {
+			web.Close()
+			continue
 		}
+		_ = m.grpcClient.Disconnect(id)
 	}
-	m.activeClients = make(map[string]*grpc.ClientConn)
+	m.activeClients = make(map[string]grpc.ClientConnInterface)
+	m.states = make(map[string]connectivity.State)
+	m.connectErrors = make(map[string]error)
+	m.notifyStateChange()
 }
 
 // SetGRPCClient sets the gRPC client manager (useful for testing)
@@ -276,55 +643,51 @@ func (m *ServerProfileManager) SetGRPCClient(client GRPCClientManager) {
 
 // GetGRPCClient returns the GRPCClientManager
 func (m *ServerProfileManager) GetGRPCClient() GRPCClientManager {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	return m.grpcClient
 }
 
 // ListProtoDefinitionsByProfile lists all proto definitions for a given profile
 func (m *ServerProfileManager) ListProtoDefinitionsByProfile(ctx context.Context, profileID string) ([]*proto.ProtoDefinition, error) {
-	fmt.Printf("[DEBUG] Method: ListProtoDefinitionsByProfile - Starting for profile: %s\n", profileID)
-
 	// First check if we have any definitions
 	defs, err := m.store.ListProtoDefinitionsByProfile(ctx, profileID)
 	if err != nil {
-		fmt.Printf("[DEBUG] Method: ListProtoDefinitionsByProfile - Failed to list proto definitions: %v\n", err)
 		return nil, fmt.Errorf("failed to list proto definitions: %w", err)
 	}
 
 	// If we have definitions, return them
 	if len(defs) > 0 {
-		fmt.Printf("[DEBUG] Method: ListProtoDefinitionsByProfile - Found %d existing definitions\n", len(defs))
 		return defs, nil
 	}
 
-	fmt.Printf("[DEBUG] Method: ListProtoDefinitionsByProfile - No definitions found, forcing parse of all proto paths\n")
+	m.logger.Debug("No proto definitions found, parsing all proto paths", "profile", profileID)
 
 	// Get all proto paths for this profile
 	protoPaths, err := m.store.ListProtoPathsByServer(ctx, profileID)
 	if err != nil {
-		fmt.Printf("[DEBUG] Method: ListProtoDefinitionsByProfile - Failed to list proto paths: %v\n", err)
 		return nil, fmt.Errorf("failed to list proto paths: %w", err)
 	}
 
 	// Force parse each proto path without hash check
 	for _, protoPath := range protoPaths {
-		fmt.Printf("[DEBUG] Forcing parse of proto path: %s\n", protoPath.Path)
 		err := m.protoParser.ScanAndParseProtoPath(ctx, profileID, protoPath.ID, protoPath.Path)
 		if err != nil {
-			fmt.Printf("[ERROR] Failed to parse proto path %s: %v\n", protoPath.Path, err)
+			m.logger.Error("Failed to parse proto path", "path", protoPath.Path, "error", err)
 			continue // Continue with other paths even if one fails
 		}
 
 		// Update the hash after successful parse
 		hash, err := calculateProtoPathHash(protoPath.Path)
 		if err != nil {
-			fmt.Printf("[ERROR] Failed to calculate hash for %s: %v\n", protoPath.Path, err)
+			m.logger.Error("Failed to hash proto path", "path", protoPath.Path, "error", err)
 			continue
 		}
 
 		protoPath.Hash = hash
 		protoPath.LastScanned = time.Now()
 		if err := m.store.UpdateProtoPath(ctx, protoPath); err != nil {
-			fmt.Printf("[ERROR] Failed to update proto path hash: %v\n", err)
+			m.logger.Error("Failed to update proto path hash", "path", protoPath.Path, "error", err)
 		}
 	}
 
@@ -333,8 +696,6 @@ func (m *ServerProfileManager) ListProtoDefinitionsByProfile(ctx context.Context
 }
 
 func (m *ServerProfileManager) scanAndParseProtoPath(ctx context.Context, serverProfileId string, protoPathId string, path string) error {
-	fmt.Printf("[DEBUG] Scanning proto path: %s\n", path)
-
 	// Calculate hash of all proto files in the directory
 	hash, err := calculateProtoPathHash(path)
 	if err != nil {
@@ -349,7 +710,7 @@ func (m *ServerProfileManager) scanAndParseProtoPath(ctx context.Context, server
 
 	// If hash matches and last scan was recent (within 5 minutes), skip parsing
 	if protoPath != nil && protoPath.Hash == hash && time.Since(protoPath.LastScanned) < 5*time.Minute {
-		fmt.Printf("[DEBUG] Proto path %s is up to date (hash: %s), skipping parse\n", path, hash)
+		m.logger.Debug("Proto path is up to date, skipping parse", "path", path, "hash", hash)
 		return nil
 	}
 
@@ -419,7 +780,7 @@ func (m *ServerProfileManager) Get(ctx context.Context, id string) (*models.Serv
 	// Check if we have any proto definitions for this server
 	_, err = m.store.ListProtoDefinitionsByProfile(ctx, id)
 	if err != nil {
-		fmt.Printf("[DEBUG] Failed to list proto definitions: %v\n", err)
+		m.logger.Warn("Failed to list proto definitions", "profile", id, "error", err)
 	}
 
 	return profile, nil
@@ -434,19 +795,18 @@ func (m *ServerProfileManager) Update(ctx context.Context, profile *models.Serve
 	// Check if we have any proto definitions for this server
 	defs, err := m.store.ListProtoDefinitionsByProfile(ctx, profile.ID)
 	if err != nil {
-		fmt.Printf("[DEBUG] Failed to list proto definitions: %v\n", err)
+		m.logger.Warn("Failed to list proto definitions", "profile", profile.ID, "error", err)
 		// Continue with update even if we can't check definitions
 	} else if len(defs) == 0 {
-		fmt.Printf("[DEBUG] No proto definitions found for server %s, attempting to parse proto paths\n", profile.ID)
+		m.logger.Debug("No proto definitions found, parsing proto paths", "profile", profile.ID)
 		paths, err := m.store.ListProtoPathsByServer(ctx, profile.ID)
 		if err != nil {
-			fmt.Printf("[DEBUG] Failed to list proto paths: %v\n", err)
+			m.logger.Warn("Failed to list proto paths", "profile", profile.ID, "error", err)
 		} else {
 			for _, path := range paths {
-				fmt.Printf("[DEBUG] Parsing proto path: %s\n", path.Path)
 				err = m.protoParser.ScanAndParseProtoPath(ctx, profile.ID, path.ID, path.Path)
 				if err != nil {
-					fmt.Printf("[DEBUG] Failed to parse proto path %s: %v\n", path.Path, err)
+					m.logger.Warn("Failed to parse proto path", "path", path.Path, "error", err)
 					// Continue with other paths even if one fails
 					continue
 				}
@@ -471,16 +831,15 @@ func (m *ServerProfileManager) Create(ctx context.Context, profile *models.Serve
 	// After creating the profile, check if it has any proto paths
 	paths, err := m.store.ListProtoPathsByServer(ctx, profile.ID)
 	if err != nil {
-		fmt.Printf("[DEBUG] Failed to list proto paths: %v\n", err)
+		m.logger.Warn("Failed to list proto paths", "profile", profile.ID, "error", err)
 		return nil // Return success even if we can't parse paths
 	}
 
 	// Parse all proto paths for the new profile
 	for _, path := range paths {
-		fmt.Printf("[DEBUG] Parsing proto path: %s\n", path.Path)
 		err = m.protoParser.ScanAndParseProtoPath(ctx, profile.ID, path.ID, path.Path)
 		if err != nil {
-			fmt.Printf("[DEBUG] Failed to parse proto path %s: %v\n", path.Path, err)
+			m.logger.Warn("Failed to parse proto path", "path", path.Path, "error", err)
 			// Continue with other paths even if one fails
 			continue
 		}
@@ -3,6 +3,7 @@ package services
 import (
 	"context"
 	"fmt"
+	"sync"
 	"testing"
 
 	"protodesk/pkg/models"
@@ -10,11 +11,14 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
 )
 
 // mockGRPCClientManager is a mock implementation of GRPCClientManager
 type mockGRPCClientManager struct {
+	mu          sync.Mutex
 	connections map[string]*grpc.ClientConn
+	options     map[string]ConnectOptions
 	connectErr  error
 }
 
@@ -33,24 +37,37 @@ func (m *mockGRPCClient) CallMethod(ctx context.Context, method string, request
 func newMockGRPCClientManager() GRPCClientManager {
 	return &mockGRPCClientManager{
 		connections: make(map[string]*grpc.ClientConn),
+		options:     make(map[string]ConnectOptions),
 	}
 }
 
-func (m *mockGRPCClientManager) Connect(ctx context.Context, target string, useTLS bool, certPath string) error {
+func (m *mockGRPCClientManager) Connect(ctx context.Context, id string, target string, opts ConnectOptions) error {
 	if m.connectErr != nil {
 		return m.connectErr
 	}
-	m.connections[target] = &grpc.ClientConn{}
+	conn := &grpc.ClientConn{}
+	m.mu.Lock()
+	m.connections[id] = conn
+	m.options[id] = opts
+	m.mu.Unlock()
+	// Mock connections are ready straight away
+	if opts.OnStateChange != nil {
+		opts.OnStateChange(conn, connectivity.Ready)
+	}
 	return nil
 }
 
-func (m *mockGRPCClientManager) Disconnect(target string) error {
-	delete(m.connections, target)
+func (m *mockGRPCClientManager) Disconnect(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.connections, id)
 	return nil
 }
 
-func (m *mockGRPCClientManager) GetConnection(target string) (*grpc.ClientConn, error) {
-	if conn, ok := m.connections[target]; ok {
+func (m *mockGRPCClientManager) GetConnection(id string) (*grpc.ClientConn, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if conn, ok := m.connections[id]; ok {
 		return conn, nil
 	}
 	return nil, fmt.Errorf("connection not found")
@@ -60,17 +77,17 @@ func (m *mockGRPCClientManager) GetClient(serverProfileID string) (GRPCClient, e
 	return &mockGRPCClient{}, nil
 }
 
-func (m *mockGRPCClientManager) GetMethodInputDescriptor(conn *grpc.ClientConn, serviceName, methodName string) ([]FieldDescriptor, error) {
+func (m *mockGRPCClientManager) GetMethodInputDescriptor(conn grpc.ClientConnInterface, serviceName, methodName string) ([]FieldDescriptor, error) {
 	return nil, nil
 }
 
-func (m *mockGRPCClientManager) ListServicesAndMethods(conn *grpc.ClientConn) (map[string][]string, error) {
+func (m *mockGRPCClientManager) ListServicesAndMethods(conn grpc.ClientConnInterface) (map[string][]string, error) {
 	return nil, nil
 }
 
 func setupTestManager(t *testing.T) (*ServerProfileManager, *SQLiteStore, func()) {
 	store, cleanup := setupTestStore(t)
-	manager := NewServerProfileManager(store)
+	manager := NewServerProfileManager(store, nil)
 	manager.grpcClient = newMockGRPCClientManager()
 	return manager, store, cleanup
 }
@@ -79,7 +96,7 @@ func TestNewServerProfileManager(t *testing.T) {
 	store, cleanup := setupTestStore(t)
 	defer cleanup()
 
-	manager := NewServerProfileManager(store)
+	manager := NewServerProfileManager(store, nil)
 	assert.NotNil(t, manager)
 	assert.Equal(t, store, manager.GetStore())
 	assert.NotNil(t, manager.grpcClient)
@@ -179,3 +196,28 @@ func TestServerProfileManager_ConnectionErrors(t *testing.T) {
 	assert.Error(t, err)
 	assert.False(t, manager.IsConnected(profile.ID))
 }
+
+func TestServerProfileManager_ProfilesSharingATarget(t *testing.T) {
+	manager, store, cleanup := setupTestManager(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	plain := models.NewServerProfile("plain", "localhost", 50051)
+	secure := models.NewServerProfile("secure", "localhost", 50051)
+	secure.TLSEnabled = true
+	require.NoError(t, store.Create(ctx, plain))
+	require.NoError(t, store.Create(ctx, secure))
+
+	require.NoError(t, manager.Connect(ctx, plain.ID))
+	require.NoError(t, manager.Connect(ctx, secure.ID))
+
+	// Each profile is dialled with its own settings
+	mock := manager.grpcClient.(*mockGRPCClientManager)
+	assert.False(t, mock.options[plain.ID].UseTLS)
+	assert.True(t, mock.options[secure.ID].UseTLS)
+
+	// Disconnecting one profile leaves the other connected
+	require.NoError(t, manager.Disconnect(ctx, plain.ID))
+	assert.False(t, manager.IsConnected(plain.ID))
+	assert.True(t, manager.IsConnected(secure.ID))
+}
@@ -6,10 +6,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"path/filepath"
+	"sync"
 	"time"
 
 	"protodesk/pkg/models"
 	"protodesk/pkg/models/proto"
+	"protodesk/pkg/secrets"
 
 	"github.com/jmoiron/sqlx"
 	_ "github.com/mattn/go-sqlite3"
@@ -42,7 +44,38 @@ type ServerProfileStore interface {
 	// Add per-request headers CRUD methods
 	UpsertPerRequestHeaders(ctx context.Context, h *models.PerRequestHeaders) error
 	GetPerRequestHeaders(ctx context.Context, serverProfileID, serviceName, methodName string) (*models.PerRequestHeaders, error)
+	ListPerRequestHeaders(ctx context.Context, serverProfileID string) ([]*models.PerRequestHeaders, error)
 	DeletePerRequestHeaders(ctx context.Context, serverProfileID, serviceName, methodName string) error
+
+	// Collection and saved request CRUD methods
+	CreateCollection(ctx context.Context, c *models.Collection) error
+	GetCollection(ctx context.Context, id string) (*models.Collection, error)
+	ListCollections(ctx context.Context) ([]*models.Collection, error)
+	UpdateCollection(ctx context.Context, c *models.Collection) error
+	DeleteCollection(ctx context.Context, id string) error
+	CreateSavedRequest(ctx context.Context, r *models.SavedRequest) error
+	GetSavedRequest(ctx context.Context, id string) (*models.SavedRequest, error)
+	ListSavedRequests(ctx context.Context, collectionID string) ([]*models.SavedRequest, error)
+	UpdateSavedRequest(ctx context.Context, r *models.SavedRequest) error
+	DeleteSavedRequest(ctx context.Context, id string) error
+
+	// Environment CRUD methods
+	CreateEnvironment(ctx context.Context, env *models.Environment) error
+	GetEnvironment(ctx context.Context, id string) (*models.Environment, error)
+	ListEnvironments(ctx context.Context) ([]*models.Environment, error)
+	UpdateEnvironment(ctx context.Context, env *models.Environment) error
+	DeleteEnvironment(ctx context.Context, id string) error
+
+	// Application settings and secret storage methods
+	GetSetting(ctx context.Context, key string) (string, error)
+	SetSetting(ctx context.Context, key, value string) error
+	UnlockSecrets(ctx context.Context, passphrase string) error
+	UnlockSecretsWithKeyFile(ctx context.Context, path string) error
+	LockSecrets()
+	GetSecretsStatus(ctx context.Context) (SecretsStatus, error)
+
+	// Close releases the underlying database
+	Close() error
 }
 
 // ProtoPath represents a proto folder path linked to a server
@@ -57,11 +90,38 @@ type ProtoPath struct {
 // SQLiteStore implements ServerProfileStore using SQLite
 type SQLiteStore struct {
 	db *sqlx.DB
+	// q runs queries, on db or on the transaction the store is bound to
+	q  queryer
+	tx *sqlx.Tx
+
+	// key encrypts secret values; shared with stores bound to a transaction
+	key *secretKey
+}
+
+// queryer is implemented by both *sqlx.DB and *sqlx.Tx
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	GetContext(ctx context.Context, dest any, query string, args ...any) error
+	SelectContext(ctx context.Context, dest any, query string, args ...any) error
+}
+
+// secretKey holds the box that encrypts secret values
+type secretKey struct {
+	mu sync.RWMutex
+	// box is nil while secret storage is locked
+	box *secrets.Box
 }
 
-// NewSQLiteStore creates a new SQLite-based store
+// DatabaseFileName is the name of the database file inside a data directory
+const DatabaseFileName = "protodesk.db"
+
+// NewSQLiteStore creates a new SQLite-based store in dataDir
 func NewSQLiteStore(dataDir string) (*SQLiteStore, error) {
-	dbPath := filepath.Join(dataDir, "protodesk.db")
+	return OpenSQLiteStore(filepath.Join(dataDir, DatabaseFileName))
+}
+
+// OpenSQLiteStore opens, creating if needed, the SQLite database at dbPath
+func OpenSQLiteStore(dbPath string) (*SQLiteStore, error) {
 	db, err := sqlx.Connect("sqlite3", dbPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
@@ -69,155 +129,45 @@ func NewSQLiteStore(dataDir string) (*SQLiteStore, error) {
 	// Enable foreign key enforcement
 	_, _ = db.Exec("PRAGMA foreign_keys = ON;")
 
-	if err := initializeSchema(db); err != nil {
-		return nil, fmt.Errorf("failed to initialize schema: %w", err)
-	}
-
-	// Run migrations
-	if err := migrateLastScannedColumn(db); err != nil {
-		return nil, fmt.Errorf("failed to run migrations: %w", err)
-	}
-
-	return &SQLiteStore{db: db}, nil
-}
-
-func initializeSchema(db *sqlx.DB) error {
-	schema := `
-	CREATE TABLE IF NOT EXISTS server_profiles (
-		id TEXT PRIMARY KEY,
-		name TEXT NOT NULL,
-		host TEXT NOT NULL,
-		port INTEGER NOT NULL,
-		tls_enabled BOOLEAN DEFAULT FALSE,
-		certificate_path TEXT,
-		use_reflection BOOLEAN DEFAULT FALSE,
-		created_at DATETIME NOT NULL,
-		updated_at DATETIME NOT NULL,
-		headers_json TEXT DEFAULT '[]'
-	);
-	CREATE INDEX IF NOT EXISTS idx_server_profiles_name ON server_profiles(name);
-
-	CREATE TABLE IF NOT EXISTS proto_paths (
-		id TEXT PRIMARY KEY,
-		server_profile_id TEXT NOT NULL,
-		path TEXT NOT NULL,
-		hash TEXT,
-		last_scanned DATETIME,
-		UNIQUE(server_profile_id, path),
-		FOREIGN KEY(server_profile_id) REFERENCES server_profiles(id) ON DELETE CASCADE
-	);
-	CREATE INDEX IF NOT EXISTS idx_proto_paths_profile ON proto_paths(server_profile_id);
-
-	CREATE TABLE IF NOT EXISTS proto_definitions (
-		id TEXT PRIMARY KEY,
-		file_path TEXT NOT NULL,
-		content TEXT NOT NULL,
-		imports TEXT,
-		services TEXT,
-		messages TEXT,
-		created_at DATETIME NOT NULL,
-		updated_at DATETIME NOT NULL,
-		description TEXT,
-		version TEXT,
-		server_profile_id TEXT,
-		proto_path_id TEXT,
-		last_parsed DATETIME,
-		error TEXT,
-		enums TEXT,
-		file_options TEXT,
-		FOREIGN KEY(server_profile_id) REFERENCES server_profiles(id) ON DELETE CASCADE,
-		FOREIGN KEY(proto_path_id) REFERENCES proto_paths(id) ON DELETE CASCADE
-	);
-	CREATE INDEX IF NOT EXISTS idx_proto_definitions_profile ON proto_definitions(server_profile_id);
-	CREATE INDEX IF NOT EXISTS idx_proto_definitions_path ON proto_definitions(proto_path_id);
-
-	CREATE TABLE IF NOT EXISTS per_request_headers (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		server_profile_id TEXT NOT NULL,
-		service_name TEXT NOT NULL,
-		method_name TEXT NOT NULL,
-		headers_json TEXT NOT NULL DEFAULT '[]',
-		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		UNIQUE(server_profile_id, service_name, method_name),
-		FOREIGN KEY(server_profile_id) REFERENCES server_profiles(id) ON DELETE CASCADE
-	);
-	CREATE INDEX IF NOT EXISTS idx_per_request_headers_profile ON per_request_headers(server_profile_id);
-	`
-	_, err := db.Exec(schema)
-	return err
-}
-
-// migrateLastScannedColumn updates the last_scanned column type from TEXT to DATETIME
-func migrateLastScannedColumn(db *sqlx.DB) error {
-	// Check if the column exists and is TEXT type
-	var columnType string
-	err := db.Get(&columnType, `
-		SELECT type FROM pragma_table_info('proto_paths') 
-		WHERE name = 'last_scanned'
-	`)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			// Column doesn't exist, no need to migrate
-			return nil
-		}
-		return fmt.Errorf("failed to check column type: %w", err)
-	}
-
-	if columnType == "TEXT" {
-		// Create a temporary table with the correct schema
-		_, err = db.Exec(`
-			CREATE TABLE proto_paths_new (
-				id TEXT PRIMARY KEY,
-				server_profile_id TEXT NOT NULL,
-				path TEXT NOT NULL,
-				hash TEXT,
-				last_scanned DATETIME,
-				UNIQUE(server_profile_id, path),
-				FOREIGN KEY(server_profile_id) REFERENCES server_profiles(id) ON DELETE CASCADE
-			)
-		`)
-		if err != nil {
-			return fmt.Errorf("failed to create temporary table: %w", err)
-		}
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate schema: %w", err)
+	}
 
-		// Copy data, converting TEXT timestamps to DATETIME
-		_, err = db.Exec(`
-			INSERT INTO proto_paths_new (id, server_profile_id, path, hash, last_scanned)
-			SELECT 
-				id, 
-				server_profile_id, 
-				path, 
-				hash,
-				CASE 
-					WHEN last_scanned IS NULL THEN NULL
-					WHEN last_scanned = '' THEN NULL
-					ELSE datetime(last_scanned)
-				END as last_scanned
-			FROM proto_paths
-		`)
-		if err != nil {
-			return fmt.Errorf("failed to copy data: %w", err)
-		}
+	return &SQLiteStore{db: db, q: db, key: &secretKey{}}, nil
+}
 
-		// Drop the old table
-		_, err = db.Exec(`DROP TABLE proto_paths`)
-		if err != nil {
-			return fmt.Errorf("failed to drop old table: %w", err)
-		}
+// Close closes the database
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
 
-		// Rename the new table
-		_, err = db.Exec(`ALTER TABLE proto_paths_new RENAME TO proto_paths`)
-		if err != nil {
-			return fmt.Errorf("failed to rename new table: %w", err)
-		}
+// InTransaction runs fn with a store whose reads and writes all happen in one
+// transaction. The transaction is committed if fn returns nil and rolled back
+// otherwise. The store passed to fn must not be used after fn returns.
+func (s *SQLiteStore) InTransaction(ctx context.Context, fn func(store ServerProfileStore) error) error {
+	return s.withTx(ctx, func(tx *sqlx.Tx) error {
+		return fn(&SQLiteStore{db: s.db, q: tx, tx: tx, key: s.key})
+	})
+}
 
-		// Recreate the index
-		_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_proto_paths_profile ON proto_paths(server_profile_id)`)
-		if err != nil {
-			return fmt.Errorf("failed to recreate index: %w", err)
-		}
+// withTx runs fn in a new transaction, or in the one the store is bound to
+func (s *SQLiteStore) withTx(ctx context.Context, fn func(tx *sqlx.Tx) error) error {
+	if s.tx != nil {
+		return fn(s.tx)
+	}
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
 
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
 	return nil
 }
 
@@ -226,19 +176,36 @@ func (s *SQLiteStore) Create(ctx context.Context, profile *models.ServerProfile)
 		return err
 	}
 
-	// Marshal headers to JSON
-	data, err := json.Marshal(profile.Headers)
+	// Marshal headers to JSON, encrypting secret values
+	headers, err := s.sealHeaders(profile.Headers)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(headers)
 	if err != nil {
 		return err
 	}
 	profile.HeadersJSON = string(data)
+	if err := s.marshalProfileAuth(profile); err != nil {
+		return err
+	}
+	if err := marshalProfileTransport(profile); err != nil {
+		return err
+	}
+	if err := marshalProfileJSONOptions(profile); err != nil {
+		return err
+	}
+	if err := s.marshalProfileProxy(profile); err != nil {
+		return err
+	}
 
 	query := `
 		INSERT INTO server_profiles (
-			id, name, host, port, tls_enabled, certificate_path, use_reflection, created_at, updated_at, headers_json
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			id, name, host, port, tls_enabled, certificate_path, use_reflection, created_at, updated_at, headers_json, auth_json, connect_timeout_ms, transport_json,
+			target_kind, target, authority, proxy_json, protocol, json_options_json
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
-	_, err = s.db.ExecContext(ctx, query,
+	_, err = s.q.ExecContext(ctx, query,
 		profile.ID,
 		profile.Name,
 		profile.Host,
@@ -249,6 +216,15 @@ func (s *SQLiteStore) Create(ctx context.Context, profile *models.ServerProfile)
 		profile.CreatedAt,
 		profile.UpdatedAt,
 		profile.HeadersJSON,
+		profile.AuthJSON,
+		profile.ConnectTimeoutMs,
+		profile.TransportJSON,
+		profile.TargetKind,
+		profile.Target,
+		profile.Authority,
+		profile.ProxyJSON,
+		profile.Protocol,
+		profile.JSONOptionsJSON,
 	)
 	return err
 }
@@ -256,21 +232,26 @@ func (s *SQLiteStore) Create(ctx context.Context, profile *models.ServerProfile)
 func (s *SQLiteStore) Get(ctx context.Context, id string) (*models.ServerProfile, error) {
 	var profile models.ServerProfile
 	query := `SELECT * FROM server_profiles WHERE id = ?`
-	err := s.db.GetContext(ctx, &profile, query, id)
+	err := s.q.GetContext(ctx, &profile, query, id)
 	if err != nil {
 		return nil, models.ErrProfileNotFound
 	}
 	// Unmarshal headers_json into Headers
 	if profile.HeadersJSON != "" {
 		_ = json.Unmarshal([]byte(profile.HeadersJSON), &profile.Headers)
+		s.openHeaders(profile.Headers)
 	}
+	s.unmarshalProfileAuth(&profile)
+	unmarshalProfileTransport(&profile)
+	unmarshalProfileJSONOptions(&profile)
+	s.unmarshalProfileProxy(&profile)
 	return &profile, nil
 }
 
 func (s *SQLiteStore) List(ctx context.Context) ([]*models.ServerProfile, error) {
 	var profiles []*models.ServerProfile
 	query := `SELECT * FROM server_profiles ORDER BY name`
-	err := s.db.SelectContext(ctx, &profiles, query)
+	err := s.q.SelectContext(ctx, &profiles, query)
 	if err != nil {
 		return nil, err
 	}
@@ -278,7 +259,12 @@ func (s *SQLiteStore) List(ctx context.Context) ([]*models.ServerProfile, error)
 	for _, profile := range profiles {
 		if profile.HeadersJSON != "" {
 			_ = json.Unmarshal([]byte(profile.HeadersJSON), &profile.Headers)
+			s.openHeaders(profile.Headers)
 		}
+		s.unmarshalProfileAuth(profile)
+		unmarshalProfileTransport(profile)
+		unmarshalProfileJSONOptions(profile)
+		s.unmarshalProfileProxy(profile)
 	}
 	return profiles, nil
 }
@@ -288,12 +274,28 @@ func (s *SQLiteStore) Update(ctx context.Context, profile *models.ServerProfile)
 		return err
 	}
 
-	// Marshal headers to JSON
-	data, err := json.Marshal(profile.Headers)
+	// Marshal headers to JSON, encrypting secret values
+	headers, err := s.sealHeaders(profile.Headers)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(headers)
 	if err != nil {
 		return err
 	}
 	profile.HeadersJSON = string(data)
+	if err := s.marshalProfileAuth(profile); err != nil {
+		return err
+	}
+	if err := marshalProfileTransport(profile); err != nil {
+		return err
+	}
+	if err := marshalProfileJSONOptions(profile); err != nil {
+		return err
+	}
+	if err := s.marshalProfileProxy(profile); err != nil {
+		return err
+	}
 
 	query := `
 		UPDATE server_profiles SET
@@ -304,10 +306,19 @@ func (s *SQLiteStore) Update(ctx context.Context, profile *models.ServerProfile)
 			certificate_path = ?,
 			use_reflection = ?,
 			updated_at = ?,
-			headers_json = ?
+			headers_json = ?,
+			auth_json = ?,
+			connect_timeout_ms = ?,
+			transport_json = ?,
+			target_kind = ?,
+			target = ?,
+			authority = ?,
+			proxy_json = ?,
+			protocol = ?,
+			json_options_json = ?
 		WHERE id = ?
 	`
-	result, err := s.db.ExecContext(ctx, query,
+	result, err := s.q.ExecContext(ctx, query,
 		profile.Name,
 		profile.Host,
 		profile.Port,
@@ -316,6 +327,15 @@ func (s *SQLiteStore) Update(ctx context.Context, profile *models.ServerProfile)
 		profile.UseReflection,
 		profile.UpdatedAt,
 		profile.HeadersJSON,
+		profile.AuthJSON,
+		profile.ConnectTimeoutMs,
+		profile.TransportJSON,
+		profile.TargetKind,
+		profile.Target,
+		profile.Authority,
+		profile.ProxyJSON,
+		profile.Protocol,
+		profile.JSONOptionsJSON,
 		profile.ID,
 	)
 	if err != nil {
@@ -334,7 +354,7 @@ func (s *SQLiteStore) Update(ctx context.Context, profile *models.ServerProfile)
 
 func (s *SQLiteStore) Delete(ctx context.Context, id string) error {
 	query := `DELETE FROM server_profiles WHERE id = ?`
-	result, err := s.db.ExecContext(ctx, query, id)
+	result, err := s.q.ExecContext(ctx, query, id)
 	if err != nil {
 		return err
 	}
@@ -349,17 +369,123 @@ func (s *SQLiteStore) Delete(ctx context.Context, id string) error {
 	return nil
 }
 
-// ProtoDefinition CRUD methods
-func (s *SQLiteStore) CreateProtoDefinition(ctx context.Context, def *proto.ProtoDefinition) error {
-	fmt.Printf("[DEBUG] Method: CreateProtoDefinition - Starting for file: %s\n", def.FilePath)
+// marshalProfileAuth serializes the auth provider of a profile with its
+// credentials encrypted; profiles without one store an empty string
+func (s *SQLiteStore) marshalProfileAuth(profile *models.ServerProfile) error {
+	if profile.Auth == nil || profile.Auth.Type == models.AuthNone {
+		profile.AuthJSON = ""
+		return nil
+	}
+	auth, err := s.sealAuth(profile.Auth)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(auth)
+	if err != nil {
+		return fmt.Errorf("failed to marshal auth config: %w", err)
+	}
+	profile.AuthJSON = string(data)
+	return nil
+}
 
-	// Start a transaction
-	tx, err := s.db.BeginTx(ctx, nil)
+// unmarshalProfileAuth restores the auth provider of a profile
+func (s *SQLiteStore) unmarshalProfileAuth(profile *models.ServerProfile) {
+	if profile.AuthJSON == "" {
+		return
+	}
+	var auth models.AuthConfig
+	if err := json.Unmarshal([]byte(profile.AuthJSON), &auth); err == nil {
+		s.openAuth(&auth)
+		profile.Auth = &auth
+	}
+}
+
+// marshalProfileProxy serializes the proxy of a profile with its password
+// encrypted; profiles connecting directly store an empty string
+func (s *SQLiteStore) marshalProfileProxy(profile *models.ServerProfile) error {
+	if profile.Proxy.IsDirect() {
+		profile.ProxyJSON = ""
+		return nil
+	}
+	proxy := *profile.Proxy
+	var err error
+	if proxy.Password, err = s.sealSecret(proxy.Password); err != nil {
+		return fmt.Errorf("proxy password: %w", err)
+	}
+	data, err := json.Marshal(proxy)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return fmt.Errorf("failed to marshal proxy config: %w", err)
 	}
-	defer tx.Rollback()
+	profile.ProxyJSON = string(data)
+	return nil
+}
+
+// unmarshalProfileProxy restores the proxy of a profile
+func (s *SQLiteStore) unmarshalProfileProxy(profile *models.ServerProfile) {
+	if profile.ProxyJSON == "" {
+		return
+	}
+	var proxy models.ProxyConfig
+	if err := json.Unmarshal([]byte(profile.ProxyJSON), &proxy); err == nil {
+		proxy.Password = s.openSecret(proxy.Password)
+		profile.Proxy = &proxy
+	}
+}
+
+// marshalProfileTransport serializes the transport settings of a profile;
+// profiles keeping the gRPC defaults store an empty string
+func marshalProfileTransport(profile *models.ServerProfile) error {
+	if profile.Transport.IsZero() {
+		profile.TransportJSON = ""
+		return nil
+	}
+	data, err := json.Marshal(profile.Transport)
+	if err != nil {
+		return fmt.Errorf("failed to marshal transport settings: %w", err)
+	}
+	profile.TransportJSON = string(data)
+	return nil
+}
+
+// unmarshalProfileTransport restores the transport settings of a profile
+func unmarshalProfileTransport(profile *models.ServerProfile) {
+	if profile.TransportJSON == "" {
+		return
+	}
+	var transport models.TransportSettings
+	if err := json.Unmarshal([]byte(profile.TransportJSON), &transport); err == nil {
+		profile.Transport = &transport
+	}
+}
+
+// marshalProfileJSONOptions serializes the JSON options of a profile;
+// profiles keeping the defaults store an empty string
+func marshalProfileJSONOptions(profile *models.ServerProfile) error {
+	if profile.JSONOptions.IsZero() {
+		profile.JSONOptionsJSON = ""
+		return nil
+	}
+	data, err := json.Marshal(profile.JSONOptions)
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON options: %w", err)
+	}
+	profile.JSONOptionsJSON = string(data)
+	return nil
+}
 
+// unmarshalProfileJSONOptions restores the JSON options of a profile
+func unmarshalProfileJSONOptions(profile *models.ServerProfile) {
+	if profile.JSONOptionsJSON == "" {
+		return
+	}
+	var opts models.JSONOptions
+	if err := json.Unmarshal([]byte(profile.JSONOptionsJSON), &opts); err == nil {
+		profile.JSONOptions = &opts
+	}
+}
+
+// ProtoDefinition CRUD methods
+func (s *SQLiteStore) CreateProtoDefinition(ctx context.Context, def *proto.ProtoDefinition) error {
 	// Marshal imports, services, messages, and enums to JSON
 	importsJSON, err := json.Marshal(def.Imports)
 	if err != nil {
@@ -381,12 +507,17 @@ func (s *SQLiteStore) CreateProtoDefinition(ctx context.Context, def *proto.Prot
 		return fmt.Errorf("failed to marshal enums: %w", err)
 	}
 
+	fileOptionsJSON, err := marshalFileOptions(def.FileOptions)
+	if err != nil {
+		return err
+	}
+
 	// Insert the proto definition
-	_, err = tx.ExecContext(ctx, `
+	_, err = s.q.ExecContext(ctx, `
 		INSERT INTO proto_definitions (
 			id, file_path, content, imports, services, messages, enums,
-			created_at, updated_at, description, server_profile_id, proto_path_id
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			created_at, updated_at, description, server_profile_id, proto_path_id, file_options
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`,
 		def.ID,
 		def.FilePath,
@@ -400,17 +531,12 @@ func (s *SQLiteStore) CreateProtoDefinition(ctx context.Context, def *proto.Prot
 		sql.NullString{String: def.Description, Valid: def.Description != ""},
 		def.ServerProfileID,
 		def.ProtoPathID,
+		fileOptionsJSON,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to insert proto definition: %w", err)
 	}
 
-	// Commit the transaction
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
-	}
-
-	fmt.Printf("[DEBUG] Method: CreateProtoDefinition - Successfully created definition for %s\n", def.FilePath)
 	return nil
 }
 
@@ -434,7 +560,7 @@ func (s *SQLiteStore) GetProtoDefinition(ctx context.Context, id string) (*proto
 		FileOptions     sql.NullString `db:"file_options"`
 	}
 	query := `SELECT * FROM proto_definitions WHERE id = ?`
-	err := s.db.GetContext(ctx, &row, query, id)
+	err := s.q.GetContext(ctx, &row, query, id)
 	if err != nil {
 		return nil, err
 	}
@@ -464,9 +590,9 @@ func (s *SQLiteStore) GetProtoDefinition(ctx context.Context, id string) (*proto
 	if row.Error.Valid {
 		errorMsg = row.Error.String
 	}
-	fileOptions := ""
+	var fileOptions map[string]any
 	if row.FileOptions.Valid {
-		fileOptions = row.FileOptions.String
+		_ = json.Unmarshal([]byte(row.FileOptions.String), &fileOptions)
 	}
 	return &proto.ProtoDefinition{
 		ID:              row.ID,
@@ -508,7 +634,7 @@ func (s *SQLiteStore) ListProtoDefinitions(ctx context.Context) ([]*proto.ProtoD
 		FileOptions     sql.NullString `db:"file_options"`
 	}
 	query := `SELECT * FROM proto_definitions`
-	err := s.db.SelectContext(ctx, &rows, query)
+	err := s.q.SelectContext(ctx, &rows, query)
 	if err != nil {
 		return nil, err
 	}
@@ -540,9 +666,9 @@ func (s *SQLiteStore) ListProtoDefinitions(ctx context.Context) ([]*proto.ProtoD
 		if row.Error.Valid {
 			errorMsg = row.Error.String
 		}
-		fileOptions := ""
+		var fileOptions map[string]any
 		if row.FileOptions.Valid {
-			fileOptions = row.FileOptions.String
+			_ = json.Unmarshal([]byte(row.FileOptions.String), &fileOptions)
 		}
 		defs = append(defs, &proto.ProtoDefinition{
 			ID:              row.ID,
@@ -567,15 +693,6 @@ func (s *SQLiteStore) ListProtoDefinitions(ctx context.Context) ([]*proto.ProtoD
 }
 
 func (s *SQLiteStore) UpdateProtoDefinition(ctx context.Context, def *proto.ProtoDefinition) error {
-	fmt.Printf("[DEBUG] Method: UpdateProtoDefinition - Starting for file: %s\n", def.FilePath)
-
-	// Start a transaction
-	tx, err := s.db.BeginTx(ctx, nil)
-	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
-	}
-	defer tx.Rollback()
-
 	// Marshal imports, services, messages, and enums to JSON
 	importsJSON, err := json.Marshal(def.Imports)
 	if err != nil {
@@ -597,11 +714,16 @@ func (s *SQLiteStore) UpdateProtoDefinition(ctx context.Context, def *proto.Prot
 		return fmt.Errorf("failed to marshal enums: %w", err)
 	}
 
+	fileOptionsJSON, err := marshalFileOptions(def.FileOptions)
+	if err != nil {
+		return err
+	}
+
 	// Update the proto definition
-	_, err = tx.ExecContext(ctx, `
+	_, err = s.q.ExecContext(ctx, `
 		UPDATE proto_definitions
 		SET file_path = ?, content = ?, imports = ?, services = ?, messages = ?, enums = ?,
-			updated_at = ?, description = ?, server_profile_id = ?, proto_path_id = ?
+			updated_at = ?, description = ?, server_profile_id = ?, proto_path_id = ?, file_options = ?
 		WHERE id = ?
 	`,
 		def.FilePath,
@@ -614,24 +736,32 @@ func (s *SQLiteStore) UpdateProtoDefinition(ctx context.Context, def *proto.Prot
 		sql.NullString{String: def.Description, Valid: def.Description != ""},
 		def.ServerProfileID,
 		def.ProtoPathID,
+		fileOptionsJSON,
 		def.ID,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to update proto definition: %w", err)
 	}
 
-	// Commit the transaction
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
-	}
-
-	fmt.Printf("[DEBUG] Method: UpdateProtoDefinition - Successfully updated definition for %s\n", def.FilePath)
 	return nil
 }
 
+// marshalFileOptions serializes the options of a proto file; files without
+// options store NULL
+func marshalFileOptions(opts map[string]any) (sql.NullString, error) {
+	if len(opts) == 0 {
+		return sql.NullString{}, nil
+	}
+	data, err := json.Marshal(opts)
+	if err != nil {
+		return sql.NullString{}, fmt.Errorf("failed to marshal file options: %w", err)
+	}
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
+
 func (s *SQLiteStore) DeleteProtoDefinition(ctx context.Context, id string) error {
 	query := `DELETE FROM proto_definitions WHERE id = ?`
-	result, err := s.db.ExecContext(ctx, query, id)
+	result, err := s.q.ExecContext(ctx, query, id)
 	if err != nil {
 		return err
 	}
@@ -646,8 +776,6 @@ func (s *SQLiteStore) DeleteProtoDefinition(ctx context.Context, id string) erro
 }
 
 func (s *SQLiteStore) ListProtoDefinitionsByProfile(ctx context.Context, profileID string) ([]*proto.ProtoDefinition, error) {
-	fmt.Printf("[DEBUG] Method: ListProtoDefinitionsByProfile - Starting for profile: %s\n", profileID)
-
 	var rows []struct {
 		ID              string         `db:"id"`
 		FilePath        string         `db:"file_path"`
@@ -667,7 +795,7 @@ func (s *SQLiteStore) ListProtoDefinitionsByProfile(ctx context.Context, profile
 		FileOptions     sql.NullString `db:"file_options"`
 	}
 	query := `SELECT * FROM proto_definitions WHERE server_profile_id = ?`
-	err := s.db.SelectContext(ctx, &rows, query, profileID)
+	err := s.q.SelectContext(ctx, &rows, query, profileID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query proto definitions: %w", err)
 	}
@@ -699,9 +827,9 @@ func (s *SQLiteStore) ListProtoDefinitionsByProfile(ctx context.Context, profile
 		if row.Error.Valid {
 			errorMsg = row.Error.String
 		}
-		fileOptions := ""
+		var fileOptions map[string]any
 		if row.FileOptions.Valid {
-			fileOptions = row.FileOptions.String
+			_ = json.Unmarshal([]byte(row.FileOptions.String), &fileOptions)
 		}
 		defs = append(defs, &proto.ProtoDefinition{
 			ID:              row.ID,
@@ -722,7 +850,6 @@ func (s *SQLiteStore) ListProtoDefinitionsByProfile(ctx context.Context, profile
 			FileOptions:     fileOptions,
 		})
 	}
-	fmt.Printf("[DEBUG] Method: ListProtoDefinitionsByProfile - Found %d definitions\n", len(defs))
 	return defs, nil
 }
 
@@ -746,7 +873,7 @@ func (s *SQLiteStore) ListProtoDefinitionsByProtoPath(ctx context.Context, proto
 		FileOptions     sql.NullString `db:"file_options"`
 	}
 	query := `SELECT * FROM proto_definitions WHERE proto_path_id = ?`
-	err := s.db.SelectContext(ctx, &rows, query, protoPathID)
+	err := s.q.SelectContext(ctx, &rows, query, protoPathID)
 	if err != nil {
 		return nil, err
 	}
@@ -776,9 +903,9 @@ func (s *SQLiteStore) ListProtoDefinitionsByProtoPath(ctx context.Context, proto
 		if row.Error.Valid {
 			errorMsg = row.Error.String
 		}
-		fileOptions := ""
+		var fileOptions map[string]any
 		if row.FileOptions.Valid {
-			fileOptions = row.FileOptions.String
+			_ = json.Unmarshal([]byte(row.FileOptions.String), &fileOptions)
 		}
 		defs = append(defs, &proto.ProtoDefinition{
 			ID:              row.ID,
@@ -802,15 +929,9 @@ func (s *SQLiteStore) ListProtoDefinitionsByProtoPath(ctx context.Context, proto
 }
 
 func (s *SQLiteStore) CreateProtoPath(ctx context.Context, path *proto.ProtoPath) error {
-	fmt.Printf("[DEBUG] SQLiteStore.CreateProtoPath called with ID: %s, ServerProfileID: %s, Path: %s\n", path.ID, path.ServerProfileID, path.Path)
 	query := `INSERT INTO proto_paths (id, server_profile_id, path, hash, last_scanned) VALUES (?, ?, ?, ?, ?)`
-	_, err := s.db.ExecContext(ctx, query, path.ID, path.ServerProfileID, path.Path, path.Hash, path.LastScanned)
-	if err != nil {
-		fmt.Printf("[ERROR] Failed to insert proto path: %v\n", err)
-		return err
-	}
-	fmt.Printf("[DEBUG] Successfully inserted proto path into database\n")
-	return nil
+	_, err := s.q.ExecContext(ctx, query, path.ID, path.ServerProfileID, path.Path, path.Hash, path.LastScanned)
+	return err
 }
 
 func (s *SQLiteStore) GetProtoPath(ctx context.Context, id string) (*proto.ProtoPath, error) {
@@ -822,7 +943,7 @@ func (s *SQLiteStore) GetProtoPath(ctx context.Context, id string) (*proto.Proto
 		LastScanned     time.Time `db:"last_scanned"`
 	}
 	query := `SELECT * FROM proto_paths WHERE id = ?`
-	err := s.db.GetContext(ctx, &row, query, id)
+	err := s.q.GetContext(ctx, &row, query, id)
 	if err != nil {
 		return nil, err
 	}
@@ -843,7 +964,7 @@ func (s *SQLiteStore) UpdateProtoPath(ctx context.Context, path *proto.ProtoPath
 		last_scanned = ?
 	WHERE id = ?
 	`
-	_, err := s.db.ExecContext(ctx, query, path.Path, path.Hash, path.LastScanned, path.ID)
+	_, err := s.q.ExecContext(ctx, query, path.Path, path.Hash, path.LastScanned, path.ID)
 	return err
 }
 
@@ -856,7 +977,7 @@ func (s *SQLiteStore) ListProtoPathsByServer(ctx context.Context, serverID strin
 		LastScanned     time.Time `db:"last_scanned"`
 	}
 	query := `SELECT * FROM proto_paths WHERE server_profile_id = ?`
-	err := s.db.SelectContext(ctx, &rows, query, serverID)
+	err := s.q.SelectContext(ctx, &rows, query, serverID)
 	if err != nil {
 		return nil, err
 	}
@@ -875,19 +996,34 @@ func (s *SQLiteStore) ListProtoPathsByServer(ctx context.Context, serverID strin
 
 func (s *SQLiteStore) DeleteProtoPath(ctx context.Context, id string) error {
 	query := `DELETE FROM proto_paths WHERE id = ?`
-	_, err := s.db.ExecContext(ctx, query, id)
+	_, err := s.q.ExecContext(ctx, query, id)
 	return err
 }
 
 // Upsert per-request headers
 func (s *SQLiteStore) UpsertPerRequestHeaders(ctx context.Context, h *models.PerRequestHeaders) error {
+	// Encrypt the values of secret headers
+	headersJSON, err := transformHeaderJSON(h.HeadersJSON, h.SecretKeys, s.sealSecret)
+	if err != nil {
+		return err
+	}
+	secretKeys := h.SecretKeys
+	if secretKeys == nil {
+		secretKeys = []string{}
+	}
+	data, err := json.Marshal(secretKeys)
+	if err != nil {
+		return err
+	}
+	h.SecretKeysJSON = string(data)
+
 	query := `
-	INSERT INTO per_request_headers (server_profile_id, service_name, method_name, headers_json, updated_at)
-	VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
+	INSERT INTO per_request_headers (server_profile_id, service_name, method_name, headers_json, secret_keys_json, updated_at)
+	VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
 	ON CONFLICT(server_profile_id, service_name, method_name)
-	DO UPDATE SET headers_json=excluded.headers_json, updated_at=CURRENT_TIMESTAMP
+	DO UPDATE SET headers_json=excluded.headers_json, secret_keys_json=excluded.secret_keys_json, updated_at=CURRENT_TIMESTAMP
 	`
-	_, err := s.db.ExecContext(ctx, query, h.ServerProfileID, h.ServiceName, h.MethodName, h.HeadersJSON)
+	_, err = s.q.ExecContext(ctx, query, h.ServerProfileID, h.ServiceName, h.MethodName, headersJSON, h.SecretKeysJSON)
 	return err
 }
 
@@ -899,19 +1035,49 @@ func (s *SQLiteStore) GetPerRequestHeaders(ctx context.Context, serverProfileID,
 	WHERE server_profile_id = ? AND service_name = ? AND method_name = ?
 	LIMIT 1
 	`
-	err := s.db.GetContext(ctx, &h, query, serverProfileID, serviceName, methodName)
+	err := s.q.GetContext(ctx, &h, query, serverProfileID, serviceName, methodName)
 	if err != nil {
 		return nil, err
 	}
+	s.openPerRequestHeaders(&h)
 	return &h, nil
 }
 
+// List the per-request headers of a server profile
+func (s *SQLiteStore) ListPerRequestHeaders(ctx context.Context, serverProfileID string) ([]*models.PerRequestHeaders, error) {
+	var list []*models.PerRequestHeaders
+	query := `
+	SELECT * FROM per_request_headers
+	WHERE server_profile_id = ?
+	ORDER BY service_name, method_name
+	`
+	if err := s.q.SelectContext(ctx, &list, query, serverProfileID); err != nil {
+		return nil, err
+	}
+	for _, h := range list {
+		s.openPerRequestHeaders(h)
+	}
+	return list, nil
+}
+
+// openPerRequestHeaders restores the secret keys and decrypts their values
+func (s *SQLiteStore) openPerRequestHeaders(h *models.PerRequestHeaders) {
+	if h.SecretKeysJSON != "" {
+		_ = json.Unmarshal([]byte(h.SecretKeysJSON), &h.SecretKeys)
+	}
+	if opened, err := transformHeaderJSON(h.HeadersJSON, h.SecretKeys, func(v string) (string, error) {
+		return s.openSecret(v), nil
+	}); err == nil {
+		h.HeadersJSON = opened
+	}
+}
+
 // Delete per-request headers for a method
 func (s *SQLiteStore) DeletePerRequestHeaders(ctx context.Context, serverProfileID, serviceName, methodName string) error {
 	query := `
 	DELETE FROM per_request_headers
 	WHERE server_profile_id = ? AND service_name = ? AND method_name = ?
 	`
-	_, err := s.db.ExecContext(ctx, query, serverProfileID, serviceName, methodName)
+	_, err := s.q.ExecContext(ctx, query, serverProfileID, serviceName, methodName)
 	return err
 }
@@ -258,7 +258,7 @@ func TestProtoDefinitionStorage_CRUD_EnumsAndOptions(t *testing.T) {
 			Values:      []proto.EnumValue{{Name: "A", Number: 0}, {Name: "B", Number: 1}},
 			Description: "Enum description",
 		}},
-		FileOptions: `{"java_package":"com.example"}`,
+		FileOptions: map[string]any{"java_package": "com.example"},
 	}
 
 	// Create
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"time"
+
+	"protodesk/pkg/models"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/keepalive"
+)
+
+// appUserAgent identifies the app in the user agent of its calls
+const appUserAgent = "protodesk/0.1.0"
+
+// userAgent returns the user agent of the app's calls with the suffix of a
+// profile's transport settings appended
+func userAgent(t *models.TransportSettings) string {
+	if t == nil || t.UserAgent == "" {
+		return appUserAgent
+	}
+	return appUserAgent + " " + t.UserAgent
+}
+
+// TransportDialOptions returns the dial options applying a profile's
+// transport settings, or nil when it keeps the gRPC defaults. Message sizes
+// and compression become default call options of the connection.
+func TransportDialOptions(t *models.TransportSettings) []grpc.DialOption {
+	if t.IsZero() {
+		return nil
+	}
+	var opts []grpc.DialOption
+	if t.KeepaliveTimeMs > 0 {
+		opts = append(opts, grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                time.Duration(t.KeepaliveTimeMs) * time.Millisecond,
+			Timeout:             time.Duration(t.KeepaliveTimeoutMs) * time.Millisecond,
+			PermitWithoutStream: t.KeepaliveWithoutCalls,
+		}))
+	}
+
+	var callOpts []grpc.CallOption
+	if t.MaxSendMessageBytes > 0 {
+		callOpts = append(callOpts, grpc.MaxCallSendMsgSize(t.MaxSendMessageBytes))
+	}
+	if t.MaxReceiveMessageBytes > 0 {
+		callOpts = append(callOpts, grpc.MaxCallRecvMsgSize(t.MaxReceiveMessageBytes))
+	}
+	if t.Compression == models.CompressionGzip {
+		callOpts = append(callOpts, grpc.UseCompressor(gzip.Name))
+	}
+	if len(callOpts) > 0 {
+		opts = append(opts, grpc.WithDefaultCallOptions(callOpts...))
+	}
+
+	if t.InitialWindowSize > 0 {
+		opts = append(opts, grpc.WithInitialWindowSize(t.InitialWindowSize))
+	}
+	if t.InitialConnWindowSize > 0 {
+		opts = append(opts, grpc.WithInitialConnWindowSize(t.InitialConnWindowSize))
+	}
+	return opts
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"protodesk/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
+)
+
+// headerRecorder is a server stats handler that keeps the incoming headers
+// of health checks
+type headerRecorder struct {
+	mu          sync.Mutex
+	userAgent   string
+	compression string
+	authority   string
+}
+
+func (r *headerRecorder) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (r *headerRecorder) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (r *headerRecorder) HandleConn(context.Context, stats.ConnStats) {}
+
+func (r *headerRecorder) HandleRPC(_ context.Context, s stats.RPCStats) {
+	in, ok := s.(*stats.InHeader)
+	if !ok || !strings.HasSuffix(in.FullMethod, "/Check") {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.compression = in.Compression
+	if ua := in.Header.Get("user-agent"); len(ua) > 0 {
+		r.userAgent = ua[0]
+	}
+	if authority := in.Header.Get(":authority"); len(authority) > 0 {
+		r.authority = authority[0]
+	}
+}
+
+func TestServerProfileManager_TransportSettings(t *testing.T) {
+	recorder := &headerRecorder{}
+	addr := startTestServer(t, grpc.StatsHandler(recorder))
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+	manager := NewServerProfileManager(store, nil)
+	defer manager.DisconnectAll()
+	ctx := context.Background()
+
+	host, port := splitTestAddr(t, addr)
+	profile := models.NewServerProfile("tuned", host, port)
+	profile.Transport = &models.TransportSettings{
+		KeepaliveTimeMs:        60000,
+		KeepaliveTimeoutMs:     5000,
+		MaxReceiveMessageBytes: 64 << 20,
+		Compression:            models.CompressionGzip,
+		UserAgent:              "protodesk-test/1.0",
+		InitialWindowSize:      1 << 20,
+	}
+	require.NoError(t, store.Create(ctx, profile))
+
+	stored, err := store.Get(ctx, profile.ID)
+	require.NoError(t, err)
+	assert.Equal(t, profile.Transport, stored.Transport)
+
+	require.NoError(t, manager.Connect(ctx, profile.ID))
+	result, err := manager.Invoke(ctx, profile.ID, InvocationRequest{
+		ServiceName: "grpc.health.v1.Health",
+		MethodName:  "Check",
+		RequestJSON: `{"service":"test.Service"}`,
+	})
+	require.NoError(t, err)
+	require.NoError(t, result.Err())
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	assert.True(t, strings.HasPrefix(recorder.userAgent, "protodesk/0.1.0 protodesk-test/1.0 grpc-go/"), recorder.userAgent)
+	assert.Equal(t, "gzip", recorder.compression)
+}
+
+func TestUserAgent(t *testing.T) {
+	assert.Equal(t, "protodesk/0.1.0", userAgent(nil))
+	assert.Equal(t, "protodesk/0.1.0", userAgent(&models.TransportSettings{KeepaliveTimeMs: 1000}))
+	assert.Equal(t, "protodesk/0.1.0 ci-smoke/2", userAgent(&models.TransportSettings{UserAgent: "ci-smoke/2"}))
+}
+
+func TestTransportSettings_Validate(t *testing.T) {
+	valid := &models.TransportSettings{KeepaliveTimeMs: 30000, MaxSendMessageBytes: 1 << 20, Compression: "gzip"}
+	assert.NoError(t, valid.Validate())
+	assert.True(t, (*models.TransportSettings)(nil).IsZero())
+	assert.Nil(t, TransportDialOptions(&models.TransportSettings{}))
+
+	for _, invalid := range []*models.TransportSettings{
+		{KeepaliveTimeMs: -1},
+		{KeepaliveTimeoutMs: 1000},
+		{MaxReceiveMessageBytes: -1},
+		{Compression: "snappy"},
+		{InitialWindowSize: 1024},
+	} {
+		assert.ErrorIs(t, invalid.Validate(), models.ErrInvalidTransportSettings)
+	}
+}
+
+func TestNewInvocationResult_MessageSizeHint(t *testing.T) {
+	result := newInvocationResult(0, nil, nil, status.Error(codes.ResourceExhausted, "grpc: received message larger than max (5000000 vs. 4194304)"))
+	assert.Equal(t, "RESOURCE_EXHAUSTED", result.StatusName)
+	assert.Contains(t, result.StatusMessage, "transport settings")
+}
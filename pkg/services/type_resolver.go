@@ -0,0 +1,152 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"protodesk/pkg/models/proto"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/jhump/protoreflect/grpcreflect"
+	reflectpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// MessageFinder finds a message type by its fully-qualified name, returning
+// nil if it does not know the type
+type MessageFinder func(name string) *desc.MessageDescriptor
+
+// typeResolver resolves the payload types of google.protobuf.Any fields.
+// It checks the types linked into the app, such as the well-known types, and
+// then each finder in turn, remembering what they found. It serves protojson
+// as a protoregistry resolver and dynamic messages as a jsonpb.AnyResolver.
+type typeResolver struct {
+	finders []MessageFinder
+
+	mu    sync.Mutex
+	found map[string]*desc.MessageDescriptor
+}
+
+// newTypeResolver returns a resolver searching finders in order; nil finders
+// are skipped
+func newTypeResolver(finders ...MessageFinder) *typeResolver {
+	r := &typeResolver{found: make(map[string]*desc.MessageDescriptor)}
+	for _, find := range finders {
+		if find != nil {
+			r.finders = append(r.finders, find)
+		}
+	}
+	return r
+}
+
+// findMessage returns the message type name from the finders, or nil
+func (r *typeResolver) findMessage(name string) *desc.MessageDescriptor {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if md, ok := r.found[name]; ok {
+		return md
+	}
+	var md *desc.MessageDescriptor
+	for _, find := range r.finders {
+		if md = find(name); md != nil {
+			break
+		}
+	}
+	// Misses are remembered too so that reflection is asked only once
+	r.found[name] = md
+	return md
+}
+
+// FindMessageByName implements protoregistry.MessageTypeResolver
+func (r *typeResolver) FindMessageByName(name protoreflect.FullName) (protoreflect.MessageType, error) {
+	if mt, err := protoregistry.GlobalTypes.FindMessageByName(name); err == nil {
+		return mt, nil
+	}
+	md := r.findMessage(string(name))
+	if md == nil {
+		return nil, fmt.Errorf("%w: %s", ErrMessageNotFound, name)
+	}
+	return dynamicpb.NewMessageType(md.UnwrapMessage()), nil
+}
+
+// FindMessageByURL implements protoregistry.MessageTypeResolver. The type
+// name is the part of the URL after its last slash.
+func (r *typeResolver) FindMessageByURL(url string) (protoreflect.MessageType, error) {
+	return r.FindMessageByName(protoreflect.FullName(typeURLName(url)))
+}
+
+// FindExtensionByName implements protoregistry.ExtensionTypeResolver with
+// the extensions linked into the app
+func (r *typeResolver) FindExtensionByName(field protoreflect.FullName) (protoreflect.ExtensionType, error) {
+	return protoregistry.GlobalTypes.FindExtensionByName(field)
+}
+
+// FindExtensionByNumber implements protoregistry.ExtensionTypeResolver with
+// the extensions linked into the app
+func (r *typeResolver) FindExtensionByNumber(message protoreflect.FullName, field protoreflect.FieldNumber) (protoreflect.ExtensionType, error) {
+	return protoregistry.GlobalTypes.FindExtensionByNumber(message, field)
+}
+
+// Resolve implements jsonpb.AnyResolver for dynamic messages
+func (r *typeResolver) Resolve(typeURL string) (protoadapt.MessageV1, error) {
+	name := typeURLName(typeURL)
+	if mt, err := protoregistry.GlobalTypes.FindMessageByName(protoreflect.FullName(name)); err == nil {
+		return protoadapt.MessageV1Of(mt.New().Interface()), nil
+	}
+	md := r.findMessage(name)
+	if md == nil {
+		return nil, fmt.Errorf("%w: %s", ErrMessageNotFound, name)
+	}
+	return dynamic.NewMessage(md), nil
+}
+
+// typeURLName returns the message name of an Any type URL such as
+// type.googleapis.com/acme.v1.Order
+func typeURLName(url string) string {
+	return url[strings.LastIndex(url, "/")+1:]
+}
+
+// reflectedMessages finds message types through a server's reflection
+func reflectedMessages(rc *grpcreflect.Client) MessageFinder {
+	return func(name string) *desc.MessageDescriptor {
+		md, err := rc.ResolveMessage(name)
+		if err != nil {
+			return nil
+		}
+		return md
+	}
+}
+
+// definedMessages finds message types in the proto definitions of a profile,
+// listing them when a type is first looked up
+func (m *ServerProfileManager) definedMessages(ctx context.Context, profileID string) MessageFinder {
+	var once sync.Once
+	var defs []*proto.ProtoDefinition
+	return func(name string) *desc.MessageDescriptor {
+		once.Do(func() {
+			var err error
+			if defs, err = m.store.ListProtoDefinitionsByProfile(ctx, profileID); err != nil {
+				m.logger.Warn("Failed to list proto definitions", "profile", profileID, "error", err)
+			}
+		})
+		return m.findDefinedMessage(defs, name)
+	}
+}
+
+// messageTypes resolves Any payload types from the proto definitions of a
+// profile and, if it is connected, its server's reflection. release frees
+// the reflection stream.
+func (m *ServerProfileManager) messageTypes(ctx context.Context, profileID string) (types *typeResolver, release func()) {
+	conn, err := m.GetConnection(profileID)
+	if err != nil {
+		return newTypeResolver(m.definedMessages(ctx, profileID)), func() {}
+	}
+	rc := grpcreflect.NewClient(ctx, reflectpb.NewServerReflectionClient(conn))
+	return newTypeResolver(m.definedMessages(ctx, profileID), reflectedMessages(rc)), rc.Reset
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"protodesk/pkg/models"
+	"protodesk/pkg/models/proto"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/protoparse"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const envelopeProto = `syntax = "proto3";
+package acme.v1;
+
+import "google/protobuf/any.proto";
+
+message Envelope {
+  string id = 1;
+  google.protobuf.Any payload = 2;
+}
+`
+
+// nestedAnyJSON is an envelope holding an envelope holding an order, whose
+// type is defined in a file the envelope does not import
+const nestedAnyJSON = `{
+  "id": "e-1",
+  "payload": {
+    "@type": "type.googleapis.com/acme.v1.Envelope",
+    "id": "e-2",
+    "payload": {
+      "@type": "type.googleapis.com/acme.v1.Order",
+      "id": "o-1",
+      "createdAt": "2024-05-01T10:00:00Z"
+    }
+  }
+}`
+
+func TestTypeResolver_JSON(t *testing.T) {
+	defs := []*proto.ProtoDefinition{
+		proto.NewProtoDefinition("acme/envelope.proto", envelopeProto),
+		proto.NewProtoDefinition("acme/orders.proto", ordersProto),
+		proto.NewProtoDefinition("common/money.proto", moneyProto),
+	}
+	parser := protoparse.Parser{Accessor: definitionAccessor(defs)}
+	fds, err := parser.ParseFiles("acme/envelope.proto", "acme/orders.proto")
+	require.NoError(t, err)
+	envelope := fds[0].FindMessage("acme.v1.Envelope")
+	lookups := 0
+	types := newTypeResolver(nil, func(name string) *desc.MessageDescriptor {
+		lookups++
+		for _, fd := range fds {
+			if md := fd.FindMessage(name); md != nil {
+				return md
+			}
+		}
+		return nil
+	})
+
+	msg, err := unmarshalMessage(envelope, []byte(nestedAnyJSON), models.JSONOptions{}, types)
+	require.NoError(t, err)
+	data, err := marshalMessage(msg, models.JSONOptions{}, types)
+	require.NoError(t, err)
+	assert.JSONEq(t, nestedAnyJSON, string(data))
+	assert.Equal(t, 2, lookups, "found types are remembered")
+
+	// Well-known types resolve without finders; other types do not
+	_, err = unmarshalMessage(envelope, []byte(`{"payload":{"@type":"type.googleapis.com/google.protobuf.Duration","value":"1.5s"}}`), models.JSONOptions{}, newTypeResolver())
+	require.NoError(t, err)
+	_, err = unmarshalMessage(envelope, []byte(nestedAnyJSON), models.JSONOptions{}, newTypeResolver())
+	assert.ErrorContains(t, err, "message type not found: acme.v1.Envelope")
+
+	// Conversions to other formats resolve payloads too
+	text, err := convertMessages(envelope, nestedAnyJSON, models.FormatJSON, models.FormatYAML, false, types)
+	require.NoError(t, err)
+	assert.Contains(t, text, "'@type': type.googleapis.com/acme.v1.Order")
+	back, err := convertMessages(envelope, text, models.FormatYAML, models.FormatJSON, false, types)
+	require.NoError(t, err)
+	assert.JSONEq(t, nestedAnyJSON, back)
+}
+
+func TestServerProfileManager_DecodeMessageAny(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	t.Cleanup(cleanup)
+	manager := NewServerProfileManager(store, nil)
+	ctx := context.Background()
+	profile := models.NewServerProfile("envelopes", "localhost", 50051)
+	require.NoError(t, store.Create(ctx, profile))
+	protoPath := &proto.ProtoPath{ID: "protos", ServerProfileID: profile.ID, Path: "/protos"}
+	require.NoError(t, store.CreateProtoPath(ctx, protoPath))
+	for _, def := range []*proto.ProtoDefinition{
+		proto.NewProtoDefinition("/protos/acme/envelope.proto", envelopeProto),
+		proto.NewProtoDefinition("/protos/acme/orders.proto", ordersProto),
+		proto.NewProtoDefinition("/protos/common/money.proto", moneyProto),
+	} {
+		def.ServerProfileID, def.ProtoPathID = profile.ID, protoPath.ID
+		require.NoError(t, store.CreateProtoDefinition(ctx, def))
+	}
+
+	data, err := manager.EncodeMessage(ctx, profile.ID, "acme.v1.Envelope", nestedAnyJSON)
+	require.NoError(t, err)
+	decoded, err := manager.DecodeMessage(ctx, profile.ID, "acme.v1.Envelope", data)
+	require.NoError(t, err)
+	assert.JSONEq(t, nestedAnyJSON, decoded)
+}
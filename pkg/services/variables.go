@@ -0,0 +1,166 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"protodesk/pkg/models"
+	"protodesk/pkg/secrets"
+)
+
+// ErrUndefinedVariable is returned when a {{name}} placeholder has no value
+var ErrUndefinedVariable = errors.New("undefined variable")
+
+// placeholderPattern matches {{name}} placeholders, allowing spaces inside the braces
+var placeholderPattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_.-]*)\s*\}\}`)
+
+// VariableScope holds the variables visible to the requests of a run. It is
+// safe for concurrent use.
+type VariableScope struct {
+	mu   sync.RWMutex
+	vars map[string]string
+}
+
+// NewVariableScope creates a scope seeded with a copy of initial
+func NewVariableScope(initial map[string]string) *VariableScope {
+	vars := make(map[string]string, len(initial))
+	maps.Copy(vars, initial)
+	return &VariableScope{vars: vars}
+}
+
+// Get returns the value of a variable
+func (s *VariableScope) Get(name string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, ok := s.vars[name]
+	return v, ok
+}
+
+// Set assigns a variable
+func (s *VariableScope) Set(name, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.vars[name] = value
+}
+
+// Snapshot returns a copy of every variable in the scope
+func (s *VariableScope) Snapshot() map[string]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return maps.Clone(s.vars)
+}
+
+// ExpandVariables replaces {{name}} placeholders in a JSON document (a request
+// body or headers) with their values. Values are JSON-escaped so that a
+// placeholder can sit inside a string literal, e.g. {"token": "{{token}}"}.
+// Every placeholder without a value is reported in a single error; a secret
+// that is still encrypted fails with secrets.ErrLocked.
+func ExpandVariables(text string, vars map[string]string) (string, error) {
+	var missing, locked []string
+	out := placeholderPattern.ReplaceAllStringFunc(text, func(match string) string {
+		name := placeholderPattern.FindStringSubmatch(match)[1]
+		value, ok := vars[name]
+		if !ok {
+			if !slices.Contains(missing, name) {
+				missing = append(missing, name)
+			}
+			return match
+		}
+		if secrets.IsEncrypted(value) {
+			locked = append(locked, name)
+			return match
+		}
+		return escapeJSONString(value)
+	})
+	if len(locked) > 0 {
+		return "", fmt.Errorf("%w: cannot use %s", secrets.ErrLocked, strings.Join(locked, ", "))
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("%w: %s", ErrUndefinedVariable, strings.Join(missing, ", "))
+	}
+	return out, nil
+}
+
+// escapeJSONString escapes s for use inside a JSON string literal
+func escapeJSONString(s string) string {
+	data, _ := json.Marshal(s)
+	return string(data[1 : len(data)-1])
+}
+
+// ExpandInvocation expands the placeholders of a request body and its headers
+// JSON, returning the request to send
+func ExpandInvocation(serviceName, methodName, requestJSON, headersJSON string, vars map[string]string) (InvocationRequest, error) {
+	body, err := ExpandVariables(requestJSON, vars)
+	if err != nil {
+		return InvocationRequest{}, fmt.Errorf("request body: %w", err)
+	}
+	headers, err := ExpandVariables(headersJSON, vars)
+	if err != nil {
+		return InvocationRequest{}, fmt.Errorf("headers: %w", err)
+	}
+	md, err := MetadataFromJSON(headers)
+	if err != nil {
+		return InvocationRequest{}, err
+	}
+	return InvocationRequest{
+		ServiceName: serviceName,
+		MethodName:  methodName,
+		RequestJSON: body,
+		Metadata:    md,
+	}, nil
+}
+
+// ApplyExtractions evaluates extraction rules against a result and returns the
+// extracted variables. JSONPath values that are not strings are stored as
+// JSON; header and trailer rules take the first value of the key.
+func ApplyExtractions(result *InvocationResult, rules []models.ExtractionRule) (map[string]string, error) {
+	extracted := make(map[string]string, len(rules))
+	for _, rule := range rules {
+		if err := rule.Validate(); err != nil {
+			return extracted, err
+		}
+		value, err := extractValue(result, rule)
+		if err != nil {
+			return extracted, fmt.Errorf("failed to extract %s: %w", rule.Variable, err)
+		}
+		extracted[rule.Variable] = value
+	}
+	return extracted, nil
+}
+
+func extractValue(result *InvocationResult, rule models.ExtractionRule) (string, error) {
+	switch rule.Source {
+	case models.ExtractFromJSONPath:
+		value, err := jsonPathValue(result.ResponseJSON, rule.Expression)
+		if err != nil {
+			return "", err
+		}
+		if s, ok := value.(string); ok {
+			return s, nil
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	case models.ExtractFromHeader:
+		return firstMetadataValue("header", result.Headers, rule.Expression)
+	case models.ExtractFromTrailer:
+		return firstMetadataValue("trailer", result.Trailers, rule.Expression)
+	}
+	return "", fmt.Errorf("unsupported extraction source %q", rule.Source)
+}
+
+func firstMetadataValue(kind string, md map[string][]string, key string) (string, error) {
+	values := md[strings.ToLower(key)]
+	if len(values) == 0 {
+		return "", fmt.Errorf("%s %q not present", kind, key)
+	}
+	return values[0], nil
+}
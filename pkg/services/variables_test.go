@@ -0,0 +1,77 @@
+package services
+
+import (
+	"testing"
+
+	"protodesk/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandVariables(t *testing.T) {
+	vars := map[string]string{
+		"token":   "abc",
+		"quote":   `say "hi"`,
+		"user.id": "42",
+	}
+
+	out, err := ExpandVariables(`{"token":"{{token}}","id":{{ user.id }},"msg":"{{quote}}"}`, vars)
+	require.NoError(t, err)
+	assert.Equal(t, `{"token":"abc","id":42,"msg":"say \"hi\""}`, out)
+
+	_, err = ExpandVariables(`{"a":"{{missing}}","b":"{{other}}","c":"{{missing}}"}`, vars)
+	assert.ErrorIs(t, err, ErrUndefinedVariable)
+	assert.Contains(t, err.Error(), "missing, other")
+
+	out, err = ExpandVariables(`{"literal":"{not a placeholder}"}`, nil)
+	require.NoError(t, err)
+	assert.Equal(t, `{"literal":"{not a placeholder}"}`, out)
+}
+
+func TestExpandInvocation(t *testing.T) {
+	req, err := ExpandInvocation("test.Users", "Get", `{"id":"{{id}}"}`, `{"authorization":"Bearer {{token}}"}`, map[string]string{
+		"id":    "7",
+		"token": "secret",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, `{"id":"7"}`, req.RequestJSON)
+	assert.Equal(t, []string{"Bearer secret"}, req.Metadata.Get("authorization"))
+
+	_, err = ExpandInvocation("test.Users", "Get", `{}`, `{"authorization":"{{token}}"}`, nil)
+	assert.ErrorIs(t, err, ErrUndefinedVariable)
+}
+
+func TestApplyExtractions(t *testing.T) {
+	result := &InvocationResult{
+		ResponseJSON: `{"session":{"token":"t-1","ttl":300,"scopes":["read"]}}`,
+		Headers:      map[string][]string{"x-session": {"s-1", "s-2"}},
+		Trailers:     map[string][]string{"x-request-id": {"r-1"}},
+	}
+
+	extracted, err := ApplyExtractions(result, []models.ExtractionRule{
+		{Source: models.ExtractFromJSONPath, Expression: "$.session.token", Variable: "token"},
+		{Source: models.ExtractFromJSONPath, Expression: "$.session.ttl", Variable: "ttl"},
+		{Source: models.ExtractFromJSONPath, Expression: "$.session.scopes", Variable: "scopes"},
+		{Source: models.ExtractFromHeader, Expression: "X-Session", Variable: "session"},
+		{Source: models.ExtractFromTrailer, Expression: "x-request-id", Variable: "requestId"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"token":     "t-1",
+		"ttl":       "300",
+		"scopes":    `["read"]`,
+		"session":   "s-1",
+		"requestId": "r-1",
+	}, extracted)
+
+	extracted, err = ApplyExtractions(result, []models.ExtractionRule{
+		{Source: models.ExtractFromJSONPath, Expression: "$.session.token", Variable: "token"},
+		{Source: models.ExtractFromTrailer, Expression: "x-missing", Variable: "missing"},
+	})
+	assert.Error(t, err)
+	assert.Equal(t, map[string]string{"token": "t-1"}, extracted)
+
+	_, err = ApplyExtractions(result, []models.ExtractionRule{{Source: "body", Expression: "$", Variable: "x"}})
+	assert.ErrorIs(t, err, models.ErrInvalidExtraction)
+}
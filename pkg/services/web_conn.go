@@ -0,0 +1,870 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"protodesk/pkg/models"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/protoadapt"
+)
+
+const (
+	// defaultMaxReceiveMessageBytes matches the receive limit of native gRPC
+	defaultMaxReceiveMessageBytes = 4 << 20
+	// maxErrorBodyBytes bounds how much of an error response is read
+	maxErrorBodyBytes = 64 << 10
+)
+
+// Flags of the length-prefixed frames of gRPC-Web and Connect streams
+const (
+	frameCompressed byte = 0x01
+	// frameConnectEndStream marks the final frame of a Connect stream
+	frameConnectEndStream byte = 0x02
+	// frameGRPCWebTrailer marks the trailer frame of a gRPC-Web response
+	frameGRPCWebTrailer byte = 0x80
+)
+
+// WebConnOptions configures a WebConn
+type WebConnOptions struct {
+	// Protocol is one of the gRPC-Web or Connect protocols
+	Protocol models.Protocol
+	// BaseURL is the http:// or https:// URL that method paths are appended to
+	BaseURL string
+	// Authority overrides the Host header
+	Authority string
+	// Header is sent with every call, except for keys the call's own
+	// metadata sets
+	Header metadata.MD
+	// Credentials add per-call metadata such as an auth token
+	Credentials credentials.PerRPCCredentials
+	// Transport limits message sizes and sets the user agent; the other
+	// settings only apply to native gRPC
+	Transport *models.TransportSettings
+	// Dial opens the connections of the HTTP client, e.g. through a proxy or
+	// to a unix socket; nil dials the host of BaseURL directly
+	Dial func(ctx context.Context, addr string) (net.Conn, error)
+}
+
+// WebConn calls a gRPC-Web or Connect server over plain HTTP requests. It
+// implements grpc.ClientConnInterface, so reflection and InvokeMethod use it
+// like a native connection, with the same dynamic messages.
+//
+// Unary and server streaming calls behave as they do over gRPC. Client and
+// bidirectional streams are half-duplex: the messages sent so far are posted
+// when a response is first read, and messages sent after that start a new
+// request. That is enough for reflection, which sends one request per
+// response.
+type WebConn struct {
+	opts      WebConnOptions
+	baseURL   string
+	transport *http.Transport
+	client    *http.Client
+}
+
+// NewWebConn creates a WebConn. No connection is opened until the first call.
+func NewWebConn(opts WebConnOptions) (*WebConn, error) {
+	switch opts.Protocol {
+	case models.ProtocolGRPCWeb, models.ProtocolGRPCWebText, models.ProtocolConnect, models.ProtocolConnectJSON:
+	default:
+		return nil, fmt.Errorf("%w: %q is not a gRPC-Web or Connect protocol", models.ErrInvalidProtocol, opts.Protocol)
+	}
+	u, err := url.Parse(opts.BaseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("%w: %q is not an http:// or https:// URL", models.ErrInvalidProtocol, opts.BaseURL)
+	}
+
+	transport := &http.Transport{
+		// Proxies are configured per profile and applied through Dial
+		Proxy:             nil,
+		ForceAttemptHTTP2: true,
+		// Match the TLS settings of native connections
+		TLSClientConfig: &tls.Config{
+			MinVersion:         tls.VersionTLS12,
+			Renegotiation:      tls.RenegotiateOnceAsClient,
+			InsecureSkipVerify: true,
+		},
+		IdleConnTimeout: 90 * time.Second,
+	}
+	if opts.Dial != nil {
+		transport.DialContext = func(ctx context.Context, _, addr string) (net.Conn, error) {
+			return opts.Dial(ctx, addr)
+		}
+	}
+	return &WebConn{
+		opts:      opts,
+		baseURL:   strings.TrimSuffix(u.String(), "/"),
+		transport: transport,
+		client:    &http.Client{Transport: transport},
+	}, nil
+}
+
+// WebBaseURL returns the URL that the calls of a gRPC-Web or Connect profile
+// are posted to
+func WebBaseURL(profile *models.ServerProfile, useTLS bool) string {
+	if profile.TargetKind == models.TargetRaw {
+		return profile.Target
+	}
+	scheme := "http"
+	if useTLS {
+		scheme = "https"
+	}
+	host := "localhost"
+	if profile.IsTCP() {
+		host = net.JoinHostPort(profile.Host, strconv.Itoa(profile.Port))
+	}
+	return scheme + "://" + host
+}
+
+// Close releases the idle connections of the HTTP client
+func (c *WebConn) Close() {
+	c.transport.CloseIdleConnections()
+}
+
+// Invoke performs a unary call
+func (c *WebConn) Invoke(ctx context.Context, method string, args, reply any, opts ...grpc.CallOption) error {
+	stream, err := c.NewStream(ctx, &grpc.StreamDesc{}, method, opts...)
+	if err != nil {
+		return err
+	}
+	if err := stream.SendMsg(args); err != nil {
+		return err
+	}
+	if err := stream.RecvMsg(reply); err != nil {
+		if errors.Is(err, io.EOF) {
+			return status.Error(codes.Internal, "server sent no response message")
+		}
+		return err
+	}
+	// Read to the end of the response for its trailers and status
+	switch err := stream.RecvMsg(reply); {
+	case err == nil:
+		return status.Error(codes.Internal, "server sent more than one response message")
+	case !errors.Is(err, io.EOF):
+		return err
+	}
+	return nil
+}
+
+// NewStream starts a streaming call. Requests are posted once a response is
+// read, see WebConn.
+func (c *WebConn) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	s := &webStream{
+		conn:    c,
+		ctx:     ctx,
+		method:  method,
+		unary:   !desc.ClientStreams && !desc.ServerStreams,
+		maxRecv: defaultMaxReceiveMessageBytes,
+		maxSend: math.MaxInt32,
+	}
+	if t := c.opts.Transport; t != nil {
+		if t.MaxReceiveMessageBytes > 0 {
+			s.maxRecv = t.MaxReceiveMessageBytes
+		}
+		if t.MaxSendMessageBytes > 0 {
+			s.maxSend = t.MaxSendMessageBytes
+		}
+	}
+	for _, opt := range opts {
+		switch o := opt.(type) {
+		case grpc.HeaderCallOption:
+			s.headerAddr = o.HeaderAddr
+		case grpc.TrailerCallOption:
+			s.trailerAddr = o.TrailerAddr
+		case grpc.MaxRecvMsgSizeCallOption:
+			s.maxRecv = o.MaxRecvMsgSize
+		case grpc.MaxSendMsgSizeCallOption:
+			s.maxSend = o.MaxSendMsgSize
+		}
+	}
+	return s, nil
+}
+
+func (c *WebConn) isConnect() bool {
+	return c.opts.Protocol == models.ProtocolConnect || c.opts.Protocol == models.ProtocolConnectJSON
+}
+
+func (c *WebConn) isJSON() bool {
+	return c.opts.Protocol == models.ProtocolConnectJSON
+}
+
+// contentType returns the media type of a call; unary Connect calls are not
+// enveloped and have their own media types
+func (c *WebConn) contentType(unary bool) string {
+	switch c.opts.Protocol {
+	case models.ProtocolGRPCWebText:
+		return "application/grpc-web-text+proto"
+	case models.ProtocolConnect:
+		if unary {
+			return "application/proto"
+		}
+		return "application/connect+proto"
+	case models.ProtocolConnectJSON:
+		if unary {
+			return "application/json"
+		}
+		return "application/connect+json"
+	default:
+		return "application/grpc-web+proto"
+	}
+}
+
+// encode marshals a message for the wire. Messages that marshal themselves to
+// JSON, such as dynamic messages, do so; others use protojson.
+func (c *WebConn) encode(m any) ([]byte, error) {
+	if c.isJSON() {
+		if jm, ok := m.(json.Marshaler); ok {
+			return jm.MarshalJSON()
+		}
+		msg, err := messageV2(m)
+		if err != nil {
+			return nil, err
+		}
+		return protojson.Marshal(msg)
+	}
+	msg, err := messageV2(m)
+	if err != nil {
+		return nil, err
+	}
+	return proto.Marshal(msg)
+}
+
+// decode unmarshals a message read from the wire
+func (c *WebConn) decode(data []byte, m any) error {
+	if c.isJSON() {
+		if jm, ok := m.(json.Unmarshaler); ok {
+			return jm.UnmarshalJSON(data)
+		}
+		msg, err := messageV2(m)
+		if err != nil {
+			return err
+		}
+		return protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(data, msg)
+	}
+	msg, err := messageV2(m)
+	if err != nil {
+		return err
+	}
+	return proto.Unmarshal(data, msg)
+}
+
+// messageV2 returns m as a message of the current protobuf API, wrapping
+// dynamic and other legacy messages the way the gRPC codec does
+func messageV2(m any) (proto.Message, error) {
+	switch msg := m.(type) {
+	case proto.Message:
+		return msg, nil
+	case protoadapt.MessageV1:
+		return protoadapt.MessageV2Of(msg), nil
+	default:
+		return nil, fmt.Errorf("%T is not a protobuf message", m)
+	}
+}
+
+// newRequest builds the HTTP request posting messages to method
+func (c *WebConn) newRequest(ctx context.Context, method string, unary bool, messages [][]byte) (*http.Request, error) {
+	var body []byte
+	if unary && c.isConnect() {
+		if len(messages) != 1 {
+			return nil, status.Errorf(codes.Internal, "a unary call sends one message, not %d", len(messages))
+		}
+		body = messages[0]
+	} else {
+		for _, msg := range messages {
+			body = appendFrame(body, 0, msg)
+		}
+	}
+	if c.opts.Protocol == models.ProtocolGRPCWebText {
+		body = []byte(base64.StdEncoding.EncodeToString(body))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+method, bytes.NewReader(body))
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to create request: %v", err)
+	}
+	if c.opts.Authority != "" {
+		req.Host = c.opts.Authority
+	}
+
+	outgoing, _ := metadata.FromOutgoingContext(ctx)
+	md := mergeHeaders(c.opts.Header, outgoing)
+	if c.opts.Credentials != nil {
+		service := method[:strings.LastIndex(method, "/")]
+		creds, err := c.opts.Credentials.GetRequestMetadata(ctx, c.baseURL+service)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range creds {
+			md.Set(k, v)
+		}
+	}
+	for key, values := range md {
+		if reservedWebHeader(key) {
+			continue
+		}
+		for _, v := range values {
+			if strings.HasSuffix(key, "-bin") {
+				v = base64.StdEncoding.EncodeToString([]byte(v))
+			}
+			req.Header.Add(key, v)
+		}
+	}
+
+	contentType := c.contentType(unary)
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("User-Agent", userAgent(c.opts.Transport))
+	var timeout time.Duration
+	if deadline, ok := ctx.Deadline(); ok {
+		if timeout = time.Until(deadline); timeout <= 0 {
+			return nil, status.Error(codes.DeadlineExceeded, context.DeadlineExceeded.Error())
+		}
+	}
+	if c.isConnect() {
+		req.Header.Set("Connect-Protocol-Version", "1")
+		if timeout > 0 {
+			req.Header.Set("Connect-Timeout-Ms", strconv.FormatInt(max(timeout.Milliseconds(), 1), 10))
+		}
+	} else {
+		req.Header.Set("Accept", contentType)
+		req.Header.Set("X-Grpc-Web", "1")
+		if timeout > 0 {
+			// grpc-timeout allows at most eight digits
+			req.Header.Set("Grpc-Timeout", strconv.FormatInt(min(max(timeout.Milliseconds(), 1), 99999999), 10)+"m")
+		}
+	}
+	return req, nil
+}
+
+// reservedWebHeader reports whether a metadata key is set by the protocol
+// itself and must not be copied from call metadata
+func reservedWebHeader(key string) bool {
+	switch key {
+	case "content-type", "content-length", "host", "te", "connection", "grpc-timeout",
+		"connect-protocol-version", "connect-timeout-ms", "x-grpc-web":
+		return true
+	}
+	return strings.HasPrefix(key, ":")
+}
+
+// appendFrame appends a length-prefixed frame to b
+func appendFrame(b []byte, flag byte, data []byte) []byte {
+	b = append(b, flag)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(data)))
+	return append(b, data...)
+}
+
+// webStream is a call on a WebConn. It is not safe for concurrent use.
+type webStream struct {
+	conn    *WebConn
+	ctx     context.Context
+	method  string
+	unary   bool
+	maxRecv int
+	maxSend int
+
+	headerAddr  *metadata.MD
+	trailerAddr *metadata.MD
+
+	// pending holds encoded messages that have not been posted yet
+	pending    [][]byte
+	posted     bool
+	sendClosed bool
+
+	// resp is the response being read, nil between responses
+	resp *http.Response
+	// frames reads the frames of resp; unaryBody holds the message of a
+	// unary Connect response until it is read
+	frames       io.Reader
+	unaryBody    []byte
+	hasUnaryBody bool
+
+	header  metadata.MD
+	trailer metadata.MD
+	done    bool
+	// err is the status of a call that failed
+	err error
+}
+
+func (s *webStream) Context() context.Context {
+	return s.ctx
+}
+
+// Header returns the headers of the first response, posting the messages
+// sent so far if no request was made yet
+func (s *webStream) Header() (metadata.MD, error) {
+	if !s.posted && !s.done {
+		s.post()
+	}
+	if s.header == nil && s.err != nil {
+		return nil, s.err
+	}
+	return s.header, nil
+}
+
+func (s *webStream) Trailer() metadata.MD {
+	return s.trailer
+}
+
+func (s *webStream) CloseSend() error {
+	s.sendClosed = true
+	return nil
+}
+
+func (s *webStream) SendMsg(m any) error {
+	if s.done {
+		return io.EOF
+	}
+	if s.sendClosed {
+		return status.Error(codes.Internal, "SendMsg called after CloseSend")
+	}
+	data, err := s.conn.encode(m)
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to encode request: %v", err)
+	}
+	if len(data) > s.maxSend {
+		return status.Errorf(codes.ResourceExhausted, "trying to send message larger than max (%d vs. %d)", len(data), s.maxSend)
+	}
+	s.pending = append(s.pending, data)
+	return nil
+}
+
+// RecvMsg reads the next response message. Messages sent since the last
+// request are posted first once the current response has ended.
+func (s *webStream) RecvMsg(m any) error {
+	for !s.done {
+		if s.resp == nil {
+			if s.posted && len(s.pending) == 0 {
+				s.finish(nil)
+				break
+			}
+			s.post()
+			continue
+		}
+		data, ok, err := s.next()
+		if err != nil {
+			s.finish(err)
+			break
+		}
+		if ok {
+			if err := s.conn.decode(data, m); err != nil {
+				return status.Errorf(codes.Internal, "failed to decode response: %v", err)
+			}
+			return nil
+		}
+	}
+	if s.err != nil {
+		return s.err
+	}
+	return io.EOF
+}
+
+// post sends the pending messages in a new request and opens its response
+func (s *webStream) post() {
+	messages := s.pending
+	s.pending = nil
+	s.posted = true
+
+	req, err := s.conn.newRequest(s.ctx, s.method, s.unary, messages)
+	if err != nil {
+		s.finish(err)
+		return
+	}
+	capture := wireCaptureFrom(s.ctx)
+	if capture != nil {
+		s.captureRequest(capture, req, messages)
+	}
+	resp, err := s.conn.client.Do(req)
+	if err != nil {
+		s.finish(s.transportError(err))
+		return
+	}
+	if capture != nil {
+		pseudo := []WireHeader{{Name: ":status", Value: strconv.Itoa(resp.StatusCode)}}
+		capture.setHeaders(&capture.ResponseHeaders, httpHeaders(pseudo, resp.Header))
+	}
+
+	header, trailer := responseMetadata(resp.Header, s.unary && s.conn.isConnect())
+	if s.header == nil {
+		s.header = header
+		if s.headerAddr != nil {
+			*s.headerAddr = header
+		}
+	}
+	s.trailer = metadata.Join(s.trailer, trailer)
+
+	if err := s.checkResponse(resp); err != nil {
+		resp.Body.Close()
+		s.finish(err)
+		return
+	}
+	s.resp = resp
+
+	switch {
+	case s.unary && s.conn.isConnect():
+		body, err := io.ReadAll(io.LimitReader(resp.Body, int64(s.maxRecv)+1))
+		if err != nil {
+			s.finish(s.transportError(err))
+			return
+		}
+		if len(body) > s.maxRecv {
+			s.finish(status.Errorf(codes.ResourceExhausted, "grpc: received message larger than max (%d vs. %d)", len(body), s.maxRecv))
+			return
+		}
+		s.unaryBody, s.hasUnaryBody = body, true
+		if capture != nil {
+			capture.addFrame(WireFrame{Direction: WireResponse, Length: len(body), Message: body, isJSON: s.conn.isJSON()})
+		}
+	case !s.conn.isConnect() && len(header.Get("grpc-status")) > 0:
+		// A trailers-only response carries its status in the headers
+		s.endResponse(header, grpcWebStatus(header))
+	case s.conn.opts.Protocol == models.ProtocolGRPCWebText:
+		s.frames = &base64Reader{src: bufio.NewReader(resp.Body)}
+	default:
+		s.frames = bufio.NewReader(resp.Body)
+	}
+}
+
+// captureRequest records the headers and message frames of a request
+func (s *webStream) captureRequest(capture *WireCapture, req *http.Request, messages [][]byte) {
+	pseudo := []WireHeader{
+		{Name: ":method", Value: req.Method},
+		{Name: ":path", Value: req.URL.Path},
+		{Name: ":authority", Value: req.Host},
+	}
+	capture.setHeaders(&capture.RequestHeaders, httpHeaders(pseudo, req.Header))
+	enveloped := !s.unary || !s.conn.isConnect()
+	for _, msg := range messages {
+		frame := WireFrame{Direction: WireRequest, Length: len(msg), Message: msg, isJSON: s.conn.isJSON()}
+		if enveloped {
+			frame.Prefix = framePrefix(false, len(msg))
+		}
+		capture.addFrame(frame)
+	}
+}
+
+// isMessageFrame reports whether a frame of the current response carries a
+// message rather than its trailers or end of stream
+func (s *webStream) isMessageFrame(flag byte) bool {
+	if s.conn.isConnect() {
+		return flag&frameConnectEndStream == 0
+	}
+	return flag&frameGRPCWebTrailer == 0
+}
+
+// checkResponse turns a response that does not carry messages into an error
+func (s *webStream) checkResponse(resp *http.Response) error {
+	if resp.StatusCode != http.StatusOK {
+		if s.conn.isConnect() {
+			return connectHTTPError(resp)
+		}
+		return status.Errorf(httpStatusCode(resp.StatusCode), "unexpected HTTP status %s", resp.Status)
+	}
+	want := s.conn.contentType(s.unary)
+	if !s.unary || !s.conn.isConnect() {
+		want, _, _ = strings.Cut(want, "+")
+	}
+	got, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if got != want && !strings.HasPrefix(got, want+"+") {
+		return status.Errorf(codes.Unknown, "server responded with content type %q instead of %s", resp.Header.Get("Content-Type"), want)
+	}
+	return nil
+}
+
+// next returns the next message of the current response. ok is false once
+// the response has ended with an OK status.
+func (s *webStream) next() (data []byte, ok bool, err error) {
+	if s.unary && s.conn.isConnect() {
+		if !s.hasUnaryBody {
+			s.endResponse(nil, nil)
+			return nil, false, nil
+		}
+		data, s.unaryBody, s.hasUnaryBody = s.unaryBody, nil, false
+		return data, true, nil
+	}
+
+	var prefix [5]byte
+	if _, err := io.ReadFull(s.frames, prefix[:]); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, false, status.Error(codes.Internal, "server closed the stream without a status")
+		}
+		return nil, false, s.transportError(err)
+	}
+	flag := prefix[0]
+	length := binary.BigEndian.Uint32(prefix[1:])
+	if int64(length) > int64(s.maxRecv) {
+		return nil, false, status.Errorf(codes.ResourceExhausted, "grpc: received message larger than max (%d vs. %d)", length, s.maxRecv)
+	}
+	data = make([]byte, length)
+	if _, err := io.ReadFull(s.frames, data); err != nil {
+		return nil, false, s.transportError(err)
+	}
+	capture := wireCaptureFrom(s.ctx)
+	if capture != nil && !s.conn.isConnect() && flag&frameGRPCWebTrailer != 0 {
+		// Keep the status, which grpcWebStatus takes out of the trailers
+		capture.setHeaders(&capture.Trailers, metadataHeaders(parseTrailerBlock(data)))
+	}
+	if capture != nil && s.isMessageFrame(flag) {
+		frame := WireFrame{
+			Direction:  WireResponse,
+			Compressed: flag&frameCompressed != 0,
+			Length:     len(data),
+			Prefix:     hex.EncodeToString(prefix[:]),
+			Message:    data,
+			isJSON:     s.conn.isJSON(),
+		}
+		if frame.Compressed {
+			frame.DecodeError = "the message is compressed"
+		}
+		capture.addFrame(frame)
+	}
+
+	switch {
+	case !s.conn.isConnect() && flag&frameGRPCWebTrailer != 0:
+		trailer := parseTrailerBlock(data)
+		err := grpcWebStatus(trailer)
+		s.endResponse(trailer, err)
+		return nil, false, err
+	case s.conn.isConnect() && flag&frameConnectEndStream != 0:
+		trailer, err := parseConnectEndStream(data)
+		s.endResponse(trailer, err)
+		return nil, false, err
+	case flag&frameCompressed != 0:
+		return nil, false, status.Error(codes.Internal, "server sent a compressed message, which is not supported")
+	}
+	return data, true, nil
+}
+
+// endResponse closes the current response and records its trailers
+func (s *webStream) endResponse(trailer metadata.MD, err error) {
+	s.resp.Body.Close()
+	s.resp = nil
+	s.frames = nil
+	s.trailer = metadata.Join(s.trailer, trailer)
+	if err != nil {
+		s.finish(err)
+	}
+}
+
+// finish ends the call with the status err
+func (s *webStream) finish(err error) {
+	if s.resp != nil {
+		s.resp.Body.Close()
+		s.resp = nil
+	}
+	s.done = true
+	s.err = err
+	if s.trailerAddr != nil {
+		*s.trailerAddr = s.trailer
+	}
+}
+
+// transportError converts a failure to send a request or read a response
+// into a status
+func (s *webStream) transportError(err error) error {
+	if ctxErr := s.ctx.Err(); ctxErr != nil {
+		return status.FromContextError(ctxErr).Err()
+	}
+	return status.Error(codes.Unavailable, err.Error())
+}
+
+// responseMetadata converts response headers to metadata. Unary Connect
+// responses carry their trailers as headers prefixed with Trailer-.
+func responseMetadata(h http.Header, connectUnary bool) (header, trailer metadata.MD) {
+	header = metadata.New(nil)
+	trailer = metadata.New(nil)
+	for key, values := range h {
+		key = strings.ToLower(key)
+		target := header
+		if name, ok := strings.CutPrefix(key, "trailer-"); ok && connectUnary {
+			key, target = name, trailer
+		}
+		for _, v := range values {
+			target.Append(key, decodeMetadataValue(key, v))
+		}
+	}
+	return header, trailer
+}
+
+// decodeMetadataValue decodes the base64 value of a binary header
+func decodeMetadataValue(key, value string) string {
+	if !strings.HasSuffix(key, "-bin") {
+		return value
+	}
+	if decoded, err := base64.StdEncoding.DecodeString(value); err == nil {
+		return string(decoded)
+	}
+	if decoded, err := base64.RawStdEncoding.DecodeString(value); err == nil {
+		return string(decoded)
+	}
+	return value
+}
+
+// parseTrailerBlock parses the HTTP/1 style header block of a gRPC-Web
+// trailer frame
+func parseTrailerBlock(data []byte) metadata.MD {
+	md := metadata.New(nil)
+	for _, line := range strings.Split(string(data), "\r\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		md.Append(key, decodeMetadataValue(key, strings.TrimSpace(value)))
+	}
+	return md
+}
+
+// grpcWebStatus takes grpc-status and grpc-message out of md and returns
+// the status they describe
+func grpcWebStatus(md metadata.MD) error {
+	values := md.Get("grpc-status")
+	message := strings.Join(md.Get("grpc-message"), ",")
+	delete(md, "grpc-status")
+	delete(md, "grpc-message")
+	delete(md, "grpc-status-details-bin")
+	if len(values) == 0 {
+		return status.Error(codes.Internal, "server sent trailers without grpc-status")
+	}
+	code, err := strconv.Atoi(values[0])
+	if err != nil {
+		return status.Errorf(codes.Internal, "server sent a malformed grpc-status %q", values[0])
+	}
+	if unescaped, err := url.PathUnescape(message); err == nil {
+		message = unescaped
+	}
+	if codes.Code(code) == codes.OK {
+		return nil
+	}
+	return status.Error(codes.Code(code), message)
+}
+
+// connectError is the JSON error of the Connect protocol
+type connectError struct {
+	Code    string `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// status returns the gRPC status of a Connect error
+func (e *connectError) status() error {
+	for c := codes.Canceled; c <= codes.Unauthenticated; c++ {
+		if connectCode(c) == e.Code {
+			return status.Error(c, e.Message)
+		}
+	}
+	return status.Error(codes.Unknown, e.Message)
+}
+
+// connectCode returns the Connect name of a status code. Connect codes are
+// the gRPC code names in lower snake case, e.g. not_found, except that
+// Connect spells cancelled as canceled.
+func connectCode(c codes.Code) string {
+	if c == codes.Canceled {
+		return "canceled"
+	}
+	return strings.ToLower(statusName(c))
+}
+
+// parseConnectEndStream parses the end-of-stream message of a Connect stream
+func parseConnectEndStream(data []byte) (metadata.MD, error) {
+	var end struct {
+		Error    *connectError       `json:"error"`
+		Metadata map[string][]string `json:"metadata"`
+	}
+	if err := json.Unmarshal(data, &end); err != nil {
+		return nil, status.Errorf(codes.Internal, "server sent a malformed end of stream: %v", err)
+	}
+	trailer := metadata.New(nil)
+	for key, values := range end.Metadata {
+		key = strings.ToLower(key)
+		for _, v := range values {
+			trailer.Append(key, decodeMetadataValue(key, v))
+		}
+	}
+	if end.Error != nil {
+		return trailer, end.Error.status()
+	}
+	return trailer, nil
+}
+
+// connectHTTPError returns the status of a Connect response that is not OK,
+// from its JSON error body if it has one
+func connectHTTPError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyBytes))
+	var e connectError
+	if err := json.Unmarshal(body, &e); err == nil && e.Code != "" {
+		return e.status()
+	}
+	return status.Errorf(httpStatusCode(resp.StatusCode), "unexpected HTTP status %s", resp.Status)
+}
+
+// httpStatusCode maps the HTTP status of a failed response to a gRPC code,
+// as the gRPC over HTTP/2 specification does
+func httpStatusCode(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusBadRequest:
+		return codes.Internal
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.Unimplemented
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return codes.Unavailable
+	default:
+		return codes.Unknown
+	}
+}
+
+// base64Reader decodes a gRPC-Web text body. Servers may encode each frame
+// separately, so padding can appear mid-stream; every four character group
+// is therefore decoded on its own.
+type base64Reader struct {
+	src *bufio.Reader
+	out [3]byte
+	buf []byte
+}
+
+func (r *base64Reader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		var quantum [4]byte
+		if _, err := io.ReadFull(r.src, quantum[:]); err != nil {
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				return 0, errors.New("truncated base64 response")
+			}
+			return 0, err
+		}
+		n, err := base64.StdEncoding.Decode(r.out[:], quantum[:])
+		if err != nil {
+			return 0, fmt.Errorf("malformed base64 response: %w", err)
+		}
+		r.buf = r.out[:n]
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
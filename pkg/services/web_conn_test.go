@@ -0,0 +1,427 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"protodesk/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// webProtocols are the protocols served by webBridge
+var webProtocols = []models.Protocol{
+	models.ProtocolGRPCWeb,
+	models.ProtocolGRPCWebText,
+	models.ProtocolConnect,
+	models.ProtocolConnectJSON,
+}
+
+// rawCodec passes messages through as bytes
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v any) ([]byte, error) { return *v.(*[]byte), nil }
+
+func (rawCodec) Unmarshal(data []byte, v any) error {
+	*v.(*[]byte) = append([]byte(nil), data...)
+	return nil
+}
+
+func (rawCodec) Name() string { return "raw" }
+
+// webBridge serves gRPC-Web and Connect requests by forwarding them to a
+// native gRPC server, the way an Envoy gRPC-Web filter or a Connect handler
+// would. Connect JSON is transcoded with the descriptors of the global
+// registry.
+type webBridge struct {
+	conn *grpc.ClientConn
+
+	mu      sync.Mutex
+	headers []http.Header
+}
+
+// startWebBridge serves a bridge to the test server over HTTP
+func startWebBridge(t *testing.T) (*webBridge, *httptest.Server) {
+	t.Helper()
+	b := &webBridge{conn: dialTestServer(t, startTestServer(t))}
+	server := httptest.NewServer(b)
+	t.Cleanup(server.Close)
+	return b, server
+}
+
+func (b *webBridge) seen() []http.Header {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]http.Header(nil), b.headers...)
+}
+
+func (b *webBridge) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	b.headers = append(b.headers, r.Header.Clone())
+	b.mu.Unlock()
+
+	contentType := r.Header.Get("Content-Type")
+	body, _ := io.ReadAll(r.Body)
+	text := contentType == "application/grpc-web-text+proto"
+	connect := strings.HasPrefix(contentType, "application/connect+")
+	unary := contentType == "application/proto" || contentType == "application/json"
+	asJSON := strings.HasSuffix(contentType, "json")
+	switch {
+	case text:
+		body, _ = base64.StdEncoding.DecodeString(string(body))
+	case connect, unary, contentType == "application/grpc-web+proto":
+	default:
+		http.Error(w, "unsupported content type", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	var requests [][]byte
+	if unary {
+		requests = [][]byte{body}
+	} else {
+		for len(body) >= 5 {
+			n := binary.BigEndian.Uint32(body[1:5])
+			requests = append(requests, body[5:5+n])
+			body = body[5+n:]
+		}
+	}
+	input, output := bridgeMessageTypes(r.URL.Path)
+	if asJSON {
+		for i, msg := range requests {
+			requests[i] = transcode(input, msg, protojson.Unmarshal, proto.Marshal)
+		}
+	}
+
+	md := metadata.New(nil)
+	for key, values := range r.Header {
+		if key = strings.ToLower(key); strings.HasPrefix(key, "x-") || key == "authorization" {
+			md.Append(key, values...)
+		}
+	}
+	ctx := metadata.NewOutgoingContext(r.Context(), md)
+	stream, err := b.conn.NewStream(ctx, &grpc.StreamDesc{ClientStreams: true, ServerStreams: true}, r.URL.Path, grpc.ForceCodec(rawCodec{}))
+	if err == nil {
+		for _, msg := range requests {
+			if err = stream.SendMsg(&msg); err != nil {
+				break
+			}
+		}
+		_ = stream.CloseSend()
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	if header, _ := stream.Header(); header != nil {
+		for key, values := range header {
+			if key != "content-type" {
+				w.Header()[key] = values
+			}
+		}
+	}
+
+	var responses [][]byte
+	for err == nil {
+		var msg []byte
+		if err = stream.RecvMsg(&msg); err != nil {
+			break
+		}
+		if asJSON {
+			msg = transcode(output, msg, proto.Unmarshal, protojson.Marshal)
+		}
+		if unary {
+			responses = append(responses, msg)
+			continue
+		}
+		writeBridgeFrame(w, text, 0, msg)
+	}
+	if errors.Is(err, io.EOF) {
+		err = nil
+	}
+	st := status.Convert(err)
+
+	switch {
+	case unary && st.Code() == codes.OK:
+		for key, values := range stream.Trailer() {
+			w.Header()["Trailer-"+key] = values
+		}
+		_, _ = w.Write(responses[0])
+	case unary:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(connectError{Code: connectCode(st.Code()), Message: st.Message()})
+	case connect:
+		end := map[string]any{"metadata": stream.Trailer()}
+		if st.Code() != codes.OK {
+			end["error"] = connectError{Code: connectCode(st.Code()), Message: st.Message()}
+		}
+		data, _ := json.Marshal(end)
+		writeBridgeFrame(w, false, frameConnectEndStream, data)
+	default:
+		trailer := fmt.Sprintf("grpc-status: %d\r\ngrpc-message: %s\r\n", st.Code(), st.Message())
+		for key, values := range stream.Trailer() {
+			trailer += key + ": " + strings.Join(values, ",") + "\r\n"
+		}
+		writeBridgeFrame(w, text, frameGRPCWebTrailer, []byte(trailer))
+	}
+}
+
+// writeBridgeFrame writes and flushes one frame; text frames are encoded on
+// their own, so padding appears mid-stream
+func writeBridgeFrame(w http.ResponseWriter, text bool, flag byte, data []byte) {
+	frame := appendFrame(nil, flag, data)
+	if text {
+		frame = []byte(base64.StdEncoding.EncodeToString(frame))
+	}
+	_, _ = w.Write(frame)
+	w.(http.Flusher).Flush()
+}
+
+// bridgeMessageTypes looks up the request and response types of a method
+func bridgeMessageTypes(path string) (input, output protoreflect.MessageDescriptor) {
+	service, method, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	d, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(service))
+	if err != nil {
+		return nil, nil
+	}
+	m := d.(protoreflect.ServiceDescriptor).Methods().ByName(protoreflect.Name(method))
+	if m == nil {
+		return nil, nil
+	}
+	return m.Input(), m.Output()
+}
+
+// transcode converts a message between the JSON and binary encodings
+func transcode(md protoreflect.MessageDescriptor, data []byte, unmarshal func([]byte, proto.Message) error, marshal func(proto.Message) ([]byte, error)) []byte {
+	if md == nil {
+		return data
+	}
+	msg := dynamicpb.NewMessage(md)
+	if err := unmarshal(data, msg); err != nil {
+		return data
+	}
+	out, _ := marshal(msg)
+	return out
+}
+
+func TestServerProfileManager_WebProtocols(t *testing.T) {
+	for _, protocol := range webProtocols {
+		t.Run(string(protocol), func(t *testing.T) {
+			bridge, server := startWebBridge(t)
+			store, cleanup := setupTestStore(t)
+			defer cleanup()
+			manager := NewServerProfileManager(store, nil)
+			defer manager.DisconnectAll()
+			ctx := context.Background()
+
+			host, port := splitTestAddr(t, strings.TrimPrefix(server.URL, "http://"))
+			profile := models.NewServerProfile(string(protocol), host, port)
+			profile.Protocol = protocol
+			profile.Headers = []models.Header{{Key: "x-team", Value: "payments"}}
+			profile.Transport = &models.TransportSettings{UserAgent: "protodesk-test/1.0"}
+			require.NoError(t, store.Create(ctx, profile))
+
+			stored, err := store.Get(ctx, profile.ID)
+			require.NoError(t, err)
+			assert.Equal(t, protocol, stored.Protocol)
+
+			require.NoError(t, manager.Connect(ctx, profile.ID))
+			require.NoError(t, manager.WaitForReady(ctx, profile.ID))
+			assert.True(t, manager.IsConnected(profile.ID))
+
+			result, err := manager.Invoke(ctx, profile.ID, InvocationRequest{
+				ServiceName: "grpc.health.v1.Health",
+				MethodName:  "Check",
+				RequestJSON: `{"service":"test.Service"}`,
+				Metadata:    metadata.Pairs("x-request-id", "42"),
+			})
+			require.NoError(t, err)
+			require.NoError(t, result.Err())
+			assert.JSONEq(t, `{"status":"SERVING"}`, result.ResponseJSON)
+
+			result, err = manager.Invoke(ctx, profile.ID, InvocationRequest{
+				ServiceName: "grpc.health.v1.Health",
+				MethodName:  "Check",
+				RequestJSON: `{"service":"missing.Service"}`,
+			})
+			require.NoError(t, err)
+			assert.Equal(t, "NOT_FOUND", result.StatusName)
+
+			conn, err := manager.GetConnection(profile.ID)
+			require.NoError(t, err)
+			services, err := manager.GetGRPCClient().ListServicesAndMethods(conn)
+			require.NoError(t, err)
+			assert.Contains(t, services["grpc.health.v1.Health"], "Watch")
+
+			err = conn.Invoke(ctx, "/grpc.health.v1.Health/Missing", &healthpb.HealthCheckRequest{}, &healthpb.HealthCheckResponse{})
+			assert.Equal(t, codes.Unimplemented, status.Code(err))
+
+			headers := bridge.seen()
+			require.NotEmpty(t, headers)
+			last := headers[len(headers)-1]
+			assert.Equal(t, "payments", last.Get("X-Team"))
+			assert.Equal(t, "protodesk/0.1.0 protodesk-test/1.0", last.Get("User-Agent"))
+			var sawRequestID bool
+			for _, h := range headers {
+				sawRequestID = sawRequestID || h.Get("X-Request-Id") == "42"
+			}
+			assert.True(t, sawRequestID)
+
+			require.NoError(t, manager.Disconnect(ctx, profile.ID))
+			assert.False(t, manager.IsConnected(profile.ID))
+		})
+	}
+}
+
+func TestServerProfileManager_ProfileHeadersSentOnce(t *testing.T) {
+	var mu sync.Mutex
+	var native []string
+	nativeAddr := startTestServer(t, grpc.UnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		mu.Lock()
+		native = md.Get("x-team")
+		mu.Unlock()
+		return handler(ctx, req)
+	}))
+	bridge, server := startWebBridge(t)
+
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+	manager := NewServerProfileManager(store, nil)
+	defer manager.DisconnectAll()
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		protocol models.Protocol
+		addr     string
+		received func() []string
+	}{
+		{models.ProtocolGRPC, nativeAddr, func() []string {
+			mu.Lock()
+			defer mu.Unlock()
+			return native
+		}},
+		{models.ProtocolGRPCWeb, strings.TrimPrefix(server.URL, "http://"), func() []string {
+			headers := bridge.seen()
+			return headers[len(headers)-1].Values("X-Team")
+		}},
+	} {
+		t.Run(string(tc.protocol), func(t *testing.T) {
+			host, port := splitTestAddr(t, tc.addr)
+			profile := models.NewServerProfile(string(tc.protocol), host, port)
+			profile.Protocol = tc.protocol
+			profile.Headers = []models.Header{{Key: "X-Team", Value: "payments"}}
+			require.NoError(t, store.Create(ctx, profile))
+			require.NoError(t, manager.Connect(ctx, profile.ID))
+			require.NoError(t, manager.WaitForReady(ctx, profile.ID))
+
+			invoke := func(md metadata.MD) []string {
+				result, err := manager.Invoke(ctx, profile.ID, InvocationRequest{
+					ServiceName: "grpc.health.v1.Health",
+					MethodName:  "Check",
+					RequestJSON: `{"service":"test.Service"}`,
+					Metadata:    md,
+				})
+				require.NoError(t, err)
+				require.NoError(t, result.Err())
+				return tc.received()
+			}
+			// Headless calls carry the profile headers, and calls that
+			// already copied them do not send them twice
+			assert.Equal(t, []string{"payments"}, invoke(nil))
+			assert.Equal(t, []string{"payments"}, invoke(metadata.Pairs("x-team", "payments")))
+			// A call's own value replaces the profile's
+			assert.Equal(t, []string{"billing"}, invoke(metadata.Pairs("x-team", "billing")))
+		})
+	}
+}
+
+func TestWebConn_ServerStreaming(t *testing.T) {
+	for _, protocol := range webProtocols {
+		t.Run(string(protocol), func(t *testing.T) {
+			_, server := startWebBridge(t)
+			conn, err := NewWebConn(WebConnOptions{Protocol: protocol, BaseURL: server.URL})
+			require.NoError(t, err)
+			defer conn.Close()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			desc := &grpc.StreamDesc{ServerStreams: true}
+			stream, err := conn.NewStream(ctx, desc, "/grpc.health.v1.Health/Watch")
+			require.NoError(t, err)
+			require.NoError(t, stream.SendMsg(&healthpb.HealthCheckRequest{Service: "test.Service"}))
+			require.NoError(t, stream.CloseSend())
+
+			// Watch sends the current status and then waits for changes
+			var resp healthpb.HealthCheckResponse
+			require.NoError(t, stream.RecvMsg(&resp))
+			assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+			header, err := stream.Header()
+			require.NoError(t, err)
+			assert.NotNil(t, header)
+
+			cancel()
+			assert.Equal(t, codes.Canceled, status.Code(stream.RecvMsg(&resp)))
+		})
+	}
+}
+
+func TestWebConn_ConnectErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/test.Service/Denied":
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = io.WriteString(w, `{"code":"permission_denied","message":"no access"}`)
+		case "/test.Service/Canceled":
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(499)
+			_, _ = io.WriteString(w, `{"code":"canceled"}`)
+		default:
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	conn, err := NewWebConn(WebConnOptions{Protocol: models.ProtocolConnect, BaseURL: server.URL})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	err = conn.Invoke(ctx, "/test.Service/Denied", &healthpb.HealthCheckRequest{}, &healthpb.HealthCheckResponse{})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	assert.Equal(t, "no access", status.Convert(err).Message())
+
+	// Connect spells the code canceled, gRPC CANCELLED
+	err = conn.Invoke(ctx, "/test.Service/Canceled", &healthpb.HealthCheckRequest{}, &healthpb.HealthCheckResponse{})
+	assert.Equal(t, codes.Canceled, status.Code(err))
+	assert.Equal(t, "CANCELLED", statusName(status.Code(err)))
+
+	// A body that is not a Connect error falls back to the HTTP status
+	err = conn.Invoke(ctx, "/test.Service/Missing", &healthpb.HealthCheckRequest{}, &healthpb.HealthCheckResponse{})
+	assert.Equal(t, codes.Unimplemented, status.Code(err))
+}
+
+func TestNewWebConn_Invalid(t *testing.T) {
+	_, err := NewWebConn(WebConnOptions{Protocol: models.ProtocolGRPC, BaseURL: "http://localhost:8080"})
+	assert.ErrorIs(t, err, models.ErrInvalidProtocol)
+	_, err = NewWebConn(WebConnOptions{Protocol: models.ProtocolConnect, BaseURL: "localhost:8080"})
+	assert.ErrorIs(t, err, models.ErrInvalidProtocol)
+}
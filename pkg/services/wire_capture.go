@@ -0,0 +1,520 @@
+package services
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic"
+	"google.golang.org/grpc/encoding"
+	grpcproto "google.golang.org/grpc/encoding/proto"
+	"google.golang.org/grpc/mem"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// Directions of a captured frame
+const (
+	WireRequest  = "request"
+	WireResponse = "response"
+)
+
+// maxWireDepth bounds how deep the wire breakdown follows nested messages
+const maxWireDepth = 32
+
+// WireCapture records what a call put on and read from the wire: the
+// HTTP/2 headers and trailers and every length-prefixed message frame
+type WireCapture struct {
+	RequestHeaders  []WireHeader `json:"requestHeaders"`
+	ResponseHeaders []WireHeader `json:"responseHeaders"`
+	Trailers        []WireHeader `json:"trailers"`
+	Frames          []WireFrame  `json:"frames"`
+
+	mu sync.Mutex
+	// compression is the message encoding of each direction, as reported
+	// by the gRPC stats handler
+	compression map[string]string
+	// wireLengths are the compressed lengths of each direction's messages,
+	// in order, as reported by the gRPC stats handler
+	wireLengths map[string][]int
+}
+
+// WireHeader is a header or trailer field
+type WireHeader struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// WireFrame is a message frame. Message holds the serialized message after
+// decompression; Length and Compressed are taken from the frame prefix.
+type WireFrame struct {
+	Direction  string `json:"direction"`
+	Compressed bool   `json:"compressed"`
+	// Length is the length in the frame prefix, i.e. of the message as sent
+	Length int `json:"length"`
+	// Prefix is the five byte frame prefix in hex, or empty for unary
+	// Connect calls, which send the message as the whole body
+	Prefix  string `json:"prefix,omitempty"`
+	Message []byte `json:"message"`
+	// Hex is a hex dump of Message with offsets and printable characters
+	Hex string `json:"hex"`
+	// Fields breaks Message down by tag and wire type, naming the fields
+	// known to the method's descriptors
+	Fields []WireField `json:"fields,omitempty"`
+	// JSON is the message decoded with the method's descriptors
+	JSON string `json:"json,omitempty"`
+	// DecodeError explains why Fields or JSON are missing or incomplete
+	DecodeError string `json:"decodeError,omitempty"`
+
+	// isJSON marks the messages of Connect JSON calls
+	isJSON bool
+}
+
+// WireField is one tag-value pair of a serialized message
+type WireField struct {
+	Number int32 `json:"number"`
+	// WireType is varint, i64, len, sgroup, egroup or i32
+	WireType string `json:"wireType"`
+	// Name is the field name, empty for unknown fields
+	Name    string `json:"name,omitempty"`
+	Unknown bool   `json:"unknown,omitempty"`
+	// Offset and Length locate the field, including its tag, in the
+	// serialized message
+	Offset int    `json:"offset"`
+	Length int    `json:"length"`
+	Value  string `json:"value,omitempty"`
+	// Fields holds the fields of a nested message or group. Unknown
+	// length-delimited values are broken down when they parse as a message.
+	Fields []WireField `json:"fields,omitempty"`
+}
+
+type wireCaptureKey struct{}
+
+// withWireCapture returns a context whose calls are recorded in capture by
+// WebConn and by the stats handler of native connections
+func withWireCapture(ctx context.Context, capture *WireCapture) context.Context {
+	return context.WithValue(ctx, wireCaptureKey{}, capture)
+}
+
+// wireCaptureFrom returns the capture of a call's context, or nil
+func wireCaptureFrom(ctx context.Context) *WireCapture {
+	capture, _ := ctx.Value(wireCaptureKey{}).(*WireCapture)
+	return capture
+}
+
+// addFrame records a frame of a message seen by the capture codec or WebConn
+func (c *WireCapture) addFrame(frame WireFrame) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Frames = append(c.Frames, frame)
+}
+
+// setHeaders replaces the headers at dst
+func (c *WireCapture) setHeaders(dst *[]WireHeader, headers []WireHeader) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	*dst = headers
+}
+
+// setCompression records the message encoding of a direction
+func (c *WireCapture) setCompression(direction, name string) {
+	if name == "" || name == "identity" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.compression == nil {
+		c.compression = make(map[string]string)
+	}
+	c.compression[direction] = name
+}
+
+// addWireLength records the length of the next message of a direction as
+// sent in its frame
+func (c *WireCapture) addWireLength(direction string, length int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.wireLengths == nil {
+		c.wireLengths = make(map[string][]int)
+	}
+	c.wireLengths[direction] = append(c.wireLengths[direction], length)
+}
+
+// decode fills in the frame details the stats handler reported and the hex,
+// field and JSON views of every frame, resolving Any payloads with types
+func (c *WireCapture) decode(input, output *desc.MessageDescriptor, types *typeResolver) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	seen := map[string]int{}
+	for i := range c.Frames {
+		frame := &c.Frames[i]
+		if lengths := c.wireLengths[frame.Direction]; seen[frame.Direction] < len(lengths) {
+			frame.Length = lengths[seen[frame.Direction]]
+			frame.Compressed = c.compression[frame.Direction] != ""
+			frame.Prefix = framePrefix(frame.Compressed, frame.Length)
+		}
+		seen[frame.Direction]++
+
+		md := output
+		if frame.Direction == WireRequest {
+			md = input
+		}
+		frame.decode(md, types)
+	}
+}
+
+// decode fills in the hex, field and JSON views of a frame
+func (f *WireFrame) decode(md *desc.MessageDescriptor, types *typeResolver) {
+	f.Hex = hex.Dump(f.Message)
+	if f.DecodeError != "" {
+		return
+	}
+	if f.isJSON {
+		f.JSON = string(f.Message)
+		return
+	}
+	fields, err := wireFields(f.Message, md, 0)
+	f.Fields = fields
+	if err != nil {
+		f.DecodeError = err.Error()
+		return
+	}
+	if md == nil {
+		return
+	}
+	msg := dynamic.NewMessage(md)
+	if err := msg.Unmarshal(f.Message); err != nil {
+		f.DecodeError = fmt.Sprintf("failed to decode %s: %v", md.GetFullyQualifiedName(), err)
+		return
+	}
+	data, err := msg.MarshalJSONPB(&jsonpb.Marshaler{AnyResolver: types})
+	if err != nil {
+		f.DecodeError = err.Error()
+		return
+	}
+	f.JSON = string(data)
+}
+
+// framePrefix returns the hex of a length-prefixed frame's prefix
+func framePrefix(compressed bool, length int) string {
+	var prefix [5]byte
+	if compressed {
+		prefix[0] = frameCompressed
+	}
+	binary.BigEndian.PutUint32(prefix[1:], uint32(length))
+	return hex.EncodeToString(prefix[:])
+}
+
+// finish completes the headers of a call from its result where no stats
+// handler or WebConn recorded them, i.e. on connections made elsewhere
+func (c *WireCapture) finish(method string, md metadata.MD, result *InvocationResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.RequestHeaders == nil {
+		c.RequestHeaders = append([]WireHeader{{Name: ":path", Value: method}}, metadataHeaders(md)...)
+	}
+	if c.ResponseHeaders == nil {
+		c.ResponseHeaders = metadataHeaders(result.Headers)
+	}
+	if c.Trailers == nil {
+		c.Trailers = metadataHeaders(result.Trailers)
+	}
+}
+
+// httpHeaders lists HTTP headers with lower case names after any
+// pseudo-headers, as HTTP/2 sends them
+func httpHeaders(pseudo []WireHeader, h http.Header) []WireHeader {
+	md := metadata.MD{}
+	for name, values := range h {
+		md[strings.ToLower(name)] = values
+	}
+	return append(pseudo, metadataHeaders(md)...)
+}
+
+// metadataHeaders lists metadata as headers sorted by name, keeping the
+// order of repeated values
+func metadataHeaders(md metadata.MD) []WireHeader {
+	names := make([]string, 0, len(md))
+	for name := range md {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var headers []WireHeader
+	for _, name := range names {
+		for _, v := range md[name] {
+			headers = append(headers, WireHeader{Name: name, Value: v})
+		}
+	}
+	return headers
+}
+
+// wireFields breaks a serialized message down into its fields. md names
+// the known fields and may be nil. Fields read before malformed data are
+// returned with the error.
+func wireFields(data []byte, md *desc.MessageDescriptor, depth int) ([]WireField, error) {
+	var fields []WireField
+	for offset := 0; offset < len(data); {
+		num, typ, tagLen := protowire.ConsumeTag(data[offset:])
+		if tagLen < 0 {
+			return fields, fmt.Errorf("invalid tag at offset %d: %w", offset, protowire.ParseError(tagLen))
+		}
+		valueLen := protowire.ConsumeFieldValue(num, typ, data[offset+tagLen:])
+		if valueLen < 0 {
+			return fields, fmt.Errorf("invalid value of field %d at offset %d: %w", num, offset, protowire.ParseError(valueLen))
+		}
+		field := WireField{
+			Number:   int32(num),
+			WireType: wireTypeName(typ),
+			Offset:   offset,
+			Length:   tagLen + valueLen,
+		}
+		var fd *desc.FieldDescriptor
+		if md != nil {
+			fd = md.FindFieldByNumber(int32(num))
+		}
+		if fd != nil {
+			field.Name = fd.GetName()
+		} else {
+			field.Unknown = true
+		}
+		wireValue(&field, typ, data[offset+tagLen:offset+tagLen+valueLen], fd, depth)
+		fields = append(fields, field)
+		offset += tagLen + valueLen
+	}
+	return fields, nil
+}
+
+// wireValue fills in the value or nested fields of a field
+func wireValue(field *WireField, typ protowire.Type, value []byte, fd *desc.FieldDescriptor, depth int) {
+	var kind descriptorpb.FieldDescriptorProto_Type
+	if fd != nil {
+		kind = fd.GetType()
+	}
+	switch typ {
+	case protowire.VarintType:
+		v, _ := protowire.ConsumeVarint(value)
+		field.Value = varintValue(v, kind, fd)
+	case protowire.Fixed32Type:
+		v, _ := protowire.ConsumeFixed32(value)
+		switch kind {
+		case descriptorpb.FieldDescriptorProto_TYPE_FLOAT:
+			field.Value = strconv.FormatFloat(float64(math.Float32frombits(v)), 'g', -1, 32)
+		case descriptorpb.FieldDescriptorProto_TYPE_SFIXED32:
+			field.Value = strconv.FormatInt(int64(int32(v)), 10)
+		default:
+			field.Value = strconv.FormatUint(uint64(v), 10)
+		}
+	case protowire.Fixed64Type:
+		v, _ := protowire.ConsumeFixed64(value)
+		switch kind {
+		case descriptorpb.FieldDescriptorProto_TYPE_DOUBLE:
+			field.Value = strconv.FormatFloat(math.Float64frombits(v), 'g', -1, 64)
+		case descriptorpb.FieldDescriptorProto_TYPE_SFIXED64:
+			field.Value = strconv.FormatInt(int64(v), 10)
+		default:
+			field.Value = strconv.FormatUint(v, 10)
+		}
+	case protowire.BytesType:
+		v, _ := protowire.ConsumeBytes(value)
+		field.Value, field.Fields = bytesValue(v, kind, fd, depth)
+	case protowire.StartGroupType:
+		v, _ := protowire.ConsumeGroup(protowire.Number(field.Number), value)
+		var md *desc.MessageDescriptor
+		if fd != nil {
+			md = fd.GetMessageType()
+		}
+		if depth < maxWireDepth {
+			field.Fields, _ = wireFields(v, md, depth+1)
+		}
+	}
+}
+
+// varintValue formats a varint as the type of its field, or as an unsigned
+// number for unknown fields
+func varintValue(v uint64, kind descriptorpb.FieldDescriptorProto_Type, fd *desc.FieldDescriptor) string {
+	switch kind {
+	case descriptorpb.FieldDescriptorProto_TYPE_INT32, descriptorpb.FieldDescriptorProto_TYPE_INT64:
+		return strconv.FormatInt(int64(v), 10)
+	case descriptorpb.FieldDescriptorProto_TYPE_SINT32, descriptorpb.FieldDescriptorProto_TYPE_SINT64:
+		return strconv.FormatInt(protowire.DecodeZigZag(v), 10)
+	case descriptorpb.FieldDescriptorProto_TYPE_BOOL:
+		return strconv.FormatBool(v != 0)
+	case descriptorpb.FieldDescriptorProto_TYPE_ENUM:
+		number := strconv.FormatInt(int64(int32(v)), 10)
+		if ev := fd.GetEnumType().FindValueByNumber(int32(v)); ev != nil {
+			return number + " (" + ev.GetName() + ")"
+		}
+		return number
+	}
+	return strconv.FormatUint(v, 10)
+}
+
+// bytesValue formats a length-delimited value as the type of its field.
+// Unknown values are broken down if they parse as a message and shown as
+// text if printable, or else as hex.
+func bytesValue(v []byte, kind descriptorpb.FieldDescriptorProto_Type, fd *desc.FieldDescriptor, depth int) (string, []WireField) {
+	switch kind {
+	case descriptorpb.FieldDescriptorProto_TYPE_MESSAGE:
+		if depth < maxWireDepth {
+			fields, err := wireFields(v, fd.GetMessageType(), depth+1)
+			if err == nil {
+				return "", fields
+			}
+		}
+	case descriptorpb.FieldDescriptorProto_TYPE_STRING:
+		return strconv.Quote(string(v)), nil
+	case 0:
+		if len(v) > 0 && depth < maxWireDepth {
+			if fields, err := wireFields(v, nil, depth+1); err == nil && !printable(v) {
+				return "", fields
+			}
+		}
+		if printable(v) {
+			return strconv.Quote(string(v)), nil
+		}
+	}
+	// Bytes, packed scalars and malformed values
+	return hex.EncodeToString(v), nil
+}
+
+// printable reports whether b is valid UTF-8 text without control characters
+func printable(b []byte) bool {
+	if !utf8.Valid(b) {
+		return false
+	}
+	for _, r := range string(b) {
+		if !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// wireTypeName returns the name the protobuf encoding guide uses for a wire
+// type
+func wireTypeName(typ protowire.Type) string {
+	switch typ {
+	case protowire.VarintType:
+		return "varint"
+	case protowire.Fixed64Type:
+		return "i64"
+	case protowire.BytesType:
+		return "len"
+	case protowire.StartGroupType:
+		return "sgroup"
+	case protowire.EndGroupType:
+		return "egroup"
+	case protowire.Fixed32Type:
+		return "i32"
+	}
+	return strconv.Itoa(int(typ))
+}
+
+// captureCodec is the proto codec of a captured native call, recording the
+// serialized messages before compression and after decompression
+type captureCodec struct {
+	capture *WireCapture
+}
+
+func (c captureCodec) Marshal(v any) (mem.BufferSlice, error) {
+	data, err := encoding.GetCodecV2(grpcproto.Name).Marshal(v)
+	if err == nil {
+		message := data.Materialize()
+		c.capture.addFrame(WireFrame{
+			Direction: WireRequest,
+			Length:    len(message),
+			Prefix:    framePrefix(false, len(message)),
+			Message:   message,
+		})
+	}
+	return data, err
+}
+
+func (c captureCodec) Unmarshal(data mem.BufferSlice, v any) error {
+	message := data.Materialize()
+	c.capture.addFrame(WireFrame{
+		Direction: WireResponse,
+		Length:    len(message),
+		Prefix:    framePrefix(false, len(message)),
+		Message:   message,
+	})
+	return encoding.GetCodecV2(grpcproto.Name).Unmarshal(data, v)
+}
+
+// Name is empty so a forced captureCodec keeps the content type of an
+// ordinary call, application/grpc
+func (captureCodec) Name() string {
+	return ""
+}
+
+// wireCaptureHandler is the stats handler of native connections. It records
+// the headers, trailers, compression and frame lengths of captured calls and
+// does nothing for the others.
+type wireCaptureHandler struct{}
+
+func (wireCaptureHandler) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (wireCaptureHandler) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	capture := wireCaptureFrom(ctx)
+	if capture == nil || !s.IsClient() {
+		return
+	}
+	switch s := s.(type) {
+	case *stats.OutHeader:
+		pseudo := []WireHeader{
+			{Name: ":method", Value: "POST"},
+			{Name: ":path", Value: s.FullMethod},
+			{Name: "content-type", Value: "application/grpc"},
+		}
+		if s.Compression != "" {
+			pseudo = append(pseudo, WireHeader{Name: "grpc-encoding", Value: s.Compression})
+		}
+		capture.setCompression(WireRequest, s.Compression)
+		capture.setHeaders(&capture.RequestHeaders, append(pseudo, metadataHeaders(s.Header)...))
+	case *stats.InHeader:
+		var pseudo []WireHeader
+		if s.Compression != "" {
+			pseudo = append(pseudo, WireHeader{Name: "grpc-encoding", Value: s.Compression})
+		}
+		capture.setCompression(WireResponse, s.Compression)
+		capture.setHeaders(&capture.ResponseHeaders, append(pseudo, metadataHeaders(s.Header)...))
+	case *stats.InTrailer:
+		capture.setHeaders(&capture.Trailers, metadataHeaders(s.Trailer))
+	case *stats.OutPayload:
+		capture.addWireLength(WireRequest, s.CompressedLength)
+	case *stats.InPayload:
+		capture.addWireLength(WireResponse, s.CompressedLength)
+	case *stats.End:
+		// gRPC keeps the status out of the trailer metadata
+		st := status.Convert(s.Error)
+		trailer := []WireHeader{{Name: "grpc-status", Value: strconv.Itoa(int(st.Code()))}}
+		if st.Message() != "" {
+			trailer = append(trailer, WireHeader{Name: "grpc-message", Value: st.Message()})
+		}
+		capture.mu.Lock()
+		capture.Trailers = append(trailer, capture.Trailers...)
+		capture.mu.Unlock()
+	}
+}
+
+func (wireCaptureHandler) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (wireCaptureHandler) HandleConn(context.Context, stats.ConnStats) {}
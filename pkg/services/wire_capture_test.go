@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"protodesk/pkg/models"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// headerValue returns the first value of a captured header, or ""
+func headerValue(headers []WireHeader, name string) string {
+	for _, h := range headers {
+		if h.Name == name {
+			return h.Value
+		}
+	}
+	return ""
+}
+
+func TestServerProfileManager_InvokeCapture(t *testing.T) {
+	addr := startTestServer(t)
+	store, cleanup := setupTestStore(t)
+	t.Cleanup(cleanup)
+	manager := NewServerProfileManager(store, nil)
+	t.Cleanup(manager.DisconnectAll)
+	ctx := context.Background()
+
+	host, port := splitTestAddr(t, addr)
+	profile := models.NewServerProfile("gzip", host, port)
+	profile.Transport = &models.TransportSettings{Compression: models.CompressionGzip}
+	require.NoError(t, store.Create(ctx, profile))
+	require.NoError(t, manager.Connect(ctx, profile.ID))
+
+	result, err := manager.Invoke(ctx, profile.ID, InvocationRequest{
+		ServiceName: "grpc.health.v1.Health",
+		MethodName:  "Check",
+		RequestJSON: `{"service":"test.Service"}`,
+		Metadata:    metadata.Pairs("x-request-id", "42"),
+		Capture:     true,
+	})
+	require.NoError(t, err)
+	require.NoError(t, result.Err())
+	capture := result.Capture
+	require.NotNil(t, capture)
+
+	assert.Equal(t, "/grpc.health.v1.Health/Check", headerValue(capture.RequestHeaders, ":path"))
+	assert.Equal(t, "gzip", headerValue(capture.RequestHeaders, "grpc-encoding"))
+	assert.Equal(t, "42", headerValue(capture.RequestHeaders, "x-request-id"))
+	assert.Equal(t, "application/grpc", headerValue(capture.ResponseHeaders, "content-type"))
+	assert.Equal(t, "0", headerValue(capture.Trailers, "grpc-status"))
+
+	require.Len(t, capture.Frames, 2)
+	request := capture.Frames[0]
+	assert.Equal(t, WireRequest, request.Direction)
+	assert.True(t, request.Compressed)
+	assert.True(t, strings.HasPrefix(request.Prefix, "01"))
+	assert.Equal(t, "\n\ftest.Service", string(request.Message))
+	assert.Contains(t, request.Hex, "0a 0c 74 65 73 74")
+	assert.Equal(t, []WireField{{
+		Number: 1, WireType: "len", Name: "service", Length: 14, Value: `"test.Service"`,
+	}}, request.Fields)
+	assert.JSONEq(t, `{"service":"test.Service"}`, request.JSON)
+
+	response := capture.Frames[1]
+	assert.Equal(t, WireResponse, response.Direction)
+	require.Len(t, response.Fields, 1)
+	assert.Equal(t, "1 (SERVING)", response.Fields[0].Value)
+	assert.JSONEq(t, `{"status":"SERVING"}`, response.JSON)
+
+	// Calls that do not ask for a capture are not recorded
+	result, err = manager.Invoke(ctx, profile.ID, InvocationRequest{
+		ServiceName: "grpc.health.v1.Health",
+		MethodName:  "Check",
+		RequestJSON: `{"service":"test.Service"}`,
+	})
+	require.NoError(t, err)
+	assert.Nil(t, result.Capture)
+}
+
+func TestServerProfileManager_InvokeCaptureWeb(t *testing.T) {
+	_, server := startWebBridge(t)
+	store, cleanup := setupTestStore(t)
+	t.Cleanup(cleanup)
+	manager := NewServerProfileManager(store, nil)
+	t.Cleanup(manager.DisconnectAll)
+	ctx := context.Background()
+
+	host, port := splitTestAddr(t, strings.TrimPrefix(server.URL, "http://"))
+	profile := models.NewServerProfile("web", host, port)
+	profile.Protocol = models.ProtocolGRPCWeb
+	require.NoError(t, store.Create(ctx, profile))
+	require.NoError(t, manager.Connect(ctx, profile.ID))
+
+	result, err := manager.Invoke(ctx, profile.ID, InvocationRequest{
+		ServiceName: "grpc.health.v1.Health",
+		MethodName:  "Check",
+		RequestJSON: `{"service":"test.Service"}`,
+		Capture:     true,
+	})
+	require.NoError(t, err)
+	require.NoError(t, result.Err())
+	capture := result.Capture
+	require.NotNil(t, capture)
+
+	assert.Equal(t, "/grpc.health.v1.Health/Check", headerValue(capture.RequestHeaders, ":path"))
+	assert.Equal(t, "application/grpc-web+proto", headerValue(capture.RequestHeaders, "content-type"))
+	assert.Equal(t, "200", headerValue(capture.ResponseHeaders, ":status"))
+	assert.Equal(t, "0", headerValue(capture.Trailers, "grpc-status"))
+	require.Len(t, capture.Frames, 2)
+	assert.Equal(t, "000000000e", capture.Frames[0].Prefix)
+	assert.JSONEq(t, `{"service":"test.Service"}`, capture.Frames[0].JSON)
+	assert.JSONEq(t, `{"status":"SERVING"}`, capture.Frames[1].JSON)
+}
+
+func TestWireFields_Unknown(t *testing.T) {
+	md, err := desc.LoadMessageDescriptorForMessage(&healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+
+	var nested []byte
+	nested = protowire.AppendTag(nested, 1, protowire.VarintType)
+	nested = protowire.AppendVarint(nested, 150)
+	var data []byte
+	data = protowire.AppendTag(data, 1, protowire.BytesType)
+	data = protowire.AppendString(data, "svc")
+	data = protowire.AppendTag(data, 7, protowire.Fixed32Type)
+	data = protowire.AppendFixed32(data, 5)
+	data = protowire.AppendTag(data, 8, protowire.BytesType)
+	data = protowire.AppendBytes(data, nested)
+	data = protowire.AppendTag(data, 9, protowire.BytesType)
+	data = protowire.AppendString(data, "hi there")
+
+	fields, err := wireFields(data, md, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []WireField{
+		{Number: 1, WireType: "len", Name: "service", Offset: 0, Length: 5, Value: `"svc"`},
+		{Number: 7, WireType: "i32", Unknown: true, Offset: 5, Length: 5, Value: "5"},
+		{Number: 8, WireType: "len", Unknown: true, Offset: 10, Length: 5, Fields: []WireField{
+			{Number: 1, WireType: "varint", Unknown: true, Offset: 0, Length: 3, Value: "150"},
+		}},
+		{Number: 9, WireType: "len", Unknown: true, Offset: 15, Length: 10, Value: `"hi there"`},
+	}, fields)
+
+	fields, err = wireFields(append(data, 0x0a, 0x05), md, 0)
+	assert.ErrorContains(t, err, "offset 25")
+	assert.Len(t, fields, 4)
+}
@@ -0,0 +1,865 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"protodesk/pkg/logging"
+	"protodesk/pkg/models"
+	"protodesk/pkg/models/proto"
+	"protodesk/pkg/secrets"
+
+	"github.com/google/uuid"
+	"sigs.k8s.io/yaml"
+)
+
+// ExportOptions selects what ExportWorkspace writes
+type ExportOptions struct {
+	// ProfileIDs are the profiles to export; empty exports every profile
+	ProfileIDs []string
+	// StripSecrets leaves secret header values and auth credentials out,
+	// along with the values of sensitive saved request headers
+	StripSecrets bool
+	// BaseDir is the directory of the workspace file. Proto paths inside it
+	// are written relative to it so the file works from any checkout.
+	BaseDir string
+}
+
+// ImportOptions controls how ImportWorkspace merges a workspace file
+type ImportOptions struct {
+	// Overwrite replaces records that conflict with the file instead of
+	// keeping the local version
+	Overwrite bool
+	// DryRun reports what an import would do without changing anything
+	DryRun bool
+	// BaseDir is the directory relative proto paths are resolved against
+	BaseDir string
+}
+
+// ImportAction is what an import did, or would do, with one record
+type ImportAction string
+
+const (
+	// ImportCreated means the record did not exist locally and was added
+	ImportCreated ImportAction = "created"
+	// ImportUnchanged means an identical record with the same name exists
+	ImportUnchanged ImportAction = "unchanged"
+	// ImportUpdated means a conflicting local record was overwritten
+	ImportUpdated ImportAction = "updated"
+	// ImportConflict means a local record with the same name differs and was kept
+	ImportConflict ImportAction = "conflict"
+	// ImportFailed means the record in the file is invalid and was skipped
+	ImportFailed ImportAction = "failed"
+)
+
+// ImportItem describes the outcome for one record of a workspace file
+type ImportItem struct {
+	// Kind is profile, protoPath, requestHeaders, collection or request
+	Kind   string       `json:"kind"`
+	Name   string       `json:"name"`
+	Action ImportAction `json:"action"`
+	Detail string       `json:"detail,omitempty"`
+}
+
+// ImportReport lists what ImportWorkspace did with each record
+type ImportReport struct {
+	DryRun bool         `json:"dryRun"`
+	Items  []ImportItem `json:"items"`
+	// NewProtoPaths are the proto paths that were added and still need a scan
+	NewProtoPaths []*proto.ProtoPath `json:"-"`
+}
+
+// Count returns the number of records with the given outcome
+func (r *ImportReport) Count(action ImportAction) int {
+	n := 0
+	for _, item := range r.Items {
+		if item.Action == action {
+			n++
+		}
+	}
+	return n
+}
+
+func (r *ImportReport) add(kind, name string, action ImportAction, detail string) {
+	r.Items = append(r.Items, ImportItem{Kind: kind, Name: name, Action: action, Detail: detail})
+}
+
+// ExportWorkspace builds a workspace file from the selected profiles, their
+// proto paths and per-request headers, and the saved requests that use them.
+// Unless secrets are stripped, secret values must be readable, so exporting
+// from a locked store fails with secrets.ErrLocked.
+func ExportWorkspace(ctx context.Context, store ServerProfileStore, opts ExportOptions) (*models.WorkspaceFile, error) {
+	profiles, err := store.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list profiles: %w", err)
+	}
+	if len(opts.ProfileIDs) > 0 {
+		var selected []*models.ServerProfile
+		for _, id := range opts.ProfileIDs {
+			i := slices.IndexFunc(profiles, func(p *models.ServerProfile) bool { return p.ID == id })
+			if i < 0 {
+				return nil, fmt.Errorf("%w: %s", models.ErrProfileNotFound, id)
+			}
+			selected = append(selected, profiles[i])
+		}
+		profiles = selected
+	}
+
+	exportSecret := func(value string) (string, error) {
+		if opts.StripSecrets {
+			return "", nil
+		}
+		if secrets.IsEncrypted(value) {
+			return "", secrets.ErrLocked
+		}
+		return value, nil
+	}
+
+	file := &models.WorkspaceFile{
+		Version:         models.WorkspaceFileVersion,
+		ExportedAt:      time.Now().UTC(),
+		SecretsStripped: opts.StripSecrets,
+		Profiles:        []models.WorkspaceProfile{},
+	}
+	names := make(map[string]string, len(profiles))
+	for _, p := range profiles {
+		names[p.ID] = p.Name
+		wp := models.WorkspaceProfile{
+			Name:             p.Name,
+			Host:             p.Host,
+			Port:             p.Port,
+			TLSEnabled:       p.TLSEnabled,
+			UseReflection:    p.UseReflection,
+			ConnectTimeoutMs: p.ConnectTimeoutMs,
+			TargetKind:       p.TargetKind,
+			Target:           p.Target,
+			Authority:        p.Authority,
+			Protocol:         p.Protocol,
+		}
+		if !p.Transport.IsZero() {
+			transport := *p.Transport
+			wp.Transport = &transport
+		}
+		if !p.JSONOptions.IsZero() {
+			jsonOpts := *p.JSONOptions
+			wp.JSONOptions = &jsonOpts
+		}
+		if p.CertificatePath != nil {
+			wp.CertificatePath = *p.CertificatePath
+		}
+		for _, h := range p.Headers {
+			if h.Secret {
+				if h.Value, err = exportSecret(h.Value); err != nil {
+					return nil, fmt.Errorf("profile %s: header %s: %w", p.Name, h.Key, err)
+				}
+			}
+			wp.Headers = append(wp.Headers, h)
+		}
+		if p.Auth != nil && p.Auth.Type != models.AuthNone {
+			auth := *p.Auth
+			if auth.ClientSecret, err = exportSecret(auth.ClientSecret); err != nil {
+				return nil, fmt.Errorf("profile %s: client secret: %w", p.Name, err)
+			}
+			if auth.RefreshToken, err = exportSecret(auth.RefreshToken); err != nil {
+				return nil, fmt.Errorf("profile %s: refresh token: %w", p.Name, err)
+			}
+			wp.Auth = &auth
+		}
+		if !p.Proxy.IsDirect() {
+			proxy := *p.Proxy
+			if proxy.Password, err = exportSecret(proxy.Password); err != nil {
+				return nil, fmt.Errorf("profile %s: proxy password: %w", p.Name, err)
+			}
+			wp.Proxy = &proxy
+		}
+
+		paths, err := store.ListProtoPathsByServer(ctx, p.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list proto paths of %s: %w", p.Name, err)
+		}
+		for _, pp := range paths {
+			wp.ProtoPaths = append(wp.ProtoPaths, exportProtoPath(pp.Path, opts.BaseDir))
+		}
+		slices.Sort(wp.ProtoPaths)
+
+		perRequest, err := store.ListPerRequestHeaders(ctx, p.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list per-request headers of %s: %w", p.Name, err)
+		}
+		for _, h := range perRequest {
+			headersJSON, err := transformHeaderJSON(h.HeadersJSON, h.SecretKeys, exportSecret)
+			if err != nil {
+				return nil, fmt.Errorf("profile %s: %s/%s: %w", p.Name, h.ServiceName, h.MethodName, err)
+			}
+			headers, err := rawJSON(headersJSON)
+			if err != nil {
+				return nil, fmt.Errorf("profile %s: %s/%s headers: %w", p.Name, h.ServiceName, h.MethodName, err)
+			}
+			wp.RequestHeaders = append(wp.RequestHeaders, models.WorkspaceRequestHeaders{
+				Service:    h.ServiceName,
+				Method:     h.MethodName,
+				Headers:    headers,
+				SecretKeys: h.SecretKeys,
+			})
+		}
+		file.Profiles = append(file.Profiles, wp)
+	}
+
+	collections, err := store.ListCollections(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list collections: %w", err)
+	}
+	for _, c := range collections {
+		requests, err := store.ListSavedRequests(ctx, c.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list saved requests of %s: %w", c.Name, err)
+		}
+		wc := models.WorkspaceCollection{Name: c.Name, Description: c.Description, Requests: []models.WorkspaceRequest{}}
+		for _, r := range requests {
+			profile, ok := names[r.ServerProfileID]
+			if !ok {
+				continue
+			}
+			wr := models.WorkspaceRequest{
+				Name:           r.Name,
+				Profile:        profile,
+				Service:        r.ServiceName,
+				Method:         r.MethodName,
+				RequestFormat:  r.RequestFormat,
+				ResponseFormat: r.ResponseFormat,
+				Assertions:     r.Assertions,
+				Extractions:    r.Extractions,
+			}
+			if r.RequestFormat.IsJSON() {
+				wr.Request, err = rawJSON(r.RequestJSON)
+			} else {
+				wr.Request, err = json.Marshal(r.RequestJSON)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("saved request %s: request: %w", r.Name, err)
+			}
+			headersJSON := r.HeadersJSON
+			if opts.StripSecrets {
+				// Saved requests have no secret flags, so sensitive keys are
+				// recognised by name
+				if headersJSON, err = transformHeaderJSON(headersJSON, sensitiveHeaderKeys(headersJSON), exportSecret); err != nil {
+					return nil, fmt.Errorf("saved request %s: %w", r.Name, err)
+				}
+			}
+			if wr.Headers, err = rawJSON(headersJSON); err != nil {
+				return nil, fmt.Errorf("saved request %s: headers: %w", r.Name, err)
+			}
+			wc.Requests = append(wc.Requests, wr)
+		}
+		// A partial export only carries the collections its profiles use
+		if len(wc.Requests) == 0 && len(opts.ProfileIDs) > 0 {
+			continue
+		}
+		file.Collections = append(file.Collections, wc)
+	}
+	return file, nil
+}
+
+// ImportWorkspace merges a workspace file into the store. Records are matched
+// by name: missing ones are created, identical ones are left alone and ones
+// that differ are reported as conflicts and kept unless opts.Overwrite is set.
+// Proto paths and per-request headers of a profile are merged even when the
+// profile itself conflicts. Stores that support transactions apply the whole
+// merge or, if it fails part-way, none of it.
+func ImportWorkspace(ctx context.Context, store ServerProfileStore, file *models.WorkspaceFile, opts ImportOptions) (*ImportReport, error) {
+	if file.Version < 1 || file.Version > models.WorkspaceFileVersion {
+		return nil, fmt.Errorf("%w: %d", models.ErrUnsupportedWorkspaceVersion, file.Version)
+	}
+	ts, ok := store.(transactionalStore)
+	if !ok || opts.DryRun {
+		return importWorkspace(ctx, store, file, opts)
+	}
+	var report *ImportReport
+	err := ts.InTransaction(ctx, func(tx ServerProfileStore) error {
+		var err error
+		report, err = importWorkspace(ctx, tx, file, opts)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// transactionalStore is implemented by stores that can apply several changes
+// atomically, such as SQLiteStore
+type transactionalStore interface {
+	InTransaction(ctx context.Context, fn func(store ServerProfileStore) error) error
+}
+
+// importWorkspace merges a workspace file of a supported version
+func importWorkspace(ctx context.Context, store ServerProfileStore, file *models.WorkspaceFile, opts ImportOptions) (*ImportReport, error) {
+	report := &ImportReport{DryRun: opts.DryRun}
+
+	existing, err := store.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list profiles: %w", err)
+	}
+	profileIDs := make(map[string]string, len(existing))
+	for _, p := range existing {
+		profileIDs[p.Name] = p.ID
+	}
+
+	for _, wp := range file.Profiles {
+		id, created, err := importProfile(ctx, store, file, wp, opts, report, existing)
+		if err != nil {
+			return nil, err
+		}
+		if id == "" {
+			continue
+		}
+		profileIDs[wp.Name] = id
+		if err := importProtoPaths(ctx, store, id, created, wp, opts, report); err != nil {
+			return nil, err
+		}
+		if err := importRequestHeaders(ctx, store, file, id, created, wp, opts, report); err != nil {
+			return nil, err
+		}
+	}
+
+	collections, err := store.ListCollections(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list collections: %w", err)
+	}
+	for _, wc := range file.Collections {
+		if err := importCollection(ctx, store, file, wc, profileIDs, opts, report, collections); err != nil {
+			return nil, err
+		}
+	}
+	return report, nil
+}
+
+// importProfile creates or merges one profile and returns its ID, or an
+// empty string if the profile in the file is invalid. created is true for a
+// profile that did not exist locally.
+func importProfile(ctx context.Context, store ServerProfileStore, file *models.WorkspaceFile, wp models.WorkspaceProfile, opts ImportOptions, report *ImportReport, existing []*models.ServerProfile) (id string, created bool, err error) {
+	imported := models.NewServerProfile(wp.Name, wp.Host, wp.Port)
+	imported.TLSEnabled = wp.TLSEnabled
+	imported.UseReflection = wp.UseReflection
+	imported.ConnectTimeoutMs = wp.ConnectTimeoutMs
+	imported.TargetKind = wp.TargetKind
+	imported.Target = wp.Target
+	imported.Authority = wp.Authority
+	imported.Protocol = wp.Protocol
+	if !wp.Proxy.IsDirect() {
+		proxy := *wp.Proxy
+		imported.Proxy = &proxy
+	}
+	if !wp.Transport.IsZero() {
+		transport := *wp.Transport
+		imported.Transport = &transport
+	}
+	if !wp.JSONOptions.IsZero() {
+		jsonOpts := *wp.JSONOptions
+		imported.JSONOptions = &jsonOpts
+	}
+	if wp.CertificatePath != "" {
+		certPath := wp.CertificatePath
+		imported.CertificatePath = &certPath
+	}
+	imported.Headers = slices.Clone(wp.Headers)
+	if wp.Auth != nil && wp.Auth.Type != models.AuthNone {
+		auth := *wp.Auth
+		imported.Auth = &auth
+	}
+
+	i := slices.IndexFunc(existing, func(p *models.ServerProfile) bool { return p.Name == wp.Name })
+	if i < 0 {
+		detail := ""
+		if err := imported.Validate(); err != nil {
+			if !file.SecretsStripped || !errors.Is(err, models.ErrInvalidAuthConfig) {
+				report.add("profile", wp.Name, ImportFailed, err.Error())
+				return "", false, nil
+			}
+			// The credentials the provider needs were stripped from the file
+			imported.Auth = nil
+			detail = "auth provider left out because its credentials were stripped"
+		}
+		if !opts.DryRun {
+			if err := store.Create(ctx, imported); err != nil {
+				return "", false, fmt.Errorf("failed to create profile %s: %w", wp.Name, err)
+			}
+		}
+		report.add("profile", wp.Name, ImportCreated, detail)
+		return imported.ID, true, nil
+	}
+
+	current := existing[i]
+	if file.SecretsStripped {
+		fillStrippedProfileSecrets(imported, current)
+	}
+	if sameProfileSettings(imported, current) {
+		report.add("profile", wp.Name, ImportUnchanged, "")
+		return current.ID, false, nil
+	}
+	if !opts.Overwrite {
+		report.add("profile", wp.Name, ImportConflict, "connection settings differ from the local profile")
+		return current.ID, false, nil
+	}
+	if err := imported.Validate(); err != nil {
+		report.add("profile", wp.Name, ImportFailed, err.Error())
+		return current.ID, false, nil
+	}
+	imported.ID = current.ID
+	imported.CreatedAt = current.CreatedAt
+	if !opts.DryRun {
+		if err := store.Update(ctx, imported); err != nil {
+			return "", false, fmt.Errorf("failed to update profile %s: %w", wp.Name, err)
+		}
+	}
+	report.add("profile", wp.Name, ImportUpdated, "")
+	return current.ID, false, nil
+}
+
+// importProtoPaths adds the proto paths of a profile that are missing locally
+func importProtoPaths(ctx context.Context, store ServerProfileStore, profileID string, created bool, wp models.WorkspaceProfile, opts ImportOptions, report *ImportReport) error {
+	var paths []*proto.ProtoPath
+	if !created {
+		var err error
+		if paths, err = store.ListProtoPathsByServer(ctx, profileID); err != nil {
+			return fmt.Errorf("failed to list proto paths of %s: %w", wp.Name, err)
+		}
+	}
+	for _, p := range wp.ProtoPaths {
+		path := importProtoPath(p, opts.BaseDir)
+		name := wp.Name + ": " + p
+		if slices.ContainsFunc(paths, func(pp *proto.ProtoPath) bool { return filepath.Clean(pp.Path) == path }) {
+			report.add("protoPath", name, ImportUnchanged, "")
+			continue
+		}
+		pp := &proto.ProtoPath{ID: uuid.New().String(), ServerProfileID: profileID, Path: path}
+		if !opts.DryRun {
+			if err := store.CreateProtoPath(ctx, pp); err != nil {
+				return fmt.Errorf("failed to add proto path %s: %w", path, err)
+			}
+			report.NewProtoPaths = append(report.NewProtoPaths, pp)
+		}
+		paths = append(paths, pp)
+		report.add("protoPath", name, ImportCreated, "")
+	}
+	return nil
+}
+
+// importRequestHeaders merges the per-request headers of a profile
+func importRequestHeaders(ctx context.Context, store ServerProfileStore, file *models.WorkspaceFile, profileID string, created bool, wp models.WorkspaceProfile, opts ImportOptions, report *ImportReport) error {
+	var current []*models.PerRequestHeaders
+	if !created {
+		var err error
+		if current, err = store.ListPerRequestHeaders(ctx, profileID); err != nil {
+			return fmt.Errorf("failed to list per-request headers of %s: %w", wp.Name, err)
+		}
+	}
+	for _, wh := range wp.RequestHeaders {
+		name := fmt.Sprintf("%s: %s/%s", wp.Name, wh.Service, wh.Method)
+		imported := &models.PerRequestHeaders{
+			ServerProfileID: profileID,
+			ServiceName:     wh.Service,
+			MethodName:      wh.Method,
+			HeadersJSON:     jsonText(wh.Headers),
+			SecretKeys:      wh.SecretKeys,
+		}
+		i := slices.IndexFunc(current, func(h *models.PerRequestHeaders) bool {
+			return h.ServiceName == wh.Service && h.MethodName == wh.Method
+		})
+		action := ImportCreated
+		if i >= 0 {
+			local := current[i]
+			if file.SecretsStripped {
+				filled, err := fillStrippedHeaderJSON(imported.HeadersJSON, local.HeadersJSON, imported.SecretKeys)
+				if err != nil {
+					report.add("requestHeaders", name, ImportFailed, err.Error())
+					continue
+				}
+				imported.HeadersJSON = filled
+			}
+			if jsonEqual(imported.HeadersJSON, local.HeadersJSON) && sameStrings(imported.SecretKeys, local.SecretKeys) {
+				report.add("requestHeaders", name, ImportUnchanged, "")
+				continue
+			}
+			if !opts.Overwrite {
+				report.add("requestHeaders", name, ImportConflict, "headers differ from the local ones")
+				continue
+			}
+			action = ImportUpdated
+		}
+		if !opts.DryRun {
+			if err := store.UpsertPerRequestHeaders(ctx, imported); err != nil {
+				return fmt.Errorf("failed to save headers of %s: %w", name, err)
+			}
+		}
+		report.add("requestHeaders", name, action, "")
+	}
+	return nil
+}
+
+// importCollection creates or merges a collection and its saved requests
+func importCollection(ctx context.Context, store ServerProfileStore, file *models.WorkspaceFile, wc models.WorkspaceCollection, profileIDs map[string]string, opts ImportOptions, report *ImportReport, existing []*models.Collection) error {
+	var collection *models.Collection
+	var current []*models.SavedRequest
+	if i := slices.IndexFunc(existing, func(c *models.Collection) bool { return c.Name == wc.Name }); i >= 0 {
+		collection = existing[i]
+		var err error
+		if current, err = store.ListSavedRequests(ctx, collection.ID); err != nil {
+			return fmt.Errorf("failed to list saved requests of %s: %w", wc.Name, err)
+		}
+		switch {
+		case collection.Description == wc.Description:
+			report.add("collection", wc.Name, ImportUnchanged, "")
+		case !opts.Overwrite:
+			report.add("collection", wc.Name, ImportConflict, "description differs from the local collection")
+		default:
+			collection.Description = wc.Description
+			collection.UpdatedAt = time.Now()
+			if !opts.DryRun {
+				if err := store.UpdateCollection(ctx, collection); err != nil {
+					return fmt.Errorf("failed to update collection %s: %w", wc.Name, err)
+				}
+			}
+			report.add("collection", wc.Name, ImportUpdated, "")
+		}
+	} else {
+		collection = models.NewCollection(wc.Name, wc.Description)
+		if err := collection.Validate(); err != nil {
+			report.add("collection", wc.Name, ImportFailed, err.Error())
+			return nil
+		}
+		if !opts.DryRun {
+			if err := store.CreateCollection(ctx, collection); err != nil {
+				return fmt.Errorf("failed to create collection %s: %w", wc.Name, err)
+			}
+		}
+		report.add("collection", wc.Name, ImportCreated, "")
+	}
+
+	for position, wr := range wc.Requests {
+		name := wc.Name + ": " + wr.Name
+		profileID, ok := profileIDs[wr.Profile]
+		if !ok {
+			report.add("request", name, ImportFailed, fmt.Sprintf("unknown profile %q", wr.Profile))
+			continue
+		}
+		imported := models.NewSavedRequest(collection.ID, profileID, wr.Name, wr.Service, wr.Method)
+		imported.RequestFormat, imported.ResponseFormat = wr.RequestFormat, wr.ResponseFormat
+		if wr.RequestFormat.IsJSON() {
+			if text := jsonText(wr.Request); text != "" {
+				imported.RequestJSON = text
+			}
+		} else if err := json.Unmarshal(wr.Request, &imported.RequestJSON); err != nil {
+			report.add("request", name, ImportFailed, fmt.Sprintf("a %s request must be a string", wr.RequestFormat))
+			continue
+		}
+		imported.HeadersJSON = jsonText(wr.Headers)
+		imported.Position = position
+		imported.Assertions = wr.Assertions
+		imported.Extractions = wr.Extractions
+		if err := imported.Validate(); err != nil {
+			report.add("request", name, ImportFailed, err.Error())
+			continue
+		}
+
+		i := slices.IndexFunc(current, func(r *models.SavedRequest) bool { return r.Name == wr.Name })
+		if i < 0 {
+			if !opts.DryRun {
+				if err := store.CreateSavedRequest(ctx, imported); err != nil {
+					return fmt.Errorf("failed to create saved request %s: %w", name, err)
+				}
+			}
+			report.add("request", name, ImportCreated, "")
+			continue
+		}
+		local := current[i]
+		if file.SecretsStripped {
+			filled, err := fillStrippedHeaderJSON(imported.HeadersJSON, local.HeadersJSON, sensitiveHeaderKeys(imported.HeadersJSON))
+			if err != nil {
+				report.add("request", name, ImportFailed, err.Error())
+				continue
+			}
+			imported.HeadersJSON = filled
+		}
+		if sameSavedRequest(imported, local) {
+			report.add("request", name, ImportUnchanged, "")
+			continue
+		}
+		if !opts.Overwrite {
+			report.add("request", name, ImportConflict, "differs from the local saved request")
+			continue
+		}
+		imported.ID = local.ID
+		imported.CreatedAt = local.CreatedAt
+		if !opts.DryRun {
+			if err := store.UpdateSavedRequest(ctx, imported); err != nil {
+				return fmt.Errorf("failed to update saved request %s: %w", name, err)
+			}
+		}
+		report.add("request", name, ImportUpdated, "")
+	}
+	return nil
+}
+
+// fillStrippedProfileSecrets copies local secret values into the secrets a
+// stripped workspace file left empty, so that they are kept on import
+func fillStrippedProfileSecrets(imported, local *models.ServerProfile) {
+	for i, h := range imported.Headers {
+		if !h.Secret || h.Value != "" {
+			continue
+		}
+		for _, l := range local.Headers {
+			if l.Key == h.Key {
+				imported.Headers[i].Value = l.Value
+				break
+			}
+		}
+	}
+	if imported.Auth != nil && local.Auth != nil && imported.Auth.Type == local.Auth.Type {
+		if imported.Auth.ClientSecret == "" {
+			imported.Auth.ClientSecret = local.Auth.ClientSecret
+		}
+		if imported.Auth.RefreshToken == "" {
+			imported.Auth.RefreshToken = local.Auth.RefreshToken
+		}
+	}
+	if !imported.Proxy.IsDirect() && !local.Proxy.IsDirect() && imported.Proxy.Password == "" && imported.Proxy.Username == local.Proxy.Username {
+		imported.Proxy.Password = local.Proxy.Password
+	}
+}
+
+// fillStrippedHeaderJSON fills empty secret values of a headers JSON document
+// with the values of the local document
+func fillStrippedHeaderJSON(importedJSON, localJSON string, keys []string) (string, error) {
+	for _, key := range keys {
+		var local string
+		if _, err := transformHeaderJSON(localJSON, []string{key}, func(v string) (string, error) {
+			local = v
+			return v, nil
+		}); err != nil {
+			return "", err
+		}
+		filled, err := transformHeaderJSON(importedJSON, []string{key}, func(v string) (string, error) {
+			if v == "" {
+				return local, nil
+			}
+			return v, nil
+		})
+		if err != nil {
+			return "", err
+		}
+		importedJSON = filled
+	}
+	return importedJSON, nil
+}
+
+// sensitiveHeaderKeys returns the keys of a headers JSON document whose
+// values are redacted in logs, such as authorization and api keys
+func sensitiveHeaderKeys(headersJSON string) []string {
+	var headers map[string]string
+	if err := json.Unmarshal([]byte(headersJSON), &headers); err != nil {
+		return nil
+	}
+	var keys []string
+	for key := range headers {
+		if logging.IsSensitive(key) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// sameProfileSettings compares the connection settings of two profiles
+func sameProfileSettings(a, b *models.ServerProfile) bool {
+	certA, certB := "", ""
+	if a.CertificatePath != nil {
+		certA = *a.CertificatePath
+	}
+	if b.CertificatePath != nil {
+		certB = *b.CertificatePath
+	}
+	authA, authB := a.Auth, b.Auth
+	if authA != nil && authA.Type == models.AuthNone {
+		authA = nil
+	}
+	if authB != nil && authB.Type == models.AuthNone {
+		authB = nil
+	}
+	return a.Host == b.Host &&
+		a.Port == b.Port &&
+		a.TLSEnabled == b.TLSEnabled &&
+		certA == certB &&
+		a.UseReflection == b.UseReflection &&
+		a.ConnectTimeoutMs == b.ConnectTimeoutMs &&
+		a.DialTarget() == b.DialTarget() &&
+		a.Authority == b.Authority &&
+		(a.Protocol == b.Protocol || a.IsNativeGRPC() && b.IsNativeGRPC()) &&
+		sameProxy(a.Proxy, b.Proxy) &&
+		sameTransport(a.Transport, b.Transport) &&
+		sameJSONOptions(a.JSONOptions, b.JSONOptions) &&
+		(len(a.Headers) == 0 && len(b.Headers) == 0 || reflect.DeepEqual(a.Headers, b.Headers)) &&
+		reflect.DeepEqual(authA, authB)
+}
+
+// sameProxy compares proxy configs, treating nil as a direct connection
+func sameProxy(a, b *models.ProxyConfig) bool {
+	if a.IsDirect() || b.IsDirect() {
+		return a.IsDirect() && b.IsDirect()
+	}
+	return *a == *b
+}
+
+// sameTransport compares transport settings, treating nil as the defaults
+func sameTransport(a, b *models.TransportSettings) bool {
+	if a.IsZero() || b.IsZero() {
+		return a.IsZero() && b.IsZero()
+	}
+	return *a == *b
+}
+
+// sameJSONOptions compares JSON options, treating nil as the defaults
+func sameJSONOptions(a, b *models.JSONOptions) bool {
+	if a.IsZero() || b.IsZero() {
+		return a.IsZero() && b.IsZero()
+	}
+	return *a == *b
+}
+
+// sameSavedRequest compares what two saved requests send and check, ignoring
+// their position in the collection
+func sameSavedRequest(a, b *models.SavedRequest) bool {
+	return a.ServerProfileID == b.ServerProfileID &&
+		a.ServiceName == b.ServiceName &&
+		a.MethodName == b.MethodName &&
+		sameFormat(a.RequestFormat, b.RequestFormat) &&
+		sameFormat(a.ResponseFormat, b.ResponseFormat) &&
+		jsonEqual(a.RequestJSON, b.RequestJSON) &&
+		jsonEqual(a.HeadersJSON, b.HeadersJSON) &&
+		(len(a.Assertions) == 0 && len(b.Assertions) == 0 || reflect.DeepEqual(a.Assertions, b.Assertions)) &&
+		(len(a.Extractions) == 0 && len(b.Extractions) == 0 || reflect.DeepEqual(a.Extractions, b.Extractions))
+}
+
+// sameFormat compares message formats, treating an empty format as JSON
+func sameFormat(a, b models.MessageFormat) bool {
+	return a == b || a.IsJSON() && b.IsJSON()
+}
+
+// sameStrings compares two string sets
+func sameStrings(a, b []string) bool {
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(a, b)
+}
+
+// jsonEqual compares two JSON documents ignoring formatting. Documents that
+// cannot be parsed are compared as text.
+func jsonEqual(a, b string) bool {
+	if strings.TrimSpace(a) == strings.TrimSpace(b) {
+		return true
+	}
+	var va, vb any
+	if json.Unmarshal([]byte(a), &va) != nil || json.Unmarshal([]byte(b), &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+// rawJSON embeds a stored JSON document in a workspace file
+func rawJSON(text string) (json.RawMessage, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, nil
+	}
+	if !json.Valid([]byte(text)) {
+		return nil, errors.New("invalid JSON")
+	}
+	return json.RawMessage(text), nil
+}
+
+// jsonText turns a JSON document from a workspace file back into stored text
+func jsonText(raw json.RawMessage) string {
+	if len(raw) == 0 || string(raw) == "null" {
+		return ""
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, raw); err != nil {
+		return string(raw)
+	}
+	return buf.String()
+}
+
+// exportProtoPath makes a proto path relative to baseDir when it lies inside it
+func exportProtoPath(path, baseDir string) string {
+	if baseDir == "" {
+		return path
+	}
+	rel, err := filepath.Rel(baseDir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
+
+// importProtoPath resolves a proto path from a workspace file against baseDir
+func importProtoPath(path, baseDir string) string {
+	path = filepath.FromSlash(path)
+	if !filepath.IsAbs(path) && baseDir != "" {
+		path = filepath.Join(baseDir, path)
+	}
+	return filepath.Clean(path)
+}
+
+// MarshalWorkspaceFile encodes a workspace file as YAML, or as indented JSON
+// when asYAML is false
+func MarshalWorkspaceFile(file *models.WorkspaceFile, asYAML bool) ([]byte, error) {
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if asYAML {
+		return yaml.JSONToYAML(data)
+	}
+	return append(data, '\n'), nil
+}
+
+// UnmarshalWorkspaceFile decodes a JSON or YAML workspace file
+func UnmarshalWorkspaceFile(data []byte) (*models.WorkspaceFile, error) {
+	var file models.WorkspaceFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid workspace file: %w", err)
+	}
+	return &file, nil
+}
+
+// WriteWorkspaceFile writes a workspace file, as YAML if the path ends in
+// .yaml or .yml and as JSON otherwise
+func WriteWorkspaceFile(path string, file *models.WorkspaceFile) error {
+	data, err := MarshalWorkspaceFile(file, isYAMLPath(path))
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// ReadWorkspaceFile reads a JSON or YAML workspace file
+func ReadWorkspaceFile(path string) (*models.WorkspaceFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return UnmarshalWorkspaceFile(data)
+}
+
+func isYAMLPath(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".yaml" || ext == ".yml"
+}
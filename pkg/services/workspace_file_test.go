@@ -0,0 +1,236 @@
+package services
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"protodesk/pkg/models"
+	"protodesk/pkg/models/proto"
+	"protodesk/pkg/secrets"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newWorkspaceFixture fills a store with a profile, its proto path and
+// per-request headers, and a collection using it
+func newWorkspaceFixture(t *testing.T, repoDir string) (*SQLiteStore, *models.ServerProfile, func()) {
+	store, cleanup := setupTestStore(t)
+	ctx := context.Background()
+	require.NoError(t, store.UnlockSecrets(ctx, "passphrase"))
+
+	profile := models.NewServerProfile("staging", "staging.example.com", 443)
+	profile.TLSEnabled = true
+	profile.Headers = []models.Header{
+		{Key: "authorization", Value: "Bearer s3cret", Secret: true},
+		{Key: "x-tenant", Value: "acme"},
+	}
+	profile.Auth = &models.AuthConfig{
+		Type:         models.AuthOAuth2ClientCredentials,
+		TokenURL:     "https://auth.example.com/token",
+		ClientID:     "client",
+		ClientSecret: "client-secret",
+	}
+	require.NoError(t, store.Create(ctx, profile))
+	require.NoError(t, store.CreateProtoPath(ctx, &proto.ProtoPath{
+		ID:              "pp1",
+		ServerProfileID: profile.ID,
+		Path:            filepath.Join(repoDir, "protos"),
+	}))
+	require.NoError(t, store.UpsertPerRequestHeaders(ctx, &models.PerRequestHeaders{
+		ServerProfileID: profile.ID,
+		ServiceName:     "test.Users",
+		MethodName:      "Get",
+		HeadersJSON:     `{"x-api-key":"k-123","x-trace":"on"}`,
+		SecretKeys:      []string{"x-api-key"},
+	}))
+
+	collection := models.NewCollection("smoke", "smoke checks")
+	require.NoError(t, store.CreateCollection(ctx, collection))
+	get := models.NewSavedRequest(collection.ID, profile.ID, "get user", "test.Users", "Get")
+	get.RequestJSON = `{"id": 1}`
+	get.HeadersJSON = `{"authorization":"Bearer saved-token","x-trace":"on"}`
+	get.Assertions = []models.Assertion{{Type: models.AssertionStatus, Expected: "OK"}}
+	list := models.NewSavedRequest(collection.ID, profile.ID, "list users", "test.Users", "List")
+	list.Position = 1
+	list.RequestJSON = "page_size: 10\n"
+	list.RequestFormat, list.ResponseFormat = models.FormatPrototext, models.FormatYAML
+	list.Extractions = []models.ExtractionRule{{Source: models.ExtractFromJSONPath, Expression: "$.next", Variable: "page"}}
+	require.NoError(t, store.CreateSavedRequest(ctx, get))
+	require.NoError(t, store.CreateSavedRequest(ctx, list))
+	return store, profile, cleanup
+}
+
+func TestWorkspaceFile_ExportImportRoundTrip(t *testing.T) {
+	repoDir := t.TempDir()
+	source, profile, cleanup := newWorkspaceFixture(t, repoDir)
+	defer cleanup()
+	ctx := context.Background()
+
+	file, err := ExportWorkspace(ctx, source, ExportOptions{ProfileIDs: []string{profile.ID}, BaseDir: repoDir})
+	require.NoError(t, err)
+	require.Len(t, file.Profiles, 1)
+	assert.Equal(t, []string{"protos"}, file.Profiles[0].ProtoPaths, "paths inside the base dir are relative")
+	require.Len(t, file.Collections, 1)
+	assert.Len(t, file.Collections[0].Requests, 2)
+
+	// The file survives a trip through YAML
+	data, err := MarshalWorkspaceFile(file, true)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "host: staging.example.com")
+	decoded, err := UnmarshalWorkspaceFile(data)
+	require.NoError(t, err)
+
+	target, cleanupTarget := setupTestStore(t)
+	defer cleanupTarget()
+	require.NoError(t, target.UnlockSecrets(ctx, "other passphrase"))
+	checkoutDir := t.TempDir()
+
+	report, err := ImportWorkspace(ctx, target, decoded, ImportOptions{BaseDir: checkoutDir})
+	require.NoError(t, err)
+	assert.Equal(t, 6, report.Count(ImportCreated), report.Items)
+	require.Len(t, report.NewProtoPaths, 1)
+	assert.Equal(t, filepath.Join(checkoutDir, "protos"), report.NewProtoPaths[0].Path)
+
+	profiles, err := target.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, profiles, 1)
+	imported := profiles[0]
+	assert.Equal(t, "staging.example.com", imported.Host)
+	assert.Equal(t, profile.Headers, imported.Headers)
+	assert.Equal(t, "client-secret", imported.Auth.ClientSecret)
+
+	headers, err := target.GetPerRequestHeaders(ctx, imported.ID, "test.Users", "Get")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"x-api-key":"k-123","x-trace":"on"}`, headers.HeadersJSON)
+	assert.Equal(t, []string{"x-api-key"}, headers.SecretKeys)
+
+	collections, err := target.ListCollections(ctx)
+	require.NoError(t, err)
+	require.Len(t, collections, 1)
+	requests, err := target.ListSavedRequests(ctx, collections[0].ID)
+	require.NoError(t, err)
+	require.Len(t, requests, 2)
+	assert.Equal(t, "get user", requests[0].Name)
+	assert.Equal(t, imported.ID, requests[0].ServerProfileID)
+	assert.Equal(t, `{"id":1}`, requests[0].RequestJSON)
+	assert.Equal(t, "page", requests[1].Extractions[0].Variable)
+	assert.Equal(t, "page_size: 10\n", requests[1].RequestJSON)
+	assert.Equal(t, models.FormatPrototext, requests[1].RequestFormat)
+	assert.Equal(t, models.FormatYAML, requests[1].ResponseFormat)
+
+	// Importing the same file again changes nothing
+	report, err = ImportWorkspace(ctx, target, decoded, ImportOptions{BaseDir: checkoutDir})
+	require.NoError(t, err)
+	assert.Equal(t, len(report.Items), report.Count(ImportUnchanged), report.Items)
+}
+
+func TestWorkspaceFile_ImportConflicts(t *testing.T) {
+	store, profile, cleanup := newWorkspaceFixture(t, t.TempDir())
+	defer cleanup()
+	ctx := context.Background()
+
+	file, err := ExportWorkspace(ctx, store, ExportOptions{})
+	require.NoError(t, err)
+	file.Profiles[0].Port = 8443
+	file.Collections[0].Requests[0].Request = []byte(`{"id":2}`)
+	file.Collections[0].Requests = append(file.Collections[0].Requests, models.WorkspaceRequest{
+		Name: "orphan", Profile: "missing", Service: "test.Users", Method: "Get",
+	})
+
+	// A dry run reports conflicts without touching the store
+	report, err := ImportWorkspace(ctx, store, file, ImportOptions{DryRun: true})
+	require.NoError(t, err)
+	assert.True(t, report.DryRun)
+	assert.Equal(t, 2, report.Count(ImportConflict), report.Items)
+	assert.Equal(t, 1, report.Count(ImportFailed), report.Items)
+
+	report, err = ImportWorkspace(ctx, store, file, ImportOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Count(ImportConflict))
+	kept, err := store.Get(ctx, profile.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 443, kept.Port)
+
+	report, err = ImportWorkspace(ctx, store, file, ImportOptions{Overwrite: true})
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Count(ImportUpdated), report.Items)
+	updated, err := store.Get(ctx, profile.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 8443, updated.Port)
+	assert.Equal(t, profile.CreatedAt.Unix(), updated.CreatedAt.Unix())
+}
+
+func TestWorkspaceFile_StripSecrets(t *testing.T) {
+	store, profile, cleanup := newWorkspaceFixture(t, t.TempDir())
+	defer cleanup()
+	ctx := context.Background()
+
+	file, err := ExportWorkspace(ctx, store, ExportOptions{StripSecrets: true})
+	require.NoError(t, err)
+	data, err := MarshalWorkspaceFile(file, false)
+	require.NoError(t, err)
+	for _, secret := range []string{"s3cret", "client-secret", "k-123", "saved-token"} {
+		assert.NotContains(t, string(data), secret)
+	}
+	assert.Contains(t, string(data), "acme")
+
+	// Stripped values keep the local secrets instead of conflicting
+	report, err := ImportWorkspace(ctx, store, file, ImportOptions{Overwrite: true})
+	require.NoError(t, err)
+	assert.Equal(t, len(report.Items), report.Count(ImportUnchanged), report.Items)
+	kept, err := store.Get(ctx, profile.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer s3cret", kept.Headers[0].Value)
+	collections, err := store.ListCollections(ctx)
+	require.NoError(t, err)
+	requests, err := store.ListSavedRequests(ctx, collections[0].ID)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"authorization":"Bearer saved-token","x-trace":"on"}`, requests[0].HeadersJSON)
+
+	// Without stripping, a locked store cannot export its secrets
+	store.LockSecrets()
+	_, err = ExportWorkspace(ctx, store, ExportOptions{})
+	assert.ErrorIs(t, err, secrets.ErrLocked)
+}
+
+func TestWorkspaceFile_ImportRollsBackOnFailure(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	// The secret header of the second profile cannot be saved while secret
+	// storage is locked, after the first profile was already created
+	file := &models.WorkspaceFile{
+		Version: models.WorkspaceFileVersion,
+		Profiles: []models.WorkspaceProfile{
+			{Name: "local", Host: "localhost", Port: 50051, ProtoPaths: []string{"/protos"}},
+			{Name: "staging", Host: "staging.example.com", Port: 443, Headers: []models.Header{
+				{Key: "authorization", Value: "Bearer s3cret", Secret: true},
+			}},
+		},
+	}
+	_, err := ImportWorkspace(ctx, store, file, ImportOptions{})
+	assert.ErrorIs(t, err, secrets.ErrLocked)
+
+	profiles, err := store.List(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, profiles, "a failed import leaves nothing behind")
+
+	// Once unlocked, the same file imports in full
+	require.NoError(t, store.UnlockSecrets(ctx, "passphrase"))
+	report, err := ImportWorkspace(ctx, store, file, ImportOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 3, report.Count(ImportCreated))
+}
+
+func TestWorkspaceFile_RejectsNewerVersion(t *testing.T) {
+	store, cleanup := setupTestStore(t)
+	defer cleanup()
+
+	file, err := UnmarshalWorkspaceFile([]byte(`{"version": 99, "profiles": []}`))
+	require.NoError(t, err)
+	_, err = ImportWorkspace(context.Background(), store, file, ImportOptions{})
+	assert.ErrorIs(t, err, models.ErrUnsupportedWorkspaceVersion)
+}
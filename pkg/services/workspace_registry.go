@@ -0,0 +1,206 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"protodesk/pkg/models"
+)
+
+// workspaceRegistryFileName is the file in the data directory listing the
+// named workspaces
+const workspaceRegistryFileName = "workspaces.json"
+
+// unsafeFileNameChars matches the characters replaced when a workspace name
+// is turned into a database file name
+var unsafeFileNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// WorkspaceRegistry keeps track of the named workspaces and which one is
+// active. It is persisted as workspaces.json in the data directory, next to
+// the database of the default workspace.
+type WorkspaceRegistry struct {
+	dataDir string
+
+	mu         sync.Mutex
+	active     string
+	workspaces []models.Workspace
+}
+
+// workspaceRegistryState is the on-disk form of a WorkspaceRegistry
+type workspaceRegistryState struct {
+	Active     string             `json:"active,omitempty"`
+	Workspaces []models.Workspace `json:"workspaces"`
+}
+
+// LoadWorkspaceRegistry reads the workspace registry of a data directory. A
+// missing registry holds only the default workspace.
+func LoadWorkspaceRegistry(dataDir string) (*WorkspaceRegistry, error) {
+	r := &WorkspaceRegistry{dataDir: dataDir}
+	data, err := os.ReadFile(filepath.Join(dataDir, workspaceRegistryFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read workspace registry: %w", err)
+	}
+	var state workspaceRegistryState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("invalid workspace registry: %w", err)
+	}
+	r.active = state.Active
+	r.workspaces = state.Workspaces
+	return r, nil
+}
+
+// defaultWorkspace returns the workspace backed by the data directory itself
+func (r *WorkspaceRegistry) defaultWorkspace() models.Workspace {
+	return models.Workspace{
+		Name: models.DefaultWorkspaceName,
+		Path: filepath.Join(r.dataDir, DatabaseFileName),
+	}
+}
+
+// List returns the default workspace followed by the named ones, by name
+func (r *WorkspaceRegistry) List() []models.Workspace {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	list := append([]models.Workspace{r.defaultWorkspace()}, r.workspaces...)
+	slices.SortStableFunc(list[1:], func(a, b models.Workspace) int { return strings.Compare(a.Name, b.Name) })
+	return list
+}
+
+// Get returns a workspace by name
+func (r *WorkspaceRegistry) Get(name string) (models.Workspace, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.get(name)
+}
+
+func (r *WorkspaceRegistry) get(name string) (models.Workspace, error) {
+	if name == models.DefaultWorkspaceName {
+		return r.defaultWorkspace(), nil
+	}
+	for _, ws := range r.workspaces {
+		if ws.Name == name {
+			return ws, nil
+		}
+	}
+	return models.Workspace{}, fmt.Errorf("%w: %s", models.ErrWorkspaceNotFound, name)
+}
+
+// DefaultPath returns where the database of a new workspace is kept when no
+// location is chosen: workspaces/<name>.db in the data directory
+func (r *WorkspaceRegistry) DefaultPath(name string) string {
+	fileName := strings.Trim(unsafeFileNameChars.ReplaceAllString(name, "_"), "_.")
+	if fileName == "" {
+		fileName = "workspace"
+	}
+	return filepath.Join(r.dataDir, "workspaces", fileName+".db")
+}
+
+// Add registers a workspace, creating the directory of its database
+func (r *WorkspaceRegistry) Add(ws models.Workspace) error {
+	if err := ws.Validate(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, err := r.get(ws.Name); err == nil {
+		return fmt.Errorf("%w: %s", models.ErrWorkspaceExists, ws.Name)
+	}
+	if err := os.MkdirAll(filepath.Dir(ws.Path), 0755); err != nil {
+		return fmt.Errorf("failed to create workspace directory: %w", err)
+	}
+	r.workspaces = append(r.workspaces, ws)
+	return r.save()
+}
+
+// Remove forgets a workspace. Its database file is left on disk.
+func (r *WorkspaceRegistry) Remove(name string) error {
+	if name == models.DefaultWorkspaceName {
+		return fmt.Errorf("the %s workspace cannot be removed", models.DefaultWorkspaceName)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := slices.IndexFunc(r.workspaces, func(ws models.Workspace) bool { return ws.Name == name })
+	if i < 0 {
+		return fmt.Errorf("%w: %s", models.ErrWorkspaceNotFound, name)
+	}
+	r.workspaces = slices.Delete(r.workspaces, i, i+1)
+	if r.active == name {
+		r.active = ""
+	}
+	return r.save()
+}
+
+// Active returns the workspace opened when no other one is requested
+func (r *WorkspaceRegistry) Active() models.Workspace {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if ws, err := r.get(r.active); err == nil {
+		return ws
+	}
+	return r.defaultWorkspace()
+}
+
+// SetActive makes a registered workspace the one opened on the next start
+func (r *WorkspaceRegistry) SetActive(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, err := r.get(name); err != nil {
+		return err
+	}
+	if name == models.DefaultWorkspaceName {
+		name = ""
+	}
+	r.active = name
+	return r.save()
+}
+
+// Resolve turns a workspace reference into a workspace. An empty reference
+// is the active workspace; otherwise it is a registered name or the path of
+// a database file, or of a directory holding protodesk.db, which need not be
+// registered.
+func (r *WorkspaceRegistry) Resolve(ref string) (models.Workspace, error) {
+	if ref == "" {
+		return r.Active(), nil
+	}
+	if ws, err := r.Get(ref); err == nil {
+		return ws, nil
+	}
+	if !filepath.IsAbs(ref) && !strings.ContainsRune(ref, filepath.Separator) && filepath.Ext(ref) != ".db" {
+		return models.Workspace{}, fmt.Errorf("%w: %s", models.ErrWorkspaceNotFound, ref)
+	}
+	path, err := filepath.Abs(ref)
+	if err != nil {
+		return models.Workspace{}, err
+	}
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		path = filepath.Join(path, DatabaseFileName)
+	}
+	return models.Workspace{Name: ref, Path: path}, nil
+}
+
+// save writes the registry, replacing the previous file atomically
+func (r *WorkspaceRegistry) save() error {
+	data, err := json.MarshalIndent(workspaceRegistryState{Active: r.active, Workspaces: r.workspaces}, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(r.dataDir, workspaceRegistryFileName)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write workspace registry: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write workspace registry: %w", err)
+	}
+	return nil
+}
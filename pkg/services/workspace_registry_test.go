@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"protodesk/pkg/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkspaceRegistry(t *testing.T) {
+	dataDir := t.TempDir()
+	registry, err := LoadWorkspaceRegistry(dataDir)
+	require.NoError(t, err)
+
+	defaultWS := models.Workspace{Name: models.DefaultWorkspaceName, Path: filepath.Join(dataDir, DatabaseFileName)}
+	assert.Equal(t, []models.Workspace{defaultWS}, registry.List())
+	assert.Equal(t, defaultWS, registry.Active())
+
+	clientB := models.Workspace{Name: "client b", Path: registry.DefaultPath("client b")}
+	assert.Equal(t, filepath.Join(dataDir, "workspaces", "client_b.db"), clientB.Path)
+	clientA := models.Workspace{Name: "client-a", Path: filepath.Join(t.TempDir(), "nested", "a.db")}
+	require.NoError(t, registry.Add(clientB))
+	require.NoError(t, registry.Add(clientA))
+	assert.DirExists(t, filepath.Dir(clientA.Path))
+
+	assert.ErrorIs(t, registry.Add(clientA), models.ErrWorkspaceExists)
+	assert.ErrorIs(t, registry.Add(models.Workspace{Name: models.DefaultWorkspaceName, Path: clientA.Path}), models.ErrWorkspaceExists)
+	assert.ErrorIs(t, registry.Add(models.Workspace{Name: "relative", Path: "a.db"}), models.ErrRelativeWorkspacePath)
+	assert.ErrorIs(t, registry.SetActive("missing"), models.ErrWorkspaceNotFound)
+
+	require.NoError(t, registry.SetActive("client-a"))
+
+	// The registry survives a reload
+	reloaded, err := LoadWorkspaceRegistry(dataDir)
+	require.NoError(t, err)
+	assert.Equal(t, []models.Workspace{defaultWS, clientB, clientA}, reloaded.List())
+	assert.Equal(t, clientA, reloaded.Active())
+
+	// Removing the active workspace falls back to the default one
+	require.NoError(t, reloaded.Remove("client-a"))
+	assert.Equal(t, defaultWS, reloaded.Active())
+	assert.Error(t, reloaded.Remove(models.DefaultWorkspaceName))
+	assert.ErrorIs(t, reloaded.Remove("client-a"), models.ErrWorkspaceNotFound)
+}
+
+func TestWorkspaceRegistry_Resolve(t *testing.T) {
+	dataDir := t.TempDir()
+	registry, err := LoadWorkspaceRegistry(dataDir)
+	require.NoError(t, err)
+	clientA := models.Workspace{Name: "client-a", Path: registry.DefaultPath("client-a")}
+	require.NoError(t, registry.Add(clientA))
+
+	ws, err := registry.Resolve("")
+	require.NoError(t, err)
+	assert.Equal(t, models.DefaultWorkspaceName, ws.Name)
+
+	ws, err = registry.Resolve("client-a")
+	require.NoError(t, err)
+	assert.Equal(t, clientA, ws)
+
+	// Unregistered database files and directories can be opened by path
+	dir := t.TempDir()
+	ws, err = registry.Resolve(dir)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, DatabaseFileName), ws.Path)
+	ws, err = registry.Resolve(filepath.Join(dir, "other.db"))
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "other.db"), ws.Path)
+
+	_, err = registry.Resolve("unknown")
+	assert.ErrorIs(t, err, models.ErrWorkspaceNotFound)
+}
+
+func TestWorkspaces_AreIsolated(t *testing.T) {
+	dataDir := t.TempDir()
+	registry, err := LoadWorkspaceRegistry(dataDir)
+	require.NoError(t, err)
+	require.NoError(t, registry.Add(models.Workspace{Name: "client-a", Path: registry.DefaultPath("client-a")}))
+	ctx := context.Background()
+
+	open := func(name string) *SQLiteStore {
+		ws, err := registry.Get(name)
+		require.NoError(t, err)
+		store, err := OpenSQLiteStore(ws.Path)
+		require.NoError(t, err)
+		return store
+	}
+
+	defaultStore := open(models.DefaultWorkspaceName)
+	require.NoError(t, defaultStore.Create(ctx, models.NewServerProfile("local", "localhost", 50051)))
+	require.NoError(t, defaultStore.Close())
+
+	clientStore := open("client-a")
+	defer clientStore.Close()
+	profiles, err := clientStore.List(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, profiles)
+
+	_, err = os.Stat(filepath.Join(dataDir, "workspaces", "client-a.db"))
+	assert.NoError(t, err)
+}